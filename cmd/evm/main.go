@@ -124,6 +124,10 @@ var (
 		Usage: "External EVM configuration (default = built-in interpreter)",
 		Value: "",
 	}
+	FirehoseFlag = cli.BoolFlag{
+		Name:  "firehose",
+		Usage: "print the Firehose instrumentation of the execution to stdout",
+	}
 )
 
 func init() {
@@ -150,6 +154,7 @@ func init() {
 		DisableMemoryFlag,
 		DisableStackFlag,
 		EVMInterpreterFlag,
+		FirehoseFlag,
 	}
 	app.Commands = []cli.Command{
 		compileCommand,
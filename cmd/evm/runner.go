@@ -34,6 +34,7 @@ import (
 	"github.com/ethereum/go-ethereum/core"
 	"github.com/ethereum/go-ethereum/core/rawdb"
 	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/core/vm"
 	"github.com/ethereum/go-ethereum/core/vm/runtime"
 	"github.com/ethereum/go-ethereum/firehose"
@@ -98,6 +99,39 @@ func timedExec(bench bool, execFunc func() ([]byte, uint64, error)) ([]byte, uin
 	return output, gasLeft, execTime, err
 }
 
+// firehoseExec wraps `execFunc` so that each execution is recorded through `firehoseContext`
+// as the single transaction `tx` of a block built from the runtime configuration.
+func firehoseExec(firehoseContext *firehose.Context, cfg *runtime.Config, tx *types.Transaction, execFunc func() ([]byte, uint64, error)) func() ([]byte, uint64, error) {
+	return func() ([]byte, uint64, error) {
+		block := types.NewBlockWithHeader(&types.Header{
+			Number:     cfg.BlockNumber,
+			Time:       cfg.Time.Uint64(),
+			Difficulty: cfg.Difficulty,
+			Coinbase:   cfg.Coinbase,
+			GasLimit:   cfg.GasLimit,
+		})
+
+		firehoseContext.StartBlock(block)
+		firehoseContext.StartTransaction(tx, 0, nil)
+		firehoseContext.RecordTrxFrom(cfg.Origin, nil)
+
+		output, gasLeft, err := execFunc()
+
+		receipt := &types.Receipt{Status: types.ReceiptStatusSuccessful, GasUsed: cfg.GasLimit - gasLeft, Logs: cfg.State.Logs()}
+		if err != nil {
+			receipt.Status = types.ReceiptStatusFailed
+		}
+		receipt.CumulativeGasUsed = receipt.GasUsed
+		receipt.Bloom = types.CreateBloom(types.Receipts{receipt})
+
+		firehoseContext.EndTransaction(receipt)
+		firehoseContext.FinalizeBlock(block)
+		firehoseContext.EndBlock(block, block.Difficulty())
+
+		return output, gasLeft, err
+	}
+}
+
 func runCmd(ctx *cli.Context) error {
 	glogger := log.NewGlogHandler(log.StreamHandler(os.Stderr, log.TerminalFormat(false)))
 	glogger.Verbosity(log.Lvl(ctx.GlobalInt(VerbosityFlag.Name)))
@@ -189,6 +223,14 @@ func runCmd(ctx *cli.Context) error {
 		}
 		code = common.Hex2Bytes(bin)
 	}
+	var firehoseContext *firehose.Context
+	if ctx.GlobalBool(FirehoseFlag.Name) {
+		if ctx.GlobalBool(BenchFlag.Name) {
+			utils.Fatalf("The --%s and --%s flags are mutually exclusive", FirehoseFlag.Name, BenchFlag.Name)
+		}
+		firehoseContext = firehose.NewContext(firehose.NewDelegateToWriterPrinter(os.Stdout))
+	}
+
 	initialGas := ctx.GlobalUint64(GasFlag.Name)
 	if genesisConfig.GasLimit != 0 {
 		initialGas = genesisConfig.GasLimit
@@ -208,6 +250,7 @@ func runCmd(ctx *cli.Context) error {
 			Debug:          ctx.GlobalBool(DebugFlag.Name) || ctx.GlobalBool(MachineFlag.Name),
 			EVMInterpreter: ctx.GlobalString(EVMInterpreterFlag.Name),
 		},
+		Firehose: firehoseContext,
 	}
 
 	if cpuProfilePath := ctx.GlobalString(CPUProfileFlag.Name); cpuProfilePath != "" {
@@ -257,6 +300,16 @@ func runCmd(ctx *cli.Context) error {
 		}
 	}
 
+	if firehoseContext.Enabled() {
+		var tx *types.Transaction
+		if ctx.GlobalBool(CreateFlag.Name) {
+			tx = types.NewContractCreation(statedb.GetNonce(sender), runtimeConfig.Value, initialGas, runtimeConfig.GasPrice, input)
+		} else {
+			tx = types.NewTransaction(statedb.GetNonce(sender), receiver, runtimeConfig.Value, initialGas, runtimeConfig.GasPrice, input)
+		}
+		execFunc = firehoseExec(firehoseContext, &runtimeConfig, tx, execFunc)
+	}
+
 	output, leftOverGas, execTime, err := timedExec(ctx.GlobalBool(BenchFlag.Name), execFunc)
 
 	if ctx.GlobalBool(DumpFlag.Name) {
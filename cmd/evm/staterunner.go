@@ -25,6 +25,7 @@ import (
 
 	"github.com/ethereum/go-ethereum/core/state"
 	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/firehose"
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/ethereum/go-ethereum/tests"
 
@@ -91,12 +92,21 @@ func stateTestCmd(ctx *cli.Context) error {
 		Tracer: tracer,
 		Debug:  ctx.GlobalBool(DebugFlag.Name) || ctx.GlobalBool(MachineFlag.Name),
 	}
+	var firehoseContext *firehose.Context
+	if ctx.GlobalBool(FirehoseFlag.Name) {
+		firehoseContext = firehose.NewContext(firehose.NewDelegateToWriterPrinter(os.Stdout))
+	}
 	results := make([]StatetestResult, 0, len(tests))
 	for key, test := range tests {
 		for _, st := range test.Subtests() {
 			// Run the test and aggregate the result
 			result := &StatetestResult{Name: key, Fork: st.Fork, Pass: true}
-			state, err := test.Run(st, cfg)
+			var state *state.StateDB
+			if firehoseContext.Enabled() {
+				state, err = test.RunWithFirehose(st, cfg, firehoseContext)
+			} else {
+				state, err = test.Run(st, cfg)
+			}
 			// print state root for evmlab tracing
 			if ctx.GlobalBool(MachineFlag.Name) && state != nil {
 				fmt.Fprintf(os.Stderr, "{\"stateRoot\": \"%x\"}\n", state.IntermediateRoot(false))
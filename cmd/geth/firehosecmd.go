@@ -0,0 +1,262 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of go-ethereum.
+//
+// go-ethereum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-ethereum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-ethereum. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"text/tabwriter"
+
+	"github.com/ethereum/go-ethereum/cmd/utils"
+	"github.com/ethereum/go-ethereum/firehose"
+	"gopkg.in/urfave/cli.v1"
+)
+
+var (
+	firehoseStatsTopFlag = cli.IntFlag{
+		Name:  "top",
+		Usage: "Amount of largest transactions and calls listed",
+		Value: 10,
+	}
+
+	firehoseCommand = cli.Command{
+		Name:     "firehose",
+		Usage:    "Firehose instrumentation tools",
+		Category: "FIREHOSE COMMANDS",
+		Description: `
+Tools to work with the Firehose instrumentation output (dmlog) produced
+when running with --firehose.enabled.`,
+		Subcommands: []cli.Command{
+			{
+				Name:      "check",
+				Usage:     "Validate the structural invariants of a captured dmlog file",
+				ArgsUsage: "<dmlogFile>",
+				Action:    utils.MigrateFlags(firehoseCheck),
+				Category:  "FIREHOSE COMMANDS",
+				Description: `
+    geth firehose check /path/to/capture.dmlog
+
+parses the captured dmlog file and verifies that blocks, transactions and
+calls are balanced, that ordinals are monotonic within transactions and that
+hexadecimal fields are valid. A summary is printed along with the line number
+of every violation found, the command fails if any violation was found.`,
+			},
+			{
+				Name:      "diff",
+				Usage:     "Compare two dmlog captures of the same block range",
+				ArgsUsage: "<dmlogFileA> <dmlogFileB>",
+				Action:    utils.MigrateFlags(firehoseDiff),
+				Category:  "FIREHOSE COMMANDS",
+				Description: `
+    geth firehose diff /path/to/before.dmlog /path/to/after.dmlog
+
+parses two captures of the same block range, for example taken before and
+after a node upgrade, and compares them block by block. Ordinals and events
+depending on the node's run (trie commits, slow transactions, segmentation)
+are ignored. The first difference of each block is printed along with the
+blocks found in a single capture, the command fails if any difference was
+found.`,
+			},
+			{
+				Name:      "stats",
+				Usage:     "Summarize the content of a captured dmlog file",
+				ArgsUsage: "<dmlogFile>",
+				Action:    utils.MigrateFlags(firehoseStats),
+				Flags:     []cli.Flag{firehoseStatsTopFlag},
+				Category:  "FIREHOSE COMMANDS",
+				Description: `
+    geth firehose stats [--top 10] /path/to/capture.dmlog
+
+parses the captured dmlog file and prints the amount of lines and bytes per
+event family and per event, the histograms of block and transaction sizes,
+the distribution of the gas used by transactions and the largest
+transactions and calls in bytes. It helps deciding which optional event
+families are worth disabling for a given workload.`,
+			},
+		},
+	}
+)
+
+// firehoseCheck validates the structural invariants of a dmlog file.
+func firehoseCheck(ctx *cli.Context) error {
+	if len(ctx.Args()) != 1 {
+		utils.Fatalf("This command requires an argument.")
+	}
+
+	file, err := os.Open(ctx.Args().First())
+	if err != nil {
+		return fmt.Errorf("open dmlog file: %w", err)
+	}
+	defer file.Close()
+
+	report, err := firehose.Check(file)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Lines:         %d\n", report.Lines)
+	fmt.Printf("Blocks:        %d\n", report.Blocks)
+	fmt.Printf("Cancel blocks: %d\n", report.CancelBlocks)
+	fmt.Printf("Transactions:  %d\n", report.Transactions)
+	fmt.Printf("Calls:         %d\n", report.Calls)
+	fmt.Printf("Violations:    %d\n", len(report.Violations))
+
+	for _, violation := range report.Violations {
+		fmt.Println(violation)
+	}
+
+	if !report.Valid() {
+		return errors.New("dmlog file is invalid")
+	}
+	return nil
+}
+
+// firehoseDiff compares two dmlog files block by block.
+func firehoseDiff(ctx *cli.Context) error {
+	if len(ctx.Args()) != 2 {
+		utils.Fatalf("This command requires two arguments.")
+	}
+
+	fileA, err := os.Open(ctx.Args().Get(0))
+	if err != nil {
+		return fmt.Errorf("open first dmlog file: %w", err)
+	}
+	defer fileA.Close()
+
+	fileB, err := os.Open(ctx.Args().Get(1))
+	if err != nil {
+		return fmt.Errorf("open second dmlog file: %w", err)
+	}
+	defer fileB.Close()
+
+	report, err := firehose.Diff(fileA, fileB)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Blocks compared: %d\n", report.BlocksCompared)
+	fmt.Printf("Differences:     %d\n", len(report.Differences))
+
+	for _, difference := range report.Differences {
+		fmt.Println(difference)
+	}
+
+	if !report.Identical() {
+		return errors.New("dmlog files differ")
+	}
+	return nil
+}
+
+// firehoseStats summarizes the content of a dmlog file.
+func firehoseStats(ctx *cli.Context) error {
+	if len(ctx.Args()) != 1 {
+		utils.Fatalf("This command requires an argument.")
+	}
+
+	file, err := os.Open(ctx.Args().First())
+	if err != nil {
+		return fmt.Errorf("open dmlog file: %w", err)
+	}
+	defer file.Close()
+
+	report, err := firehose.Stats(file, ctx.Int(firehoseStatsTopFlag.Name))
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Lines:         %d\n", report.Lines)
+	fmt.Printf("Bytes:         %d\n", report.Bytes)
+	fmt.Printf("Blocks:        %d\n", report.Blocks)
+	fmt.Printf("Transactions:  %d\n", report.Transactions)
+	fmt.Printf("Calls:         %d\n", report.Calls)
+
+	printStatsUsages("Family", report.Families, report.Bytes)
+	printStatsUsages("Event", report.Events, report.Bytes)
+
+	printStatsHistogram("Block bytes", &report.BlockBytes)
+	printStatsHistogram("Transaction bytes", &report.TransactionBytes)
+	printStatsHistogram("Transaction gas used", &report.TransactionGas)
+
+	fmt.Println()
+	writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(writer, "Block\tLargest transaction\tBytes\tGas used\t")
+	for _, trx := range report.LargestTransactions {
+		fmt.Fprintf(writer, "%d\t%s\t%d\t%d\t\n", trx.Block, trx.Hash, trx.Bytes, trx.GasUsed)
+	}
+	writer.Flush()
+
+	fmt.Println()
+	writer = tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(writer, "Block\tTransaction\tLargest call\tBytes\t")
+	for _, call := range report.LargestCalls {
+		fmt.Fprintf(writer, "%d\t%s\t%s\t%d\t\n", call.Block, call.TrxHash, call.CallIndex, call.Bytes)
+	}
+	writer.Flush()
+
+	return nil
+}
+
+// printStatsUsages prints the usages ordered by decreasing bytes, along their share of the
+// total bytes.
+func printStatsUsages(title string, usages map[string]*firehose.StatsUsage, totalBytes uint64) {
+	keys := make([]string, 0, len(usages))
+	for key := range usages {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if usages[keys[i]].Bytes == usages[keys[j]].Bytes {
+			return keys[i] < keys[j]
+		}
+		return usages[keys[i]].Bytes > usages[keys[j]].Bytes
+	})
+
+	fmt.Println()
+	writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintf(writer, "%s\tLines\tBytes\tShare\t\n", title)
+	for _, key := range keys {
+		usage := usages[key]
+		share := 0.0
+		if totalBytes > 0 {
+			share = 100 * float64(usage.Bytes) / float64(totalBytes)
+		}
+		fmt.Fprintf(writer, "%s\t%d\t%d\t%.2f%%\t\n", key, usage.Count, usage.Bytes, share)
+	}
+	writer.Flush()
+}
+
+// printStatsHistogram prints the non-empty buckets of the histogram.
+func printStatsHistogram(title string, histogram *firehose.StatsHistogram) {
+	fmt.Println()
+	if histogram.Total == 0 {
+		fmt.Printf("%s: no values\n", title)
+		return
+	}
+
+	fmt.Printf("%s: average %d, max %d\n", title, histogram.Sum/histogram.Total, histogram.Max)
+	writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.AlignRight)
+	for i, count := range histogram.Buckets {
+		if count == 0 {
+			continue
+		}
+
+		low, high := histogram.BucketBounds(i)
+		fmt.Fprintf(writer, "[%d, %d)\t%d\t\n", low, high, count)
+	}
+	writer.Flush()
+}
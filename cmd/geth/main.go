@@ -109,6 +109,12 @@ var (
 		utils.CacheTrieFlag,
 		utils.CacheGCFlag,
 		utils.CacheNoPrefetchFlag,
+		utils.DatabaseWriteBufferFlag,
+		utils.DatabaseCompactionTableSizeFlag,
+		utils.DatabaseCompactionL0TriggerFlag,
+		utils.DatabaseWriteL0SlowdownFlag,
+		utils.DatabaseWriteL0PauseFlag,
+		utils.DatabaseMaintenanceWindowFlag,
 		utils.ListenPortFlag,
 		utils.MaxPeersFlag,
 		utils.MaxPendingPeersFlag,
@@ -224,6 +230,8 @@ func init() {
 		dumpConfigCommand,
 		// See retesteth.go
 		retestethCommand,
+		// See firehosecmd.go
+		firehoseCommand,
 	}
 	sort.Sort(cli.CommandsByName(app.Commands))
 
@@ -232,6 +240,7 @@ func init() {
 	app.Flags = append(app.Flags, consoleFlags...)
 	app.Flags = append(app.Flags, debug.Flags...)
 	app.Flags = append(app.Flags, debug.FirehoseFlags...)
+	app.Flags = append(app.Flags, debug.FirehoseDeprecatedFlags...)
 	app.Flags = append(app.Flags, whisperFlags...)
 	app.Flags = append(app.Flags, metricsFlags...)
 
@@ -240,10 +249,17 @@ func init() {
 			return err
 		}
 
+		firehose.MaybeSyncContext().StreamHeader(
+			params.VersionWithMeta,
+			gitCommit,
+			params.FirehoseVersion(),
+			firehose.ActiveVariant,
+		)
+
 		firehose.MaybeSyncContext().InitVersion(
 			params.VersionWithCommit(gitCommit, gitDate),
 			params.FirehoseVersion(),
-			params.Variant,
+			firehose.ActiveVariant,
 		)
 
 		return nil
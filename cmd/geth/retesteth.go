@@ -225,9 +225,9 @@ func (e *NoRewardEngine) accumulateRewards(config *params.ChainConfig, state *st
 	// Simply touch miner and uncle coinbase accounts
 	reward := big.NewInt(0)
 	for _, uncle := range uncles {
-		state.AddBalance(uncle.Coinbase, reward, false, firehose.NoOpContext, firehose.BalanceChangeReason("reward_mine_uncle"))
+		state.AddBalance(uncle.Coinbase, reward, false, firehose.NoOpContext, firehose.RewardMineUncleBalanceChangeReason)
 	}
-	state.AddBalance(header.Coinbase, reward, false, firehose.NoOpContext, firehose.BalanceChangeReason("reward_mine_block"))
+	state.AddBalance(header.Coinbase, reward, false, firehose.NoOpContext, firehose.RewardMineBlockBalanceChangeReason)
 }
 
 func (e *NoRewardEngine) Finalize(chain consensus.ChainReader, header *types.Header, statedb *state.StateDB, txs []*types.Transaction,
@@ -138,6 +138,12 @@ var AppHelpFlagGroups = []flagGroup{
 			utils.CacheTrieFlag,
 			utils.CacheGCFlag,
 			utils.CacheNoPrefetchFlag,
+			utils.DatabaseWriteBufferFlag,
+			utils.DatabaseCompactionTableSizeFlag,
+			utils.DatabaseCompactionL0TriggerFlag,
+			utils.DatabaseWriteL0SlowdownFlag,
+			utils.DatabaseWriteL0PauseFlag,
+			utils.DatabaseMaintenanceWindowFlag,
 		},
 	},
 	{
@@ -240,7 +246,7 @@ var AppHelpFlagGroups = []flagGroup{
 	},
 	{
 		Name: "DEPRECATED",
-		Flags: []cli.Flag{
+		Flags: append([]cli.Flag{
 			utils.LightLegacyServFlag,
 			utils.LightLegacyPeersFlag,
 			utils.MinerLegacyThreadsFlag,
@@ -248,7 +254,7 @@ var AppHelpFlagGroups = []flagGroup{
 			utils.MinerLegacyGasPriceFlag,
 			utils.MinerLegacyEtherbaseFlag,
 			utils.MinerLegacyExtraDataFlag,
-		},
+		}, debug.FirehoseDeprecatedFlags...),
 	},
 	{
 		Name: "MISC",
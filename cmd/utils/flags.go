@@ -46,6 +46,7 @@ import (
 	"github.com/ethereum/go-ethereum/eth/downloader"
 	"github.com/ethereum/go-ethereum/eth/gasprice"
 	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/ethdb/leveldb"
 	"github.com/ethereum/go-ethereum/ethstats"
 	"github.com/ethereum/go-ethereum/graphql"
 	"github.com/ethereum/go-ethereum/les"
@@ -395,6 +396,31 @@ var (
 		Name:  "cache.noprefetch",
 		Usage: "Disable heuristic state prefetch during block import (less CPU and disk IO, more time waiting for data)",
 	}
+	// Database tuning settings
+	DatabaseWriteBufferFlag = cli.IntFlag{
+		Name:  "db.writebuffer",
+		Usage: "Size in megabytes of the database write buffer (0 = derived from the database cache)",
+	}
+	DatabaseCompactionTableSizeFlag = cli.IntFlag{
+		Name:  "db.compaction.tablesize",
+		Usage: "Size in megabytes of the tables produced by database compactions (0 = default)",
+	}
+	DatabaseCompactionL0TriggerFlag = cli.IntFlag{
+		Name:  "db.compaction.l0trigger",
+		Usage: "Amount of level-0 tables triggering a database compaction (0 = default)",
+	}
+	DatabaseWriteL0SlowdownFlag = cli.IntFlag{
+		Name:  "db.write.l0slowdown",
+		Usage: "Amount of level-0 tables from which database writes are throttled while compaction catches up (0 = default)",
+	}
+	DatabaseWriteL0PauseFlag = cli.IntFlag{
+		Name:  "db.write.l0pause",
+		Usage: "Amount of level-0 tables from which database writes are paused while compaction catches up (0 = default)",
+	}
+	DatabaseMaintenanceWindowFlag = cli.StringFlag{
+		Name:  "db.maintenance.window",
+		Usage: "Daily window (HH:MM-HH:MM, local time) during which a full database compaction is run (disabled when empty)",
+	}
 	// Miner settings
 	MiningEnabledFlag = cli.BoolFlag{
 		Name:  "mine",
@@ -1440,6 +1466,7 @@ func SetEthConfig(ctx *cli.Context, stack *node.Node, cfg *eth.Config) {
 		cfg.DatabaseCache = ctx.GlobalInt(CacheFlag.Name) * ctx.GlobalInt(CacheDatabaseFlag.Name) / 100
 	}
 	cfg.DatabaseHandles = makeDatabaseHandles()
+	setDatabaseTuning(ctx)
 	if ctx.GlobalIsSet(AncientFlag.Name) {
 		cfg.DatabaseFreezer = ctx.GlobalString(AncientFlag.Name)
 	}
@@ -1631,12 +1658,32 @@ func SplitTagsFlag(tagsFlag string) map[string]string {
 	return tagsMap
 }
 
+// setDatabaseTuning applies the database tuning flags to the databases opened afterwards.
+func setDatabaseTuning(ctx *cli.Context) {
+	tuning := leveldb.Tuning{
+		WriteBuffer:            ctx.GlobalInt(DatabaseWriteBufferFlag.Name),
+		CompactionTableSize:    ctx.GlobalInt(DatabaseCompactionTableSizeFlag.Name),
+		CompactionL0Trigger:    ctx.GlobalInt(DatabaseCompactionL0TriggerFlag.Name),
+		WriteL0SlowdownTrigger: ctx.GlobalInt(DatabaseWriteL0SlowdownFlag.Name),
+		WriteL0PauseTrigger:    ctx.GlobalInt(DatabaseWriteL0PauseFlag.Name),
+	}
+	if window := ctx.GlobalString(DatabaseMaintenanceWindowFlag.Name); window != "" {
+		parsed, err := leveldb.ParseWindow(window)
+		if err != nil {
+			Fatalf("Option %q: %v", DatabaseMaintenanceWindowFlag.Name, err)
+		}
+		tuning.MaintenanceWindow = parsed
+	}
+	leveldb.SetTuning(tuning)
+}
+
 // MakeChainDatabase open an LevelDB using the flags passed to the client and will hard crash if it fails.
 func MakeChainDatabase(ctx *cli.Context, stack *node.Node) ethdb.Database {
 	var (
 		cache   = ctx.GlobalInt(CacheFlag.Name) * ctx.GlobalInt(CacheDatabaseFlag.Name) / 100
 		handles = makeDatabaseHandles()
 	)
+	setDatabaseTuning(ctx)
 	name := "chaindata"
 	if ctx.GlobalString(SyncModeFlag.Name) == "light" {
 		name = "lightchaindata"
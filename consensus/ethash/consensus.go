@@ -636,10 +636,10 @@ func accumulateRewards(config *params.ChainConfig, state *state.StateDB, header
 		r.Sub(r, header.Number)
 		r.Mul(r, blockReward)
 		r.Div(r, big8)
-		state.AddBalance(uncle.Coinbase, r, false, firehoseContext, firehose.BalanceChangeReason("reward_mine_uncle"))
+		state.AddBalance(uncle.Coinbase, r, false, firehoseContext, firehose.RewardMineUncleBalanceChangeReason)
 
 		r.Div(blockReward, big32)
 		reward.Add(reward, r)
 	}
-	state.AddBalance(header.Coinbase, reward, false, firehoseContext, firehose.BalanceChangeReason("reward_mine_block"))
+	state.AddBalance(header.Coinbase, reward, false, firehoseContext, firehose.RewardMineBlockBalanceChangeReason)
 }
@@ -80,7 +80,7 @@ func ApplyDAOHardFork(statedb *state.StateDB, firehoseContext *firehose.Context)
 
 	// Move every DAO account and extra-balance account funds into the refund contract
 	for _, addr := range params.DAODrainList() {
-		statedb.AddBalance(params.DAORefundContract, statedb.GetBalance(addr), false, firehoseContext, firehose.BalanceChangeReason("dao_refund_contract"))
-		statedb.SetBalance(addr, new(big.Int), firehoseContext, firehose.BalanceChangeReason("dao_adjust_balance"))
+		statedb.AddBalance(params.DAORefundContract, statedb.GetBalance(addr), false, firehoseContext, firehose.DAORefundContractBalanceChangeReason)
+		statedb.SetBalance(addr, new(big.Int), firehoseContext, firehose.DAOAdjustBalanceChangeReason)
 	}
 }
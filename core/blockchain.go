@@ -18,7 +18,6 @@
 package core
 
 import (
-	"bytes"
 	"errors"
 	"fmt"
 	"io"
@@ -37,7 +36,6 @@ import (
 	"github.com/ethereum/go-ethereum/core/state"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/core/vm"
-	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/ethdb"
 	"github.com/ethereum/go-ethereum/event"
 	"github.com/ethereum/go-ethereum/firehose"
@@ -180,12 +178,26 @@ type BlockChain struct {
 	badBlocks       *lru.Cache                     // Bad block cache
 	shouldPreserve  func(*types.Block) bool        // Function used to determine whether should preserve the given block.
 	terminateInsert func(common.Hash, uint64) bool // Testing hook used to terminate ancient receipt chain insertion.
+
+	firehose firehose.ContextProvider // Firehose contexts of this chain instance
+}
+
+// BlockChainOption configures optional behavior of a BlockChain.
+type BlockChainOption func(bc *BlockChain)
+
+// WithFirehoseContextProvider makes the chain record its Firehose stream through the given
+// provider instead of the process wide `firehose.DefaultContextProvider`, letting several
+// chain instances of a single process each own their context and output.
+func WithFirehoseContextProvider(provider firehose.ContextProvider) BlockChainOption {
+	return func(bc *BlockChain) {
+		bc.firehose = provider
+	}
 }
 
 // NewBlockChain returns a fully initialised block chain using information
 // available in the database. It initialises the default Ethereum Validator and
 // Processor.
-func NewBlockChain(db ethdb.Database, cacheConfig *CacheConfig, chainConfig *params.ChainConfig, engine consensus.Engine, vmConfig vm.Config, shouldPreserve func(block *types.Block) bool) (*BlockChain, error) {
+func NewBlockChain(db ethdb.Database, cacheConfig *CacheConfig, chainConfig *params.ChainConfig, engine consensus.Engine, vmConfig vm.Config, shouldPreserve func(block *types.Block) bool, opts ...BlockChainOption) (*BlockChain, error) {
 	if cacheConfig == nil {
 		cacheConfig = &CacheConfig{
 			TrieCleanLimit: 256,
@@ -218,6 +230,10 @@ func NewBlockChain(db ethdb.Database, cacheConfig *CacheConfig, chainConfig *par
 		engine:         engine,
 		vmConfig:       vmConfig,
 		badBlocks:      badBlocks,
+		firehose:       firehose.DefaultContextProvider,
+	}
+	for _, opt := range opts {
+		opt(bc)
 	}
 	bc.validator = NewBlockValidator(chainConfig, bc, engine)
 	bc.prefetcher = newStatePrefetcher(chainConfig, bc, engine)
@@ -297,60 +313,46 @@ func NewBlockChain(db ethdb.Database, cacheConfig *CacheConfig, chainConfig *par
 		}
 	}
 
-	if firehose.Enabled && bc.CurrentBlock().NumberU64() == 0 {
+	// Blocks total difficulty and uncle bodies emitted by Firehose are resolved through the chain
+	bc.firehose.SyncContext().SetTotalDifficultyProvider(bc)
+	bc.firehose.SyncContext().SetBlockProvider(bc)
+
+	if bc.firehose.Enabled() && bc.CurrentBlock().NumberU64() == 0 {
 		if bc.genesisBlock == nil {
 			panic(fmt.Errorf("expected to have genesis block here"))
 		}
 
-		if firehose.GenesisConfig == nil {
-			panic(fmt.Errorf("genesis config is not set, there is something weird as all code path should generate the correct genesis config"))
-		}
-
-		genesis := firehose.GenesisConfig.(*Genesis)
-		if genesis == nil {
-			panic(fmt.Errorf("genesis config is not set, there is something weird as all code path should generate the correct genesis config"))
-		}
+		if firehose.GenesisAllocFromStateEnabled {
+			// The genesis state is committed along the genesis block, walking it avoids going
+			// through the (possibly huge) genesis spec
+			bc.firehose.MaybeSyncContextForBlock(0).RecordGenesisBlock(bc.genesisBlock, func(ctx *firehose.Context) {
+				if err := recordGenesisAllocFromState(ctx, bc.stateCache, bc.genesisBlock.Root()); err != nil {
+					panic(fmt.Errorf("firehose genesis alloc from state: %w", err))
+				}
+			})
+		} else {
+			if bc.firehose.GenesisConfig() == nil {
+				panic(fmt.Errorf("genesis config is not set, there is something weird as all code path should generate the correct genesis config"))
+			}
 
-		// As far as I can tell, the block's hash comes from the keccak hash of the rlp encoding
-		// of the block's header which includes all fields. So we can check the hash to ensure
-		// the genesis config computed matched Geth savec genesis block.
-		recomputedGenesisBlock := genesis.ToBlock(nil)
-		if bc.genesisBlock.Hash() != recomputedGenesisBlock.Hash() {
-			firehose.ReportHeaderComparisonResult(recomputedGenesisBlock.Header(), bc.genesisBlock.Header())
-			panic("firehose genesis block hash mismatch vs geth computed genesis block hash")
-		}
+			genesis := bc.firehose.GenesisConfig().(*Genesis)
+			if genesis == nil {
+				panic(fmt.Errorf("genesis config is not set, there is something weird as all code path should generate the correct genesis config"))
+			}
 
-		firehose.MaybeSyncContext().RecordGenesisBlock(bc.genesisBlock, func(ctx *firehose.Context) {
-			sortedAddrs := make([]common.Address, len(genesis.Alloc))
-			i := 0
-			for addr := range genesis.Alloc {
-				sortedAddrs[i] = addr
-				i++
+			// As far as I can tell, the block's hash comes from the keccak hash of the rlp encoding
+			// of the block's header which includes all fields. So we can check the hash to ensure
+			// the genesis config computed matched Geth savec genesis block.
+			recomputedGenesisBlock := genesis.ToBlock(nil)
+			if bc.genesisBlock.Hash() != recomputedGenesisBlock.Hash() {
+				firehose.ReportHeaderComparisonResult(recomputedGenesisBlock.Header(), bc.genesisBlock.Header())
+				panic("firehose genesis block hash mismatch vs geth computed genesis block hash")
 			}
 
-			sort.Slice(sortedAddrs, func(i, j int) bool {
-				return bytes.Compare(sortedAddrs[i][:], sortedAddrs[j][:]) <= -1
+			bc.firehose.MaybeSyncContextForBlock(0).RecordGenesisBlock(bc.genesisBlock, func(ctx *firehose.Context) {
+				recordGenesisAllocFromSpec(ctx, genesis)
 			})
-
-			for _, addr := range sortedAddrs {
-				account := genesis.Alloc[addr]
-
-				ctx.RecordNewAccount(addr)
-
-				ctx.RecordBalanceChange(addr, common.Big0, account.Balance, firehose.BalanceChangeReason("genesis_balance"))
-				if len(account.Code) > 0 {
-					ctx.RecordCodeChange(addr, nil, nil, crypto.Keccak256Hash(account.Code), account.Code)
-				}
-
-				if account.Nonce > 0 {
-					ctx.RecordNonceChange(addr, 0, account.Nonce)
-				}
-
-				for key, value := range account.Storage {
-					ctx.RecordStorageChange(addr, key, common.Hash{}, value)
-				}
-			}
-		})
+		}
 	}
 
 	// Take ownership of this particular state
@@ -577,6 +579,17 @@ func (bc *BlockChain) Processor() Processor {
 	return bc.processor
 }
 
+// SetProcessor replaces the processor, e.g. by a StateProcessor created with options. It must
+// be called before any block is imported.
+func (bc *BlockChain) SetProcessor(processor Processor) {
+	bc.processor = processor
+}
+
+// FirehoseContextProvider returns the provider of the chain's Firehose contexts.
+func (bc *BlockChain) FirehoseContextProvider() firehose.ContextProvider {
+	return bc.firehose
+}
+
 // State returns a new mutable state based on the current HEAD block.
 func (bc *BlockChain) State() (*state.StateDB, error) {
 	return bc.StateAt(bc.CurrentBlock().Root())
@@ -1049,6 +1062,10 @@ func (bc *BlockChain) InsertReceiptChain(blockChain types.Blocks, receiptChain [
 	var (
 		ancientBlocks, liveBlocks     types.Blocks
 		ancientReceipts, liveReceipts []types.Receipts
+
+		// Blocks actually written, emitted as skeleton blocks in Firehose follower mode
+		writtenBlocks   types.Blocks
+		writtenReceipts []types.Receipts
 	)
 	// Do a sanity check that the provided chain is actually ordered and linked
 	for i := 0; i < len(blockChain); i++ {
@@ -1186,6 +1203,7 @@ func (bc *BlockChain) InsertReceiptChain(blockChain types.Blocks, receiptChain [
 			rawdb.WriteTxLookupEntries(batch, block)
 
 			stats.processed++
+			writtenBlocks, writtenReceipts = append(writtenBlocks, block), append(writtenReceipts, receiptChain[i])
 		}
 		// Flush all tx-lookup index data.
 		size += batch.ValueSize()
@@ -1271,6 +1289,7 @@ func (bc *BlockChain) InsertReceiptChain(blockChain types.Blocks, receiptChain [
 				batch.Reset()
 			}
 			stats.processed++
+			writtenBlocks, writtenReceipts = append(writtenBlocks, block), append(writtenReceipts, receiptChain[i])
 		}
 		// Write everything belongs to the blocks into the database. So that
 		// we can ensure all components of body is completed(body, receipts,
@@ -1302,6 +1321,10 @@ func (bc *BlockChain) InsertReceiptChain(blockChain types.Blocks, receiptChain [
 		}
 	}
 
+	if firehose.FollowerModeEnabled {
+		bc.recordSkeletonBlocks(writtenBlocks, writtenReceipts)
+	}
+
 	head := blockChain[len(blockChain)-1]
 	context := []interface{}{
 		"count", stats.processed, "elapsed", common.PrettyDuration(time.Since(start)),
@@ -1316,6 +1339,31 @@ func (bc *BlockChain) InsertReceiptChain(blockChain types.Blocks, receiptChain [
 	return 0, nil
 }
 
+// recordSkeletonBlocks emits the skeleton of blocks imported along their receipts without
+// being executed, see `firehose.FollowerModeEnabled`.
+func (bc *BlockChain) recordSkeletonBlocks(blocks types.Blocks, receiptChain []types.Receipts) {
+	for i, block := range blocks {
+		firehoseContext := bc.firehose.MaybeSyncContextForBlock(block.NumberU64())
+		if !firehoseContext.Enabled() {
+			continue
+		}
+
+		receipts := receiptChain[i]
+		if err := receipts.DeriveFields(bc.chainConfig, block.Hash(), block.NumberU64(), block.Transactions()); err != nil {
+			log.Error("Failed to derive block receipts fields, skeleton block not emitted", "number", block.Number(), "hash", block.Hash(), "err", err)
+			continue
+		}
+
+		signer := types.MakeSigner(bc.chainConfig, block.Number())
+		senders := make([]common.Address, len(block.Transactions()))
+		for j, tx := range block.Transactions() {
+			senders[j], _ = types.Sender(signer, tx)
+		}
+
+		firehoseContext.RecordSkeletonBlock(block, receipts, senders)
+	}
+}
+
 var lastWrite uint64
 
 // writeBlockWithoutState writes only the block and its metadata to the database,
@@ -1386,12 +1434,15 @@ func (bc *BlockChain) writeBlockWithState(block *types.Block, receipts []*types.
 	if err := blockBatch.Write(); err != nil {
 		log.Crit("Failed to write block into disk", "err", err)
 	}
+	triedb := bc.stateCache.TrieDB()
+	commitStart := time.Now()
+	persistedNodes, persistedSize := triedb.Persisted()
+
 	// Commit all cached state changes into underlying memory database.
 	root, err := state.Commit(bc.chainConfig.IsEIP158(block.Number()))
 	if err != nil {
 		return NonStatTy, err
 	}
-	triedb := bc.stateCache.TrieDB()
 
 	// If we're running an archive node, always flush
 	if bc.cacheConfig.TrieDirtyDisabled {
@@ -1445,6 +1496,10 @@ func (bc *BlockChain) writeBlockWithState(block *types.Block, receipts []*types.
 			}
 		}
 	}
+	if firehose.TrieCommitStatsEnabled {
+		nodes, size := triedb.Persisted()
+		bc.firehose.SyncContext().RecordTrieCommit(block.NumberU64(), nodes-persistedNodes, uint64(size-persistedSize), time.Since(commitStart))
+	}
 	// If the total difficulty is higher than our known, add it to the canonical chain
 	// Second clause in the if statement reduces the vulnerability to selfish mining.
 	// Please refer to http://www.cs.cornell.edu/~ie53/publications/btcProcFC.pdf
@@ -1695,12 +1750,10 @@ func (bc *BlockChain) insertChain(chain types.Blocks, verifySeals bool) (int, er
 			}
 
 			// some blocks with 0 transactions are only processed here
-			if firehoseContext := firehose.MaybeSyncContext(); firehoseContext.Enabled() {
+			if firehoseContext := bc.firehose.MaybeSyncContextForBlock(block.NumberU64()); firehoseContext.Enabled() {
 				firehoseContext.StartBlock(block)
 				firehoseContext.FinalizeBlock(block)
-				ptd := bc.GetTd(block.ParentHash(), block.NumberU64()-1)
-				td := new(big.Int).Add(block.Difficulty(), ptd)
-				firehoseContext.EndBlock(block, td)
+				firehoseContext.EndBlock(block, nil)
 			}
 
 			stats.processed++
@@ -1740,7 +1793,7 @@ func (bc *BlockChain) insertChain(chain types.Blocks, verifySeals bool) (int, er
 		}
 		// Process block using the parent state as reference point
 		substart := time.Now()
-		receipts, logs, usedGas, err := bc.processor.Process(block, statedb, bc.vmConfig, firehose.MaybeSyncContext())
+		receipts, logs, usedGas, err := bc.processor.Process(block, statedb, bc.vmConfig, bc.firehose.MaybeSyncContextForBlock(block.NumberU64()))
 		if err != nil {
 			bc.reportBlock(block, receipts, err)
 			atomic.StoreUint32(&followupInterrupt, 1)
@@ -1763,17 +1816,16 @@ func (bc *BlockChain) insertChain(chain types.Blocks, verifySeals bool) (int, er
 		if err := bc.validator.ValidateState(block, statedb, receipts, usedGas); err != nil {
 			bc.reportBlock(block, receipts, err)
 			atomic.StoreUint32(&followupInterrupt, 1)
-			if firehoseContext := firehose.MaybeSyncContext(); firehoseContext.Enabled() {
+			if firehoseContext := bc.firehose.MaybeSyncContextForBlock(block.NumberU64()); firehoseContext.Enabled() {
 				firehoseContext.CancelBlock(block, err)
 			}
 			return it.index, err
 		}
 
-		if firehoseContext := firehose.MaybeSyncContext(); firehoseContext.Enabled() {
-			// Calculate the total difficulty of the block
-			ptd := bc.GetTd(block.ParentHash(), block.NumberU64()-1)
-			td := new(big.Int).Add(block.Difficulty(), ptd)
-			firehoseContext.EndBlock(block, td)
+		if firehoseContext := bc.firehose.MaybeSyncContextForBlock(block.NumberU64()); firehoseContext.Enabled() {
+			// The total difficulty of the block is resolved by the context through the chain
+			firehoseContext.EndBlock(block, nil)
+			firehoseContext.ValidateAgainstReference(block, receipts)
 		}
 
 		proctime := time.Since(start)
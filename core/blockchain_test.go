@@ -17,12 +17,14 @@
 package core
 
 import (
+	"bytes"
 	"fmt"
 	"io/ioutil"
 	"math/big"
 	"math/rand"
 	"os"
 	"reflect"
+	"strings"
 	"sync"
 	"testing"
 	"time"
@@ -2364,3 +2366,114 @@ func TestDeleteCreateRevert(t *testing.T) {
 		t.Fatalf("block %d: failed to insert into chain: %v", n, err)
 	}
 }
+
+type recordingTransactionHook struct {
+	fees []*big.Int
+}
+
+func (h *recordingTransactionHook) OnTransactionExecuted(receipt *types.Receipt, fee *big.Int, skipped bool) {
+	if skipped {
+		panic("unexpected skipped transaction")
+	}
+	h.fees = append(h.fees, fee)
+}
+
+// Tests that the transaction hooks registered on the state processor are notified of each
+// executed transaction along the fee paid.
+func TestStateProcessorTransactionHook(t *testing.T) {
+	var (
+		db      = rawdb.NewMemoryDatabase()
+		key, _  = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+		address = crypto.PubkeyToAddress(key.PublicKey)
+		gspec   = &Genesis{Config: params.TestChainConfig, Alloc: GenesisAlloc{address: {Balance: big.NewInt(1000000000)}}}
+		genesis = gspec.MustCommit(db)
+		signer  = types.HomesteadSigner{}
+	)
+
+	blocks, _ := GenerateChain(gspec.Config, genesis, ethash.NewFaker(), db, 2, func(i int, block *BlockGen) {
+		tx, err := types.SignTx(types.NewTransaction(block.TxNonce(address), common.Address{}, new(big.Int), 21000, big.NewInt(int64(i+1)), nil), signer, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		block.AddTx(tx)
+	})
+
+	blockchain, _ := NewBlockChain(db, nil, gspec.Config, ethash.NewFaker(), vm.Config{}, nil)
+	defer blockchain.Stop()
+
+	hook := &recordingTransactionHook{}
+	blockchain.SetProcessor(NewStateProcessor(gspec.Config, blockchain, blockchain.engine, WithTransactionHook(hook)))
+
+	if _, err := blockchain.InsertChain(blocks); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(hook.fees) != 2 || hook.fees[0].Uint64() != 21000 || hook.fees[1].Uint64() != 42000 {
+		t.Fatalf("unexpected fees %v", hook.fees)
+	}
+}
+
+// rewardlessEngine is an engine not rewarding blocks, the instrumentation expecting balance
+// changes to happen within transactions.
+type rewardlessEngine struct {
+	consensus.Engine
+}
+
+func (rewardlessEngine) Finalize(chain consensus.ChainReader, header *types.Header, state *state.StateDB, txs []*types.Transaction, uncles []*types.Header, firehoseContext *firehose.Context) {
+	header.Root = state.IntermediateRoot(chain.Config().IsEIP158(header.Number))
+}
+
+func (rewardlessEngine) FinalizeAndAssemble(chain consensus.ChainReader, header *types.Header, state *state.StateDB, txs []*types.Transaction, uncles []*types.Header, receipts []*types.Receipt, firehoseContext *firehose.Context) (*types.Block, error) {
+	header.Root = state.IntermediateRoot(chain.Config().IsEIP158(header.Number))
+	return types.NewBlock(header, txs, uncles, receipts), nil
+}
+
+// Tests that chains created with their own Firehose context provider each record their
+// blocks to their own output only.
+func TestBlockChainFirehoseContextProviderIsolation(t *testing.T) {
+	newChain := func() (*BlockChain, firehose.ContextProvider, *bytes.Buffer, []*types.Block) {
+		var (
+			db      = rawdb.NewMemoryDatabase()
+			engine  = rewardlessEngine{ethash.NewFaker()}
+			gspec   = &Genesis{Config: params.TestChainConfig}
+			genesis = gspec.MustCommit(db)
+			output  = new(bytes.Buffer)
+		)
+		blocks, _ := GenerateChain(gspec.Config, genesis, engine, db, 2, nil)
+
+		provider, err := firehose.NewContextProvider(output, firehose.ProviderConfig{Enabled: true, GenesisConfig: gspec})
+		if err != nil {
+			t.Fatal(err)
+		}
+		blockchain, err := NewBlockChain(db, nil, gspec.Config, engine, vm.Config{}, nil, WithFirehoseContextProvider(provider))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return blockchain, provider, output, blocks
+	}
+
+	first, firstProvider, firstOutput, firstBlocks := newChain()
+	defer first.Stop()
+	second, secondProvider, secondOutput, _ := newChain()
+	defer second.Stop()
+
+	secondOutput.Reset()
+	if _, err := first.InsertChain(firstBlocks); err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(firstOutput.String(), "FIRE END_BLOCK 2 ") {
+		t.Fatalf("expected first chain output to contain its blocks, got %q", firstOutput.String())
+	}
+	if secondOutput.Len() != 0 {
+		t.Fatalf("expected second chain output to be empty, got %q", secondOutput.String())
+	}
+
+	// Each chain reports its own pipeline health
+	if current := firstProvider.Health().CurrentBlock; current != 2 {
+		t.Fatalf("expected first chain health at block 2, got %d", current)
+	}
+	if current := secondProvider.Health().CurrentBlock; current != 0 {
+		t.Fatalf("expected second chain health to be untouched, got block %d", current)
+	}
+}
@@ -16,7 +16,11 @@
 
 package core
 
-import "errors"
+import (
+	"errors"
+
+	"github.com/ethereum/go-ethereum/firehose"
+)
 
 var (
 	// ErrKnownBlock is returned when a block to import is already known locally.
@@ -36,3 +40,30 @@ var (
 	// ErrNoGenesis is returned when there is no Genesis Block.
 	ErrNoGenesis = errors.New("genesis not found in chain")
 )
+
+// Firehose additions
+
+// ErrTxReplayProtected is returned when a replay protected transaction is seen before the
+// EIP155 fork is active, such transaction is not supported yet.
+var ErrTxReplayProtected = errors.New("replay protected transaction before EIP155")
+
+var errorToSkippedTransactionReasonMap = map[error]firehose.SkippedTransactionReason{
+	ErrNonceTooHigh:              firehose.SkippedTransactionReason("nonce_too_high"),
+	ErrNonceTooLow:               firehose.SkippedTransactionReason("nonce_too_low"),
+	ErrGasLimitReached:           firehose.SkippedTransactionReason("gas_limit_reached"),
+	ErrIntrinsicGas:              firehose.SkippedTransactionReason("intrinsic_gas_too_low"),
+	ErrInsufficientFunds:         firehose.SkippedTransactionReason("insufficient_funds"),
+	ErrInvalidSender:             firehose.SkippedTransactionReason("invalid_sender"),
+	errInsufficientBalanceForGas: firehose.SkippedTransactionReason("insufficient_balance_for_gas"),
+	ErrTxReplayProtected:         firehose.SkippedTransactionReason("replay_protected"),
+}
+
+// ErrorToSkippedTransactionReason maps a transaction pre-check failure to its stable Firehose
+// classification, used when recording a transaction skipped by the execution.
+func ErrorToSkippedTransactionReason(err error) firehose.SkippedTransactionReason {
+	if reason, found := errorToSkippedTransactionReasonMap[err]; found {
+		return reason
+	}
+
+	return firehose.UnknownSkippedTransactionReason
+}
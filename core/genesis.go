@@ -262,7 +262,7 @@ func (g *Genesis) ToBlock(db ethdb.Database) *types.Block {
 	}
 	statedb, _ := state.New(common.Hash{}, state.NewDatabase(db))
 	for addr, account := range g.Alloc {
-		statedb.AddBalance(addr, account.Balance, false, firehose.NoOpContext, firehose.BalanceChangeReason("genesis_balance"))
+		statedb.AddBalance(addr, account.Balance, false, firehose.NoOpContext, firehose.GenesisBalanceChangeReason)
 		statedb.SetCode(addr, account.Code, firehose.NoOpContext)
 		statedb.SetNonce(addr, account.Nonce, firehose.NoOpContext)
 		for key, value := range account.Storage {
@@ -0,0 +1,213 @@
+package core
+
+import (
+	"bytes"
+	"fmt"
+	"runtime"
+	"sort"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/firehose"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/trie"
+)
+
+var emptyCodeHash = crypto.Keccak256(nil)
+
+// recordGenesisAllocFromSpec emits the genesis accounts as defined by the genesis spec,
+// ordered by address.
+func recordGenesisAllocFromSpec(ctx *firehose.Context, genesis *Genesis) {
+	sortedAddrs := make([]common.Address, len(genesis.Alloc))
+	i := 0
+	for addr := range genesis.Alloc {
+		sortedAddrs[i] = addr
+		i++
+	}
+
+	sort.Slice(sortedAddrs, func(i, j int) bool {
+		return bytes.Compare(sortedAddrs[i][:], sortedAddrs[j][:]) <= -1
+	})
+
+	for _, addr := range sortedAddrs {
+		account := genesis.Alloc[addr]
+
+		ctx.RecordNewAccount(addr)
+
+		ctx.RecordBalanceChange(addr, common.Big0, account.Balance, firehose.GenesisBalanceChangeReason)
+		if len(account.Code) > 0 {
+			ctx.RecordCodeChange(addr, nil, nil, crypto.Keccak256Hash(account.Code), account.Code)
+		}
+
+		if account.Nonce > 0 {
+			ctx.RecordNonceChange(addr, 0, account.Nonce)
+		}
+
+		for key, value := range account.Storage {
+			ctx.RecordStorageChange(addr, key, common.Hash{}, value)
+		}
+	}
+}
+
+// genesisStateAccount is a genesis account read from the state, its code and storage being
+// loaded by the readers of `recordGenesisAllocFromState`.
+type genesisStateAccount struct {
+	address  common.Address
+	addrHash common.Hash
+	data     state.Account
+
+	code    []byte
+	storage []genesisStorageSlot
+	err     error
+	loaded  chan struct{}
+}
+
+type genesisStorageSlot struct {
+	key   common.Hash
+	value common.Hash
+}
+
+// recordGenesisAllocFromState emits the genesis accounts by walking the genesis state trie
+// instead of the genesis spec, see `firehose.GenesisAllocFromStateEnabled`. The accounts are
+// emitted ordered by address like from the spec, storage slots being ordered by key. Code and
+// storage of the accounts are loaded by `firehose.GenesisAllocReaders` parallel readers while
+// the emission, which must remain sequential, follows.
+func recordGenesisAllocFromState(ctx *firehose.Context, db state.Database, root common.Hash) error {
+	accounts, err := readGenesisStateAccounts(db, root)
+	if err != nil {
+		return err
+	}
+
+	readers := firehose.GenesisAllocReaders
+	if readers <= 0 {
+		readers = runtime.NumCPU()
+	}
+
+	// The accounts are queued in order for the emission while being loaded by the readers,
+	// bounding the amount of loaded accounts waiting to be emitted
+	jobs := make(chan *genesisStateAccount)
+	pending := make(chan *genesisStateAccount, 4*readers)
+	done := make(chan struct{})
+	defer close(done)
+
+	for i := 0; i < readers; i++ {
+		go func() {
+			for account := range jobs {
+				account.err = loadGenesisStateAccount(db, account)
+				close(account.loaded)
+			}
+		}()
+	}
+
+	go func() {
+		defer close(jobs)
+		defer close(pending)
+
+		for _, account := range accounts {
+			account.loaded = make(chan struct{})
+			select {
+			case pending <- account:
+			case <-done:
+				return
+			}
+
+			jobs <- account
+		}
+	}()
+
+	for account := range pending {
+		<-account.loaded
+		if account.err != nil {
+			return account.err
+		}
+
+		ctx.RecordNewAccount(account.address)
+
+		ctx.RecordBalanceChange(account.address, common.Big0, account.data.Balance, firehose.GenesisBalanceChangeReason)
+		if len(account.code) > 0 {
+			ctx.RecordCodeChange(account.address, nil, nil, common.BytesToHash(account.data.CodeHash), account.code)
+		}
+
+		if account.data.Nonce > 0 {
+			ctx.RecordNonceChange(account.address, 0, account.data.Nonce)
+		}
+
+		for _, slot := range account.storage {
+			ctx.RecordStorageChange(account.address, slot.key, common.Hash{}, slot.value)
+		}
+	}
+
+	return nil
+}
+
+// readGenesisStateAccounts reads all the accounts of the account trie at `root`, ordered by
+// address, which requires the preimages of their hashed keys.
+func readGenesisStateAccounts(db state.Database, root common.Hash) ([]*genesisStateAccount, error) {
+	tr, err := db.OpenTrie(root)
+	if err != nil {
+		return nil, fmt.Errorf("open genesis state trie: %w", err)
+	}
+
+	var accounts []*genesisStateAccount
+	it := trie.NewIterator(tr.NodeIterator(nil))
+	for it.Next() {
+		preimage := tr.GetKey(it.Key)
+		if preimage == nil {
+			return nil, fmt.Errorf("genesis account %x preimage is missing", it.Key)
+		}
+
+		account := &genesisStateAccount{address: common.BytesToAddress(preimage), addrHash: common.BytesToHash(it.Key)}
+		if err := rlp.DecodeBytes(it.Value, &account.data); err != nil {
+			return nil, fmt.Errorf("decode genesis account %s: %w", account.address.Hex(), err)
+		}
+		accounts = append(accounts, account)
+	}
+	if it.Err != nil {
+		return nil, fmt.Errorf("iterate genesis state trie: %w", it.Err)
+	}
+
+	sort.Slice(accounts, func(i, j int) bool {
+		return bytes.Compare(accounts[i].address[:], accounts[j].address[:]) <= -1
+	})
+
+	return accounts, nil
+}
+
+// loadGenesisStateAccount loads the code and storage of the genesis account.
+func loadGenesisStateAccount(db state.Database, account *genesisStateAccount) (err error) {
+	if !bytes.Equal(account.data.CodeHash, emptyCodeHash) {
+		if account.code, err = db.ContractCode(account.addrHash, common.BytesToHash(account.data.CodeHash)); err != nil {
+			return fmt.Errorf("read genesis account %s code: %w", account.address.Hex(), err)
+		}
+	}
+
+	tr, err := db.OpenStorageTrie(account.addrHash, account.data.Root)
+	if err != nil {
+		return fmt.Errorf("open genesis account %s storage trie: %w", account.address.Hex(), err)
+	}
+
+	it := trie.NewIterator(tr.NodeIterator(nil))
+	for it.Next() {
+		preimage := tr.GetKey(it.Key)
+		if preimage == nil {
+			return fmt.Errorf("genesis account %s storage key %x preimage is missing", account.address.Hex(), it.Key)
+		}
+
+		_, content, _, err := rlp.Split(it.Value)
+		if err != nil {
+			return fmt.Errorf("decode genesis account %s storage value: %w", account.address.Hex(), err)
+		}
+
+		account.storage = append(account.storage, genesisStorageSlot{common.BytesToHash(preimage), common.BytesToHash(content)})
+	}
+	if it.Err != nil {
+		return fmt.Errorf("iterate genesis account %s storage trie: %w", account.address.Hex(), it.Err)
+	}
+
+	sort.Slice(account.storage, func(i, j int) bool {
+		return bytes.Compare(account.storage[i].key[:], account.storage[j].key[:]) <= -1
+	})
+
+	return nil
+}
@@ -0,0 +1,42 @@
+package core
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/firehose"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+func TestRecordGenesisAllocFromState(t *testing.T) {
+	genesis := &Genesis{
+		Config: params.TestChainConfig,
+		Alloc: GenesisAlloc{
+			common.Address{3}: {Balance: big.NewInt(3)},
+			common.Address{1}: {Balance: big.NewInt(1), Nonce: 2, Code: []byte{0x60, 0x00}, Storage: map[common.Hash]common.Hash{{1}: {2}}},
+			common.Address{2}: {Balance: big.NewInt(0), Storage: map[common.Hash]common.Hash{{3}: {4}}},
+		},
+	}
+
+	db := rawdb.NewMemoryDatabase()
+	block := genesis.MustCommit(db)
+
+	fromSpec := firehose.NewSpeculativeExecutionContext(1024 * 1024)
+	fromSpec.RecordGenesisBlock(block, func(ctx *firehose.Context) {
+		recordGenesisAllocFromSpec(ctx, genesis)
+	})
+
+	fromState := firehose.NewSpeculativeExecutionContext(1024 * 1024)
+	fromState.RecordGenesisBlock(block, func(ctx *firehose.Context) {
+		if err := recordGenesisAllocFromState(ctx, state.NewDatabase(db), block.Root()); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+	})
+
+	if string(fromState.FirehoseLog()) != string(fromSpec.FirehoseLog()) {
+		t.Fatalf("genesis alloc from state differs from the spec one, have:\n%s\nwant:\n%s", fromState.FirehoseLog(), fromSpec.FirehoseLog())
+	}
+}
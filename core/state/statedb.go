@@ -423,6 +423,9 @@ func (s *StateDB) Suicide(addr common.Address, firehoseContext *firehose.Context
 
 	if firehoseContext.Enabled() {
 		firehoseContext.RecordSuicide(stateObject.address, stateObject.suicided, stateObject.Balance())
+		if !stateObject.suicided {
+			firehoseContext.RecordStorageWipe(stateObject.address, stateObject.data.Root)
+		}
 	}
 
 	stateObject.markSuicided()
@@ -17,6 +17,8 @@
 package core
 
 import (
+	"math/big"
+
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/consensus"
 	"github.com/ethereum/go-ethereum/consensus/misc"
@@ -25,26 +27,71 @@ import (
 	"github.com/ethereum/go-ethereum/core/vm"
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/firehose"
+	"github.com/ethereum/go-ethereum/metrics"
 	"github.com/ethereum/go-ethereum/params"
 )
 
+var (
+	txGasUsedHistogram    = metrics.NewRegisteredHistogram("chain/tx/gas", nil, metrics.NewExpDecaySample(1028, 0.015))
+	gasPoolRemainingGauge = metrics.NewRegisteredGauge("chain/gaspool/remaining", nil)
+)
+
 // StateProcessor is a basic Processor, which takes care of transitioning
 // state from one point to another.
 //
 // StateProcessor implements Processor.
 type StateProcessor struct {
-	config *params.ChainConfig // Chain configuration options
-	bc     *BlockChain         // Canonical block chain
-	engine consensus.Engine    // Consensus engine used for block rewards
+	config   *params.ChainConfig      // Chain configuration options
+	bc       *BlockChain              // Canonical block chain
+	engine   consensus.Engine         // Consensus engine used for block rewards
+	hooks    []TransactionHook        // Hooks notified of each executed transaction
+	firehose firehose.ContextProvider // Firehose contexts of the chain instance
+}
+
+// TransactionHook is notified by the StateProcessor of each transaction it executes, letting
+// embedders collect fee and economics data without re-parsing the Firehose output.
+type TransactionHook interface {
+	// OnTransactionExecuted is called once the transaction has been applied, fee being the
+	// amount paid by the sender for the gas used. The processor never skips transactions, a
+	// failing one invalidates the block, so skipped is always false and the receipt always
+	// set, it's part of the interface for processors that do skip transactions.
+	OnTransactionExecuted(receipt *types.Receipt, fee *big.Int, skipped bool)
+}
+
+// StateProcessorOption configures optional behavior of a StateProcessor.
+type StateProcessorOption func(p *StateProcessor)
+
+// WithTransactionHook registers a hook notified of each executed transaction, hooks are
+// called in registration order, synchronously from Process.
+func WithTransactionHook(hook TransactionHook) StateProcessorOption {
+	return func(p *StateProcessor) {
+		p.hooks = append(p.hooks, hook)
+	}
+}
+
+// WithProcessorFirehoseContextProvider sets the provider of the Firehose contexts used when
+// finalizing blocks, defaulting to the one of the chain.
+func WithProcessorFirehoseContextProvider(provider firehose.ContextProvider) StateProcessorOption {
+	return func(p *StateProcessor) {
+		p.firehose = provider
+	}
 }
 
 // NewStateProcessor initialises a new StateProcessor.
-func NewStateProcessor(config *params.ChainConfig, bc *BlockChain, engine consensus.Engine) *StateProcessor {
-	return &StateProcessor{
-		config: config,
-		bc:     bc,
-		engine: engine,
+func NewStateProcessor(config *params.ChainConfig, bc *BlockChain, engine consensus.Engine, opts ...StateProcessorOption) *StateProcessor {
+	p := &StateProcessor{
+		config:   config,
+		bc:       bc,
+		engine:   engine,
+		firehose: firehose.DefaultContextProvider,
+	}
+	if bc != nil {
+		p.firehose = bc.firehose
 	}
+	for _, opt := range opts {
+		opt(p)
+	}
+	return p
 }
 
 // Process processes the state changes according to the Ethereum rules by running
@@ -72,10 +119,13 @@ func (p *StateProcessor) Process(block *types.Block, statedb *state.StateDB, cfg
 		misc.ApplyDAOHardFork(statedb, firehoseContext)
 	}
 
+	// Forks introducing system-level calls (e.g. EIP-4788 beacon block root) must apply them
+	// here through `ApplySystemCall`, before any transaction is started.
+
 	txFirehoseContext := firehoseContext
 	if txFirehoseContext.Enabled() {
 		// 5 MiB should hold enough for all transaction and it's re-used for all transactions so shouldn't be a big deal for the memory
-		txFirehoseContext = firehose.NewSpeculativeExecutionContext(5 * 1024 * 1024)
+		txFirehoseContext = firehoseContext.NewTransactionContext(5 * 1024 * 1024)
 	}
 
 	// Iterate over and process the individual transactions
@@ -89,6 +139,8 @@ func (p *StateProcessor) Process(block *types.Block, statedb *state.StateDB, cfg
 
 		receipt, err := ApplyTransaction(p.config, p.bc, nil, gp, statedb, header, tx, usedGas, cfg, txFirehoseContext)
 		if err != nil {
+			metrics.GetOrRegisterMeter("chain/tx/rejected/"+string(ErrorToSkippedTransactionReason(err)), nil).Mark(1)
+
 			// Trapped later at 'Process' call site at which point the block is canceled
 			return nil, nil, 0, err
 		}
@@ -100,22 +152,36 @@ func (p *StateProcessor) Process(block *types.Block, statedb *state.StateDB, cfg
 			firehoseContext.FlushTransaction(txFirehoseContext)
 		}
 
+		if len(p.hooks) > 0 {
+			fee := new(big.Int).Mul(new(big.Int).SetUint64(receipt.GasUsed), tx.GasPrice())
+			for _, hook := range p.hooks {
+				hook.OnTransactionExecuted(receipt, fee, false)
+			}
+		}
+
+		txGasUsedHistogram.Update(int64(receipt.GasUsed))
+
 		receipts = append(receipts, receipt)
 		allLogs = append(allLogs, receipt.Logs...)
 	}
+	gasPoolRemainingGauge.Update(int64(gp.Gas()))
 
 	// Finalize block is a bit special since it can be enabled without the full firehose sync.
 	// As such, if firehose is enabled, we log it and us the firehose context. Otherwise if
-	// block progress is enabled.
+	// block progress is enabled (or the block is below the configured start block).
 	if firehoseContext.Enabled() {
 		firehoseContext.FinalizeBlock(block)
-	} else if firehose.BlockProgressEnabled {
-		firehose.SyncContext().FinalizeBlock(block)
+	} else if p.firehose.BlockProgressEnabledForBlock(block.NumberU64()) {
+		p.firehose.SyncContext().FinalizeBlock(block)
 	}
 
 	// Finalize the block, applying any consensus engine specific extras (e.g. block rewards)
 	p.engine.Finalize(p.bc, header, statedb, block.Transactions(), block.Uncles(), firehoseContext)
 
+	if firehoseContext.Enabled() && len(firehose.StateProofAccounts) > 0 {
+		recordStateProofs(firehoseContext, statedb, p.config.IsEIP158(header.Number))
+	}
+
 	return receipts, allLogs, *usedGas, nil
 }
 
@@ -130,7 +196,15 @@ func ApplyTransaction(config *params.ChainConfig, bc ChainContext, author *commo
 	}
 
 	if txFirehoseContext.Enabled() {
-		txFirehoseContext.RecordTrxFrom(msg.From())
+		var pubkey []byte
+		if firehose.TrxFromPubkeyEnabled {
+			if pubkey, err = types.SenderPubkey(types.MakeSigner(config, header.Number), tx); err != nil {
+				return nil, err
+			}
+		}
+
+		txFirehoseContext.RecordTrxFrom(msg.From(), pubkey)
+		txFirehoseContext.RecordTrxReplacements(msg.From(), tx)
 	}
 
 	// Create a new context to be used in the EVM environment
@@ -171,3 +245,37 @@ func ApplyTransaction(config *params.ChainConfig, bc ChainContext, author *commo
 
 	return receipt, err
 }
+
+// SystemAddress is the caller of system-level calls executed outside of any user transaction.
+var SystemAddress = common.HexToAddress("0xfffffffffffffffffffffffffffffffffffffffe")
+
+// systemCallGasLimit is the gas given to system-level calls, it's not charged to anyone.
+const systemCallGasLimit = 30_000_000
+
+// ApplySystemCall executes a system-level call (like EIP-4788 beacon block root write or
+// history storage) against `target`, outside of any user transaction. Such calls must be
+// applied before the block's transactions, it's recorded as a SYSTEM_CALL section named
+// `name` in the block's Firehose context. A `nil` target deploys `input` as a contract.
+func ApplySystemCall(name string, config *params.ChainConfig, bc ChainContext, statedb *state.StateDB, header *types.Header, cfg vm.Config, target *common.Address, input []byte, firehoseContext *firehose.Context) error {
+	if firehoseContext.Enabled() {
+		firehoseContext.StartSystemCall(name, SystemAddress, target)
+	}
+
+	msg := types.NewMessage(SystemAddress, target, 0, common.Big0, systemCallGasLimit, common.Big0, input, false)
+	context := NewEVMContext(msg, header, bc, nil)
+	vmenv := vm.NewEVM(context, statedb, config, cfg, firehoseContext)
+
+	var err error
+	if target == nil {
+		_, _, _, err = vmenv.Create(vm.AccountRef(msg.From()), input, systemCallGasLimit, common.Big0)
+	} else {
+		_, _, err = vmenv.Call(vm.AccountRef(msg.From()), *target, input, systemCallGasLimit, common.Big0)
+	}
+	statedb.Finalise(true)
+
+	if firehoseContext.Enabled() {
+		firehoseContext.EndSystemCall()
+	}
+
+	return err
+}
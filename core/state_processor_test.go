@@ -0,0 +1,59 @@
+package core
+
+import (
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus"
+	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/firehose"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+type fakerChainContext struct{}
+
+func (fakerChainContext) Engine() consensus.Engine                    { return ethash.NewFaker() }
+func (fakerChainContext) GetHeader(common.Hash, uint64) *types.Header { return nil }
+
+func TestApplySystemCallCreation(t *testing.T) {
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(rawdb.NewMemoryDatabase()))
+	header := &types.Header{Number: big.NewInt(1), Difficulty: big.NewInt(1), GasLimit: 8_000_000}
+	block := types.NewBlockWithHeader(header)
+
+	// PUSH1 1, PUSH1 0, MSTORE8, PUSH1 1, PUSH1 0, RETURN: deploys the single byte 0x01
+	initCode := common.FromHex("0x600160005360016000f3")
+
+	for _, test := range []struct {
+		name     string
+		target   *common.Address
+		expected string
+	}{
+		{"creation", nil, ". 1 true"},
+		{"call", &common.Address{0xaa}, firehose.Addr(common.Address{0xaa}) + " 1 false"},
+	} {
+		ctx := firehose.NewSpeculativeExecutionContext(1024)
+		ctx.StartBlock(block)
+
+		if err := ApplySystemCall(test.name, params.TestChainConfig, fakerChainContext{}, statedb, header, vm.Config{}, test.target, initCode, ctx); err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+
+		log := string(ctx.FirehoseLog())
+		prefix := "FIRE BEGIN_SYSTEM_CALL " + test.name + " " + firehose.Addr(SystemAddress) + " " + test.expected + "\n"
+		if !strings.Contains(log, prefix) {
+			t.Errorf("%s: expected %q in %q", test.name, prefix, log)
+		}
+	}
+
+	created := crypto.CreateAddress(SystemAddress, 0)
+	if code := statedb.GetCode(created); len(code) != 1 || code[0] != 0x01 {
+		t.Errorf("expected the system call to deploy its code at %s, got %x", created.Hex(), code)
+	}
+}
@@ -172,7 +172,7 @@ func (st *StateTransition) buyGas() error {
 	st.gas += st.msg.Gas()
 
 	st.initialGas = st.msg.Gas()
-	st.state.SubBalance(st.msg.From(), mgval, st.firehoseContext, firehose.BalanceChangeReason("gas_buy"))
+	st.state.SubBalance(st.msg.From(), mgval, st.firehoseContext, firehose.GasBuyBalanceChangeReason)
 	return nil
 }
 
@@ -207,7 +207,7 @@ func (st *StateTransition) TransitionDb() (ret []byte, usedGas uint64, failed bo
 	if err != nil {
 		return nil, 0, false, err
 	}
-	if err = st.useGas(gas, firehose.GasChangeReason("intrinsic_gas")); err != nil {
+	if err = st.useGas(gas, firehose.IntrinsicGasChangeReason); err != nil {
 		return nil, 0, false, err
 	}
 
@@ -235,7 +235,7 @@ func (st *StateTransition) TransitionDb() (ret []byte, usedGas uint64, failed bo
 		}
 	}
 	st.refundGas()
-	st.state.AddBalance(st.evm.Coinbase, new(big.Int).Mul(new(big.Int).SetUint64(st.gasUsed()), st.gasPrice), false, st.firehoseContext, firehose.BalanceChangeReason("reward_transaction_fee"))
+	st.state.AddBalance(st.evm.Coinbase, new(big.Int).Mul(new(big.Int).SetUint64(st.gasUsed()), st.gasPrice), false, st.firehoseContext, firehose.RewardTransactionFeeBalanceChangeReason)
 
 	return ret, st.gasUsed(), vmerr != nil, err
 }
@@ -246,11 +246,16 @@ func (st *StateTransition) refundGas() {
 	if refund > st.state.GetRefund() {
 		refund = st.state.GetRefund()
 	}
+
+	if st.firehoseContext.Enabled() {
+		// London fork not active in this branch yet, when it's the case, record the refund capped to a fifth of the used gas under `firehose.EIP3529RefundCapRule` (and remove this comment)
+		st.firehoseContext.RecordTrxGasRefund(st.gas, st.state.GetRefund(), refund, firehose.HalfGasUsedRefundCapRule)
+	}
 	st.gas += refund
 
 	// Return ETH for remaining gas, exchanged at the original rate.
 	remaining := new(big.Int).Mul(new(big.Int).SetUint64(st.gas), st.gasPrice)
-	st.state.AddBalance(st.msg.From(), remaining, false, st.firehoseContext, firehose.BalanceChangeReason("gas_refund"))
+	st.state.AddBalance(st.msg.From(), remaining, false, st.firehoseContext, firehose.GasRefundBalanceChangeReason)
 
 	// Also return remaining gas to the block gas counter so it is
 	// available for the next transaction.
@@ -0,0 +1,51 @@
+package core
+
+import (
+	"bytes"
+	"sort"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/firehose"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// recordStateProofs emits the Merkle proofs of the accounts configured in
+// `firehose.StateProofAccounts`, and of their selected storage slots, against the block's
+// post state root. It must be called once the block is finalized (rewards applied), the
+// accounts being proven ordered by address. A proof that can't be built is logged and
+// skipped.
+func recordStateProofs(ctx *firehose.Context, statedb *state.StateDB, deleteEmptyObjects bool) {
+	root := statedb.IntermediateRoot(deleteEmptyObjects)
+
+	addresses := make([]common.Address, 0, len(firehose.StateProofAccounts))
+	for addr := range firehose.StateProofAccounts {
+		addresses = append(addresses, addr)
+	}
+	sort.Slice(addresses, func(i, j int) bool {
+		return bytes.Compare(addresses[i][:], addresses[j][:]) <= -1
+	})
+
+	for _, addr := range addresses {
+		proof, err := statedb.GetProof(addr)
+		if err != nil {
+			log.Error("Unable to build Firehose account proof", "address", addr, "root", root, "err", err)
+			continue
+		}
+		ctx.RecordAccountProof(root, addr, proof)
+
+		// The account proof proves the absence of the account, and so of its storage
+		if !statedb.Exist(addr) {
+			continue
+		}
+
+		for _, key := range firehose.StateProofAccounts[addr] {
+			proof, err := statedb.GetStorageProof(addr, key)
+			if err != nil {
+				log.Error("Unable to build Firehose storage proof", "address", addr, "key", key, "root", root, "err", err)
+				continue
+			}
+			ctx.RecordStorageProof(addr, key, statedb.GetState(addr, key), proof)
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package core
+
+import (
+	"encoding/json"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethdb/memorydb"
+	"github.com/ethereum/go-ethereum/firehose"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/trie"
+)
+
+func TestRecordStateProofs(t *testing.T) {
+	defer func(accounts map[common.Address][]common.Hash) { firehose.StateProofAccounts = accounts }(firehose.StateProofAccounts)
+
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(rawdb.NewMemoryDatabase()))
+	statedb.AddBalance(common.Address{1}, big.NewInt(100), false, firehose.NoOpContext, firehose.IgnoredBalanceChangeReason)
+	statedb.SetState(common.Address{1}, common.Hash{1}, common.Hash{2}, firehose.NoOpContext)
+	statedb.AddBalance(common.Address{2}, big.NewInt(200), false, firehose.NoOpContext, firehose.IgnoredBalanceChangeReason)
+
+	firehose.StateProofAccounts = map[common.Address][]common.Hash{
+		common.Address{1}: {{1}},
+		// Missing account, the proof proves its absence and its slots are skipped
+		common.Address{3}: {{1}},
+	}
+
+	ctx := firehose.NewSpeculativeExecutionContext(1024 * 1024)
+	recordStateProofs(ctx, statedb, true)
+	root := statedb.IntermediateRoot(true)
+
+	verify := func(root common.Hash, key []byte, encodedProof string) []byte {
+		var proof []hexutil.Bytes
+		if err := json.Unmarshal([]byte(encodedProof), &proof); err != nil {
+			t.Fatalf("invalid proof %q: %s", encodedProof, err)
+		}
+
+		db := memorydb.New()
+		for _, node := range proof {
+			db.Put(crypto.Keccak256(node), node)
+		}
+
+		value, _, err := trie.VerifyProof(root, crypto.Keccak256(key), db)
+		if err != nil {
+			t.Fatalf("invalid proof of %x: %s", key, err)
+		}
+		return value
+	}
+
+	lines := strings.Split(strings.TrimSpace(string(ctx.FirehoseLog())), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("expected 3 proofs, got:\n%s", strings.Join(lines, "\n"))
+	}
+
+	// Account 1 is proven along its slot, its storage root being the one of its proven leaf
+	fields := strings.Split(lines[0], " ")
+	if fields[1] != "ACCOUNT_PROOF" || fields[3] != firehose.Hash(root) || fields[4] != firehose.Addr(common.Address{1}) {
+		t.Fatalf("unexpected account proof %q", lines[0])
+	}
+
+	var account state.Account
+	if err := rlp.DecodeBytes(verify(root, common.Address{1}.Bytes(), fields[5]), &account); err != nil {
+		t.Fatal(err)
+	}
+	if account.Balance.Cmp(big.NewInt(100)) != 0 {
+		t.Errorf("unexpected proven balance %s", account.Balance)
+	}
+
+	fields = strings.Split(lines[1], " ")
+	if fields[1] != "STORAGE_PROOF" || fields[4] != firehose.Hash(common.Hash{1}) || fields[5] != firehose.Hash(common.Hash{2}) {
+		t.Fatalf("unexpected storage proof %q", lines[1])
+	}
+
+	value, _ := rlp.EncodeToBytes(common.TrimLeftZeroes(common.Hash{2}.Bytes()))
+	if proven := verify(account.Root, common.Hash{1}.Bytes(), fields[6]); string(proven) != string(value) {
+		t.Errorf("unexpected proven slot value %x", proven)
+	}
+
+	// Account 3 doesn't exist, its proof proves the absence
+	fields = strings.Split(lines[2], " ")
+	if fields[1] != "ACCOUNT_PROOF" || fields[4] != firehose.Addr(common.Address{3}) {
+		t.Fatalf("unexpected account proof %q", lines[2])
+	}
+	if value := verify(root, common.Address{3}.Bytes(), fields[5]); value != nil {
+		t.Errorf("expected absence proof, got value %x", value)
+	}
+}
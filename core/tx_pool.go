@@ -626,6 +626,10 @@ func (pool *TxPool) add(tx *types.Transaction, local bool, firehoseContext *fire
 			pool.all.Remove(old.Hash())
 			pool.priced.Removed(1)
 			pendingReplaceMeter.Mark(1)
+
+			if firehoseContext.Enabled() {
+				firehoseContext.RecordTrxPoolReplaced(from, old, tx)
+			}
 		}
 		pool.all.Add(tx)
 		pool.priced.Put(tx)
@@ -635,10 +639,17 @@ func (pool *TxPool) add(tx *types.Transaction, local bool, firehoseContext *fire
 		return old != nil, nil
 	}
 	// New transaction isn't replacing a pending one, push into queue
+	var queued *types.Transaction
+	if list := pool.queue[from]; list != nil && firehoseContext.Enabled() {
+		queued = list.txs.Get(tx.Nonce())
+	}
 	replaced, err = pool.enqueueTx(hash, tx)
 	if err != nil {
 		return false, err
 	}
+	if replaced && queued != nil {
+		firehoseContext.RecordTrxPoolReplaced(from, queued, tx)
+	}
 	// Mark local addresses and journal local transactions
 	if local {
 		if !pool.locals.contains(from) {
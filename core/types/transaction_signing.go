@@ -223,12 +223,25 @@ func (fs FrontierSigner) Sender(tx *Transaction) (common.Address, error) {
 }
 
 func recoverPlain(tx *Transaction, sighash common.Hash, R, S, Vb *big.Int, homestead bool) (common.Address, error) {
+	pub, err := recoverPubkey(sighash, R, S, Vb, homestead)
+	if err != nil {
+		return common.Address{}, err
+	}
+
+	var addr common.Address
+	copy(addr[:], crypto.Keccak256(pub[1:])[12:])
+
+	return addr, nil
+}
+
+// recoverPubkey recovers the uncompressed public key (0x04 prefixed) from the signature values.
+func recoverPubkey(sighash common.Hash, R, S, Vb *big.Int, homestead bool) ([]byte, error) {
 	if Vb.BitLen() > 8 {
-		return common.Address{}, ErrInvalidSig
+		return nil, ErrInvalidSig
 	}
 	V := byte(Vb.Uint64() - 27)
 	if !crypto.ValidateSignatureValues(V, R, S, homestead) {
-		return common.Address{}, ErrInvalidSig
+		return nil, ErrInvalidSig
 	}
 	// encode the signature in uncompressed format
 	r, s := R.Bytes(), S.Bytes()
@@ -239,16 +252,45 @@ func recoverPlain(tx *Transaction, sighash common.Hash, R, S, Vb *big.Int, homes
 	// recover the public key from the signature
 	pub, err := crypto.Ecrecover(sighash[:], sig)
 	if err != nil {
-		return common.Address{}, err
+		return nil, err
 	}
 	if len(pub) == 0 || pub[0] != 4 {
-		return common.Address{}, errors.New("invalid public key")
+		return nil, errors.New("invalid public key")
 	}
+	return pub, nil
+}
 
-	var addr common.Address
-	copy(addr[:], crypto.Keccak256(pub[1:])[12:])
-
-	return addr, nil
+// SenderPubkey returns the 64 bytes uncompressed public key (without its 0x04 prefix)
+// of the transaction's sender, recovered from its signature using the signer's rules.
+//
+// Unlike Sender, the result is not cached, each call performs the recovery.
+func SenderPubkey(signer Signer, tx *Transaction) ([]byte, error) {
+	var (
+		pub []byte
+		err error
+	)
+	switch signer := signer.(type) {
+	case EIP155Signer:
+		if !tx.Protected() {
+			return SenderPubkey(HomesteadSigner{}, tx)
+		}
+		if tx.ChainId().Cmp(signer.chainId) != 0 {
+			return nil, ErrInvalidChainId
+		}
+		V := new(big.Int).Sub(tx.data.V, signer.chainIdMul)
+		V.Sub(V, big8)
+		pub, err = recoverPubkey(signer.Hash(tx), tx.data.R, tx.data.S, V, true)
+	case HomesteadSigner:
+		pub, err = recoverPubkey(signer.Hash(tx), tx.data.R, tx.data.S, tx.data.V, true)
+	case FrontierSigner:
+		pub, err = recoverPubkey(signer.Hash(tx), tx.data.R, tx.data.S, tx.data.V, false)
+	default:
+		return nil, fmt.Errorf("unsupported signer %T", signer)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return pub[1:], nil
 }
 
 // deriveChainId derives the chain id from the given v parameter
@@ -17,6 +17,7 @@
 package types
 
 import (
+	"bytes"
 	"math/big"
 	"testing"
 
@@ -136,3 +137,23 @@ func TestChainId(t *testing.T) {
 		t.Error("expected no error")
 	}
 }
+
+func TestSenderPubkey(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	pubkey := crypto.FromECDSAPub(&key.PublicKey)[1:]
+
+	for _, signer := range []Signer{NewEIP155Signer(big.NewInt(18)), HomesteadSigner{}, FrontierSigner{}} {
+		tx, err := SignTx(NewTransaction(0, common.Address{}, new(big.Int), 0, new(big.Int), nil), signer, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		recovered, err := SenderPubkey(signer, tx)
+		if err != nil {
+			t.Fatalf("%T: %v", signer, err)
+		}
+		if !bytes.Equal(recovered, pubkey) {
+			t.Errorf("%T: pubkey mismatch, got %x want %x", signer, recovered, pubkey)
+		}
+	}
+}
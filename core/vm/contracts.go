@@ -81,7 +81,7 @@ var PrecompiledContractsIstanbul = map[common.Address]PrecompiledContract{
 // RunPrecompiledContract runs and evaluates the output of a precompiled contract.
 func RunPrecompiledContract(p PrecompiledContract, input []byte, contract *Contract, firehoseContext *firehose.Context) (ret []byte, err error) {
 	gas := p.RequiredGas(input)
-	if contract.UseGas(gas, firehose.GasChangeReason("precompiled_contract")) {
+	if contract.UseGas(gas, firehose.PrecompiledContractGasChangeReason) {
 		return p.Run(input)
 	}
 	return nil, ErrOutOfGas
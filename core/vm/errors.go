@@ -16,7 +16,12 @@
 
 package vm
 
-import "errors"
+import (
+	"errors"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/firehose"
+)
 
 // List execution errors
 var (
@@ -28,3 +33,50 @@ var (
 	ErrContractAddressCollision = errors.New("contract address collision")
 	ErrNoCompatibleInterpreter  = errors.New("no compatible interpreter")
 )
+
+// Firehose additions
+
+var errorToCallFailureCodeMap = map[error]firehose.CallFailureCode{
+	ErrOutOfGas:                  firehose.CallFailureCode("out_of_gas"),
+	ErrCodeStoreOutOfGas:         firehose.CallFailureCode("code_store_out_of_gas"),
+	ErrDepth:                     firehose.CallFailureCode("max_call_depth_exceeded"),
+	ErrInsufficientBalance:       firehose.CallFailureCode("insufficient_balance"),
+	ErrContractAddressCollision:  firehose.CallFailureCode("contract_address_collision"),
+	errWriteProtection:           firehose.CallFailureCode("write_protection"),
+	errReturnDataOutOfBounds:     firehose.CallFailureCode("return_data_out_of_bounds"),
+	errExecutionReverted:         firehose.CallFailureCode("execution_reverted"),
+	errMaxCodeSizeExceeded:       firehose.CallFailureCode("max_code_size_exceeded"),
+	errInvalidJump:               firehose.CallFailureCode("invalid_jump"),
+	errGasUintOverflow:           firehose.CallFailureCode("gas_uint_overflow"),
+	errBadPairingInput:           firehose.CallFailureCode("precompile_failure"),
+	errBlake2FInvalidInputLength: firehose.CallFailureCode("precompile_failure"),
+	errBlake2FInvalidFinalFlag:   firehose.CallFailureCode("precompile_failure"),
+}
+
+// The interpreter creates those errors dynamically through `fmt.Errorf`, so we have no
+// choice but to match them on their (stable) prefix.
+var errorPrefixToCallFailureCode = []struct {
+	prefix string
+	code   firehose.CallFailureCode
+}{
+	{"invalid opcode", firehose.CallFailureCode("invalid_opcode")},
+	{"stack underflow", firehose.CallFailureCode("stack_underflow")},
+	{"stack limit reached", firehose.CallFailureCode("stack_overflow")},
+}
+
+// ErrorToCallFailureCode maps an execution error to its stable Firehose failure code so
+// that consumers do not depend on the error message wording that changes between versions.
+func ErrorToCallFailureCode(err error) firehose.CallFailureCode {
+	if code, found := errorToCallFailureCodeMap[err]; found {
+		return code
+	}
+
+	message := err.Error()
+	for _, candidate := range errorPrefixToCallFailureCode {
+		if strings.HasPrefix(message, candidate.prefix) {
+			return candidate.code
+		}
+	}
+
+	return firehose.UnknownCallFailureCode
+}
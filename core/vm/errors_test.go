@@ -0,0 +1,46 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package vm
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/firehose"
+)
+
+func TestErrorToCallFailureCode(t *testing.T) {
+	tests := []struct {
+		err  error
+		want firehose.CallFailureCode
+	}{
+		{ErrOutOfGas, firehose.CallFailureCode("out_of_gas")},
+		{ErrDepth, firehose.CallFailureCode("max_call_depth_exceeded")},
+		{errWriteProtection, firehose.CallFailureCode("write_protection")},
+		{errExecutionReverted, firehose.CallFailureCode("execution_reverted")},
+		{fmt.Errorf("invalid opcode 0x%x", 0xfe), firehose.CallFailureCode("invalid_opcode")},
+		{fmt.Errorf("stack underflow (%d <=> %d)", 0, 2), firehose.CallFailureCode("stack_underflow")},
+		{fmt.Errorf("stack limit reached %d (%d)", 1025, 1024), firehose.CallFailureCode("stack_overflow")},
+		{errors.New("something else"), firehose.UnknownCallFailureCode},
+	}
+	for i, test := range tests {
+		if have := ErrorToCallFailureCode(test.err); have != test.want {
+			t.Errorf("test %d (%v): have %q, want %q", i, test.err, have, test.want)
+		}
+	}
+}
@@ -201,13 +201,13 @@ func (evm *EVM) Interpreter() Interpreter {
 // execution error or failed value transfer.
 func (evm *EVM) Call(caller ContractRef, addr common.Address, input []byte, gas uint64, value *big.Int) (ret []byte, leftOverGas uint64, err error) {
 	if evm.firehoseContext.Enabled() {
-		evm.firehoseContext.StartCall("CALL")
+		evm.firehoseContext.StartCall("CALL", gas, callerGasRemaining(caller))
 		evm.firehoseContext.RecordCallParams("CALL", caller.Address(), addr, value, gas, input)
 	}
 
 	if evm.vmConfig.NoRecursion && evm.depth > 0 {
 		if evm.firehoseContext.Enabled() {
-			evm.firehoseContext.EndFailedCall(gas, true, ErrDepth.Error())
+			evm.firehoseContext.EndFailedCall(gas, true, ErrorToCallFailureCode(ErrDepth), ErrDepth.Error())
 		}
 
 		return nil, gas, nil
@@ -216,7 +216,7 @@ func (evm *EVM) Call(caller ContractRef, addr common.Address, input []byte, gas
 	// Fail if we're trying to execute above the call depth limit
 	if evm.depth > int(params.CallCreateDepth) {
 		if evm.firehoseContext.Enabled() {
-			evm.firehoseContext.EndFailedCall(gas, true, ErrDepth.Error())
+			evm.firehoseContext.EndFailedCall(gas, true, ErrorToCallFailureCode(ErrDepth), ErrDepth.Error())
 		}
 
 		return nil, gas, ErrDepth
@@ -224,7 +224,7 @@ func (evm *EVM) Call(caller ContractRef, addr common.Address, input []byte, gas
 	// Fail if we're trying to transfer more than the available balance
 	if !evm.Context.CanTransfer(evm.StateDB, caller.Address(), value) {
 		if evm.firehoseContext.Enabled() {
-			evm.firehoseContext.EndFailedCall(gas, true, ErrInsufficientBalance.Error())
+			evm.firehoseContext.EndFailedCall(gas, true, ErrorToCallFailureCode(ErrInsufficientBalance), ErrInsufficientBalance.Error())
 		}
 
 		return nil, gas, ErrInsufficientBalance
@@ -287,7 +287,7 @@ func (evm *EVM) Call(caller ContractRef, addr common.Address, input []byte, gas
 	// when we're in homestead this also counts for code storage gas errors.
 	if err != nil {
 		if evm.firehoseContext.Enabled() {
-			evm.firehoseContext.RecordCallFailed(contract.Gas, err.Error())
+			evm.firehoseContext.RecordCallFailed(contract.Gas, ErrorToCallFailureCode(err), err.Error())
 		}
 
 		evm.StateDB.RevertToSnapshot(snapshot)
@@ -295,7 +295,7 @@ func (evm *EVM) Call(caller ContractRef, addr common.Address, input []byte, gas
 			contract.UseGas(contract.Gas, firehose.FailedExecutionGasChangeReason)
 		} else {
 			if evm.firehoseContext.Enabled() {
-				evm.firehoseContext.RecordCallReverted()
+				evm.firehoseContext.RecordCallReverted(ret)
 			}
 		}
 	}
@@ -316,13 +316,13 @@ func (evm *EVM) Call(caller ContractRef, addr common.Address, input []byte, gas
 // code with the caller as context.
 func (evm *EVM) CallCode(caller ContractRef, addr common.Address, input []byte, gas uint64, value *big.Int) (ret []byte, leftOverGas uint64, err error) {
 	if evm.firehoseContext.Enabled() {
-		evm.firehoseContext.StartCall("CALLCODE")
+		evm.firehoseContext.StartCall("CALLCODE", gas, callerGasRemaining(caller))
 		evm.firehoseContext.RecordCallParams("CALLCODE", caller.Address(), addr, value, gas, input)
 	}
 
 	if evm.vmConfig.NoRecursion && evm.depth > 0 {
 		if evm.firehoseContext.Enabled() {
-			evm.firehoseContext.EndFailedCall(gas, true, ErrDepth.Error())
+			evm.firehoseContext.EndFailedCall(gas, true, ErrorToCallFailureCode(ErrDepth), ErrDepth.Error())
 		}
 
 		return nil, gas, nil
@@ -330,7 +330,7 @@ func (evm *EVM) CallCode(caller ContractRef, addr common.Address, input []byte,
 	// Fail if we're trying to execute above the call depth limit
 	if evm.depth > int(params.CallCreateDepth) {
 		if evm.firehoseContext.Enabled() {
-			evm.firehoseContext.EndFailedCall(gas, true, ErrDepth.Error())
+			evm.firehoseContext.EndFailedCall(gas, true, ErrorToCallFailureCode(ErrDepth), ErrDepth.Error())
 		}
 
 		return nil, gas, ErrDepth
@@ -338,7 +338,7 @@ func (evm *EVM) CallCode(caller ContractRef, addr common.Address, input []byte,
 	// Fail if we're trying to transfer more than the available balance
 	if !evm.CanTransfer(evm.StateDB, caller.Address(), value) {
 		if evm.firehoseContext.Enabled() {
-			evm.firehoseContext.EndFailedCall(gas, true, ErrInsufficientBalance.Error())
+			evm.firehoseContext.EndFailedCall(gas, true, ErrorToCallFailureCode(ErrInsufficientBalance), ErrInsufficientBalance.Error())
 		}
 
 		return nil, gas, ErrInsufficientBalance
@@ -356,7 +356,7 @@ func (evm *EVM) CallCode(caller ContractRef, addr common.Address, input []byte,
 	ret, err = run(evm, contract, input, false)
 	if err != nil {
 		if evm.firehoseContext.Enabled() {
-			evm.firehoseContext.RecordCallFailed(contract.Gas, err.Error())
+			evm.firehoseContext.RecordCallFailed(contract.Gas, ErrorToCallFailureCode(err), err.Error())
 		}
 
 		evm.StateDB.RevertToSnapshot(snapshot)
@@ -364,7 +364,7 @@ func (evm *EVM) CallCode(caller ContractRef, addr common.Address, input []byte,
 			contract.UseGas(contract.Gas, firehose.FailedExecutionGasChangeReason)
 		} else {
 			if evm.firehoseContext.Enabled() {
-				evm.firehoseContext.RecordCallReverted()
+				evm.firehoseContext.RecordCallReverted(ret)
 			}
 		}
 	}
@@ -383,7 +383,7 @@ func (evm *EVM) CallCode(caller ContractRef, addr common.Address, input []byte,
 // code with the caller as context and the caller is set to the caller of the caller.
 func (evm *EVM) DelegateCall(caller ContractRef, addr common.Address, input []byte, gas uint64) (ret []byte, leftOverGas uint64, err error) {
 	if evm.firehoseContext.Enabled() {
-		evm.firehoseContext.StartCall("DELEGATE")
+		evm.firehoseContext.StartCall("DELEGATE", gas, callerGasRemaining(caller))
 
 		// Firehose a Delegate Call is quite different then a standard Call or event Call Code
 		// because it executes using the state of the parent call. Assumuming a contract that
@@ -405,7 +405,7 @@ func (evm *EVM) DelegateCall(caller ContractRef, addr common.Address, input []by
 
 	if evm.vmConfig.NoRecursion && evm.depth > 0 {
 		if evm.firehoseContext.Enabled() {
-			evm.firehoseContext.EndFailedCall(gas, true, ErrDepth.Error())
+			evm.firehoseContext.EndFailedCall(gas, true, ErrorToCallFailureCode(ErrDepth), ErrDepth.Error())
 		}
 
 		return nil, gas, nil
@@ -413,7 +413,7 @@ func (evm *EVM) DelegateCall(caller ContractRef, addr common.Address, input []by
 	// Fail if we're trying to execute above the call depth limit
 	if evm.depth > int(params.CallCreateDepth) {
 		if evm.firehoseContext.Enabled() {
-			evm.firehoseContext.EndFailedCall(gas, true, ErrDepth.Error())
+			evm.firehoseContext.EndFailedCall(gas, true, ErrorToCallFailureCode(ErrDepth), ErrDepth.Error())
 		}
 
 		return nil, gas, ErrDepth
@@ -431,7 +431,7 @@ func (evm *EVM) DelegateCall(caller ContractRef, addr common.Address, input []by
 	ret, err = run(evm, contract, input, false)
 	if err != nil {
 		if evm.firehoseContext.Enabled() {
-			evm.firehoseContext.RecordCallFailed(contract.Gas, err.Error())
+			evm.firehoseContext.RecordCallFailed(contract.Gas, ErrorToCallFailureCode(err), err.Error())
 		}
 
 		evm.StateDB.RevertToSnapshot(snapshot)
@@ -439,7 +439,7 @@ func (evm *EVM) DelegateCall(caller ContractRef, addr common.Address, input []by
 			contract.UseGas(contract.Gas, firehose.FailedExecutionGasChangeReason)
 		} else {
 			if evm.firehoseContext.Enabled() {
-				evm.firehoseContext.RecordCallReverted()
+				evm.firehoseContext.RecordCallReverted(ret)
 			}
 		}
 	}
@@ -457,13 +457,13 @@ func (evm *EVM) DelegateCall(caller ContractRef, addr common.Address, input []by
 // instead of performing the modifications.
 func (evm *EVM) StaticCall(caller ContractRef, addr common.Address, input []byte, gas uint64) (ret []byte, leftOverGas uint64, err error) {
 	if evm.firehoseContext.Enabled() {
-		evm.firehoseContext.StartCall("STATIC")
+		evm.firehoseContext.StartCall("STATIC", gas, callerGasRemaining(caller))
 		evm.firehoseContext.RecordCallParams("STATIC", caller.Address(), addr, firehose.EmptyValue, gas, input)
 	}
 
 	if evm.vmConfig.NoRecursion && evm.depth > 0 {
 		if evm.firehoseContext.Enabled() {
-			evm.firehoseContext.EndFailedCall(gas, true, ErrDepth.Error())
+			evm.firehoseContext.EndFailedCall(gas, true, ErrorToCallFailureCode(ErrDepth), ErrDepth.Error())
 		}
 
 		return nil, gas, nil
@@ -471,7 +471,7 @@ func (evm *EVM) StaticCall(caller ContractRef, addr common.Address, input []byte
 	// Fail if we're trying to execute above the call depth limit
 	if evm.depth > int(params.CallCreateDepth) {
 		if evm.firehoseContext.Enabled() {
-			evm.firehoseContext.EndFailedCall(gas, true, ErrDepth.Error())
+			evm.firehoseContext.EndFailedCall(gas, true, ErrorToCallFailureCode(ErrDepth), ErrDepth.Error())
 		}
 
 		return nil, gas, ErrDepth
@@ -511,7 +511,7 @@ func (evm *EVM) StaticCall(caller ContractRef, addr common.Address, input []byte
 	ret, err = run(evm, contract, input, true)
 	if err != nil {
 		if evm.firehoseContext.Enabled() {
-			evm.firehoseContext.RecordCallFailed(contract.Gas, err.Error())
+			evm.firehoseContext.RecordCallFailed(contract.Gas, ErrorToCallFailureCode(err), err.Error())
 		}
 
 		evm.StateDB.RevertToSnapshot(snapshot)
@@ -519,7 +519,7 @@ func (evm *EVM) StaticCall(caller ContractRef, addr common.Address, input []byte
 			contract.UseGas(contract.Gas, firehose.FailedExecutionGasChangeReason)
 		} else {
 			if evm.firehoseContext.Enabled() {
-				evm.firehoseContext.RecordCallReverted()
+				evm.firehoseContext.RecordCallReverted(ret)
 			}
 		}
 	}
@@ -546,7 +546,7 @@ func (c *codeAndHash) Hash() common.Hash {
 // create creates a new contract using code as deployment code.
 func (evm *EVM) create(caller ContractRef, codeAndHash *codeAndHash, gas uint64, value *big.Int, address common.Address) ([]byte, common.Address, uint64, error) {
 	if evm.firehoseContext.Enabled() {
-		evm.firehoseContext.StartCall("CREATE")
+		evm.firehoseContext.StartCall("CREATE", gas, callerGasRemaining(caller))
 		evm.firehoseContext.RecordCallParams("CREATE", caller.Address(), address, value, gas, nil)
 	}
 
@@ -554,14 +554,14 @@ func (evm *EVM) create(caller ContractRef, codeAndHash *codeAndHash, gas uint64,
 	// limit.
 	if evm.depth > int(params.CallCreateDepth) {
 		if evm.firehoseContext.Enabled() {
-			evm.firehoseContext.EndFailedCall(gas, true, ErrDepth.Error())
+			evm.firehoseContext.EndFailedCall(gas, true, ErrorToCallFailureCode(ErrDepth), ErrDepth.Error())
 		}
 
 		return nil, common.Address{}, gas, ErrDepth
 	}
 	if !evm.CanTransfer(evm.StateDB, caller.Address(), value) {
 		if evm.firehoseContext.Enabled() {
-			evm.firehoseContext.EndFailedCall(gas, true, ErrInsufficientBalance.Error())
+			evm.firehoseContext.EndFailedCall(gas, true, ErrorToCallFailureCode(ErrInsufficientBalance), ErrInsufficientBalance.Error())
 		}
 
 		return nil, common.Address{}, gas, ErrInsufficientBalance
@@ -579,7 +579,7 @@ func (evm *EVM) create(caller ContractRef, codeAndHash *codeAndHash, gas uint64,
 			// reasons we usually see but with an actual assertion failure which burns the remaining gas that
 			// was allowed to the creation. Hence why we have an `EndFailedCall` and using `false` to show
 			// the call is **not** reverted.
-			evm.firehoseContext.EndFailedCall(gas, false, ErrContractAddressCollision.Error())
+			evm.firehoseContext.EndFailedCall(gas, false, ErrorToCallFailureCode(ErrContractAddressCollision), ErrContractAddressCollision.Error())
 		}
 
 		return nil, common.Address{}, 0, ErrContractAddressCollision
@@ -601,7 +601,7 @@ func (evm *EVM) create(caller ContractRef, codeAndHash *codeAndHash, gas uint64,
 
 	if evm.vmConfig.NoRecursion && evm.depth > 0 {
 		if evm.firehoseContext.Enabled() {
-			evm.firehoseContext.EndFailedCall(gas, true, ErrDepth.Error())
+			evm.firehoseContext.EndFailedCall(gas, true, ErrorToCallFailureCode(ErrDepth), ErrDepth.Error())
 		}
 
 		return nil, address, gas, nil
@@ -623,7 +623,7 @@ func (evm *EVM) create(caller ContractRef, codeAndHash *codeAndHash, gas uint64,
 	if err == nil && !maxCodeSizeExceeded {
 		createDataGas := uint64(len(ret)) * params.CreateDataGas
 
-		if contract.UseGas(createDataGas, firehose.GasChangeReason("code_storage")) {
+		if contract.UseGas(createDataGas, firehose.CodeStorageGasChangeReason) {
 			evm.StateDB.SetCode(address, ret, evm.firehoseContext)
 		} else {
 			err = ErrCodeStoreOutOfGas
@@ -638,9 +638,9 @@ func (evm *EVM) create(caller ContractRef, codeAndHash *codeAndHash, gas uint64,
 
 		if evm.firehoseContext.Enabled() {
 			if err != nil {
-				evm.firehoseContext.RecordCallFailed(contract.Gas, err.Error())
+				evm.firehoseContext.RecordCallFailed(contract.Gas, ErrorToCallFailureCode(err), err.Error())
 			} else {
-				evm.firehoseContext.RecordCallFailed(contract.Gas, errMaxCodeSizeExceeded.Error())
+				evm.firehoseContext.RecordCallFailed(contract.Gas, ErrorToCallFailureCode(errMaxCodeSizeExceeded), errMaxCodeSizeExceeded.Error())
 			}
 		}
 
@@ -648,7 +648,7 @@ func (evm *EVM) create(caller ContractRef, codeAndHash *codeAndHash, gas uint64,
 			contract.UseGas(contract.Gas, firehose.FailedExecutionGasChangeReason)
 		} else {
 			if evm.firehoseContext.Enabled() {
-				evm.firehoseContext.RecordCallReverted()
+				evm.firehoseContext.RecordCallReverted(ret)
 			}
 		}
 	}
@@ -685,3 +685,15 @@ func (evm *EVM) Create2(caller ContractRef, code []byte, gas uint64, endowment *
 
 // ChainConfig returns the environment's chain configuration
 func (evm *EVM) ChainConfig() *params.ChainConfig { return evm.chainConfig }
+
+// Firehose additions
+
+// callerGasRemaining returns the gas left in the caller's frame, the call's gas having already
+// been deducted from it, or 0 when the caller is not a contract (i.e. the root call).
+func callerGasRemaining(caller ContractRef) uint64 {
+	if contract, ok := caller.(*Contract); ok {
+		return contract.Gas
+	}
+
+	return 0
+}
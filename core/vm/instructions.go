@@ -417,7 +417,11 @@ func opAddress(pc *uint64, interpreter *EVMInterpreter, contract *Contract, memo
 
 func opBalance(pc *uint64, interpreter *EVMInterpreter, contract *Contract, memory *Memory, stack *Stack) ([]byte, error) {
 	slot := stack.peek()
-	slot.Set(interpreter.evm.StateDB.GetBalance(common.BigToAddress(slot)))
+	address := common.BigToAddress(slot)
+	if interpreter.evm.firehoseContext.Enabled() {
+		interpreter.evm.firehoseContext.RecordAddressAccess(address)
+	}
+	slot.Set(interpreter.evm.StateDB.GetBalance(address))
 	return nil, nil
 }
 
@@ -483,7 +487,11 @@ func opReturnDataCopy(pc *uint64, interpreter *EVMInterpreter, contract *Contrac
 
 func opExtCodeSize(pc *uint64, interpreter *EVMInterpreter, contract *Contract, memory *Memory, stack *Stack) ([]byte, error) {
 	slot := stack.peek()
-	slot.SetUint64(uint64(interpreter.evm.StateDB.GetCodeSize(common.BigToAddress(slot))))
+	address := common.BigToAddress(slot)
+	if interpreter.evm.firehoseContext.Enabled() {
+		interpreter.evm.firehoseContext.RecordAddressAccess(address)
+	}
+	slot.SetUint64(uint64(interpreter.evm.StateDB.GetCodeSize(address)))
 
 	return nil, nil
 }
@@ -515,6 +523,9 @@ func opExtCodeCopy(pc *uint64, interpreter *EVMInterpreter, contract *Contract,
 		codeOffset = stack.pop()
 		length     = stack.pop()
 	)
+	if interpreter.evm.firehoseContext.Enabled() {
+		interpreter.evm.firehoseContext.RecordAddressAccess(addr)
+	}
 	codeCopy := getDataBig(interpreter.evm.StateDB.GetCode(addr), codeOffset, length)
 	memory.Set(memOffset.Uint64(), length.Uint64(), codeCopy)
 
@@ -551,6 +562,9 @@ func opExtCodeCopy(pc *uint64, interpreter *EVMInterpreter, contract *Contract,
 func opExtCodeHash(pc *uint64, interpreter *EVMInterpreter, contract *Contract, memory *Memory, stack *Stack) ([]byte, error) {
 	slot := stack.peek()
 	address := common.BigToAddress(slot)
+	if interpreter.evm.firehoseContext.Enabled() {
+		interpreter.evm.firehoseContext.RecordAddressAccess(address)
+	}
 	if interpreter.evm.StateDB.Empty(address) {
 		slot.SetUint64(0)
 	} else {
@@ -568,11 +582,17 @@ func opBlockhash(pc *uint64, interpreter *EVMInterpreter, contract *Contract, me
 	num := stack.pop()
 
 	n := interpreter.intPool.get().Sub(interpreter.evm.BlockNumber, common.Big257)
+	var hash common.Hash
 	if num.Cmp(n) > 0 && num.Cmp(interpreter.evm.BlockNumber) < 0 {
-		stack.push(interpreter.evm.GetHash(num.Uint64()).Big())
+		hash = interpreter.evm.GetHash(num.Uint64())
+		stack.push(hash.Big())
 	} else {
 		stack.push(interpreter.intPool.getZero())
 	}
+
+	if interpreter.evm.firehoseContext.Enabled() && firehose.BlockHashReadsEnabled {
+		interpreter.evm.firehoseContext.RecordBlockHashRead(num, hash)
+	}
 	interpreter.intPool.put(num, n)
 	return nil, nil
 }
@@ -632,6 +652,9 @@ func opMstore8(pc *uint64, interpreter *EVMInterpreter, contract *Contract, memo
 
 func opSload(pc *uint64, interpreter *EVMInterpreter, contract *Contract, memory *Memory, stack *Stack) ([]byte, error) {
 	loc := stack.peek()
+	if interpreter.evm.firehoseContext.Enabled() {
+		interpreter.evm.firehoseContext.RecordStorageAccess(contract.Address(), common.BigToHash(loc))
+	}
 	val := interpreter.evm.StateDB.GetState(contract.Address(), common.BigToHash(loc))
 	loc.SetBytes(val.Bytes())
 	return nil, nil
@@ -640,6 +663,9 @@ func opSload(pc *uint64, interpreter *EVMInterpreter, contract *Contract, memory
 func opSstore(pc *uint64, interpreter *EVMInterpreter, contract *Contract, memory *Memory, stack *Stack) ([]byte, error) {
 	loc := common.BigToHash(stack.pop())
 	val := stack.pop()
+	if interpreter.evm.firehoseContext.Enabled() {
+		interpreter.evm.firehoseContext.RecordStorageAccess(contract.Address(), loc)
+	}
 	interpreter.evm.StateDB.SetState(contract.Address(), loc, common.BigToHash(val), interpreter.evm.firehoseContext)
 
 	interpreter.intPool.put(val)
@@ -702,7 +728,7 @@ func opCreate(pc *uint64, interpreter *EVMInterpreter, contract *Contract, memor
 		gas -= gas / 64
 	}
 
-	contract.UseGas(gas, firehose.GasChangeReason("contract_creation"))
+	contract.UseGas(gas, firehose.ContractCreationGasChangeReason)
 	res, addr, returnGas, suberr := interpreter.evm.Create(contract, input, gas, value)
 
 	// Push item on the stack based on the returned error. If the ruleset is
@@ -740,7 +766,7 @@ func opCreate2(pc *uint64, interpreter *EVMInterpreter, contract *Contract, memo
 
 	// Apply EIP150
 	gas -= gas / 64
-	contract.UseGas(gas, firehose.GasChangeReason("contract_creation2"))
+	contract.UseGas(gas, firehose.ContractCreation2GasChangeReason)
 	res, addr, returnGas, suberr := interpreter.evm.Create2(contract, input, gas, endowment, salt)
 
 	// Push item on the stack based on the returned error.
@@ -908,8 +934,12 @@ func opStop(pc *uint64, interpreter *EVMInterpreter, contract *Contract, memory
 }
 
 func opSuicide(pc *uint64, interpreter *EVMInterpreter, contract *Contract, memory *Memory, stack *Stack) ([]byte, error) {
+	beneficiary := common.BigToAddress(stack.pop())
+	if interpreter.evm.firehoseContext.Enabled() {
+		interpreter.evm.firehoseContext.RecordAddressAccess(beneficiary)
+	}
 	balance := interpreter.evm.StateDB.GetBalance(contract.Address())
-	interpreter.evm.StateDB.AddBalance(common.BigToAddress(stack.pop()), balance, false, interpreter.evm.firehoseContext, firehose.BalanceChangeReason("suicide_refund"))
+	interpreter.evm.StateDB.AddBalance(beneficiary, balance, false, interpreter.evm.firehoseContext, firehose.SuicideRefundBalanceChangeReason)
 
 	interpreter.evm.StateDB.Suicide(contract.Address(), interpreter.evm.firehoseContext)
 	return nil, nil
@@ -195,6 +195,13 @@ func (in *EVMInterpreter) Run(contract *Contract, input []byte, readOnly bool) (
 			}
 		}()
 	}
+	if in.evm.firehoseContext.Enabled() {
+		defer func() {
+			if err != nil {
+				in.evm.firehoseContext.RecordCallFailureSite(pc, byte(op))
+			}
+		}()
+	}
 	// The Interpreter main run loop (contextual). This loop runs until either an
 	// explicit STOP, RETURN or SELFDESTRUCT is executed, an error occurred during
 	// the execution of one of the operations or until the done flag is set by the
@@ -282,6 +289,10 @@ func (in *EVMInterpreter) Run(contract *Contract, input []byte, readOnly bool) (
 					in.evm.firehoseContext.RecordGasConsume(contract.Gas+cost, cost, gasChangeReason)
 				}
 			}
+
+			if firehose.SlowTransactionThreshold > 0 {
+				in.evm.firehoseContext.RecordExecutionStep(contract.Gas)
+			}
 		}
 
 		if memorySize > 0 {
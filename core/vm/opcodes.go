@@ -550,24 +550,24 @@ func StringToOp(str string) OpCode {
 // Firehose additions
 
 var opCodeToGasChangeReasonMap = map[OpCode]firehose.GasChangeReason{
-	CREATE:         firehose.GasChangeReason("contract_creation"),
-	CREATE2:        firehose.GasChangeReason("contract_creation2"),
-	CALL:           firehose.GasChangeReason("call"),
-	STATICCALL:     firehose.GasChangeReason("static_call"),
-	CALLCODE:       firehose.GasChangeReason("call_code"),
-	DELEGATECALL:   firehose.GasChangeReason("delegate_call"),
-	RETURN:         firehose.GasChangeReason("return"),
-	REVERT:         firehose.GasChangeReason("revert"),
-	LOG0:           firehose.GasChangeReason("event_log"),
-	LOG1:           firehose.GasChangeReason("event_log"),
-	LOG2:           firehose.GasChangeReason("event_log"),
-	LOG3:           firehose.GasChangeReason("event_log"),
-	LOG4:           firehose.GasChangeReason("event_log"),
-	SELFDESTRUCT:   firehose.GasChangeReason("self_destruct"),
-	CALLDATACOPY:   firehose.GasChangeReason("call_data_copy"),
-	CODECOPY:       firehose.GasChangeReason("code_copy"),
-	EXTCODECOPY:    firehose.GasChangeReason("ext_code_copy"),
-	RETURNDATACOPY: firehose.GasChangeReason("return_data_copy"),
+	CREATE:         firehose.ContractCreationGasChangeReason,
+	CREATE2:        firehose.ContractCreation2GasChangeReason,
+	CALL:           firehose.CallGasChangeReason,
+	STATICCALL:     firehose.StaticCallGasChangeReason,
+	CALLCODE:       firehose.CallCodeGasChangeReason,
+	DELEGATECALL:   firehose.DelegateCallGasChangeReason,
+	RETURN:         firehose.ReturnGasChangeReason,
+	REVERT:         firehose.RevertGasChangeReason,
+	LOG0:           firehose.EventLogGasChangeReason,
+	LOG1:           firehose.EventLogGasChangeReason,
+	LOG2:           firehose.EventLogGasChangeReason,
+	LOG3:           firehose.EventLogGasChangeReason,
+	LOG4:           firehose.EventLogGasChangeReason,
+	SELFDESTRUCT:   firehose.SelfDestructGasChangeReason,
+	CALLDATACOPY:   firehose.CallDataCopyGasChangeReason,
+	CODECOPY:       firehose.CodeCopyGasChangeReason,
+	EXTCODECOPY:    firehose.ExtCodeCopyGasChangeReason,
+	RETURNDATACOPY: firehose.ReturnDataCopyGasChangeReason,
 }
 
 // We only track a few high costs op code that gives a rough idea where gas is spent
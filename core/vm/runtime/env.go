@@ -20,7 +20,6 @@ import (
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core"
 	"github.com/ethereum/go-ethereum/core/vm"
-	"github.com/ethereum/go-ethereum/firehose"
 )
 
 func NewEnv(cfg *Config) *vm.EVM {
@@ -38,5 +37,5 @@ func NewEnv(cfg *Config) *vm.EVM {
 		GasPrice:    cfg.GasPrice,
 	}
 
-	return vm.NewEVM(context, cfg.State, cfg.ChainConfig, cfg.EVMConfig, firehose.NoOpContext)
+	return vm.NewEVM(context, cfg.State, cfg.ChainConfig, cfg.EVMConfig, cfg.Firehose)
 }
@@ -47,6 +47,10 @@ type Config struct {
 
 	State     *state.StateDB
 	GetHashFn func(n uint64) common.Hash
+
+	// Firehose records the execution when set, the caller being responsible for framing it
+	// in a block and a transaction.
+	Firehose *firehose.Context
 }
 
 // sets defaults on the config
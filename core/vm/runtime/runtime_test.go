@@ -17,6 +17,7 @@
 package runtime
 
 import (
+	"bytes"
 	"math/big"
 	"strings"
 	"testing"
@@ -25,6 +26,7 @@ import (
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/rawdb"
 	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/core/vm"
 	"github.com/ethereum/go-ethereum/firehose"
 	"github.com/ethereum/go-ethereum/params"
@@ -118,6 +120,43 @@ func TestCall(t *testing.T) {
 	}
 }
 
+func TestCallFirehose(t *testing.T) {
+	state, _ := state.New(common.Hash{}, state.NewDatabase(rawdb.NewMemoryDatabase()))
+	address := common.HexToAddress("0x0a")
+	state.SetCode(address, []byte{
+		byte(vm.PUSH1), 1,
+		byte(vm.PUSH1), 0,
+		byte(vm.SSTORE),
+	}, firehose.NoOpContext)
+
+	output := &bytes.Buffer{}
+	firehoseContext := firehose.NewContext(firehose.NewDelegateToWriterPrinter(output))
+
+	block := types.NewBlockWithHeader(&types.Header{Number: big.NewInt(1)})
+	firehoseContext.StartBlock(block)
+	firehoseContext.StartTransaction(types.NewTransaction(0, address, new(big.Int), 100000, new(big.Int), nil), 0, nil)
+	firehoseContext.RecordTrxFrom(common.Address{}, nil)
+
+	if _, _, err := Call(address, nil, &Config{State: state, GasLimit: 100000, Firehose: firehoseContext}); err != nil {
+		t.Fatal("didn't expect error", err)
+	}
+
+	firehoseContext.EndTransaction(&types.Receipt{Status: types.ReceiptStatusSuccessful})
+	firehoseContext.FinalizeBlock(block)
+	firehoseContext.EndBlock(block, block.Difficulty())
+
+	for _, event := range []string{"FIRE EVM_RUN_CALL CALL", "FIRE STORAGE_CHANGE", "FIRE EVM_END_CALL"} {
+		if !strings.Contains(output.String(), event) {
+			t.Errorf("expected %s event in instrumentation, got:\n%s", event, output.String())
+		}
+	}
+
+	report, err := firehose.Check(strings.NewReader(output.String()))
+	if err != nil || !report.Valid() {
+		t.Fatalf("expected instrumentation to be valid, got %v (%v)", report.Violations, err)
+	}
+}
+
 func BenchmarkCall(b *testing.B) {
 	var definition = `[{"constant":true,"inputs":[],"name":"seller","outputs":[{"name":"","type":"address"}],"type":"function"},{"constant":false,"inputs":[],"name":"abort","outputs":[],"type":"function"},{"constant":true,"inputs":[],"name":"value","outputs":[{"name":"","type":"uint256"}],"type":"function"},{"constant":false,"inputs":[],"name":"refund","outputs":[],"type":"function"},{"constant":true,"inputs":[],"name":"buyer","outputs":[{"name":"","type":"address"}],"type":"function"},{"constant":false,"inputs":[],"name":"confirmReceived","outputs":[],"type":"function"},{"constant":true,"inputs":[],"name":"state","outputs":[{"name":"","type":"uint8"}],"type":"function"},{"constant":false,"inputs":[],"name":"confirmPurchase","outputs":[],"type":"function"},{"inputs":[],"type":"constructor"},{"anonymous":false,"inputs":[],"name":"Aborted","type":"event"},{"anonymous":false,"inputs":[],"name":"PurchaseConfirmed","type":"event"},{"anonymous":false,"inputs":[],"name":"ItemReceived","type":"event"},{"anonymous":false,"inputs":[],"name":"Refunded","type":"event"}]`
 
@@ -34,6 +34,7 @@ import (
 	"github.com/ethereum/go-ethereum/core/rawdb"
 	"github.com/ethereum/go-ethereum/core/state"
 	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/firehose"
 	"github.com/ethereum/go-ethereum/internal/ethapi"
 	"github.com/ethereum/go-ethereum/rlp"
 	"github.com/ethereum/go-ethereum/rpc"
@@ -205,6 +206,20 @@ func (api *PrivateAdminAPI) ExportChain(file string, first *uint64, last *uint64
 	return true, nil
 }
 
+// SetFirehoseAnnotation attaches the operator-defined annotation `key` with `value` to the
+// Firehose BEGIN_BLOCK of the blocks processed from now on, an empty `value` removes it.
+func (api *PrivateAdminAPI) SetFirehoseAnnotation(key string, value string) (bool, error) {
+	if err := firehose.SetBlockAnnotation(key, value); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// FirehoseAnnotations returns the annotations attached to the Firehose BEGIN_BLOCK.
+func (api *PrivateAdminAPI) FirehoseAnnotations() map[string]string {
+	return firehose.BlockAnnotations()
+}
+
 func hasAllBlocks(chain *core.BlockChain, bs []*types.Block) bool {
 	for _, b := range bs {
 		if !chain.HasBlock(b.Hash(), b.NumberU64()) {
@@ -187,7 +187,7 @@ func (pm *ProtocolManager) synchronise(peer *peer) {
 		// process all transactions. It should probably be adapter so that speculative execution
 		// node could use fast sync which is not the case here.
 		if mode != downloader.FullSync {
-			log.Warn("Firehose changed syncing mode to 'full', it is required for proper extraction of the data when enabling Firehose instrumentation through --firehose-enabled", "old", mode, "new", downloader.FullSync)
+			log.Warn("Firehose changed syncing mode to 'full', it is required for proper extraction of the data when enabling Firehose instrumentation through --firehose.enabled", "old", mode, "new", downloader.FullSync)
 		}
 
 		mode = downloader.FullSync
@@ -79,6 +79,8 @@ type Database struct {
 	quitLock sync.Mutex      // Mutex protecting the quit channel access
 	quitChan chan chan error // Quit channel to stop the metrics collection before closing the database
 
+	maintenanceQuit chan struct{} // Quit channel to stop the maintenance compactions, nil when disabled
+
 	log log.Logger // Contextual logger tracking the database path
 }
 
@@ -103,6 +105,7 @@ func New(file string, cache int, handles int, namespace string) (*Database, erro
 		Filter:                 filter.NewBloomFilter(10),
 		DisableSeeksCompaction: true,
 	}
+	tuning.apply(opts)
 
 	db, err := leveldb.OpenFile(file, opts)
 	if _, corrupted := err.(*errors.ErrCorrupted); corrupted {
@@ -131,8 +134,12 @@ func New(file string, cache int, handles int, namespace string) (*Database, erro
 	ldb.nonlevel0CompGauge = metrics.NewRegisteredGauge(namespace+"compact/nonlevel0", nil)
 	ldb.seekCompGauge = metrics.NewRegisteredGauge(namespace+"compact/seek", nil)
 
-	// Start up the metrics gathering and return
+	// Start up the metrics gathering and the maintenance compactions, if any, and return
 	go ldb.meter(metricsGatheringInterval)
+	if tuning.MaintenanceWindow != nil {
+		ldb.maintenanceQuit = make(chan struct{})
+		go ldb.maintain(tuning.MaintenanceWindow, ldb.maintenanceQuit)
+	}
 	return ldb, nil
 }
 
@@ -150,6 +157,10 @@ func (db *Database) Close() error {
 		}
 		db.quitChan = nil
 	}
+	if db.maintenanceQuit != nil {
+		close(db.maintenanceQuit)
+		db.maintenanceQuit = nil
+	}
 	return db.db.Close()
 }
 
@@ -0,0 +1,140 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+//go:build !js
+// +build !js
+
+package leveldb
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/syndtr/goleveldb/leveldb/opt"
+)
+
+// maintenanceCheckInterval specifies how often the database checks if it entered its
+// maintenance compaction window.
+const maintenanceCheckInterval = time.Minute
+
+// Tuning holds the operator provided LevelDB tuning knobs, the zero value of a knob
+// keeps the default value derived from the allocated cache.
+type Tuning struct {
+	WriteBuffer            int // Size in megabytes of the write buffer (memtable)
+	CompactionTableSize    int // Size in megabytes of the tables produced by compactions
+	CompactionL0Trigger    int // Amount of level-0 tables triggering a compaction
+	WriteL0SlowdownTrigger int // Amount of level-0 tables from which writes are throttled
+	WriteL0PauseTrigger    int // Amount of level-0 tables from which writes are paused
+
+	// MaintenanceWindow is the daily window during which a full manual compaction of the
+	// database is run, outside of sync-critical hours. Disabled when empty.
+	MaintenanceWindow *Window
+}
+
+// Window is a daily time window, expressed as offsets from midnight (local time). A window
+// ending before it starts spans over midnight.
+type Window struct {
+	Start time.Duration
+	End   time.Duration
+}
+
+// ParseWindow parses a daily time window in the `HH:MM-HH:MM` format.
+func ParseWindow(in string) (*Window, error) {
+	var startHour, startMinute, endHour, endMinute int
+	if _, err := fmt.Sscanf(in, "%d:%d-%d:%d", &startHour, &startMinute, &endHour, &endMinute); err != nil {
+		return nil, fmt.Errorf("invalid window %q, expected HH:MM-HH:MM: %w", in, err)
+	}
+
+	for _, hour := range []int{startHour, endHour} {
+		if hour < 0 || hour > 23 {
+			return nil, fmt.Errorf("invalid window %q, hours must be between 0 and 23", in)
+		}
+	}
+	for _, minute := range []int{startMinute, endMinute} {
+		if minute < 0 || minute > 59 {
+			return nil, fmt.Errorf("invalid window %q, minutes must be between 0 and 59", in)
+		}
+	}
+
+	return &Window{
+		Start: time.Duration(startHour)*time.Hour + time.Duration(startMinute)*time.Minute,
+		End:   time.Duration(endHour)*time.Hour + time.Duration(endMinute)*time.Minute,
+	}, nil
+}
+
+// Contains returns `true` if the given time is within the window.
+func (w *Window) Contains(t time.Time) bool {
+	offset := time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute
+	if w.Start <= w.End {
+		return offset >= w.Start && offset < w.End
+	}
+	return offset >= w.Start || offset < w.End
+}
+
+// tuning is applied to all databases opened after it has been set.
+var tuning Tuning
+
+// SetTuning configures the tuning knobs applied to the databases opened afterwards.
+func SetTuning(t Tuning) {
+	tuning = t
+}
+
+func (t Tuning) apply(opts *opt.Options) {
+	if t.WriteBuffer > 0 {
+		opts.WriteBuffer = t.WriteBuffer * opt.MiB
+	}
+	if t.CompactionTableSize > 0 {
+		opts.CompactionTableSize = t.CompactionTableSize * opt.MiB
+	}
+	if t.CompactionL0Trigger > 0 {
+		opts.CompactionL0Trigger = t.CompactionL0Trigger
+	}
+	if t.WriteL0SlowdownTrigger > 0 {
+		opts.WriteL0SlowdownTrigger = t.WriteL0SlowdownTrigger
+	}
+	if t.WriteL0PauseTrigger > 0 {
+		opts.WriteL0PauseTrigger = t.WriteL0PauseTrigger
+	}
+}
+
+// maintain runs a full compaction of the database once per maintenance window.
+func (db *Database) maintain(window *Window, quit chan struct{}) {
+	ticker := time.NewTicker(maintenanceCheckInterval)
+	defer ticker.Stop()
+
+	var lastRun time.Time
+	for {
+		select {
+		case now := <-ticker.C:
+			// A window lasts at most a day, a run in the last 24 hours means this window was already served
+			if !window.Contains(now) || now.Sub(lastRun) < 24*time.Hour {
+				continue
+			}
+
+			lastRun = now
+			start := time.Now()
+			db.log.Info("Running maintenance database compaction")
+			if err := db.Compact(nil, nil); err != nil {
+				db.log.Error("Maintenance database compaction failed", "err", err)
+				continue
+			}
+			db.log.Info("Maintenance database compaction done", "elapsed", time.Since(start))
+
+		case <-quit:
+			return
+		}
+	}
+}
@@ -0,0 +1,57 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package leveldb
+
+import (
+	"testing"
+	"time"
+)
+
+func TestWindow(t *testing.T) {
+	at := func(hour, minute int) time.Time {
+		return time.Date(2021, 1, 1, hour, minute, 0, 0, time.Local)
+	}
+
+	tests := []struct {
+		window string
+		time   time.Time
+		want   bool
+	}{
+		{"02:00-04:30", at(1, 59), false},
+		{"02:00-04:30", at(2, 0), true},
+		{"02:00-04:30", at(4, 29), true},
+		{"02:00-04:30", at(4, 30), false},
+		{"23:00-01:00", at(23, 30), true},
+		{"23:00-01:00", at(0, 30), true},
+		{"23:00-01:00", at(12, 0), false},
+	}
+	for _, test := range tests {
+		window, err := ParseWindow(test.window)
+		if err != nil {
+			t.Fatalf("window %s: %v", test.window, err)
+		}
+		if have := window.Contains(test.time); have != test.want {
+			t.Errorf("window %s at %s: have %v, want %v", test.window, test.time.Format("15:04"), have, test.want)
+		}
+	}
+
+	for _, invalid := range []string{"", "2-4", "24:00-01:00", "01:60-02:00"} {
+		if _, err := ParseWindow(invalid); err == nil {
+			t.Errorf("window %q: expected an error", invalid)
+		}
+	}
+}
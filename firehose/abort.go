@@ -0,0 +1,65 @@
+package firehose
+
+import (
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// AbortMode tells how an instrumented call execution interrupted before its end (RPC timeout,
+// cancellation, ...) is reported, see `CallAbortMode`.
+type AbortMode string
+
+const (
+	// FailAbortMode fails the request of an aborted execution, its trace is discarded.
+	FailAbortMode AbortMode = "fail"
+
+	// CloseAbortMode closes the trace of an aborted execution through `AbortTransaction` and
+	// returns it, flagged as aborted, along the partial result.
+	CloseAbortMode AbortMode = "close"
+)
+
+// CallAbortMode is the handling of the call instrumentation executions (see
+// `CallInstrumentationEnabled`) aborted before their end.
+var CallAbortMode = FailAbortMode
+
+// ParseAbortMode parses the name of an abort mode.
+func ParseAbortMode(name string) (AbortMode, error) {
+	switch mode := AbortMode(name); mode {
+	case FailAbortMode, CloseAbortMode:
+		return mode, nil
+	default:
+		return "", fmt.Errorf("unknown abort mode %q, valid modes are %q and %q", name, FailAbortMode, CloseAbortMode)
+	}
+}
+
+// AbortTransaction closes the active transaction of an execution interrupted before its end,
+// guaranteeing that every BEGIN has its matching END in the stream. A TRX_ABORTED event giving
+// the `reason` is emitted, then an EVM_END_CALL flagged as aborted for each call still active,
+// innermost first, and finally an END_APPLY_TRX for a failed transaction without gas used nor
+// logs. Nothing is emitted when no transaction is active.
+func (ctx *Context) AbortTransaction(reason string) {
+	if ctx == nil || !ctx.inTransaction.Load() {
+		return
+	}
+	defer ctx.guard()()
+
+	ctx.abortReason = reason
+
+	ctx.print("TRX_ABORTED", reason)
+	for ctx.callIndexStack.Len() > 1 {
+		ctx.printEndCall(0, nil, true)
+	}
+
+	ctx.EndTransaction(&types.Receipt{Status: types.ReceiptStatusFailed})
+}
+
+// AbortReason returns the reason given to `AbortTransaction`, empty if the context never
+// aborted a transaction.
+func (ctx *Context) AbortReason() string {
+	if ctx == nil {
+		return ""
+	}
+
+	return ctx.abortReason
+}
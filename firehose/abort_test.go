@@ -0,0 +1,76 @@
+package firehose
+
+import (
+	"bytes"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+func TestAbortTransaction(t *testing.T) {
+	block := types.NewBlockWithHeader(&types.Header{Number: big.NewInt(1)})
+
+	output := &bytes.Buffer{}
+	ctx := NewContext(NewDelegateToWriterPrinter(output))
+	ctx.StartBlock(block)
+	ctx.StartTransaction(types.NewTransaction(0, common.Address{1}, big.NewInt(0), 100000, big.NewInt(1), nil), 0, nil)
+	ctx.RecordTrxFrom(common.Address{2}, nil)
+	ctx.StartCall("CALL", 100000, 0)
+	ctx.StartCall("CALL", 50000, 40000)
+
+	ctx.AbortTransaction("execution aborted (timeout = 5s)")
+	ctx.FinalizeBlock(block)
+	ctx.EndBlock(block, nil)
+
+	if ctx.AbortReason() != "execution aborted (timeout = 5s)" {
+		t.Errorf("unexpected abort reason %q", ctx.AbortReason())
+	}
+
+	var events []string
+	for _, line := range strings.Split(strings.TrimSpace(output.String()), "\n") {
+		event, fields, _ := splitLine(line)
+		switch event {
+		case "TRX_ABORTED":
+			event += " " + strings.Join(fields, " ")
+		case "EVM_END_CALL":
+			event += " " + fields[0] + " " + fields[len(fields)-1]
+		}
+		events = append(events, event)
+	}
+
+	expected := []string{
+		"BEGIN_BLOCK", "BEGIN_APPLY_TRX", "TRX_FROM", "EVM_RUN_CALL", "EVM_RUN_CALL",
+		"TRX_ABORTED execution aborted (timeout = 5s)", "EVM_END_CALL 2 true", "EVM_END_CALL 1 true",
+		"END_APPLY_TRX", "FINALIZE_BLOCK", "END_BLOCK",
+	}
+	if strings.Join(events, "\n") != strings.Join(expected, "\n") {
+		t.Fatalf("unexpected aborted transaction events, have:\n%s\nwant:\n%s", strings.Join(events, "\n"), strings.Join(expected, "\n"))
+	}
+
+	report, err := Check(strings.NewReader(output.String()))
+	if err != nil || !report.Valid() {
+		t.Fatalf("expected aborted transaction to be balanced, got %v (%v)", report.Violations, err)
+	}
+
+	// Nothing is left to close once the transaction is closed
+	output.Reset()
+	ctx.AbortTransaction("again")
+	if output.Len() != 0 {
+		t.Errorf("expected no events outside of a transaction, got %q", output.String())
+	}
+}
+
+func TestParseAbortMode(t *testing.T) {
+	for _, name := range []string{"fail", "close"} {
+		if mode, err := ParseAbortMode(name); err != nil || string(mode) != name {
+			t.Errorf("expected abort mode %q to be valid, got %q (%v)", name, mode, err)
+		}
+	}
+
+	if _, err := ParseAbortMode("ignore"); err == nil {
+		t.Error("expected unknown abort mode to be rejected")
+	}
+}
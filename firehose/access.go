@@ -0,0 +1,122 @@
+package firehose
+
+import (
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// callAccessSet is the set of unique addresses and storage slots accessed by a call, in
+// access order. The call's own target address is always part of it.
+type callAccessSet struct {
+	Addresses []addressAccess `json:"addresses"`
+	Slots     []slotAccess    `json:"slots"`
+
+	seenAddresses map[common.Address]bool
+	seenSlots     map[storageSlot]bool
+}
+
+type addressAccess struct {
+	Address common.Address `json:"address"`
+	Warm    bool           `json:"warm"`
+}
+
+type slotAccess struct {
+	Address common.Address `json:"address"`
+	Key     common.Hash    `json:"key"`
+	Warm    bool           `json:"warm"`
+}
+
+type storageSlot struct {
+	address common.Address
+	key     common.Hash
+}
+
+// RecordAddressAccess records that the active call accessed the account at `addr` (balance,
+// code, code size or code hash read, self-destruct beneficiary).
+func (ctx *Context) RecordAddressAccess(addr common.Address) {
+	if ctx == nil || !CallAccessSetsEnabled {
+		return
+	}
+	defer ctx.guard()()
+
+	set := ctx.activeCallAccessSet()
+	if set.seenAddresses[addr] {
+		return
+	}
+
+	set.seenAddresses[addr] = true
+	set.Addresses = append(set.Addresses, addressAccess{Address: addr, Warm: ctx.warmAddress(addr)})
+}
+
+// RecordStorageAccess records that the active call accessed the `key` storage slot of `addr`
+// (SLOAD or SSTORE).
+func (ctx *Context) RecordStorageAccess(addr common.Address, key common.Hash) {
+	if ctx == nil || !CallAccessSetsEnabled {
+		return
+	}
+	defer ctx.guard()()
+
+	slot := storageSlot{addr, key}
+
+	set := ctx.activeCallAccessSet()
+	if set.seenSlots[slot] {
+		return
+	}
+
+	if ctx.accessedSlots == nil {
+		ctx.accessedSlots = map[storageSlot]bool{}
+	}
+
+	warm := ctx.accessedSlots[slot]
+	ctx.accessedSlots[slot] = true
+
+	set.seenSlots[slot] = true
+	set.Slots = append(set.Slots, slotAccess{Address: addr, Key: key, Warm: warm})
+}
+
+// warmAddress marks the address as accessed within the transaction and returns `true` if
+// it was already accessed, i.e. if it's warm under EIP-2929 rules.
+func (ctx *Context) warmAddress(addr common.Address) bool {
+	if ctx.accessedAddresses == nil {
+		ctx.accessedAddresses = map[common.Address]bool{}
+	}
+
+	warm := ctx.accessedAddresses[addr]
+	ctx.accessedAddresses[addr] = true
+
+	return warm
+}
+
+func (ctx *Context) activeCallAccessSet() *callAccessSet {
+	index := ctx.callIndex()
+
+	if ctx.callAccessSets == nil {
+		ctx.callAccessSets = map[string]*callAccessSet{}
+	}
+
+	set, found := ctx.callAccessSets[index]
+	if !found {
+		set = &callAccessSet{
+			Addresses:     []addressAccess{},
+			Slots:         []slotAccess{},
+			seenAddresses: map[common.Address]bool{},
+			seenSlots:     map[storageSlot]bool{},
+		}
+		ctx.callAccessSets[index] = set
+	}
+
+	return set
+}
+
+// printCallAccessSet emits the CALL_ACCESS_SET event of the call at `index` being closed.
+func (ctx *Context) printCallAccessSet(index string) {
+	set, found := ctx.callAccessSets[index]
+	if !found {
+		return
+	}
+	delete(ctx.callAccessSets, index)
+
+	ctx.print("CALL_ACCESS_SET",
+		index,
+		JSON(set),
+	)
+}
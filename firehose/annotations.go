@@ -0,0 +1,63 @@
+package firehose
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// blockAnnotations holds the operator-defined key/value annotations attached to every
+// BEGIN_BLOCK, see `SetBlockAnnotation`.
+var blockAnnotations = struct {
+	lock   sync.RWMutex
+	values map[string]string
+}{values: map[string]string{}}
+
+// SetBlockAnnotation attaches the annotation `key` with `value` to the BEGIN_BLOCK of the
+// blocks started from now on, an empty `value` removing it. Annotations carry provenance
+// metadata (datacenter, node role, experiment ID, ...) so that streams multiplexed downstream
+// can be told apart. Keys and values can't contain whitespace, keys can't be empty.
+func SetBlockAnnotation(key string, value string) error {
+	if key == "" {
+		return errors.New("annotation key is empty")
+	}
+	if strings.IndexFunc(key+value, unicode.IsSpace) != -1 {
+		return fmt.Errorf("annotation %q contains whitespace", key+"="+value)
+	}
+
+	blockAnnotations.lock.Lock()
+	defer blockAnnotations.lock.Unlock()
+
+	if value == "" {
+		delete(blockAnnotations.values, key)
+	} else {
+		blockAnnotations.values[key] = value
+	}
+
+	return nil
+}
+
+// ParseBlockAnnotation parses a `key=value` annotation specification.
+func ParseBlockAnnotation(spec string) (key string, value string, err error) {
+	parts := strings.SplitN(spec, "=", 2)
+	if len(parts) != 2 || parts[0] == "" {
+		return "", "", fmt.Errorf("invalid annotation %q, expected key=value", spec)
+	}
+
+	return parts[0], parts[1], nil
+}
+
+// BlockAnnotations returns a copy of the annotations currently attached to BEGIN_BLOCK.
+func BlockAnnotations() map[string]string {
+	blockAnnotations.lock.RLock()
+	defer blockAnnotations.lock.RUnlock()
+
+	annotations := make(map[string]string, len(blockAnnotations.values))
+	for key, value := range blockAnnotations.values {
+		annotations[key] = value
+	}
+
+	return annotations
+}
@@ -0,0 +1,60 @@
+package firehose
+
+import (
+	"bytes"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+func TestBlockAnnotations(t *testing.T) {
+	defer func() {
+		for key := range BlockAnnotations() {
+			SetBlockAnnotation(key, "")
+		}
+	}()
+
+	block := types.NewBlockWithHeader(&types.Header{Number: big.NewInt(1)})
+	beginBlock := func() []string {
+		output := &bytes.Buffer{}
+		NewContext(NewDelegateToWriterPrinter(output)).StartBlock(block)
+
+		_, fields, _ := splitLine(strings.TrimSpace(output.String()))
+		return fields
+	}
+
+	if fields := beginBlock(); len(fields) != 6 {
+		t.Fatalf("expected no annotations field, got %q", fields)
+	}
+
+	key, value, err := ParseBlockAnnotation("datacenter=us-east-1")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if err := SetBlockAnnotation(key, value); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if err := SetBlockAnnotation("role", "primary"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if fields := beginBlock(); len(fields) != 7 || fields[6] != `{"datacenter":"us-east-1","role":"primary"}` {
+		t.Fatalf("expected annotations field, got %q", fields)
+	}
+
+	SetBlockAnnotation("role", "")
+	if fields := beginBlock(); len(fields) != 7 || fields[6] != `{"datacenter":"us-east-1"}` {
+		t.Fatalf("expected removed annotation to be gone, got %q", fields)
+	}
+
+	for _, spec := range []string{"", "novalue", "=value"} {
+		if _, _, err := ParseBlockAnnotation(spec); err == nil {
+			t.Errorf("expected annotation %q to be rejected", spec)
+		}
+	}
+	if err := SetBlockAnnotation("role", "primary node"); err == nil {
+		t.Error("expected annotation with whitespace to be rejected")
+	}
+}
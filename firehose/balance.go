@@ -0,0 +1,58 @@
+package firehose
+
+import (
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// netBalanceChange is the accumulation of the balance changes of an address for a given
+// reason within a call, it's printed as a single BALANCE_CHANGE once the call is left.
+type netBalanceChange struct {
+	callIndex  string
+	addr       common.Address
+	reason     BalanceChangeReason
+	oldBalance *big.Int
+	newBalance *big.Int
+}
+
+// accumulateBalanceChange adds the change to the net change of the (active call, address,
+// reason) tuple. The net change's old balance is the balance before the tuple's first change
+// and its new balance is the old balance plus the sum of the tuple's changes.
+func (ctx *Context) accumulateBalanceChange(addr common.Address, oldBalance, newBalance *big.Int, reason BalanceChangeReason) {
+	callIndex := ctx.callIndex()
+	delta := new(big.Int).Sub(newBalance, oldBalance)
+
+	for _, change := range ctx.netBalanceChanges {
+		if change.callIndex == callIndex && change.addr == addr && change.reason == reason {
+			change.newBalance.Add(change.newBalance, delta)
+			return
+		}
+	}
+
+	ctx.netBalanceChanges = append(ctx.netBalanceChanges, &netBalanceChange{
+		callIndex:  callIndex,
+		addr:       addr,
+		reason:     reason,
+		oldBalance: new(big.Int).Set(oldBalance),
+		newBalance: new(big.Int).Set(newBalance),
+	})
+}
+
+// flushBalanceChanges prints the accumulated net balance changes in the order of their first
+// change. It's called each time the active call changes (and when the transaction or system
+// call ends) so that the changes are printed within their call, before any of its sub-call.
+func (ctx *Context) flushBalanceChanges() {
+	for _, change := range ctx.netBalanceChanges {
+		ctx.print("BALANCE_CHANGE",
+			change.callIndex,
+			Addr(change.addr),
+			BigInt(change.oldBalance),
+			BigInt(change.newBalance),
+			string(change.reason),
+			Uint64(ctx.nextOrdinal()),
+		)
+	}
+
+	ctx.netBalanceChanges = nil
+}
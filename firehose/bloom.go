@@ -0,0 +1,44 @@
+package firehose
+
+import (
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// captureLog keeps the log recorded by `RecordLog` in the active transaction's captured logs
+// when `LogsBloomCheckEnabled` is set.
+func (ctx *Context) captureLog(log *types.Log) {
+	ctx.trxLogs = append(ctx.trxLogs, log)
+}
+
+// markCallLogs remembers the amount of logs captured before the call `index` started, the
+// logs captured from then on being discarded if the call fails.
+func (ctx *Context) markCallLogs(index string) {
+	if ctx.callLogMarks == nil {
+		ctx.callLogMarks = map[string]int{}
+	}
+
+	ctx.callLogMarks[index] = len(ctx.trxLogs)
+}
+
+// discardCallLogs drops the logs captured within the active call and its sub-calls, they are
+// reverted by the state along the failed call.
+func (ctx *Context) discardCallLogs() {
+	if mark, found := ctx.callLogMarks[ctx.activeCallIndex]; found && mark < len(ctx.trxLogs) {
+		ctx.trxLogs = ctx.trxLogs[:mark]
+	}
+}
+
+// verifyLogsBloom computes the bloom of the logs captured within the transaction and reports
+// an invariant violation when it doesn't match the receipt's bloom, which happens when some
+// code path emitting logs is not instrumented.
+func (ctx *Context) verifyLogsBloom(receipt *types.Receipt) {
+	captured := types.BytesToBloom(types.LogsBloom(ctx.trxLogs).Bytes())
+	if captured == receipt.Bloom {
+		return
+	}
+
+	ctx.invariantViolated(fmt.Sprintf("transaction %s receipt bloom does not match the bloom of its %d captured log(s), receipt has %d log(s)",
+		receipt.TxHash.Hex(), len(ctx.trxLogs), len(receipt.Logs)))
+}
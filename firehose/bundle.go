@@ -0,0 +1,92 @@
+package firehose
+
+import (
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// SpeculativeBundle chains the speculative executions of a bundle of transactions, each one
+// executed on the state left by the previous ones, like simulation services do when a
+// transaction's output feeds the next one. The transactions share a single speculative
+// context acting as a pseudo-block: ordinals keep increasing from one transaction to the next,
+// transaction indexes and cumulative gas are tracked by the bundle and the accumulated
+// Firehose log is the one of the whole bundle.
+//
+// The bundle doesn't hold the state, the caller executes the transactions on the same
+// `StateDB`, preparing it for each transaction (`statedb.Prepare`) with the index returned
+// by `NextTransactionIndex` so that log indexes are cumulative too.
+type SpeculativeBundle struct {
+	ctx *Context
+
+	cumulativeGasUsed uint64
+	logEnds           []int
+}
+
+// NewSpeculativeBundle creates a bundle whose accumulated log can't grow past `limitInBytes`
+// (0 means unlimited), see `NewBoundedSpeculativeExecutionContext`.
+func NewSpeculativeBundle(initialAllocationInBytes int, limitInBytes int) *SpeculativeBundle {
+	return &SpeculativeBundle{ctx: NewBoundedSpeculativeExecutionContext(initialAllocationInBytes, limitInBytes)}
+}
+
+// Context returns the context instrumenting the bundle's executions, it's the one to give to
+// the EVM.
+func (b *SpeculativeBundle) Context() *Context {
+	return b.ctx
+}
+
+// NextTransactionIndex is the index, within the bundle, of the next transaction to execute.
+func (b *SpeculativeBundle) NextTransactionIndex() uint {
+	return uint(len(b.logEnds))
+}
+
+// CumulativeGasUsed is the gas used by the transactions of the bundle ended so far.
+func (b *SpeculativeBundle) CumulativeGasUsed() uint64 {
+	return b.cumulativeGasUsed
+}
+
+// StartTransaction starts the next transaction of the bundle, unsigned messages are started
+// through `Context().StartTransactionRaw` with `NextTransactionIndex` as index instead.
+func (b *SpeculativeBundle) StartTransaction(tx *types.Transaction, baseFee *big.Int) {
+	b.ctx.StartTransaction(tx, b.NextTransactionIndex(), baseFee)
+}
+
+// EndTransaction ends the active transaction of the bundle, the receipt's transaction index
+// and cumulative gas used are set to their value within the bundle before being recorded.
+func (b *SpeculativeBundle) EndTransaction(receipt *types.Receipt) {
+	b.cumulativeGasUsed += receipt.GasUsed
+
+	receipt.TransactionIndex = b.NextTransactionIndex()
+	receipt.CumulativeGasUsed = b.cumulativeGasUsed
+	b.ctx.EndTransaction(receipt)
+
+	b.logEnds = append(b.logEnds, b.logLen())
+}
+
+// FirehoseLog returns the Firehose log accumulated by all the transactions of the bundle.
+func (b *SpeculativeBundle) FirehoseLog() []byte {
+	return b.ctx.FirehoseLog()
+}
+
+// TransactionLog returns the part of the bundle's Firehose log emitted by its `index`
+// transaction, `nil` if there is no such ended transaction or if the bundle's buffer limit
+// was exceeded.
+func (b *SpeculativeBundle) TransactionLog(index int) []byte {
+	if index < 0 || index >= len(b.logEnds) || b.ctx.BufferLimitExceeded() {
+		return nil
+	}
+
+	start := 0
+	if index > 0 {
+		start = b.logEnds[index-1]
+	}
+
+	return b.FirehoseLog()[start:b.logEnds[index]]
+}
+
+func (b *SpeculativeBundle) logLen() int {
+	if v, ok := b.ctx.bufferPrinter(); ok {
+		return v.Len()
+	}
+	return 0
+}
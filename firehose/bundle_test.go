@@ -0,0 +1,59 @@
+package firehose
+
+import (
+	"bytes"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+func TestSpeculativeBundle(t *testing.T) {
+	bundle := NewSpeculativeBundle(1024, 0)
+
+	var receipts []*types.Receipt
+	for i := 0; i < 2; i++ {
+		tx := types.NewTransaction(uint64(i), common.Address{1}, big.NewInt(0), 21000, big.NewInt(1), nil)
+
+		bundle.StartTransaction(tx, nil)
+		bundle.Context().RecordTrxFrom(common.Address{2}, nil)
+		bundle.Context().RecordNonceChange(common.Address{2}, uint64(i), uint64(i+1))
+
+		receipt := &types.Receipt{GasUsed: 21000}
+		bundle.EndTransaction(receipt)
+		receipts = append(receipts, receipt)
+	}
+
+	if receipts[1].TransactionIndex != 1 || receipts[1].CumulativeGasUsed != 42000 || bundle.CumulativeGasUsed() != 42000 {
+		t.Errorf("unexpected second receipt index %d and cumulative gas %d", receipts[1].TransactionIndex, receipts[1].CumulativeGasUsed)
+	}
+
+	first, second := bundle.TransactionLog(0), bundle.TransactionLog(1)
+	if !bytes.Equal(append(append([]byte{}, first...), second...), bundle.FirehoseLog()) {
+		t.Fatalf("transaction logs don't add up to the bundle log")
+	}
+	if bundle.TransactionLog(2) != nil {
+		t.Errorf("unexpected log for a transaction not executed")
+	}
+
+	var ordinals []string
+	for _, line := range strings.Split(strings.TrimSpace(string(second)), "\n") {
+		event, fields, _ := splitLine(line)
+		switch event {
+		case "BEGIN_APPLY_TRX":
+			if fields[15] != "1" {
+				t.Errorf("second transaction got index %s, want 1", fields[15])
+			}
+			ordinals = append(ordinals, fields[14])
+		case "END_APPLY_TRX":
+			ordinals = append(ordinals, fields[4])
+		}
+	}
+
+	// The first transaction consumed ordinals 1 to 3, they keep increasing within the bundle
+	if strings.Join(ordinals, " ") != "4 6" {
+		t.Errorf("second transaction got ordinals %v, want [4 6]", ordinals)
+	}
+}
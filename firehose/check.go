@@ -0,0 +1,348 @@
+package firehose
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// CheckViolation is a structural invariant violation found while checking a Firehose log.
+type CheckViolation struct {
+	Line    uint64
+	Message string
+}
+
+func (v CheckViolation) String() string {
+	return fmt.Sprintf("line %d: %s", v.Line, v.Message)
+}
+
+// CheckReport summarizes the result of checking a Firehose log.
+type CheckReport struct {
+	Lines        uint64
+	Blocks       uint64
+	CancelBlocks uint64
+	Transactions uint64
+	Calls        uint64
+	Events       map[string]uint64
+	Violations   []CheckViolation
+}
+
+// Valid returns `true` if no violations were found.
+func (r *CheckReport) Valid() bool {
+	return len(r.Violations) == 0
+}
+
+// Check parses the Firehose log read from `reader` and verifies its structural
+// invariants: blocks, transactions and calls are balanced, ordinals are monotonic
+// within a transaction, sequence numbers (if any) have no gaps nor duplicates and fields
+// expected to be hexadecimal are valid.
+func Check(reader io.Reader) (*CheckReport, error) {
+	checker := &checker{report: &CheckReport{Events: map[string]uint64{}}}
+
+	scanner := bufio.NewScanner(reader)
+	// Some lines (code changes, end block) can be huge, give plenty of room to the scanner
+	scanner.Buffer(make([]byte, 0, 1024*1024), 512*1024*1024)
+
+	for scanner.Scan() {
+		checker.report.Lines++
+		checker.checkLine(scanner.Text())
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("read line %d: %w", checker.report.Lines+1, err)
+	}
+
+	checker.checkEnd()
+
+	return checker.report, nil
+}
+
+type checker struct {
+	report *CheckReport
+
+	inBlock        bool
+	inTransaction  bool
+	inSystemCall   bool
+	seenTrxInBlock bool
+	callDepth      int
+	lastOrdinal    uint64
+	lastSequence   uint64
+	blockEvents    map[string]uint64
+}
+
+func (c *checker) violation(format string, args ...interface{}) {
+	c.report.Violations = append(c.report.Violations, CheckViolation{
+		Line:    c.report.Lines,
+		Message: fmt.Sprintf(format, args...),
+	})
+}
+
+func (c *checker) checkLine(line string) {
+	sequence, _, event, fields, ok := splitTaggedLine(line)
+	if !ok {
+		// Not a Firehose line, other output mixed in the capture is simply ignored
+		return
+	}
+
+	c.report.Events[event]++
+
+	if sequence != "" {
+		c.checkSequence(sequence)
+	}
+
+	schema, found := eventSchemas[event]
+	if !found {
+		c.violation("unknown event %q", event)
+		return
+	}
+
+	if (schema.freeFormTail && len(fields) < schema.fieldCount) || (!schema.freeFormTail && (len(fields) < schema.fieldCount || len(fields) > schema.fieldCount+schema.optionalFieldCount)) {
+		c.violation("event %s expected %d fields, got %d", event, schema.fieldCount, len(fields))
+		return
+	}
+
+	for _, index := range schema.hexFields {
+		if index < len(fields) && !isValidHex(fields[index]) {
+			c.violation("event %s field #%d is not valid hexadecimal", event, index)
+		}
+	}
+
+	if event == "ERROR" {
+		c.violation("instrumentation reported an error: %s", fields[0])
+	}
+
+	if event == "FATAL" {
+		c.violation("instrumentation halted the emission: %s", fields[0])
+	}
+
+	c.checkScopes(event, fields)
+	c.countBlockEvent(event)
+
+	if schema.ordinalField != -1 {
+		c.checkOrdinal(event, fields[schema.ordinalField])
+	}
+}
+
+func (c *checker) checkScopes(event string, fields []string) {
+	switch event {
+	case "BEGIN_BLOCK":
+		if c.inBlock {
+			c.violation("BEGIN_BLOCK while already in a block")
+		}
+		c.inBlock = true
+		c.blockEvents = nil
+
+	case "END_BLOCK":
+		if !c.inBlock {
+			c.violation("END_BLOCK while not in a block")
+		}
+		if c.inTransaction {
+			c.violation("END_BLOCK while a transaction is still active")
+		}
+		if c.inSystemCall {
+			c.violation("END_BLOCK while a system call is still active")
+		}
+		c.report.Blocks++
+		c.inBlock, c.inTransaction, c.inSystemCall, c.seenTrxInBlock, c.callDepth = false, false, false, false, 0
+
+	case "CANCEL_BLOCK":
+		// A block can be cancelled at any point, even before it started, everything is reset
+		c.report.CancelBlocks++
+		c.inBlock, c.inTransaction, c.inSystemCall, c.seenTrxInBlock, c.callDepth = false, false, false, false, 0
+
+	case "RESUME":
+		// A restarted reader is handed the block in progress again, along its sequence numbers
+		c.lastSequence = 0
+
+	case "UNCLE_BLOCK":
+		if !c.inBlock {
+			c.violation("UNCLE_BLOCK while not in a block")
+		}
+		if c.inTransaction || c.inSystemCall {
+			c.violation("UNCLE_BLOCK while a transaction or system call is active")
+		}
+
+	case "ACCOUNT_PROOF", "STORAGE_PROOF":
+		if !c.inBlock {
+			c.violation("%s while not in a block", event)
+		}
+		if c.inTransaction || c.inSystemCall {
+			c.violation("%s while a transaction or system call is active", event)
+		}
+
+	case "BLOCK_PROGRESS":
+		if c.inBlock {
+			c.violation("BLOCK_PROGRESS while in a block")
+		}
+
+	case "FEE_RECIPIENT_CREDIT":
+		if !c.inBlock {
+			c.violation("FEE_RECIPIENT_CREDIT while not in a block")
+		}
+		if c.inTransaction || c.inSystemCall {
+			c.violation("FEE_RECIPIENT_CREDIT while a transaction or system call is active")
+		}
+
+	case "FINALIZE_BLOCK":
+		switch fields[1] {
+		case fullFinalizeMode, skeletonFinalizeMode:
+			if !c.inBlock {
+				c.violation("FINALIZE_BLOCK in %s mode while not in a block", fields[1])
+			}
+			if c.inTransaction || c.inSystemCall {
+				c.violation("FINALIZE_BLOCK while a transaction or system call is active")
+			}
+			if len(fields) > 3 {
+				reported := map[string]uint64{}
+				if err := json.Unmarshal([]byte(fields[3]), &reported); err != nil {
+					c.violation("FINALIZE_BLOCK checkpoint event counts are invalid: %s", err)
+				} else {
+					c.checkCheckpoint(reported)
+				}
+			}
+		case progressFinalizeMode:
+			if c.inBlock {
+				c.violation("FINALIZE_BLOCK in progress mode while in a block")
+			}
+		default:
+			c.violation("FINALIZE_BLOCK with unknown mode %q", fields[1])
+		}
+
+	case "BLOCK_FINALIZED":
+		if c.inBlock {
+			c.violation("BLOCK_FINALIZED while in a block")
+		}
+
+	case "DIVERGENCE":
+		if c.inBlock {
+			c.violation("DIVERGENCE while in a block")
+		}
+
+	case "BEGIN_SYSTEM_CALL":
+		if !c.inBlock {
+			c.violation("BEGIN_SYSTEM_CALL while not in a block")
+		}
+		if c.inTransaction || c.inSystemCall {
+			c.violation("BEGIN_SYSTEM_CALL while already in a transaction or system call")
+		}
+		if c.seenTrxInBlock {
+			c.violation("BEGIN_SYSTEM_CALL after the block's first transaction")
+		}
+		if (fields[4] == "true") != (fields[2] == ".") {
+			c.violation("BEGIN_SYSTEM_CALL creation flag %s inconsistent with target %s", fields[4], fields[2])
+		}
+		c.inSystemCall = true
+		c.lastOrdinal = 0
+
+	case "END_SYSTEM_CALL":
+		if !c.inSystemCall {
+			c.violation("END_SYSTEM_CALL while not in a system call")
+		}
+		if c.callDepth != 0 {
+			c.violation("END_SYSTEM_CALL while %d call(s) are still active", c.callDepth)
+		}
+		c.inSystemCall, c.callDepth = false, 0
+
+	case "BEGIN_APPLY_TRX":
+		if !c.inBlock {
+			c.violation("BEGIN_APPLY_TRX while not in a block")
+		}
+		if c.inTransaction || c.inSystemCall {
+			c.violation("BEGIN_APPLY_TRX while already in a transaction or system call")
+		}
+		c.inTransaction, c.seenTrxInBlock = true, true
+		c.lastOrdinal = 0
+
+	case "END_APPLY_TRX":
+		if !c.inTransaction {
+			c.violation("END_APPLY_TRX while not in a transaction")
+		}
+		if c.callDepth != 0 {
+			c.violation("END_APPLY_TRX while %d call(s) are still active", c.callDepth)
+		}
+		c.report.Transactions++
+		c.inTransaction, c.callDepth = false, 0
+
+	case "TRX_GAS_REFUND":
+		if !c.inTransaction {
+			c.violation("TRX_GAS_REFUND while not in a transaction")
+		}
+		if c.callDepth != 0 {
+			c.violation("TRX_GAS_REFUND while %d call(s) are still active", c.callDepth)
+		}
+		accumulated, _ := strconv.ParseUint(fields[1], 10, 64)
+		if applied, _ := strconv.ParseUint(fields[2], 10, 64); applied > accumulated {
+			c.violation("TRX_GAS_REFUND applied refund %d exceeds the accumulated refund %d", applied, accumulated)
+		}
+
+	case "TRX_REPLACED", "TRX_ABORTED":
+		if !c.inTransaction {
+			c.violation("%s while not in a transaction", event)
+		}
+
+	case "EVM_RUN_CALL":
+		if !c.inTransaction && !c.inSystemCall {
+			c.violation("EVM_RUN_CALL while not in a transaction or system call")
+		}
+		c.callDepth++
+
+	case "EVM_END_CALL":
+		if c.callDepth == 0 {
+			c.violation("EVM_END_CALL without a matching EVM_RUN_CALL")
+		} else {
+			c.callDepth--
+		}
+		c.report.Calls++
+	}
+}
+
+// checkSequence verifies that the sequence number of a line follows the previous one, a gap
+// meaning lines were dropped and a lower or equal number that lines were duplicated.
+func (c *checker) checkSequence(raw string) {
+	sequence, err := strconv.ParseUint(raw, 10, 64)
+	if err != nil {
+		c.violation("invalid sequence number %q", raw)
+		return
+	}
+
+	switch {
+	case c.lastSequence == 0:
+	case sequence <= c.lastSequence:
+		c.violation("sequence number %d repeats previous sequence number %d, lines duplicated", sequence, c.lastSequence)
+	case sequence > c.lastSequence+1:
+		c.violation("sequence number %d follows %d, %d line(s) dropped", sequence, c.lastSequence, sequence-c.lastSequence-1)
+	}
+
+	c.lastSequence = sequence
+}
+
+func (c *checker) checkOrdinal(event string, field string) {
+	ordinal, err := strconv.ParseUint(field, 10, 64)
+	if err != nil {
+		c.violation("event %s ordinal %q is not a valid number", event, field)
+		return
+	}
+
+	// Ordinals are equal in reduced ordinals mode for informational events, they must never decrease
+	if ordinal < c.lastOrdinal {
+		c.violation("event %s ordinal %d is lower than previous ordinal %d", event, ordinal, c.lastOrdinal)
+	}
+
+	c.lastOrdinal = ordinal
+}
+
+func (c *checker) checkEnd() {
+	if c.inTransaction {
+		c.violation("log ended while a transaction is still active")
+	}
+
+	if c.inSystemCall {
+		c.violation("log ended while a system call is still active")
+	}
+
+	if c.inBlock {
+		c.violation("log ended while a block is still active")
+	}
+}
@@ -0,0 +1,134 @@
+package firehose
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCheck(t *testing.T) {
+	validTrx := strings.Join([]string{
+		"FIRE BEGIN_APPLY_TRX " + strings.Repeat("00", 32) + " . . . . . 21000 01 0 . 00 . . 0 1 0 standard",
+		"FIRE TRX_FROM " + strings.Repeat("00", 20),
+		"FIRE EVM_RUN_CALL CALL 1 2 100 0",
+		"FIRE GAS_CHANGE 1 100 50 call 3",
+		"FIRE EVM_END_CALL 1 50 . 4 100 0",
+		"FIRE END_APPLY_TRX 21000 . 21000 00 5 []",
+	}, "\n")
+
+	beginBlock := "FIRE BEGIN_BLOCK 1 " + strings.Repeat("01", 32) + " " + strings.Repeat("00", 32) + " 1000 1 600"
+
+	systemCall := strings.Join([]string{
+		"FIRE BEGIN_SYSTEM_CALL beacon_root " + strings.Repeat("ff", 20) + " " + strings.Repeat("00", 20) + " 1 false",
+		"FIRE EVM_RUN_CALL CALL 1 2 100 0",
+		"FIRE STORAGE_CHANGE 1 " + strings.Repeat("00", 20) + " 01 00 02 3",
+		"FIRE EVM_END_CALL 1 50 . 4 100 0",
+		"FIRE END_SYSTEM_CALL 5",
+	}, "\n")
+
+	tests := []struct {
+		name           string
+		log            string
+		wantViolations []string
+	}{
+		{
+			name: "valid",
+			log:  beginBlock + "\n" + validTrx + "\nFIRE FINALIZE_BLOCK 1 full\nFIRE END_BLOCK 1 100 {}\n",
+		},
+		{
+			name:           "unbalanced call",
+			log:            beginBlock + "\n" + strings.Replace(validTrx, "FIRE EVM_END_CALL 1 50 . 4 100 0\n", "", 1) + "\nFIRE END_BLOCK 1 100 {}\n",
+			wantViolations: []string{"line 6: END_APPLY_TRX while 1 call(s) are still active"},
+		},
+		{
+			name:           "decreasing ordinal",
+			log:            beginBlock + "\n" + strings.Replace(validTrx, "call 3", "call 1", 1) + "\nFIRE END_BLOCK 1 100 {}\n",
+			wantViolations: []string{"line 5: event GAS_CHANGE ordinal 1 is lower than previous ordinal 2"},
+		},
+		{
+			name:           "invalid hex",
+			log:            beginBlock + "\nFIRE END_BLOCK 1 100 {}\nFIRE TRX_FROM zz\n",
+			wantViolations: []string{"line 3: event TRX_FROM field #0 is not valid hexadecimal"},
+		},
+		{
+			name: "system call before transactions",
+			log:  beginBlock + "\n" + systemCall + "\n" + validTrx + "\nFIRE END_BLOCK 1 100 {}\n",
+		},
+		{
+			name:           "system call after transactions",
+			log:            beginBlock + "\n" + validTrx + "\n" + systemCall + "\nFIRE END_BLOCK 1 100 {}\n",
+			wantViolations: []string{"line 8: BEGIN_SYSTEM_CALL after the block's first transaction"},
+		},
+		{
+			name: "creation system call",
+			log:  beginBlock + "\n" + strings.Replace(systemCall, strings.Repeat("00", 20)+" 1 false", ". 1 true", 1) + "\nFIRE END_BLOCK 1 100 {}\n",
+		},
+		{
+			name:           "system call creation flag with target",
+			log:            beginBlock + "\n" + strings.Replace(systemCall, " 1 false", " 1 true", 1) + "\nFIRE END_BLOCK 1 100 {}\n",
+			wantViolations: []string{"line 2: BEGIN_SYSTEM_CALL creation flag true inconsistent with target " + strings.Repeat("00", 20)},
+		},
+		{
+			name: "transaction sender with public key",
+			log:  beginBlock + "\n" + strings.Replace(validTrx, "FIRE TRX_FROM "+strings.Repeat("00", 20), "FIRE TRX_FROM "+strings.Repeat("00", 20)+" "+strings.Repeat("ab", 64), 1) + "\nFIRE END_BLOCK 1 100 {}\n",
+		},
+		{
+			name: "storage change with key preimage",
+			log:  beginBlock + "\n" + strings.Replace(systemCall, " 01 00 02 3", " 01 00 02 3 "+strings.Repeat("cd", 64), 1) + "\nFIRE END_BLOCK 1 100 {}\n",
+		},
+		{
+			name: "block finalized after block",
+			log:  beginBlock + "\nFIRE END_BLOCK 1 100 {}\nFIRE BLOCK_FINALIZED 1 " + strings.Repeat("01", 32) + "\n",
+		},
+		{
+			name:           "block finalized within block",
+			log:            beginBlock + "\nFIRE BLOCK_FINALIZED 0 " + strings.Repeat("00", 32) + "\nFIRE END_BLOCK 1 100 {}\n",
+			wantViolations: []string{"line 2: BLOCK_FINALIZED while in a block"},
+		},
+		{
+			name: "uncle block",
+			log:  beginBlock + "\n" + validTrx + "\nFIRE UNCLE_BLOCK 0 0 " + strings.Repeat("02", 32) + " {\"transactions\":[],\"uncles\":[]}\nFIRE END_BLOCK 1 100 {}\n",
+		},
+		{
+			name:           "uncle block outside block",
+			log:            "FIRE UNCLE_BLOCK 0 0 " + strings.Repeat("02", 32) + " {}\n",
+			wantViolations: []string{"line 1: UNCLE_BLOCK while not in a block"},
+		},
+		{
+			name: "sequence numbers",
+			log:  "FIRE #1 " + beginBlock[len("FIRE "):] + "\nFIRE #2 END_BLOCK 1 100 {}\n",
+		},
+		{
+			name:           "sequence number gap",
+			log:            "FIRE #1 " + beginBlock[len("FIRE "):] + "\nFIRE #3 @42 END_BLOCK 1 100 {}\n",
+			wantViolations: []string{"line 2: sequence number 3 follows 1, 1 line(s) dropped"},
+		},
+		{
+			name:           "sequence number repeated",
+			log:            "FIRE #1 " + beginBlock[len("FIRE "):] + "\nFIRE #1 END_BLOCK 1 100 {}\n",
+			wantViolations: []string{"line 2: sequence number 1 repeats previous sequence number 1, lines duplicated"},
+		},
+		{
+			name:           "unterminated block",
+			log:            beginBlock + "\n",
+			wantViolations: []string{"line 1: log ended while a block is still active"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			report, err := Check(strings.NewReader(test.log))
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			var violations []string
+			for _, violation := range report.Violations {
+				violations = append(violations, violation.String())
+			}
+
+			if strings.Join(violations, "\n") != strings.Join(test.wantViolations, "\n") {
+				t.Errorf("violations mismatch, have:\n%s\nwant:\n%s", strings.Join(violations, "\n"), strings.Join(test.wantViolations, "\n"))
+			}
+		})
+	}
+}
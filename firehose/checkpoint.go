@@ -0,0 +1,87 @@
+package firehose
+
+import (
+	"sort"
+)
+
+// print emits an event through the context's printer, accounting it in the block's ordering
+// checkpoint when `OrderingCheckpointsEnabled` is set.
+func (ctx *Context) print(input ...string) {
+	ctx.countEvent(input[0])
+	ctx.printer.Print(input...)
+}
+
+// countEvent must be called before emitting `event` by other means than `print`, it flushes
+// the pending gas change and accounts the event in the block's ordering checkpoint.
+func (ctx *Context) countEvent(event string) {
+	if ctx.pendingGasChange != nil {
+		ctx.flushGasChange()
+	}
+
+	if OrderingCheckpointsEnabled {
+		if ctx.blockEventCounts == nil {
+			ctx.blockEventCounts = map[string]uint64{}
+		}
+		ctx.blockEventCounts[eventFamily(event)]++
+	}
+}
+
+// mergeCheckpoint accounts the ordinals consumed and events emitted by the transaction
+// context being flushed in the block's ordering checkpoint.
+//
+// Must be called with `flushTxLock` held.
+func (ctx *Context) mergeCheckpoint(txContext *Context) {
+	ctx.flushedOrdinals += txContext.totalOrderingCounter.Load()
+
+	for family, count := range txContext.blockEventCounts {
+		if ctx.blockEventCounts == nil {
+			ctx.blockEventCounts = map[string]uint64{}
+		}
+		ctx.blockEventCounts[family] += count
+	}
+}
+
+// checkpointFields returns the FINALIZE_BLOCK fields of the block's ordering checkpoint: the
+// amount of ordinals consumed by the block, including those of its flushed transactions, and
+// the count of events emitted since BEGIN_BLOCK per family.
+func (ctx *Context) checkpointFields() []string {
+	counts := ctx.blockEventCounts
+	if counts == nil {
+		counts = map[string]uint64{}
+	}
+
+	return []string{Uint64(ctx.totalOrderingCounter.Load() + ctx.flushedOrdinals), JSON(counts)}
+}
+
+// checkCheckpoint compares the per family event counts of an ordering checkpoint against the
+// events received since BEGIN_BLOCK.
+func (c *checker) checkCheckpoint(reported map[string]uint64) {
+	families := make([]string, 0, len(reported)+len(c.blockEvents))
+	for family := range reported {
+		families = append(families, family)
+	}
+	for family := range c.blockEvents {
+		if _, found := reported[family]; !found {
+			families = append(families, family)
+		}
+	}
+	sort.Strings(families)
+
+	for _, family := range families {
+		if reported[family] != c.blockEvents[family] {
+			c.violation("FINALIZE_BLOCK checkpoint reports %d %s event(s), %d received", reported[family], family, c.blockEvents[family])
+		}
+	}
+}
+
+// countBlockEvent accounts an event received within a block for the ordering checkpoint.
+func (c *checker) countBlockEvent(event string) {
+	if !c.inBlock {
+		return
+	}
+
+	if c.blockEvents == nil {
+		c.blockEvents = map[string]uint64{}
+	}
+	c.blockEvents[eventFamily(event)]++
+}
@@ -0,0 +1,120 @@
+package firehose
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/metrics"
+)
+
+var (
+	codeBlobsStoredCounter = metrics.NewRegisteredCounter("firehose/code/blobs/stored", nil)
+	codeBlobsFailedCounter = metrics.NewRegisteredCounter("firehose/code/blobs/failed", nil)
+)
+
+// CodeStore is a content-addressed store of contract code blobs, see `ContentAddressedCodeStore`.
+type CodeStore interface {
+	// Add stores the code under its hash and returns true if the code was not stored yet,
+	// false when it was already. It can be called concurrently.
+	Add(hash common.Hash, code []byte) (bool, error)
+}
+
+// DirectoryCodeStore stores each code blob in its own file of a directory, named by the hex
+// encoded code hash, suited as a sidecar of the Firehose output.
+type DirectoryCodeStore struct {
+	dir string
+
+	lock sync.Mutex
+	seen map[common.Hash]bool
+}
+
+// NewDirectoryCodeStore opens a DirectoryCodeStore on `dir`, creating it if it doesn't exist.
+// The blobs already stored in it from a previous run are kept.
+func NewDirectoryCodeStore(dir string) (*DirectoryCodeStore, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("create code store directory: %w", err)
+	}
+
+	return &DirectoryCodeStore{dir: dir, seen: map[common.Hash]bool{}}, nil
+}
+
+// Add implements `CodeStore`, the blob is written to a temporary file first then renamed so
+// that a blob file is always complete.
+func (s *DirectoryCodeStore) Add(hash common.Hash, code []byte) (bool, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.seen[hash] {
+		return false, nil
+	}
+
+	path := filepath.Join(s.dir, encodeHexString(hash[:]))
+	if _, err := os.Stat(path); err == nil {
+		s.seen[hash] = true
+		return false, nil
+	}
+
+	file, err := ioutil.TempFile(s.dir, ".blob-")
+	if err != nil {
+		return false, fmt.Errorf("create code blob: %w", err)
+	}
+
+	if _, err := file.Write(code); err != nil {
+		file.Close()
+		os.Remove(file.Name())
+		return false, fmt.Errorf("write code blob: %w", err)
+	}
+
+	if err := file.Close(); err != nil {
+		os.Remove(file.Name())
+		return false, fmt.Errorf("close code blob: %w", err)
+	}
+
+	if err := os.Rename(file.Name(), path); err != nil {
+		os.Remove(file.Name())
+		return false, fmt.Errorf("rename code blob: %w", err)
+	}
+
+	s.seen[hash] = true
+	return true, nil
+}
+
+// recordContentAddressedCodeChange emits a CODE_CHANGE_REF without any code bytes, the new
+// code being handed to `ContentAddressedCodeStore`. The first time a code is stored, a
+// CODE_BLOB event announces it. When the store fails, the new code bytes are printed in the
+// CODE_CHANGE_REF like in compact mode so that nothing is lost.
+func (ctx *Context) recordContentAddressedCodeChange(addr common.Address, oldCodeHash, oldCode []byte, newCodeHash common.Hash, newCode []byte) {
+	newCodeAsString := "."
+	if len(newCode) > 0 {
+		added, err := ContentAddressedCodeStore.Add(newCodeHash, newCode)
+		switch {
+		case err != nil:
+			codeBlobsFailedCounter.Inc(1)
+			log.Error("Unable to store code blob, printing it inline", "hash", newCodeHash, "err", err)
+			newCodeAsString = Hex(newCode)
+
+		case added:
+			codeBlobsStoredCounter.Inc(1)
+			ctx.print("CODE_BLOB",
+				Hash(newCodeHash),
+				Uint(uint(len(newCode))),
+			)
+		}
+	}
+
+	ctx.print("CODE_CHANGE_REF",
+		ctx.callIndex(),
+		Addr(addr),
+		Hex(oldCodeHash),
+		Uint(uint(len(oldCode))),
+		Hash(newCodeHash),
+		Uint(uint(len(newCode))),
+		newCodeAsString,
+		Uint64(ctx.informationalOrdinal()),
+	)
+}
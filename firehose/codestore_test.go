@@ -0,0 +1,66 @@
+package firehose
+
+import (
+	"bytes"
+	"io/ioutil"
+	"math/big"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+func TestContentAddressedCodeChanges(t *testing.T) {
+	defer func(store CodeStore) { ContentAddressedCodeStore = store }(ContentAddressedCodeStore)
+
+	dir := t.TempDir()
+	store, err := NewDirectoryCodeStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ContentAddressedCodeStore = store
+
+	code := []byte{0x60, 0x00, 0x60, 0x00}
+	hash := crypto.Keccak256Hash(code)
+
+	output := &bytes.Buffer{}
+	ctx := NewContext(NewDelegateToWriterPrinter(output))
+	ctx.StartTransaction(types.NewTransaction(0, common.Address{1}, big.NewInt(0), 21000, big.NewInt(1), nil), 0, nil)
+	output.Reset()
+	ctx.RecordCodeChange(common.Address{1}, nil, nil, hash, code)
+	ctx.RecordCodeChange(common.Address{2}, nil, nil, hash, code)
+
+	var events []string
+	for _, line := range strings.Split(strings.TrimSpace(output.String()), "\n") {
+		event, fields, _ := splitLine(line)
+		switch event {
+		case "CODE_BLOB":
+			event += " " + strings.Join(fields, " ")
+		case "CODE_CHANGE_REF":
+			event += " " + fields[5] + " " + fields[6]
+		}
+		events = append(events, event)
+	}
+
+	expected := []string{"CODE_BLOB " + Hash(hash) + " 4", "CODE_CHANGE_REF 4 .", "CODE_CHANGE_REF 4 ."}
+	if strings.Join(events, "\n") != strings.Join(expected, "\n") {
+		t.Fatalf("unexpected code change events, have:\n%s\nwant:\n%s", strings.Join(events, "\n"), strings.Join(expected, "\n"))
+	}
+
+	blob, err := ioutil.ReadFile(filepath.Join(dir, Hash(hash)))
+	if err != nil || !bytes.Equal(blob, code) {
+		t.Fatalf("unexpected stored blob %x (%v)", blob, err)
+	}
+
+	// A store reopened on the same directory knows the blobs stored by a previous run
+	reopened, err := NewDirectoryCodeStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if added, err := reopened.Add(hash, code); added || err != nil {
+		t.Errorf("expected blob to be already stored, got %t (%v)", added, err)
+	}
+}
@@ -0,0 +1,126 @@
+package firehose
+
+import (
+	"bytes"
+	"fmt"
+	"runtime"
+	"runtime/debug"
+	"strconv"
+
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/metrics"
+)
+
+var ownershipViolationsCounter = metrics.NewRegisteredCounter("firehose/context/ownership/violations", nil)
+
+// ConcurrencyMode determines how a `Context` deals with events recorded from multiple
+// goroutines, like precompiles or asynchronous paths emitting events from helper goroutines
+// within a transaction. It's selected when the context is created, see
+// `NewContextWithConcurrencyMode`.
+type ConcurrencyMode string
+
+const (
+	// SingleGoroutineMode, the default, performs no synchronization at all, the context must
+	// be used from a single goroutine at a time.
+	SingleGoroutineMode ConcurrencyMode = "single"
+
+	// MutexConcurrencyMode serializes the recording methods of the context through a
+	// (reentrant) mutex, events recorded concurrently are then printed whole, one after the
+	// other, and the context's state (call stack, log index, ...) remains consistent.
+	MutexConcurrencyMode ConcurrencyMode = "mutex"
+
+	// OwnershipConcurrencyMode asserts that the context is only used from the goroutine owning
+	// it, the one that started its active block or transaction (or that's recording an event
+	// while the context is idle). Use from another goroutine is an invariant violation, see
+	// `StrictEnabled`, the offending goroutine's stack being logged as diagnostic. It's meant
+	// to track down the code paths emitting events from helper goroutines.
+	OwnershipConcurrencyMode ConcurrencyMode = "ownership"
+)
+
+// ParseConcurrencyMode returns the ConcurrencyMode named `value`.
+func ParseConcurrencyMode(value string) (ConcurrencyMode, error) {
+	switch mode := ConcurrencyMode(value); mode {
+	case SingleGoroutineMode, MutexConcurrencyMode, OwnershipConcurrencyMode:
+		return mode, nil
+	}
+
+	return "", fmt.Errorf("unknown concurrency mode %q, valid values are %q, %q and %q", value, SingleGoroutineMode, MutexConcurrencyMode, OwnershipConcurrencyMode)
+}
+
+// NewContextWithConcurrencyMode is like `NewContext` but with the given concurrency mode.
+func NewContextWithConcurrencyMode(printer Printer, mode ConcurrencyMode) *Context {
+	ctx := NewContext(printer)
+	ctx.concurrencyMode = mode
+
+	return ctx
+}
+
+var releaseNothing = func() {}
+
+// guard must be called when entering a recording method, the returned function being called
+// when leaving it, typically through `defer ctx.guard()()`. It enforces the context's
+// concurrency mode.
+func (ctx *Context) guard() func() {
+	switch ctx.concurrencyMode {
+	case MutexConcurrencyMode:
+		id := goroutineID()
+		if ctx.guardOwner.Load() != id {
+			ctx.guardLock.Lock()
+			ctx.guardOwner.Store(id)
+		}
+		ctx.guardDepth++
+
+		return ctx.unlockGuard
+
+	case OwnershipConcurrencyMode:
+		id := goroutineID()
+		if !ctx.guardOwner.CAS(0, id) {
+			if owner := ctx.guardOwner.Load(); owner != id {
+				ownershipViolationsCounter.Inc(1)
+				log.Warn("Firehose context used from a goroutine not owning it", "owner", owner, "goroutine", id, "stack", string(debug.Stack()))
+				ctx.invariantViolated(fmt.Sprintf("context owned by goroutine %d used from goroutine %d", owner, id))
+				return releaseNothing
+			}
+		}
+
+		return ctx.releaseOwnership
+	}
+
+	return releaseNothing
+}
+
+func (ctx *Context) unlockGuard() {
+	ctx.guardDepth--
+	if ctx.guardDepth == 0 {
+		ctx.guardOwner.Store(0)
+		ctx.guardLock.Unlock()
+	}
+}
+
+// releaseOwnership gives up the ownership of the context once it's idle, any goroutine can
+// then take it.
+func (ctx *Context) releaseOwnership() {
+	if !ctx.inBlock.Load() && !ctx.inTransaction.Load() {
+		ctx.guardOwner.Store(0)
+	}
+}
+
+var goroutinePrefix = []byte("goroutine ")
+
+// goroutineID returns the identifier of the calling goroutine, parsed from the header of its
+// stack trace ("goroutine 42 [running]:").
+func goroutineID() uint64 {
+	var buf [64]byte
+	header := buf[:runtime.Stack(buf[:], false)]
+	header = bytes.TrimPrefix(header, goroutinePrefix)
+	if i := bytes.IndexByte(header, ' '); i > 0 {
+		header = header[:i]
+	}
+
+	id, err := strconv.ParseUint(string(header), 10, 64)
+	if err != nil {
+		panic(fmt.Errorf("unable to parse goroutine id from %q: %w", buf, err))
+	}
+
+	return id
+}
@@ -0,0 +1,120 @@
+package firehose
+
+import (
+	"math/big"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+func TestMutexConcurrencyMode(t *testing.T) {
+	block := types.NewBlockWithHeader(&types.Header{Number: big.NewInt(1)})
+	ctx := NewContextWithConcurrencyMode(NewToBufferPrinter(1024), MutexConcurrencyMode)
+
+	ctx.StartBlock(block)
+	ctx.StartTransaction(types.NewTransaction(0, common.Address{1}, big.NewInt(0), 100000, big.NewInt(1), nil), 0, nil)
+	ctx.RecordTrxFrom(common.Address{2}, nil)
+	ctx.StartCall("CALL", 100000, 0)
+
+	// Helper goroutines emit events within the active call while it emits its own
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				ctx.RecordLog(&types.Log{Address: common.Address{byte(i)}})
+				ctx.RecordStorageChange(common.Address{byte(i)}, common.Hash{byte(j)}, common.Hash{}, common.Hash{1})
+			}
+		}(i)
+	}
+	for j := 0; j < 50; j++ {
+		ctx.RecordGasConsume(100000, 1, PrecompiledContractGasChangeReason)
+	}
+	wg.Wait()
+
+	ctx.EndCall(0, nil)
+	ctx.EndTransaction(&types.Receipt{GasUsed: 21000})
+	ctx.FinalizeBlock(block)
+	ctx.EndBlock(block, nil)
+
+	var logIndexes []int
+	for _, line := range strings.Split(strings.TrimSpace(string(ctx.FirehoseLog())), "\n") {
+		if event, fields, _ := splitLine(line); event == "ADD_LOG" {
+			index, _ := strconv.Atoi(fields[1])
+			logIndexes = append(logIndexes, index)
+		}
+	}
+
+	sort.Ints(logIndexes)
+	for i, index := range logIndexes {
+		if index != i {
+			t.Fatalf("expected distinct log indexes 0 to %d, got %v", len(logIndexes)-1, logIndexes)
+		}
+	}
+	if len(logIndexes) != 400 {
+		t.Fatalf("expected 400 logs, got %d", len(logIndexes))
+	}
+
+	report, err := Check(strings.NewReader(string(ctx.FirehoseLog())))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Violations) != 0 {
+		t.Errorf("unexpected violations %v", report.Violations)
+	}
+}
+
+func TestOwnershipConcurrencyMode(t *testing.T) {
+	defer func(strict bool) { StrictEnabled = strict }(StrictEnabled)
+	StrictEnabled = false
+
+	ctx := NewContextWithConcurrencyMode(NewToBufferPrinter(1024), OwnershipConcurrencyMode)
+	tx := types.NewTransaction(0, common.Address{1}, big.NewInt(0), 100000, big.NewInt(1), nil)
+
+	runInGoroutine := func(f func()) {
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			f()
+		}()
+		<-done
+	}
+
+	ctx.StartTransaction(tx, 0, nil)
+	ctx.RecordTrxFrom(common.Address{2}, nil)
+	runInGoroutine(func() { ctx.RecordNonceChange(common.Address{2}, 0, 1) })
+	ctx.EndTransaction(&types.Receipt{GasUsed: 21000})
+
+	if !strings.Contains(string(ctx.FirehoseLog()), "FIRE ERROR context owned by goroutine ") {
+		t.Fatalf("expected an ownership violation, got:\n%s", ctx.FirehoseLog())
+	}
+
+	// Once idle, the context can be used by any goroutine
+	ctx.Reset()
+	runInGoroutine(func() {
+		ctx.StartTransaction(tx, 0, nil)
+		ctx.RecordTrxFrom(common.Address{2}, nil)
+		ctx.EndTransaction(&types.Receipt{GasUsed: 21000})
+	})
+	ctx.StartTransaction(tx, 0, nil)
+	ctx.EndTransaction(&types.Receipt{GasUsed: 21000})
+
+	if strings.Count(string(ctx.FirehoseLog()), "FIRE ERROR") != 1 {
+		t.Fatalf("unexpected ownership violation, got:\n%s", ctx.FirehoseLog())
+	}
+}
+
+func TestParseConcurrencyMode(t *testing.T) {
+	if mode, err := ParseConcurrencyMode("mutex"); err != nil || mode != MutexConcurrencyMode {
+		t.Errorf("unexpected result %q (%v)", mode, err)
+	}
+	if _, err := ParseConcurrencyMode("threads"); err == nil {
+		t.Errorf("expected unknown mode to be rejected")
+	}
+}
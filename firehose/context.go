@@ -1,24 +1,69 @@
 package firehose
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
+	"io"
 	"math/big"
 	"os"
 	"runtime/debug"
 	"strconv"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/metrics"
+	"github.com/ethereum/go-ethereum/rlp"
 	"go.uber.org/atomic"
 )
 
+// ErrBufferLimitExceeded is returned when a speculative execution is aborted because its
+// Firehose log exceeded the speculative context buffer limit.
+var ErrBufferLimitExceeded = errors.New("firehose speculative execution buffer limit exceeded")
+
+var invariantViolationsCounter = metrics.NewRegisteredCounter("firehose/invariant/violations", nil)
+
+var slowTransactionsCounter = metrics.NewRegisteredCounter("firehose/trx/slow", nil)
+
+var (
+	trieCommitNodesMeter = metrics.NewRegisteredMeter("firehose/trie/commit/nodes", nil)
+	trieCommitBytesMeter = metrics.NewRegisteredMeter("firehose/trie/commit/bytes", nil)
+	trieCommitTimer      = metrics.NewRegisteredTimer("firehose/trie/commit/time", nil)
+)
+
 // NoOpContext can be used when no recording should happen for a given code path
 var NoOpContext *Context
 
-var syncContext *Context = NewContext(&DelegateToWriterPrinter{writer: os.Stdout})
+var syncContext *Context = NewContext(NewDelegateToWriterPrinter(stdout))
+
+var syncContextWriter io.Writer = stdout
+var syncOutputFormat = TextOutputFormat
+
+// SetSyncContextWriter changes the destination of the sync context output which is standard
+// output by default. It must be called at initialization time, before any block is processed.
+func SetSyncContextWriter(writer io.Writer) {
+	syncContextWriter = writer
+	if len(syncOutputMiddlewares) > 0 {
+		writer, syncMiddlewareWriters = ChainWriterMiddlewares(writer, syncOutputMiddlewares)
+	}
+
+	if syncSizingInterval > 0 {
+		writer = newSizingWriter(writer, syncSizingInterval)
+	}
+
+	if syncSecondaryWriter != nil {
+		writer = newDualEmitWriter(writer, syncOutputFormat, syncSecondaryWriter, syncSecondaryFormat)
+	} else {
+		writer = withOutputFormat(writer, syncOutputFormat)
+	}
+
+	syncContext.setPrinter(NewDelegateToWriterPrinter(writer))
+}
 
 // MaybeSyncContext is used when syncing blocks with the network for mindreader consumption, there
 // is always a single active sync context use for the whole syncing process, should not be used
@@ -38,6 +83,27 @@ func MaybeSyncContext() *Context {
 	return syncContext
 }
 
+// MaybeSyncContextForBlock is like `MaybeSyncContext` but returns `NoOpContext` for blocks
+// below `StartBlockNumber`, such blocks only emit block progress (see `BlockProgressEnabledForBlock`).
+func MaybeSyncContextForBlock(number uint64) *Context {
+	if number < StartBlockNumber {
+		return NoOpContext
+	}
+
+	return MaybeSyncContext()
+}
+
+// BlockProgressEnabledForBlock determines if the block progress line must be emitted for the
+// given block when the block is not fully instrumented, which is the case when block progress
+// is enabled or when sync instrumentation is active but the block is below `StartBlockNumber`.
+func BlockProgressEnabledForBlock(number uint64) bool {
+	if BlockProgressEnabled {
+		return true
+	}
+
+	return Enabled && SyncInstrumentationEnabled && number < StartBlockNumber
+}
+
 // SyncContext returns the sync context without any checking if firehose is enabled or not. Use
 // it only for specific cases and ensure you only use it when it's strictly correct to do so as this
 // will print stdout lines.
@@ -53,7 +119,15 @@ func NewContext(printer Printer) *Context {
 		inBlock:              atomic.NewBool(false),
 		inTransaction:        atomic.NewBool(false),
 		totalOrderingCounter: atomic.NewUint64(0),
+
+		concurrencyMode: SingleGoroutineMode,
+		guardOwner:      atomic.NewUint64(0),
+
+		recoveries:   newRecoveryGuard(),
+		health:       newHealthTracker(),
+		replacements: newTrxReplacements(trxReplacementsLimit),
 	}
+	attachPrinter(printer, ctx)
 
 	ctx.resetBlock()
 	ctx.resetTransaction()
@@ -71,23 +145,95 @@ type Context struct {
 	// Global state
 	seenBlock   *atomic.Bool
 	flushTxLock sync.Mutex
+	recoveries  *recoveryGuard
+
+	// Pipeline state, owned by each context so that the chain instances of a process never
+	// share them, see `ContextProvider`
+	health       *healthTracker
+	differential *differentialValidator
+	replacements *trxReplacements
+
+	// recoveredViolations are the invariant violations recovered from by a speculative
+	// context, accounted by the context it's flushed to, see `FlushTransaction`
+	recoveredViolations []string
 
 	// Block state
 	inBlock              *atomic.Bool
+	blockNumber          uint64
 	blockLogIndex        uint64
+	blockSegmentCount    uint64
 	totalOrderingCounter *atomic.Uint64
+	pendingTrieCommit    *trieCommitStats
+	flushedOrdinals      uint64
+	blockEventCounts     map[string]uint64
+	feeRecipient         common.Address
+	blockFees            *big.Int
+	tdProvider           TotalDifficultyProvider
+	blockProvider        BlockProvider
 
 	// Transaction state
 	inTransaction   *atomic.Bool
 	activeCallIndex string
 	nextCallIndex   uint64
 	callIndexStack  *ExtendedStack
+	callGasStarts   map[string]callGasStart
+	keccakPreimages map[common.Hash][]byte
+	trxHash         common.Hash
+	trxStartTime    time.Time
+	trxGasLimit     uint64
+	trxSteps        uint64
+	trxLastProgress time.Time
+	failureSite     *callFailureSite
+
+	// Access sets state, only used when `CallAccessSetsEnabled` is set
+	accessedAddresses map[common.Address]bool
+	accessedSlots     map[storageSlot]bool
+	callAccessSets    map[string]*callAccessSet
+
+	// Net balance changes state, only used when `NetBalanceChangesEnabled` is set
+	netBalanceChanges []*netBalanceChange
+
+	// Coalesced gas change state, only used when `GasChangeCoalescingEnabled` is set
+	pendingGasChange *pendingGasChange
+
+	// Captured logs state, only used when `LogsBloomCheckEnabled` is set
+	trxLogs      []*types.Log
+	callLogMarks map[string]int
+
+	// abortReason is the reason of the last `AbortTransaction`, it outlives the transaction
+	abortReason string
+
+	// Concurrency state, see `ConcurrencyMode`
+	concurrencyMode ConcurrencyMode
+	guardLock       sync.Mutex
+	guardOwner      *atomic.Uint64
+	guardDepth      int
+}
+
+// callGasStart is the gas snapshot of a call taken when it's opened, it's printed again when
+// the call closes so that consumers can compute per-call gas consumption directly.
+type callGasStart struct {
+	gasAtStart         uint64
+	parentGasRemaining uint64
+}
+
+// callFailureSite is the instruction at which the interpreter stopped with an error, it's
+// printed with the EVM_CALL_FAILED line that follows.
+type callFailureSite struct {
+	pc     uint64
+	opcode byte
 }
 
 func (ctx *Context) resetBlock() {
 	ctx.inBlock.Store(false)
+	ctx.blockNumber = 0
 	ctx.blockLogIndex = 0
+	ctx.blockSegmentCount = 0
 	ctx.totalOrderingCounter.Store(0)
+	ctx.flushedOrdinals = 0
+	ctx.blockEventCounts = nil
+	ctx.feeRecipient = common.Address{}
+	ctx.blockFees = nil
 }
 
 func (ctx *Context) resetTransaction() {
@@ -96,19 +242,157 @@ func (ctx *Context) resetTransaction() {
 	ctx.activeCallIndex = "0"
 	ctx.callIndexStack = &ExtendedStack{}
 	ctx.callIndexStack.Push(ctx.activeCallIndex)
+	ctx.callGasStarts = map[string]callGasStart{}
+	ctx.keccakPreimages = nil
+	ctx.trxHash = common.Hash{}
+	ctx.trxStartTime = time.Time{}
+	ctx.trxGasLimit = 0
+	ctx.trxSteps = 0
+	ctx.trxLastProgress = time.Time{}
+	ctx.failureSite = nil
+	ctx.accessedAddresses = nil
+	ctx.accessedSlots = nil
+	ctx.callAccessSets = nil
+	ctx.netBalanceChanges = nil
+	ctx.pendingGasChange = nil
+	ctx.trxLogs = nil
+	ctx.callLogMarks = nil
+}
+
+// invariantViolated handles a broken Context invariant. In strict mode (the default), it
+// panics with the given message. Otherwise, an ERROR event is emitted, the violation is
+// counted and the caller is expected to recover as best as it can, unless too many were
+// recovered from already in which case the emission is halted, see `RecoveryLimit`.
+func (ctx *Context) invariantViolated(message string) {
+	if StrictEnabled {
+		debug.PrintStack()
+		panic(message)
+	}
+
+	invariantViolationsCounter.Inc(1)
+
+	ctx.print("ERROR", message)
+
+	if _, speculative := ctx.bufferPrinter(); speculative {
+		ctx.recoveredViolations = append(ctx.recoveredViolations, message)
+		return
+	}
+
+	ctx.recordRecovery(message)
+}
+
+// nextOrdinal consumes and returns the next ordinal, used by events that requires cross-family
+// ordering like transactions, calls, logs and balance/storage changes.
+func (ctx *Context) nextOrdinal() uint64 {
+	return ctx.totalOrderingCounter.Inc()
+}
+
+// informationalOrdinal is used by purely informational events (gas, nonce, code changes
+// and account creations). When reduced ordinals mode is active, those events do not consume
+// an ordinal and instead reference the current one, otherwise it's the same as `nextOrdinal`.
+func (ctx *Context) informationalOrdinal() uint64 {
+	if ReducedOrdinalsEnabled {
+		return ctx.totalOrderingCounter.Load()
+	}
+
+	return ctx.totalOrderingCounter.Inc()
 }
 
-func (ctx *Context) InitVersion(nodeVersion, dmVersion, variant string) {
+// InitVersion emits the INIT event followed by the INIT_REASONS event which lists, along
+// their registry version, all the balance and gas change reasons the node can emit.
+func (ctx *Context) InitVersion(nodeVersion, dmVersion string, variant Variant) {
 	if ctx == nil {
 		return
 	}
-	ctx.printer.Print("INIT", dmVersion, variant, nodeVersion)
+	defer ctx.guard()()
+
+	if NetBalanceChangesEnabled {
+		ctx.print("INIT", dmVersion, variant.Name, nodeVersion, "net")
+	} else {
+		ctx.print("INIT", dmVersion, variant.Name, nodeVersion)
+	}
+	ctx.print("INIT_REASONS", strconv.Itoa(ChangeReasonsVersion), JSON(changeReasonsManifest()))
+}
+
+// StreamHeader emits a STREAM_HEADER event that makes the stored stream self-describing, it
+// records the build information, the variant and its capabilities, the active Firehose
+// features, the host and the process start time. It must be called once at process start,
+// before `InitVersion`.
+func (ctx *Context) StreamHeader(nodeVersion, commit, dmVersion string, variant Variant) {
+	if ctx == nil {
+		return
+	}
+	defer ctx.guard()()
+
+	host, err := os.Hostname()
+	if err != nil {
+		host = "unknown"
+	}
+
+	ctx.print("STREAM_HEADER", JSON(map[string]interface{}{
+		"node_version":     nodeVersion,
+		"commit":           commit,
+		"firehose_version": dmVersion,
+		"variant":          variant.Name,
+		"capability_bits":  uint32(variant.Capabilities),
+		"capabilities":     variant.Capabilities.Names(),
+		"features":         featuresManifest(),
+		"host":             host,
+		"start_time":       time.Now().UTC().Format(time.RFC3339Nano),
+	}))
 }
 
 func NewSpeculativeExecutionContext(initialAllocationInBytes int) *Context {
 	return NewContext(NewToBufferPrinter(initialAllocationInBytes))
 }
 
+// NewTransactionContext creates the speculative execution context of a transaction of the
+// block recorded by `ctx`, to be flushed to it through `FlushTransaction`. It shares the
+// pipeline state of `ctx` needed while the transaction executes, like the mempool
+// replacements.
+func (ctx *Context) NewTransactionContext(initialAllocationInBytes int) *Context {
+	txContext := NewSpeculativeExecutionContext(initialAllocationInBytes)
+	if ctx != nil {
+		txContext.replacements = ctx.replacements
+	}
+
+	return txContext
+}
+
+// NewBoundedSpeculativeExecutionContext creates a speculative execution context whose buffer
+// can't grow past `limitInBytes` (0 means unlimited). Once the limit is exceeded, the
+// accumulated log is discarded and the handler registered through `OnBufferLimitExceeded`
+// is invoked so that the execution can be aborted.
+func NewBoundedSpeculativeExecutionContext(initialAllocationInBytes int, limitInBytes int) *Context {
+	return NewContext(NewBoundedToBufferPrinter(initialAllocationInBytes, limitInBytes))
+}
+
+// OnBufferLimitExceeded registers the handler invoked when the speculative execution buffer
+// limit is exceeded, it's a no-op for contexts that are not bounded speculative contexts.
+func (ctx *Context) OnBufferLimitExceeded(handler func()) {
+	if ctx == nil {
+		return
+	}
+
+	if v, ok := ctx.bufferPrinter(); ok {
+		v.OnExceeded(handler)
+	}
+}
+
+// BufferLimitExceeded returns `true` if the speculative execution buffer limit has been
+// exceeded, in which case the accumulated log has been discarded.
+func (ctx *Context) BufferLimitExceeded() bool {
+	if ctx == nil {
+		return false
+	}
+
+	if v, ok := ctx.bufferPrinter(); ok {
+		return v.Exceeded()
+	}
+
+	return false
+}
+
 func (ctx *Context) Enabled() bool {
 	return ctx != nil
 }
@@ -118,8 +402,12 @@ func (ctx *Context) FirehoseLog() []byte {
 		return nil
 	}
 
-	if v, ok := ctx.printer.(*ToBufferPrinter); ok {
-		return v.buffer.Bytes()
+	if v, ok := ctx.bufferPrinter(); ok {
+		contents, err := v.Contents()
+		if err != nil {
+			ctx.invariantViolated(fmt.Sprintf("unable to read back speculative execution log, lines were lost: %s", err))
+		}
+		return contents
 	}
 
 	return nil
@@ -131,17 +419,19 @@ func (ctx *Context) RecordGenesisBlock(block *types.Block, recordGenesisAlloc fu
 	if ctx == nil {
 		return
 	}
+	defer ctx.guard()()
 
 	if ctx.inBlock.Load() {
-		panic("trying to record genesis block while in block context")
+		ctx.invariantViolated("trying to record genesis block while in block context")
+		ctx.exitBlock()
 	}
 
 	zero := common.Address{}
 	root := block.Root()
 
 	ctx.StartBlock(block)
-	ctx.StartTransactionRaw(common.Hash{}, &zero, &big.Int{}, nil, nil, nil, 0, &big.Int{}, 0, nil, nil, nil, nil, 0, 0)
-	ctx.RecordTrxFrom(zero)
+	ctx.StartTransactionRaw(common.Hash{}, &zero, &big.Int{}, nil, nil, nil, 0, &big.Int{}, 0, nil, nil, nil, nil, 0, 0, SystemFeeKind)
+	ctx.RecordTrxFrom(zero, nil)
 	recordGenesisAlloc(ctx)
 	ctx.EndTransaction(&types.Receipt{PostState: root[:]})
 	ctx.FinalizeBlock(block)
@@ -149,41 +439,251 @@ func (ctx *Context) RecordGenesisBlock(block *types.Block, recordGenesisAlloc fu
 }
 
 func (ctx *Context) StartBlock(block *types.Block) {
+	defer ctx.guard()()
+
 	if !ctx.inBlock.CAS(false, true) {
-		panic("entering a block while already in a block scope")
+		ctx.invariantViolated("entering a block while already in a block scope")
+
+		// Recover by discarding the previous block state, this one is now the active block
+		ctx.resetBlock()
+		ctx.resetTransaction()
+		ctx.inBlock.Store(true)
 	}
 
 	ctx.seenBlock.Store(true)
+	ctx.blockNumber = block.NumberU64()
+
+	// The transaction count and the block's size (an estimate of the serialized size, the exact
+	// size being given by END_BLOCK) are given upfront so the reader can pre-allocate.
+	fields := []string{"BEGIN_BLOCK",
+		Uint64(block.NumberU64()),
+		Hash(block.Hash()),
+		Hash(block.ParentHash()),
+		Uint64(block.Time()),
+		Uint(uint(len(block.Transactions()))),
+		Uint64(uint64(block.Size())),
+	}
+	if annotations := BlockAnnotations(); len(annotations) > 0 {
+		fields = append(fields, JSON(annotations))
+	}
+
+	ctx.print(fields...)
 
-	ctx.printer.Print("BEGIN_BLOCK", Uint64(block.NumberU64()))
+	if MonotonicTimestampsEnabled {
+		now := time.Now()
+		ctx.print("CLOCK_ANCHOR",
+			strconv.FormatInt(int64(now.Sub(processStart)), 10),
+			strconv.FormatInt(now.UnixNano(), 10),
+		)
+	}
 }
 
+// FinalizeBlock emits the FINALIZE_BLOCK event, tagged with the mode the block is emitted in:
+// `full` for a fully instrumented block and `progress` for a block only reporting progress
+// (block progress mode or blocks below `StartBlockNumber`), the `skeleton` mode being used by
+// `RecordSkeletonBlock`. All modes are emitted by the sync context to the same sink, under the
+// transaction flush lock, so that the stream remains totally ordered whatever the mode of each
+// block, across restarts toggling it too.
 func (ctx *Context) FinalizeBlock(block *types.Block) {
+	defer ctx.guard()()
+
 	// We must not check if the finalize block is actually in the a block since
 	// when firehose block progress only is enabled, it would hit a panic
-	ctx.printer.Print("FINALIZE_BLOCK", Uint64(block.NumberU64()))
+	mode := fullFinalizeMode
+	if !ctx.inBlock.Load() {
+		mode = progressFinalizeMode
+	}
+
+	ctx.finalizeBlock(block, mode)
+
+	// A progress block has no END_BLOCK, it's emitted once finalized
+	if mode == progressFinalizeMode {
+		ctx.health.recordBlock(block.NumberU64())
+	}
+}
+
+func (ctx *Context) finalizeBlock(block *types.Block, mode string) {
+	ctx.flushTxLock.Lock()
+	defer ctx.flushTxLock.Unlock()
+
+	if BlockProgressDetailsEnabled && mode == progressFinalizeMode {
+		ctx.print("BLOCK_PROGRESS",
+			Uint64(block.NumberU64()),
+			Hash(block.Hash()),
+			Hash(block.ParentHash()),
+			Uint64(block.Time()),
+			Uint(uint(len(block.Transactions()))),
+		)
+	}
+
+	if FeeRecipientCreditEnabled && mode != progressFinalizeMode {
+		ctx.printFeeRecipientCredit(block)
+	}
+
+	fields := []string{"FINALIZE_BLOCK", Uint64(block.NumberU64()), mode}
+	if OrderingCheckpointsEnabled && mode != progressFinalizeMode {
+		fields = append(fields, ctx.checkpointFields()...)
+	}
+	ctx.print(fields...)
+
+	if stats := ctx.pendingTrieCommit; stats != nil {
+		ctx.print("TRIE_COMMIT",
+			Uint64(stats.blockNumber),
+			Uint64(stats.nodes),
+			Uint64(stats.bytes),
+			Uint64(uint64(stats.duration.Nanoseconds())),
+		)
+
+		ctx.pendingTrieCommit = nil
+	}
+}
+
+const (
+	fullFinalizeMode     = "full"
+	progressFinalizeMode = "progress"
+	skeletonFinalizeMode = "skeleton"
+)
+
+// trieCommitStats holds the state commit statistics of a block until they are emitted.
+type trieCommitStats struct {
+	blockNumber uint64
+	nodes       uint64
+	bytes       uint64
+	duration    time.Duration
+}
+
+// RecordTrieCommit records the statistics of the block's state commit, the amount of trie
+// nodes and bytes persisted to the database and the commit duration. The commit happens after
+// the block's END_BLOCK, the statistics are kept and emitted on the next `FinalizeBlock`.
+func (ctx *Context) RecordTrieCommit(blockNumber uint64, nodes uint64, bytes uint64, duration time.Duration) {
+	if ctx == nil || !TrieCommitStatsEnabled {
+		return
+	}
+	defer ctx.guard()()
+
+	trieCommitNodesMeter.Mark(int64(nodes))
+	trieCommitBytesMeter.Mark(int64(bytes))
+	trieCommitTimer.Update(duration)
+
+	ctx.pendingTrieCommit = &trieCommitStats{blockNumber, nodes, bytes, duration}
+}
+
+// TotalDifficultyProvider gives the total difficulty of a known block, it's implemented by
+// the chain reader.
+type TotalDifficultyProvider interface {
+	GetTd(hash common.Hash, number uint64) *big.Int
 }
 
+// SetTotalDifficultyProvider registers the provider used by `EndBlock` to compute the block's
+// total difficulty when the caller doesn't provide it.
+func (ctx *Context) SetTotalDifficultyProvider(provider TotalDifficultyProvider) {
+	if ctx == nil {
+		return
+	}
+
+	ctx.tdProvider = provider
+}
+
+// BlockProvider resolves locally known blocks, it's used to emit the body of uncles.
+type BlockProvider interface {
+	GetBlock(hash common.Hash, number uint64) *types.Block
+}
+
+// SetBlockProvider registers the provider used by `EndBlock` to resolve the body of the
+// block's uncles when `UncleBlocksEnabled` is set.
+func (ctx *Context) SetBlockProvider(provider BlockProvider) {
+	if ctx == nil {
+		return
+	}
+
+	ctx.blockProvider = provider
+}
+
+// EndBlock emits the END_BLOCK event. The `totalDifficulty` can be `nil` in which case it's
+// computed from the parent's total difficulty given by the registered `TotalDifficultyProvider`,
+// an explicit value always overrides the provider.
 func (ctx *Context) EndBlock(block *types.Block, totalDifficulty *big.Int) {
-	ctx.printer.Print("END_BLOCK",
+	defer ctx.guard()()
+
+	if totalDifficulty == nil {
+		totalDifficulty = ctx.totalDifficulty(block)
+	}
+
+	if UncleBlocksEnabled {
+		ctx.printUncleBlocks(block)
+	}
+
+	if BlockShardSizeInBytes > 0 {
+		// The manifest tells the reader how many segments it should have received for the block
+		ctx.print("BLOCK_SEGMENTS",
+			Uint64(block.NumberU64()),
+			Uint64(ctx.blockSegmentCount),
+		)
+	}
+
+	ctx.print("END_BLOCK",
 		Uint64(block.NumberU64()),
 		Uint64(uint64(block.Size())),
-		JSON(map[string]interface{}{
-			"header":          block.Header(),
-			"uncles":          block.Body().Uncles,
-			"totalDifficulty": (*hexutil.Big)(totalDifficulty),
-		}),
+		JSON(endBlockMeta(block, totalDifficulty)),
 	)
 
+	ctx.health.recordBlock(block.NumberU64())
+	ctx.recoveries.recordBlock()
+
 	ctx.exitBlock()
 }
 
+// printUncleBlocks emits an UNCLE_BLOCK event for each of the block's uncles whose body is
+// known to the registered `BlockProvider`.
+func (ctx *Context) printUncleBlocks(block *types.Block) {
+	if ctx.blockProvider == nil {
+		return
+	}
+
+	for i, uncle := range block.Uncles() {
+		uncleBlock := ctx.blockProvider.GetBlock(uncle.Hash(), uncle.Number.Uint64())
+		if uncleBlock == nil {
+			continue
+		}
+
+		ctx.print("UNCLE_BLOCK",
+			Uint64(uint64(i)),
+			Uint64(uncle.Number.Uint64()),
+			Hash(uncle.Hash()),
+			JSON(map[string]interface{}{
+				"transactions": uncleBlock.Transactions(),
+				"uncles":       uncleBlock.Uncles(),
+			}),
+		)
+	}
+}
+
+// totalDifficulty computes the total difficulty of the block from its parent's one, `nil` is
+// returned if there is no provider or if the parent is unknown to it. The genesis block's
+// total difficulty is its own difficulty.
+func (ctx *Context) totalDifficulty(block *types.Block) *big.Int {
+	if block.NumberU64() == 0 {
+		return block.Difficulty()
+	}
+
+	if ctx.tdProvider == nil {
+		return nil
+	}
+
+	parentTd := ctx.tdProvider.GetTd(block.ParentHash(), block.NumberU64()-1)
+	if parentTd == nil {
+		return nil
+	}
+
+	return new(big.Int).Add(parentTd, block.Difficulty())
+}
+
 // exitBlock is used when an abnormal condition is encountered while processing
 // transactions and we must end the block processing right away, resetting the start
 // along the way.
 func (ctx *Context) exitBlock() {
 	if !ctx.inBlock.Load() {
-		panic("exiting a block while not already within a block scope")
+		ctx.invariantViolated("exiting a block while not already within a block scope")
 	}
 
 	ctx.resetBlock()
@@ -192,6 +692,29 @@ func (ctx *Context) exitBlock() {
 	ctx.resetTransaction()
 }
 
+// RecordBlockFinalized emits a BLOCK_FINALIZED event when the consensus engine reaches
+// explicit finality (e.g. a DAG consensus confirmation or a checkpoint) for the block, so
+// that downstream consumers can produce final-only streams without relying on a confirmation
+// count. Finality being reached independently of block processing, it's a standalone event
+// that must be recorded outside of a block scope, the block it references having been
+// emitted beforehand.
+func (ctx *Context) RecordBlockFinalized(number uint64, hash common.Hash) {
+	if ctx == nil {
+		return
+	}
+	defer ctx.guard()()
+
+	if ctx.inBlock.Load() {
+		ctx.invariantViolated("recording block finality while within a block scope")
+		return
+	}
+
+	ctx.print("BLOCK_FINALIZED",
+		Uint64(number),
+		Hash(hash),
+	)
+}
+
 // CancelBlock emit a Firehose CANCEL_BLOCK event that tells the console reader to discard any
 // accumulated block's data and start over. This happens on certains error conditions where the block
 // is actually invalid and will be re-processed by the chain so we should not record it.
@@ -199,6 +722,7 @@ func (ctx *Context) CancelBlock(block *types.Block, err error) {
 	if ctx == nil {
 		return
 	}
+	defer ctx.guard()()
 
 	// There is some particular runtime code path that could trigger a CANCEL_BLOCK without having started
 	// one, it's ok, the reader is resistant to such and here, we simply don't call `ExitBlock`.
@@ -206,10 +730,12 @@ func (ctx *Context) CancelBlock(block *types.Block, err error) {
 		ctx.exitBlock()
 	}
 
-	ctx.printer.Print("CANCEL_BLOCK",
+	ctx.print("CANCEL_BLOCK",
 		Uint64(block.NumberU64()),
 		err.Error(),
 	)
+
+	ctx.health.recordError(err.Error())
 }
 
 // Transaction methods
@@ -218,9 +744,11 @@ func (ctx *Context) StartTransaction(tx *types.Transaction, txIndex uint, baseFe
 	if ctx == nil {
 		return
 	}
+	defer ctx.guard()()
 
 	hash := tx.Hash()
 	v, r, s := tx.RawSignatureValues()
+	gasPrice, maxFeePerGas, maxPriorityFeePerGas := TxFees(tx, baseFee)
 
 	ctx.StartTransactionRaw(
 		hash,
@@ -230,18 +758,17 @@ func (ctx *Context) StartTransaction(tx *types.Transaction, txIndex uint, baseFe
 		r.Bytes(),
 		s.Bytes(),
 		tx.Gas(),
-		tx.GasPrice(),
+		gasPrice,
 		tx.Nonce(),
 		tx.Data(),
 		// Berlin fork not active in this branch, replace by `AccessList(tx.AccessList())` when it's the case (and remove this comment)
 		nil,
-		// London fork not active in this branch yet, replace by `tx.GasFeeCap()` when it's the case (and remove this comment)
-		nil,
-		// London fork not active in this branch yet, replace by `tx.GasTipCap()` when it's the case (and remove this comment)
-		nil,
+		maxFeePerGas,
+		maxPriorityFeePerGas,
 		// Berlin fork not active in this branch, transaction's type not active, replace by `tx.Type()` when it's the case (and remove this comment)
 		0,
 		txIndex,
+		TxFeeKind(tx, gasPrice),
 	)
 }
 
@@ -259,29 +786,45 @@ func (ctx *Context) StartTransactionRaw(
 	maxPriorityFeePerGas *big.Int,
 	txType uint8,
 	txIndex uint,
+	feeKind FeeKind,
 ) {
 	if ctx == nil {
 		return
 	}
+	defer ctx.guard()()
 
 	if !ctx.inTransaction.CAS(false, true) {
-		panic("entering a transaction while already in a transaction scope")
+		ctx.invariantViolated("entering a transaction while already in a transaction scope")
+
+		// Recover by discarding the previous transaction state, this one is now the active transaction
+		ctx.resetTransaction()
+		ctx.inTransaction.Store(true)
+	}
+
+	if SlowTransactionThreshold > 0 {
+		ctx.trxHash = hash
+		ctx.trxGasLimit = gasLimit
+		ctx.trxStartTime = time.Now()
 	}
 
-	// We start assuming the "null" value (i.e. a dot character), and update if `to` is set
-	toAsString := "."
-	if to != nil {
-		toAsString = Addr(*to)
+	if CallAccessSetsEnabled && to != nil {
+		ctx.warmAddress(*to)
 	}
 
-	// London fork not active in this branch yet, add proper handling here when it's the case (and remove this comment)
+	// Both are `nil` for transactions that are not dynamic fee transactions
 	maxFeePerGasAsString := "."
-	// London fork not active in this branch yet, add proper handling here when it's the case (and remove this comment)
+	if maxFeePerGas != nil {
+		maxFeePerGasAsString = BigInt(maxFeePerGas)
+	}
+
 	maxPriorityFeePerGasAsString := "."
+	if maxPriorityFeePerGas != nil {
+		maxPriorityFeePerGasAsString = BigInt(maxPriorityFeePerGas)
+	}
 
-	ctx.printer.Print("BEGIN_APPLY_TRX",
+	ctx.print("BEGIN_APPLY_TRX",
 		Hash(hash),
-		toAsString,
+		OptionalAddr(to),
 		Hex(value.Bytes()),
 		Hex(v),
 		Hex(r),
@@ -294,22 +837,39 @@ func (ctx *Context) StartTransactionRaw(
 		maxFeePerGasAsString,
 		maxPriorityFeePerGasAsString,
 		Uint8(txType),
-		Uint64(ctx.totalOrderingCounter.Inc()),
+		Uint64(ctx.nextOrdinal()),
 		Uint(txIndex),
+		string(feeKind),
 	)
 }
 
-func (ctx *Context) RecordTrxFrom(from common.Address) {
+// RecordTrxFrom records the transaction's sender. When `TrxFromPubkeyEnabled` is set and
+// `pubkey` is known (it's not for unsigned messages), the sender's 64 bytes uncompressed public
+// key is printed along the address.
+func (ctx *Context) RecordTrxFrom(from common.Address, pubkey []byte) {
 	if ctx == nil {
 		return
 	}
+	defer ctx.guard()()
 
 	if !ctx.inTransaction.Load() {
-		debug.PrintStack()
-		panic("the RecordTrxFrom should have been call within a transaction, something is deeply wrong")
+		ctx.invariantViolated("the RecordTrxFrom should have been call within a transaction, something is deeply wrong")
+		return
+	}
+
+	if CallAccessSetsEnabled {
+		ctx.warmAddress(from)
+	}
+
+	if TrxFromPubkeyEnabled && len(pubkey) > 0 {
+		ctx.print("TRX_FROM",
+			Addr(from),
+			Hex(pubkey),
+		)
+		return
 	}
 
-	ctx.printer.Print("TRX_FROM",
+	ctx.print("TRX_FROM",
 		Addr(from),
 	)
 }
@@ -322,12 +882,43 @@ func (ctx *Context) FlushTransaction(txContext *Context) {
 	if ctx == nil || txContext == nil {
 		return
 	}
+	defer ctx.guard()()
 
-	if v, ok := txContext.printer.(*ToBufferPrinter); ok {
+	if v, ok := txContext.bufferPrinter(); ok {
 		ctx.flushTxLock.Lock()
 		defer ctx.flushTxLock.Unlock()
 
-		fmt.Print(v.buffer.String())
+		ctx.health.recordBuffer(v)
+
+		if OrderingCheckpointsEnabled {
+			ctx.mergeCheckpoint(txContext)
+		}
+
+		if FeeRecipientCreditEnabled {
+			ctx.mergeFeeCredit(txContext)
+		}
+
+		// Spilled lines are streamed back in chunks of complete lines, each chunk being
+		// segmented on its own
+		err := v.forEachChunk(func(chunk []byte) {
+			if BlockShardSizeInBytes > 0 {
+				ctx.flushSegments(chunk)
+			} else {
+				ctx.printRaw(string(chunk))
+			}
+		})
+		if err == nil {
+			err = v.SpillErr()
+		}
+		if err != nil {
+			ctx.invariantViolated(fmt.Sprintf("unable to flush transaction spilled lines, lines were lost: %s", err))
+		}
+
+		// The transaction's violations are accounted once its lines are, halting the emission
+		// if too many were recovered from
+		for _, message := range txContext.recoveredViolations {
+			ctx.recordRecovery(message)
+		}
 
 		v.Reset()
 	}
@@ -336,6 +927,46 @@ func (ctx *Context) FlushTransaction(txContext *Context) {
 	txContext.Reset()
 }
 
+// flushSegments splits the transaction's buffered lines into segments of at most
+// `BlockShardSizeInBytes` bytes (a single line bigger than the limit gets its own segment),
+// each segment is preceded by a BLOCK_SEGMENT line giving the block number, the segment's
+// index within the block and its size in bytes so the reader can reassemble them.
+//
+// Must be called with `flushTxLock` held.
+func (ctx *Context) flushSegments(buffer []byte) {
+	for len(buffer) > 0 {
+		end := 0
+		for end < len(buffer) {
+			next := bytes.IndexByte(buffer[end:], '\n')
+			if next == -1 {
+				next = len(buffer) - end - 1
+			}
+
+			if end > 0 && end+next+1 > BlockShardSizeInBytes {
+				break
+			}
+
+			end += next + 1
+		}
+
+		// The segment's size is computed by the printer, from the lines as written
+		ctx.countEvent("BLOCK_SEGMENT")
+		printSegment(ctx.printer, []string{"BLOCK_SEGMENT",
+			Uint64(ctx.blockNumber),
+			Uint64(ctx.blockSegmentCount),
+		}, string(buffer[:end]))
+
+		ctx.blockSegmentCount++
+		buffer = buffer[end:]
+	}
+}
+
+// printRaw outputs already formatted lines through the context's printer when it supports
+// it, falling back to standard output otherwise.
+func (ctx *Context) printRaw(lines string) {
+	printRaw(ctx.printer, lines)
+}
+
 // Reset resets the block/transaction context for future re-use, if desired. If does not
 // touch the global context for now.
 //
@@ -344,53 +975,237 @@ func (ctx *Context) Reset() {
 	if ctx == nil {
 		return
 	}
+	defer ctx.guard()()
 
 	ctx.resetBlock()
 	ctx.resetTransaction()
+	ctx.recoveredViolations = nil
 }
 
 func (ctx *Context) EndTransaction(receipt *types.Receipt) {
 	if ctx == nil {
 		return
 	}
+	defer ctx.guard()()
 
 	if !ctx.inTransaction.Load() {
-		panic("exiting a transaction while not already within a transaction scope")
+		ctx.invariantViolated("exiting a transaction while not already within a transaction scope")
+		return
 	}
 
+	// The `blockIndex` is the log's canonical index within the block (i.e. the `logIndex` of
+	// RPC `eth_getLogs`), as assigned by the state once the transaction's reverted logs have
+	// been discarded.
 	logItems := make([]logItem, len(receipt.Logs))
 	for i, log := range receipt.Logs {
 		logItems[i] = logItem{
-			"address": log.Address,
-			"topics":  log.Topics,
-			"data":    hexutil.Bytes(log.Data),
+			"address":    log.Address,
+			"topics":     log.Topics,
+			"data":       hexutil.Bytes(log.Data),
+			"blockIndex": log.Index,
 		}
 	}
 
-	ctx.printer.Print(
+	ctx.flushBalanceChanges()
+
+	if LogsBloomCheckEnabled {
+		ctx.verifyLogsBloom(receipt)
+	}
+
+	if SlowTransactionThreshold > 0 && !ctx.trxStartTime.IsZero() {
+		ctx.recordSlowTransaction(time.Since(ctx.trxStartTime), receipt.GasUsed)
+	}
+
+	ctx.print(
 		"END_APPLY_TRX",
 		Uint64(receipt.GasUsed),
 		Hex(receipt.PostState),
 		Uint64(receipt.CumulativeGasUsed),
 		Hex(receipt.Bloom[:]),
-		Uint64(ctx.totalOrderingCounter.Inc()),
+		Uint64(ctx.nextOrdinal()),
 		JSON(logItems),
 	)
 
 	ctx.resetTransaction()
 }
 
+// recordSlowTransaction emits a SLOW_TRX diagnostic event when the active transaction
+// execution took longer than `SlowTransactionThreshold`.
+func (ctx *Context) recordSlowTransaction(elapsed time.Duration, gasUsed uint64) {
+	if elapsed < SlowTransactionThreshold {
+		return
+	}
+
+	slowTransactionsCounter.Inc(1)
+
+	ctx.print("SLOW_TRX",
+		Hash(ctx.trxHash),
+		Uint64(uint64(elapsed.Nanoseconds())),
+		Uint64(gasUsed),
+	)
+}
+
+// slowTransactionCheckSteps is the amount of executed opcodes between two checks of the
+// active transaction elapsed time, reading the clock on each opcode being too costly.
+const slowTransactionCheckSteps = 1024
+
+// RecordExecutionStep accounts an opcode executed by the active transaction, `remainingGas`
+// being the gas left in the executing call frame. Once the transaction runs for longer than
+// `SlowTransactionThreshold`, a SLOW_TRX_PROGRESS diagnostic event giving the elapsed time
+// and the gas used so far is emitted each time the threshold elapses again, so that
+// long-running executions are diagnosed before they complete, if they ever do.
+func (ctx *Context) RecordExecutionStep(remainingGas uint64) {
+	if ctx == nil {
+		return
+	}
+	defer ctx.guard()()
+
+	if ctx.trxStartTime.IsZero() {
+		return
+	}
+
+	ctx.trxSteps++
+	if ctx.trxSteps%slowTransactionCheckSteps != 0 {
+		return
+	}
+
+	now := time.Now()
+	last := ctx.trxLastProgress
+	if last.IsZero() {
+		last = ctx.trxStartTime
+	}
+	if now.Sub(last) < SlowTransactionThreshold {
+		return
+	}
+	ctx.trxLastProgress = now
+
+	// The gas of the frames below the executing one is left untouched until it returns
+	for _, start := range ctx.callGasStarts {
+		remainingGas += start.parentGasRemaining
+	}
+	var gasUsed uint64
+	if ctx.trxGasLimit > remainingGas {
+		gasUsed = ctx.trxGasLimit - remainingGas
+	}
+
+	elapsed := now.Sub(ctx.trxStartTime)
+	log.Warn("Slow transaction still executing", "hash", ctx.trxHash, "elapsed", elapsed, "gas_used", gasUsed)
+
+	ctx.print("SLOW_TRX_PROGRESS",
+		Hash(ctx.trxHash),
+		Uint64(uint64(elapsed.Nanoseconds())),
+		Uint64(gasUsed),
+	)
+}
+
+// System call methods
+
+// StartSystemCall opens a SYSTEM_CALL section for a system-level operation executed outside
+// of any user transaction (beacon block root write, history storage, ...). Such operations
+// happen before the block's transactions, so it must be called after `StartBlock` and before
+// the first transaction is started.
+//
+// Within the section, calls and state changes are recorded like within a transaction. The
+// `target` is `nil` for system calls deploying a contract, the section is then flagged as a
+// creation.
+func (ctx *Context) StartSystemCall(name string, caller common.Address, target *common.Address) {
+	if ctx == nil {
+		return
+	}
+	defer ctx.guard()()
+
+	if !ctx.inBlock.Load() {
+		ctx.invariantViolated("entering a system call while not within a block scope")
+	}
+
+	if !ctx.inTransaction.CAS(false, true) {
+		ctx.invariantViolated("entering a system call while already in a transaction scope")
+
+		// Recover by discarding the previous transaction state, the system call is now the active scope
+		ctx.resetTransaction()
+		ctx.inTransaction.Store(true)
+	}
+
+	ctx.print("BEGIN_SYSTEM_CALL",
+		name,
+		Addr(caller),
+		OptionalAddr(target),
+		Uint64(ctx.nextOrdinal()),
+		Bool(target == nil),
+	)
+}
+
+// EndSystemCall closes the SYSTEM_CALL section opened by `StartSystemCall`.
+func (ctx *Context) EndSystemCall() {
+	if ctx == nil {
+		return
+	}
+	defer ctx.guard()()
+
+	if !ctx.inTransaction.Load() {
+		ctx.invariantViolated("exiting a system call while not already within a system call scope")
+		return
+	}
+
+	ctx.flushBalanceChanges()
+
+	ctx.print("END_SYSTEM_CALL",
+		Uint64(ctx.nextOrdinal()),
+	)
+
+	ctx.resetTransaction()
+}
+
+// RecordSkippedTransaction records a transaction that was skipped by the execution because it
+// failed its pre-checks. The full RLP encoded transaction is included so that skipped transactions
+// are recoverable downstream, along the failure classification and the actual error message.
+//
+// It's a block level event, it must not be called within a transaction.
+func (ctx *Context) RecordSkippedTransaction(tx *types.Transaction, reason SkippedTransactionReason, err error) {
+	if ctx == nil {
+		return
+	}
+	defer ctx.guard()()
+
+	encoded, encodeErr := rlp.EncodeToBytes(tx)
+	if encodeErr != nil {
+		// The transaction is only informative here, it's still recorded with an empty payload
+		log.Error("Unable to RLP encode skipped transaction", "hash", tx.Hash(), "err", encodeErr)
+	}
+
+	ctx.print("SKIPPED_TRX",
+		Hash(tx.Hash()),
+		Hex(encoded),
+		string(reason),
+		err.Error(),
+	)
+}
+
 // Call methods
 
-func (ctx *Context) StartCall(callType string) {
+// StartCall opens a new call, `gasAtStart` is the gas given to the call frame and
+// `parentGasRemaining` is the gas left in the parent frame once the call's gas has been
+// deducted from it (0 for the root call).
+func (ctx *Context) StartCall(callType string, gasAtStart, parentGasRemaining uint64) {
 	if ctx == nil {
 		return
 	}
+	defer ctx.guard()()
 
-	ctx.printer.Print("EVM_RUN_CALL",
+	ctx.flushBalanceChanges()
+
+	index := ctx.openCall()
+	ctx.callGasStarts[index] = callGasStart{gasAtStart, parentGasRemaining}
+	if LogsBloomCheckEnabled {
+		ctx.markCallLogs(index)
+	}
+
+	ctx.print("EVM_RUN_CALL",
 		callType,
-		ctx.openCall(),
-		Uint64(ctx.totalOrderingCounter.Inc()),
+		index,
+		Uint64(ctx.nextOrdinal()),
+		Uint64(gasAtStart),
+		Uint64(parentGasRemaining),
 	)
 }
 
@@ -405,8 +1220,8 @@ func (ctx *Context) openCall() string {
 
 func (ctx *Context) callIndex() string {
 	if !ctx.inTransaction.Load() {
-		debug.PrintStack()
-		panic("should have been call in a transaction, something is deeply wrong")
+		// In non-strict mode, we continue using the active call index which is the root call "0"
+		ctx.invariantViolated("should have been call in a transaction, something is deeply wrong")
 	}
 
 	return ctx.activeCallIndex
@@ -416,8 +1231,9 @@ func (ctx *Context) RecordCallParams(callType string, caller common.Address, cal
 	if ctx == nil {
 		return
 	}
+	defer ctx.guard()()
 
-	ctx.printer.Print("EVM_PARAM",
+	ctx.print("EVM_PARAM",
 		callType,
 		ctx.callIndex(),
 		Addr(caller),
@@ -426,41 +1242,94 @@ func (ctx *Context) RecordCallParams(callType string, caller common.Address, cal
 		Uint64(gasLimit),
 		Hex(input),
 	)
+
+	ctx.RecordAddressAccess(callee)
 }
 
 func (ctx *Context) RecordCallWithoutCode() {
 	if ctx == nil {
 		return
 	}
+	defer ctx.guard()()
 
-	ctx.printer.Print("ACCOUNT_WITHOUT_CODE",
+	ctx.print("ACCOUNT_WITHOUT_CODE",
 		ctx.callIndex(),
 	)
 }
 
-func (ctx *Context) RecordCallFailed(gasLeft uint64, reason string) {
+// RecordCallFailureSite records the program counter and opcode of the instruction at which
+// the interpreter stopped with an error, they are printed by the next RecordCallFailed.
+func (ctx *Context) RecordCallFailureSite(pc uint64, opcode byte) {
+	if ctx == nil {
+		return
+	}
+	defer ctx.guard()()
+
+	ctx.failureSite = &callFailureSite{pc, opcode}
+}
+
+// RecordCallFailed records that the active call failed. The failing program counter and
+// opcode are those recorded by RecordCallFailureSite, `.` is used for both when the call
+// failed before executing any instruction. The depth is the active call's depth, 1 being the
+// transaction's root call.
+func (ctx *Context) RecordCallFailed(gasLeft uint64, code CallFailureCode, reason string) {
 	if ctx == nil {
 		return
 	}
+	defer ctx.guard()()
+
+	pc, opcode := ".", "."
+	if ctx.failureSite != nil {
+		pc, opcode = Uint64(ctx.failureSite.pc), Hex([]byte{ctx.failureSite.opcode})
+		ctx.failureSite = nil
+	}
+
+	if LogsBloomCheckEnabled {
+		ctx.discardCallLogs()
+	}
 
-	ctx.printer.Print("EVM_CALL_FAILED",
+	// The reason is free-form and contains spaces, it must always remain the last element
+	ctx.print("EVM_CALL_FAILED",
 		ctx.callIndex(),
 		Uint64(gasLeft),
+		string(code),
+		pc,
+		opcode,
+		Uint64(uint64(ctx.callIndexStack.Len()-1)),
 		reason,
 	)
 }
 
-func (ctx *Context) RecordCallReverted() {
+// RecordCallReverted records that the active call reverted. When the revert data is an ABI
+// encoded `Error(string)` or `Panic(uint256)`, the matching selector and the decoded human
+// readable reason (as a JSON string) are printed too, `.` is used for both otherwise.
+func (ctx *Context) RecordCallReverted(revertData []byte) {
 	if ctx == nil {
 		return
 	}
+	defer ctx.guard()()
 
-	ctx.printer.Print("EVM_REVERTED",
+	selectorAsString := "."
+	reasonAsString := "."
+	if selector, reason, ok := decodeRevertReason(revertData); ok {
+		selectorAsString = Hex(selector)
+		reasonAsString = JSON(reason)
+	}
+
+	ctx.print("EVM_REVERTED",
 		ctx.callIndex(),
+		selectorAsString,
+		reasonAsString,
 	)
 }
 
 func (ctx *Context) closeCall() string {
+	// The root call index "0" is always present in the stack and must never be popped
+	if ctx.callIndexStack.Len() < 2 {
+		ctx.invariantViolated("closing a call while no call is active")
+		return ctx.activeCallIndex
+	}
+
 	previousIndex := ctx.callIndexStack.MustPop()
 	ctx.activeCallIndex = ctx.callIndexStack.MustPeek()
 
@@ -471,39 +1340,68 @@ func (ctx *Context) EndCall(gasLeft uint64, returnValue []byte) {
 	if ctx == nil {
 		return
 	}
+	defer ctx.guard()()
+
+	ctx.printEndCall(gasLeft, returnValue, false)
+}
+
+// printEndCall closes the active call, `aborted` flags the calls closed by `AbortTransaction`
+// rather than by the execution.
+func (ctx *Context) printEndCall(gasLeft uint64, returnValue []byte, aborted bool) {
+	ctx.flushBalanceChanges()
+
+	index := ctx.closeCall()
+	gasStart := ctx.callGasStarts[index]
+	delete(ctx.callGasStarts, index)
+
+	if CallAccessSetsEnabled {
+		ctx.printCallAccessSet(index)
+	}
+
+	returnData := returnValue
+	if ReturnDataLimitInBytes > 0 && len(returnData) > ReturnDataLimitInBytes {
+		returnData = returnData[:ReturnDataLimitInBytes]
+	}
 
-	ctx.printer.Print("EVM_END_CALL",
-		ctx.closeCall(),
+	fields := []string{"EVM_END_CALL",
+		index,
 		Uint64(gasLeft),
-		Hex(returnValue),
-		Uint64(ctx.totalOrderingCounter.Inc()),
-	)
+		Hex(returnData),
+		Uint64(ctx.nextOrdinal()),
+		Uint64(gasStart.gasAtStart),
+		Uint64(gasStart.parentGasRemaining),
+	}
+	if ReturnDataLimitInBytes > 0 {
+		// The size follows the aborted flag which is then always printed
+		fields = append(fields, Bool(aborted), Uint64(uint64(len(returnValue))))
+	} else if aborted {
+		fields = append(fields, Bool(true))
+	}
+
+	ctx.print(fields...)
 }
 
 // EndFailedCall is works similarly to EndCall but actualy also prints extra required line
 // like EVM_CALL_FAILED and EVM_REVERTED when it's the case. This is used on early exit in the
 // the instrumentation when a failure (and revertion) occurs to reduce the actual method call
 // peformed.
-func (ctx *Context) EndFailedCall(gasLeft uint64, reverted bool, reason string) {
+func (ctx *Context) EndFailedCall(gasLeft uint64, reverted bool, code CallFailureCode, reason string) {
 	if ctx == nil {
 		return
 	}
+	defer ctx.guard()()
 
-	ctx.RecordCallFailed(gasLeft, reason)
+	ctx.RecordCallFailed(gasLeft, code, reason)
 
 	if reverted {
-		ctx.RecordCallReverted()
+		// Early failures happen before any code is executed, there is never revert data to decode
+		ctx.RecordCallReverted(nil)
 	} else {
 		ctx.RecordGasConsume(gasLeft, gasLeft, FailedExecutionGasChangeReason)
 		gasLeft = 0
 	}
 
-	ctx.printer.Print("EVM_END_CALL",
-		ctx.closeCall(),
-		Uint64(gasLeft),
-		Hex(nil),
-		Uint64(ctx.totalOrderingCounter.Inc()),
-	)
+	ctx.printEndCall(gasLeft, nil, false)
 }
 
 // In-call methods
@@ -512,27 +1410,56 @@ func (ctx *Context) RecordKeccak(hashOfdata common.Hash, data []byte) {
 	if ctx == nil {
 		return
 	}
+	defer ctx.guard()()
 
-	ctx.printer.Print("EVM_KECCAK",
+	ctx.print("EVM_KECCAK",
 		ctx.callIndex(),
 		Hash(hashOfdata),
 		Hex(data),
 	)
+
+	if StorageKeyPreimagesEnabled {
+		ctx.rememberKeccakPreimage(hashOfdata, data)
+	}
+}
+
+// RecordBlockHashRead records the execution of the BLOCKHASH opcode, `number` being the queried
+// block number and `hash` the returned hash, the zero hash when the block is out of the
+// lookup window.
+func (ctx *Context) RecordBlockHashRead(number *big.Int, hash common.Hash) {
+	if ctx == nil {
+		return
+	}
+	defer ctx.guard()()
+
+	ctx.print("BLOCKHASH_READ",
+		ctx.callIndex(),
+		number.String(),
+		Hash(hash),
+	)
+}
+
+// maxKeccakPreimages bounds the amount of preimages kept for a transaction, once reached,
+// the preimages recorded so far are forgotten so that only the most recent ones are kept.
+const maxKeccakPreimages = 4096
+
+func (ctx *Context) rememberKeccakPreimage(hashOfdata common.Hash, data []byte) {
+	if ctx.keccakPreimages == nil || len(ctx.keccakPreimages) >= maxKeccakPreimages {
+		ctx.keccakPreimages = make(map[common.Hash][]byte)
+	}
+
+	// The data is a slice of the EVM memory which is going to be overwritten, it must be copied
+	ctx.keccakPreimages[hashOfdata] = common.CopyBytes(data)
 }
 
 func (ctx *Context) RecordGasRefund(gasOld, gasRefund uint64) {
 	if ctx == nil {
 		return
 	}
+	defer ctx.guard()()
 
 	if gasRefund != 0 {
-		ctx.printer.Print("GAS_CHANGE",
-			ctx.callIndex(),
-			Uint64(gasOld),
-			Uint64(gasOld+gasRefund),
-			string(RefundAfterExecutionGasChangeReason),
-			Uint64(ctx.totalOrderingCounter.Inc()),
-		)
+		ctx.recordGasChange(gasOld, gasOld+gasRefund, RefundAfterExecutionGasChangeReason)
 	}
 }
 
@@ -540,30 +1467,47 @@ func (ctx *Context) RecordGasConsume(gasOld, gasConsumed uint64, reason GasChang
 	if ctx == nil {
 		return
 	}
+	defer ctx.guard()()
 
 	if gasConsumed != 0 && reason != IgnoredGasChangeReason {
-		ctx.printer.Print("GAS_CHANGE",
-			ctx.callIndex(),
-			Uint64(gasOld),
-			Uint64(gasOld-gasConsumed),
-			string(reason),
-			Uint64(ctx.totalOrderingCounter.Inc()),
-		)
+		if !reason.Valid() {
+			ctx.invariantViolated(fmt.Sprintf("gas change reason %q is not registered", reason))
+		}
+
+		ctx.recordGasChange(gasOld, gasOld-gasConsumed, reason)
 	}
 }
 
+// RecordStorageChange records a change of the `key` storage slot of `addr`. When
+// `StorageKeyPreimagesEnabled` is set and the key is the hash of a preimage recorded through
+// `RecordKeccak` in the current transaction (i.e. a mapping slot key), the preimage is
+// printed too.
 func (ctx *Context) RecordStorageChange(addr common.Address, key, oldData, newData common.Hash) {
 	if ctx == nil {
 		return
 	}
+	defer ctx.guard()()
+
+	if preimage, found := ctx.keccakPreimages[key]; found {
+		ctx.print("STORAGE_CHANGE",
+			ctx.callIndex(),
+			Addr(addr),
+			Hash(key),
+			Hash(oldData),
+			Hash(newData),
+			Uint64(ctx.nextOrdinal()),
+			Hex(preimage),
+		)
+		return
+	}
 
-	ctx.printer.Print("STORAGE_CHANGE",
+	ctx.print("STORAGE_CHANGE",
 		ctx.callIndex(),
 		Addr(addr),
 		Hash(key),
 		Hash(oldData),
 		Hash(newData),
-		Uint64(ctx.totalOrderingCounter.Inc()),
+		Uint64(ctx.nextOrdinal()),
 	)
 }
 
@@ -571,20 +1515,34 @@ func (ctx *Context) RecordBalanceChange(addr common.Address, oldBalance, newBala
 	if ctx == nil {
 		return
 	}
+	defer ctx.guard()()
 
 	if reason != IgnoredBalanceChangeReason {
+		if !reason.Valid() {
+			ctx.invariantViolated(fmt.Sprintf("balance change reason %q is not registered", reason))
+		}
+
+		if FeeRecipientCreditEnabled && reason == RewardTransactionFeeBalanceChangeReason {
+			ctx.accumulateFeeCredit(addr, oldBalance, newBalance)
+		}
+
+		if NetBalanceChangesEnabled {
+			ctx.accumulateBalanceChange(addr, oldBalance, newBalance, reason)
+			return
+		}
+
 		// THOUGHTS: There is a choice between storage vs CPU here as we store the old balance and the new balance.
 		//           Usually, balances are quite big. Storing instead the old balance and the delta would probably
 		//           reduce a lot the storage space at the expense of CPU time to compute the delta and recomputed
 		//           the new balance in place where it's required. This would need to be computed (the space
 		//           savings) to see if it make sense to apply it or not.
-		ctx.printer.Print("BALANCE_CHANGE",
+		ctx.print("BALANCE_CHANGE",
 			ctx.callIndex(),
 			Addr(addr),
 			BigInt(oldBalance),
 			BigInt(newBalance),
 			string(reason),
-			Uint64(ctx.totalOrderingCounter.Inc()),
+			Uint64(ctx.nextOrdinal()),
 		)
 	}
 }
@@ -593,20 +1551,25 @@ func (ctx *Context) RecordLog(log *types.Log) {
 	if ctx == nil {
 		return
 	}
+	defer ctx.guard()()
 
 	strtopics := make([]string, len(log.Topics))
 	for idx, topic := range log.Topics {
 		strtopics[idx] = Hash(topic)
 	}
 
-	ctx.printer.Print("ADD_LOG",
+	ctx.print("ADD_LOG",
 		ctx.callIndex(),
 		ctx.logIndexInBlock(),
 		Addr(log.Address),
 		strings.Join(strtopics, ","),
 		Hex(log.Data),
-		Uint64(ctx.totalOrderingCounter.Inc()),
+		Uint64(ctx.nextOrdinal()),
 	)
+
+	if LogsBloomCheckEnabled {
+		ctx.captureLog(log)
+	}
 }
 
 func (ctx *Context) logIndexInBlock() string {
@@ -619,9 +1582,10 @@ func (ctx *Context) RecordSuicide(addr common.Address, suicided bool, balanceBef
 	if ctx == nil {
 		return
 	}
+	defer ctx.guard()()
 
 	// This infers a balance change, a reduction from this account. In the `opSuicide` op code, the corresponding AddBalance is emitted.
-	ctx.printer.Print("SUICIDE_CHANGE",
+	ctx.print("SUICIDE_CHANGE",
 		ctx.callIndex(),
 		Addr(addr),
 		Bool(suicided),
@@ -632,19 +1596,36 @@ func (ctx *Context) RecordSuicide(addr common.Address, suicided bool, balanceBef
 		// We need to explicit add a balance change removing the suicided contract balance since
 		// the remaining balance of the contract has already been resetted to 0 by the time we
 		// do the print call.
-		ctx.RecordBalanceChange(addr, balanceBeforeSuicide, common.Big0, BalanceChangeReason("suicide_withdraw"))
+		ctx.RecordBalanceChange(addr, balanceBeforeSuicide, common.Big0, SuicideWithdrawBalanceChangeReason)
 	}
 }
 
+// RecordStorageWipe records that the whole storage of `addr`, whose root is `storageRoot`,
+// is deleted because the contract self-destructed. It's emitted only when
+// `StorageWipesEnabled` is set.
+func (ctx *Context) RecordStorageWipe(addr common.Address, storageRoot common.Hash) {
+	if ctx == nil || !StorageWipesEnabled {
+		return
+	}
+	defer ctx.guard()()
+
+	ctx.print("STORAGE_WIPED",
+		ctx.callIndex(),
+		Addr(addr),
+		Hash(storageRoot),
+	)
+}
+
 func (ctx *Context) RecordNewAccount(addr common.Address) {
 	if ctx == nil {
 		return
 	}
+	defer ctx.guard()()
 
-	ctx.printer.Print("CREATED_ACCOUNT",
+	ctx.print("CREATED_ACCOUNT",
 		ctx.callIndex(),
 		Addr(addr),
-		Uint64(ctx.totalOrderingCounter.Inc()),
+		Uint64(ctx.informationalOrdinal()),
 	)
 }
 
@@ -652,15 +1633,48 @@ func (ctx *Context) RecordCodeChange(addr common.Address, oldCodeHash, oldCode [
 	if ctx == nil {
 		return
 	}
+	defer ctx.guard()()
+
+	if ContentAddressedCodeStore != nil {
+		ctx.recordContentAddressedCodeChange(addr, oldCodeHash, oldCode, newCodeHash, newCode)
+		return
+	}
+
+	if CompactCodeChangesEnabled {
+		ctx.recordCompactCodeChange(addr, oldCodeHash, oldCode, newCodeHash, newCode)
+		return
+	}
 
-	ctx.printer.Print("CODE_CHANGE",
+	ctx.print("CODE_CHANGE",
 		ctx.callIndex(),
 		Addr(addr),
 		Hex(oldCodeHash),
 		Hex(oldCode),
 		Hash(newCodeHash),
 		Hex(newCode),
-		Uint64(ctx.totalOrderingCounter.Inc()),
+		Uint64(ctx.informationalOrdinal()),
+	)
+}
+
+// recordCompactCodeChange emits a CODE_CHANGE_REF which prints code hashes and lengths
+// instead of the full code bytes. The new code bytes are printed in full only for newly
+// deployed code (i.e. when there is no old code), otherwise the code is reconstructable
+// from its hash.
+func (ctx *Context) recordCompactCodeChange(addr common.Address, oldCodeHash, oldCode []byte, newCodeHash common.Hash, newCode []byte) {
+	newCodeAsString := "."
+	if len(oldCode) == 0 {
+		newCodeAsString = Hex(newCode)
+	}
+
+	ctx.print("CODE_CHANGE_REF",
+		ctx.callIndex(),
+		Addr(addr),
+		Hex(oldCodeHash),
+		Uint(uint(len(oldCode))),
+		Hash(newCodeHash),
+		Uint(uint(len(newCode))),
+		newCodeAsString,
+		Uint64(ctx.informationalOrdinal()),
 	)
 }
 
@@ -668,13 +1682,14 @@ func (ctx *Context) RecordNonceChange(addr common.Address, oldNonce, newNonce ui
 	if ctx == nil {
 		return
 	}
+	defer ctx.guard()()
 
-	ctx.printer.Print("NONCE_CHANGE",
+	ctx.print("NONCE_CHANGE",
 		ctx.callIndex(),
 		Addr(addr),
 		Uint64(oldNonce),
 		Uint64(newNonce),
-		Uint64(ctx.totalOrderingCounter.Inc()),
+		Uint64(ctx.informationalOrdinal()),
 	)
 }
 
@@ -684,6 +1699,7 @@ func (ctx *Context) RecordTrxPool(eventType string, tx *types.Transaction, err e
 	if ctx == nil {
 		return
 	}
+	defer ctx.guard()()
 
 	signer := types.NewEIP155Signer(tx.ChainId())
 
@@ -693,19 +1709,14 @@ func (ctx *Context) RecordTrxPool(eventType string, tx *types.Transaction, err e
 		fromAsString = Addr(from)
 	}
 
-	toAsString := "."
-	if tx.To() != nil {
-		toAsString = Addr(*tx.To())
-	}
-
 	v, r, s := tx.RawSignatureValues()
 
 	//todo: handle error message
-	ctx.printer.Print(
+	ctx.print(
 		eventType,
 		Hash(tx.Hash()),
 		fromAsString,
-		toAsString,
+		OptionalAddr(tx.To()),
 		Hex(tx.Value().Bytes()),
 		Hex(v.Bytes()),
 		Hex(r.Bytes()),
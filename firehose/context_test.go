@@ -0,0 +1,829 @@
+package firehose
+
+import (
+	"bytes"
+	"encoding/json"
+	"math/big"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+func TestRecordStorageChangeKeyPreimage(t *testing.T) {
+	defer func(enabled bool) { StorageKeyPreimagesEnabled = enabled }(StorageKeyPreimagesEnabled)
+	StorageKeyPreimagesEnabled = true
+
+	output := &bytes.Buffer{}
+	ctx := NewContext(NewDelegateToWriterPrinter(output))
+	ctx.inTransaction.Store(true)
+
+	preimage := common.LeftPadBytes([]byte{0x01}, 64)
+	key := crypto.Keccak256Hash(preimage)
+	memory := common.CopyBytes(preimage)
+
+	ctx.RecordKeccak(key, memory)
+	memory[63] = 0xff
+
+	ctx.RecordStorageChange(common.Address{}, key, common.Hash{}, common.Hash{1})
+	ctx.RecordStorageChange(common.Address{}, common.Hash{2}, common.Hash{}, common.Hash{1})
+
+	lines := strings.Split(strings.TrimSpace(output.String()), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("expected 3 lines, got %d:\n%s", len(lines), output.String())
+	}
+
+	if !strings.HasSuffix(lines[1], " "+Hex(preimage)) {
+		t.Errorf("expected storage change with key preimage, got %s", lines[1])
+	}
+
+	if _, fields, _ := splitLine(lines[2]); len(fields) != 6 {
+		t.Errorf("expected storage change without key preimage, got %s", lines[2])
+	}
+}
+
+func TestRecordSlowTransaction(t *testing.T) {
+	defer func(threshold time.Duration) { SlowTransactionThreshold = threshold }(SlowTransactionThreshold)
+	SlowTransactionThreshold = time.Nanosecond
+
+	output := &bytes.Buffer{}
+	ctx := NewContext(NewDelegateToWriterPrinter(output))
+
+	tx := types.NewTransaction(0, common.Address{}, big.NewInt(0), 21000, big.NewInt(1), nil)
+	ctx.StartTransaction(tx, 0, nil)
+	time.Sleep(time.Millisecond)
+	ctx.EndTransaction(&types.Receipt{GasUsed: 21000})
+
+	lines := strings.Split(strings.TrimSpace(output.String()), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("expected 3 lines, got %d:\n%s", len(lines), output.String())
+	}
+
+	event, fields, _ := splitLine(lines[1])
+	if event != "SLOW_TRX" || fields[0] != Hash(tx.Hash()) || fields[2] != "21000" {
+		t.Errorf("unexpected slow transaction event %s", lines[1])
+	}
+}
+
+func TestRecordSlowTransactionProgress(t *testing.T) {
+	defer func(threshold time.Duration) { SlowTransactionThreshold = threshold }(SlowTransactionThreshold)
+	SlowTransactionThreshold = time.Nanosecond
+
+	output := &bytes.Buffer{}
+	ctx := NewContext(NewDelegateToWriterPrinter(output))
+
+	tx := types.NewTransaction(0, common.Address{}, big.NewInt(0), 100000, big.NewInt(1), nil)
+	ctx.StartTransaction(tx, 0, nil)
+	ctx.StartCall("CALL", 79000, 0)
+	ctx.StartCall("CALL", 50000, 20000)
+
+	output.Reset()
+	for i := 0; i < slowTransactionCheckSteps; i++ {
+		ctx.RecordExecutionStep(40000)
+	}
+
+	lines := strings.Split(strings.TrimSpace(output.String()), "\n")
+	if len(lines) != 1 {
+		t.Fatalf("expected a single progress line, got %d:\n%s", len(lines), output.String())
+	}
+
+	// Gas used so far is the gas limit minus the gas left in the executing frame and its parent
+	event, fields, _ := splitLine(lines[0])
+	if event != "SLOW_TRX_PROGRESS" || fields[0] != Hash(tx.Hash()) || fields[2] != "40000" {
+		t.Errorf("unexpected slow transaction progress event %s", lines[0])
+	}
+}
+
+func TestNetBalanceChanges(t *testing.T) {
+	defer func(enabled bool) { NetBalanceChangesEnabled = enabled }(NetBalanceChangesEnabled)
+	NetBalanceChangesEnabled = true
+
+	output := &bytes.Buffer{}
+	ctx := NewContext(NewDelegateToWriterPrinter(output))
+	ctx.inTransaction.Store(true)
+
+	addr := common.Address{1}
+	change := func(old, new int64, reason BalanceChangeReason) {
+		ctx.RecordBalanceChange(addr, big.NewInt(old), big.NewInt(new), reason)
+	}
+
+	ctx.StartCall("CALL", 100, 0)
+	change(100, 90, TransferBalanceChangeReason)
+	change(90, 95, GasBuyBalanceChangeReason)
+	change(95, 85, TransferBalanceChangeReason)
+	ctx.StartCall("CALL", 50, 50)
+	change(85, 80, TransferBalanceChangeReason)
+	change(80, 75, TransferBalanceChangeReason)
+	ctx.EndCall(10, nil)
+	change(75, 70, TransferBalanceChangeReason)
+	ctx.EndCall(0, nil)
+
+	var changes []string
+	for _, line := range strings.Split(strings.TrimSpace(output.String()), "\n") {
+		if event, fields, _ := splitLine(line); event == "BALANCE_CHANGE" {
+			changes = append(changes, strings.Join(fields[:5], " "))
+		}
+	}
+
+	bigInt := func(value int64) string { return BigInt(big.NewInt(value)) }
+	expected := []string{
+		"1 " + Addr(addr) + " " + bigInt(100) + " " + bigInt(80) + " transfer",
+		"1 " + Addr(addr) + " " + bigInt(90) + " " + bigInt(95) + " gas_buy",
+		"2 " + Addr(addr) + " " + bigInt(85) + " " + bigInt(75) + " transfer",
+		"1 " + Addr(addr) + " " + bigInt(75) + " " + bigInt(70) + " transfer",
+	}
+	if strings.Join(changes, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("unexpected balance changes:\n%s\nwant:\n%s", strings.Join(changes, "\n"), strings.Join(expected, "\n"))
+	}
+
+	if result, err := Check(strings.NewReader("FIRE BEGIN_BLOCK 1 " + strings.Repeat("01", 32) + " " + strings.Repeat("00", 32) + " 1000 1 600\n" +
+		"FIRE BEGIN_APPLY_TRX " + strings.Repeat("00", 32) + " . . . . . 21000 01 0 . 00 . . 0 0 0 standard\n" + output.String() +
+		"FIRE END_APPLY_TRX 21000 . 21000 00 100 []\nFIRE END_BLOCK 1 100 {}\n")); err != nil || !result.Valid() {
+		t.Errorf("expected net balance changes to pass the checker, got %v %v", err, result.Violations)
+	}
+}
+
+func TestMaybeSyncContextForBlock(t *testing.T) {
+	defer func(enabled bool, startBlock uint64) { Enabled, StartBlockNumber = enabled, startBlock }(Enabled, StartBlockNumber)
+	Enabled, StartBlockNumber = true, 100
+
+	if MaybeSyncContextForBlock(99).Enabled() || !BlockProgressEnabledForBlock(99) {
+		t.Errorf("expected block 99 to only emit block progress")
+	}
+
+	if !MaybeSyncContextForBlock(100).Enabled() || BlockProgressEnabledForBlock(100) {
+		t.Errorf("expected block 100 to be fully instrumented")
+	}
+}
+
+func TestBoundedSpeculativeExecutionContext(t *testing.T) {
+	ctx := NewBoundedSpeculativeExecutionContext(0, 100)
+	ctx.inTransaction.Store(true)
+
+	aborted := 0
+	ctx.OnBufferLimitExceeded(func() { aborted++ })
+
+	ctx.RecordNonceChange(common.Address{}, 0, 1)
+	if ctx.BufferLimitExceeded() || len(ctx.FirehoseLog()) == 0 {
+		t.Fatalf("expected first line to fit in the buffer")
+	}
+
+	ctx.RecordNonceChange(common.Address{}, 1, 2)
+	ctx.RecordNonceChange(common.Address{}, 2, 3)
+	if !ctx.BufferLimitExceeded() || aborted != 1 {
+		t.Fatalf("expected buffer limit to be exceeded once, got exceeded %t and %d abort(s)", ctx.BufferLimitExceeded(), aborted)
+	}
+	if len(ctx.FirehoseLog()) != 0 {
+		t.Errorf("expected the partial buffer to be discarded, got %q", ctx.FirehoseLog())
+	}
+}
+
+func TestSpeculativeExecutionContextSpill(t *testing.T) {
+	defer func(threshold int) { SpillThresholdInBytes = threshold }(SpillThresholdInBytes)
+	SpillThresholdInBytes = 200
+
+	ctx := NewSpeculativeExecutionContext(0)
+	ctx.inTransaction.Store(true)
+	printer := ctx.printer.(*ToBufferPrinter)
+
+	expected := &bytes.Buffer{}
+	expectedCtx := NewContext(NewDelegateToWriterPrinter(expected))
+	expectedCtx.inTransaction.Store(true)
+
+	for i := uint64(0); i < 50; i++ {
+		ctx.RecordNonceChange(common.Address{byte(i)}, i, i+1)
+		expectedCtx.RecordNonceChange(common.Address{byte(i)}, i, i+1)
+	}
+
+	if !printer.Spilled() || printer.buffer.Len() != 0 {
+		t.Fatalf("expected lines to be spilled, spilled %t with %d bytes in memory", printer.Spilled(), printer.buffer.Len())
+	}
+	if string(ctx.FirehoseLog()) != expected.String() {
+		t.Fatalf("unexpected spilled log:\n%s\nwant:\n%s", ctx.FirehoseLog(), expected.String())
+	}
+
+	// Lines keep being appended once the spilled lines were read back
+	ctx.RecordNonceChange(common.Address{}, 50, 51)
+	expectedCtx.RecordNonceChange(common.Address{}, 50, 51)
+
+	output := &bytes.Buffer{}
+	NewContext(NewDelegateToWriterPrinter(output)).FlushTransaction(ctx)
+	if output.String() != expected.String() {
+		t.Fatalf("unexpected flushed log:\n%s\nwant:\n%s", output.String(), expected.String())
+	}
+
+	if printer.Spilled() || printer.Len() != 0 {
+		t.Errorf("expected flush to discard the spill file, spilled %t with %d bytes", printer.Spilled(), printer.Len())
+	}
+}
+
+func TestSpeculativeExecutionContextSpillFailure(t *testing.T) {
+	defer func(threshold int, strict bool) { SpillThresholdInBytes, StrictEnabled = threshold, strict }(SpillThresholdInBytes, StrictEnabled)
+	SpillThresholdInBytes = 200
+	StrictEnabled = false
+
+	ctx := NewSpeculativeExecutionContext(0)
+	ctx.inTransaction.Store(true)
+	printer := ctx.printer.(*ToBufferPrinter)
+
+	for i := uint64(0); i < 50; i++ {
+		ctx.RecordNonceChange(common.Address{byte(i)}, i, i+1)
+	}
+
+	// The spilled lines can't be read back anymore
+	printer.spillFile.Close()
+
+	output := &bytes.Buffer{}
+	NewContext(NewDelegateToWriterPrinter(output)).FlushTransaction(ctx)
+	if !strings.Contains(output.String(), "FIRE ERROR unable to flush transaction spilled lines") {
+		t.Fatalf("expected spill failure to be reported as an invariant violation, got:\n%s", output.String())
+	}
+
+	printer.spillFailed = true
+	printer.Reset()
+	if printer.spillFailed || printer.spillErr != nil {
+		t.Errorf("expected reset to clear the spill failure, spill failed %t with error %v", printer.spillFailed, printer.spillErr)
+	}
+}
+
+type staticTdProvider map[common.Hash]*big.Int
+
+func (p staticTdProvider) GetTd(hash common.Hash, number uint64) *big.Int {
+	return p[hash]
+}
+
+func TestEndBlockTotalDifficulty(t *testing.T) {
+	parentHash := common.Hash{1}
+	block := types.NewBlockWithHeader(&types.Header{Number: big.NewInt(1), ParentHash: parentHash, Difficulty: big.NewInt(10)})
+
+	for _, test := range []struct {
+		name     string
+		provider TotalDifficultyProvider
+		override *big.Int
+		expected string
+	}{
+		{"provider", staticTdProvider{parentHash: big.NewInt(100)}, nil, `"totalDifficulty":"0x6e"`},
+		{"override", staticTdProvider{parentHash: big.NewInt(100)}, big.NewInt(5), `"totalDifficulty":"0x5"`},
+		{"unknown parent", staticTdProvider{}, nil, `"totalDifficulty":null`},
+		{"no provider", nil, nil, `"totalDifficulty":null`},
+	} {
+		output := &bytes.Buffer{}
+		ctx := NewContext(NewDelegateToWriterPrinter(output))
+		ctx.SetTotalDifficultyProvider(test.provider)
+
+		ctx.StartBlock(block)
+		ctx.EndBlock(block, test.override)
+
+		if !strings.Contains(output.String(), test.expected) {
+			t.Errorf("%s: expected END_BLOCK with %s, got %s", test.name, test.expected, output.String())
+		}
+	}
+}
+
+type staticBlockProvider map[common.Hash]*types.Block
+
+func (p staticBlockProvider) GetBlock(hash common.Hash, number uint64) *types.Block {
+	return p[hash]
+}
+
+func TestEndBlockUncleBlocks(t *testing.T) {
+	defer func(enabled bool) { UncleBlocksEnabled = enabled }(UncleBlocksEnabled)
+	UncleBlocksEnabled = true
+
+	tx := types.NewTransaction(7, common.Address{2}, big.NewInt(1), 21000, big.NewInt(1), nil)
+	knownUncle := &types.Header{Number: big.NewInt(1), Extra: []byte("known")}
+	unknownUncle := &types.Header{Number: big.NewInt(1), Extra: []byte("unknown")}
+	block := types.NewBlock(&types.Header{Number: big.NewInt(2)}, nil, []*types.Header{unknownUncle, knownUncle}, nil)
+
+	output := &bytes.Buffer{}
+	ctx := NewContext(NewDelegateToWriterPrinter(output))
+	ctx.SetBlockProvider(staticBlockProvider{
+		knownUncle.Hash(): types.NewBlock(knownUncle, []*types.Transaction{tx}, nil, nil),
+	})
+
+	ctx.StartBlock(block)
+	ctx.EndBlock(block, nil)
+
+	lines := strings.Split(strings.TrimSpace(output.String()), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("expected BEGIN_BLOCK, UNCLE_BLOCK and END_BLOCK, got %q", output.String())
+	}
+
+	prefix := "FIRE UNCLE_BLOCK 1 1 " + Hash(knownUncle.Hash()) + " "
+	if !strings.HasPrefix(lines[1], prefix) || !strings.Contains(lines[1], `"nonce":"0x7"`) {
+		t.Errorf("expected UNCLE_BLOCK of the known uncle with its transaction, got %q", lines[1])
+	}
+}
+
+func TestCallAccessSet(t *testing.T) {
+	defer func(enabled bool) { CallAccessSetsEnabled = enabled }(CallAccessSetsEnabled)
+	CallAccessSetsEnabled = true
+
+	output := &bytes.Buffer{}
+	ctx := NewContext(NewDelegateToWriterPrinter(output))
+
+	from, to, other := common.Address{1}, common.Address{2}, common.Address{3}
+	tx := types.NewTransaction(0, to, big.NewInt(0), 21000, big.NewInt(1), nil)
+
+	ctx.StartTransaction(tx, 0, nil)
+	ctx.RecordTrxFrom(from, nil)
+	ctx.StartCall("CALL", 100, 0)
+	ctx.RecordCallParams("CALL", from, to, big.NewInt(0), 100, nil)
+	ctx.RecordStorageAccess(to, common.Hash{1})
+	ctx.RecordStorageAccess(to, common.Hash{1})
+	ctx.StartCall("CALL", 50, 50)
+	ctx.RecordCallParams("CALL", to, other, big.NewInt(0), 50, nil)
+	ctx.RecordAddressAccess(to)
+	ctx.EndCall(50, nil)
+	ctx.RecordAddressAccess(other)
+	ctx.EndCall(50, nil)
+
+	var accessSets []string
+	for _, line := range strings.Split(output.String(), "\n") {
+		if event, fields, _ := splitLine(line); event == "CALL_ACCESS_SET" {
+			accessSets = append(accessSets, fields[0]+" "+fields[1])
+		}
+	}
+
+	expected := []string{
+		`2 {"addresses":[{"address":"0x0300000000000000000000000000000000000000","warm":false},{"address":"0x0200000000000000000000000000000000000000","warm":true}],"slots":[]}`,
+		`1 {"addresses":[{"address":"0x0200000000000000000000000000000000000000","warm":true},{"address":"0x0300000000000000000000000000000000000000","warm":true}],"slots":[{"address":"0x0200000000000000000000000000000000000000","key":"0x0100000000000000000000000000000000000000000000000000000000000000","warm":false}]}`,
+	}
+	if strings.Join(accessSets, "\n") != strings.Join(expected, "\n") {
+		t.Fatalf("unexpected access sets, got:\n%s\nwant:\n%s", strings.Join(accessSets, "\n"), strings.Join(expected, "\n"))
+	}
+}
+
+func TestRecordCallFailedSite(t *testing.T) {
+	output := &bytes.Buffer{}
+	ctx := NewContext(NewDelegateToWriterPrinter(output))
+
+	tx := types.NewTransaction(0, common.Address{2}, big.NewInt(0), 21000, big.NewInt(1), nil)
+
+	ctx.StartTransaction(tx, 0, nil)
+	ctx.StartCall("CALL", 100, 0)
+	ctx.StartCall("CALL", 50, 50)
+	ctx.EndFailedCall(50, true, CallFailureCode("depth"), "max call depth exceeded")
+	ctx.RecordCallFailureSite(42, 0xfd)
+	ctx.RecordCallFailed(10, CallFailureCode("reverted"), "execution reverted")
+
+	var failures []string
+	for _, line := range strings.Split(output.String(), "\n") {
+		if event, fields, _ := splitLine(line); event == "EVM_CALL_FAILED" {
+			failures = append(failures, strings.Join(fields, " "))
+		}
+	}
+
+	expected := []string{
+		"2 50 depth . . 2 max call depth exceeded",
+		"1 10 reverted 42 fd 1 execution reverted",
+	}
+	if strings.Join(failures, "\n") != strings.Join(expected, "\n") {
+		t.Fatalf("unexpected call failures, got:\n%s\nwant:\n%s", strings.Join(failures, "\n"), strings.Join(expected, "\n"))
+	}
+}
+
+func TestRecordStorageWipe(t *testing.T) {
+	defer func(enabled bool) { StorageWipesEnabled = enabled }(StorageWipesEnabled)
+
+	output := &bytes.Buffer{}
+	ctx := NewContext(NewDelegateToWriterPrinter(output))
+	ctx.inTransaction.Store(true)
+
+	StorageWipesEnabled = false
+	ctx.RecordStorageWipe(common.Address{1}, common.Hash{2})
+	if output.Len() != 0 {
+		t.Fatalf("unexpected output while disabled: %s", output.String())
+	}
+
+	StorageWipesEnabled = true
+	ctx.RecordStorageWipe(common.Address{1}, common.Hash{2})
+
+	event, fields, _ := splitLine(strings.TrimSpace(output.String()))
+	if event != "STORAGE_WIPED" || strings.Join(fields, " ") != "0 "+Addr(common.Address{1})+" "+Hash(common.Hash{2}) {
+		t.Fatalf("unexpected storage wipe line %q", output.String())
+	}
+}
+
+func TestFinalizeBlockMode(t *testing.T) {
+	defer func(enabled bool) { BlockProgressDetailsEnabled = enabled }(BlockProgressDetailsEnabled)
+
+	block := types.NewBlockWithHeader(&types.Header{Number: big.NewInt(1)})
+
+	output := &bytes.Buffer{}
+	ctx := NewContext(NewDelegateToWriterPrinter(output))
+
+	ctx.FinalizeBlock(block)
+	if output.String() != "FIRE FINALIZE_BLOCK 1 progress\n" {
+		t.Fatalf("expected progress mode outside a block, got %q", output.String())
+	}
+
+	BlockProgressDetailsEnabled = true
+	output.Reset()
+	ctx.FinalizeBlock(block)
+
+	expected := "FIRE BLOCK_PROGRESS 1 " + Hash(block.Hash()) + " " + Hash(common.Hash{}) + " 0 0\nFIRE FINALIZE_BLOCK 1 progress\n"
+	if output.String() != expected {
+		t.Fatalf("unexpected detailed progress, have:\n%swant:\n%s", output.String(), expected)
+	}
+
+	output.Reset()
+	ctx.StartBlock(block)
+	ctx.FinalizeBlock(block)
+	ctx.EndBlock(block, nil)
+
+	lines := strings.Split(strings.TrimSpace(output.String()), "\n")
+	if len(lines) != 3 || lines[1] != "FIRE FINALIZE_BLOCK 1 full" {
+		t.Fatalf("expected full mode within a block, got %q", output.String())
+	}
+
+	report, err := Check(strings.NewReader(output.String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Violations) != 0 {
+		t.Errorf("unexpected violations %v", report.Violations)
+	}
+}
+
+func TestMonotonicTimestamps(t *testing.T) {
+	defer func(enabled bool) { MonotonicTimestampsEnabled = enabled }(MonotonicTimestampsEnabled)
+	MonotonicTimestampsEnabled = true
+
+	block := types.NewBlockWithHeader(&types.Header{Number: big.NewInt(1)})
+
+	output := &bytes.Buffer{}
+	ctx := NewContext(NewDelegateToWriterPrinter(output))
+	ctx.StartBlock(block)
+	ctx.EndBlock(block, nil)
+
+	lines := strings.Split(strings.TrimSpace(output.String()), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("expected BEGIN_BLOCK, CLOCK_ANCHOR and END_BLOCK, got %q", output.String())
+	}
+
+	var previous uint64
+	for i, expected := range []string{"BEGIN_BLOCK", "CLOCK_ANCHOR", "END_BLOCK"} {
+		_, timestamp, event, _, ok := splitTaggedLine(lines[i])
+		if !ok || event != expected {
+			t.Fatalf("expected %s line, got %q", expected, lines[i])
+		}
+
+		value, err := strconv.ParseUint(timestamp, 10, 64)
+		if err != nil || value < previous {
+			t.Fatalf("expected increasing monotonic timestamp, got %q after %d", timestamp, previous)
+		}
+		previous = value
+	}
+
+	converted := &bytes.Buffer{}
+	writeNDJSONLine(converted, lines[0]+"\n")
+	if !strings.HasPrefix(converted.String(), `{"event":"BEGIN_BLOCK","mono_ns":"`) {
+		t.Errorf("expected the NDJSON line to carry the monotonic timestamp, got %s", converted.String())
+	}
+}
+
+func TestSequenceNumbers(t *testing.T) {
+	defer func(enabled bool) { SequenceNumbersEnabled = enabled }(SequenceNumbersEnabled)
+	SequenceNumbersEnabled = true
+
+	block := types.NewBlockWithHeader(&types.Header{Number: big.NewInt(1)})
+
+	output := &bytes.Buffer{}
+	ctx := NewContext(NewDelegateToWriterPrinter(output))
+	ctx.StartBlock(block)
+	ctx.printer.(RawPrinter).PrintRaw("FIRE TRX_FROM 00\nnot a firehose line\nFIRE TRX_FROM 01\n")
+	ctx.EndBlock(block, nil)
+
+	lines := strings.Split(strings.TrimSpace(output.String()), "\n")
+	if len(lines) != 5 {
+		t.Fatalf("expected 5 lines, got %q", output.String())
+	}
+
+	for i, want := range []string{"1 BEGIN_BLOCK", "2 TRX_FROM", "", "3 TRX_FROM", "4 END_BLOCK"} {
+		sequence, _, event, _, ok := splitTaggedLine(lines[i])
+		if want == "" {
+			if ok || lines[i] != "not a firehose line" {
+				t.Fatalf("expected non Firehose line to be left untouched, got %q", lines[i])
+			}
+			continue
+		}
+
+		if !ok || sequence+" "+event != want {
+			t.Fatalf("expected line %q, got %q", want, lines[i])
+		}
+	}
+
+	report, err := Check(strings.NewReader(output.String()))
+	if err != nil || !report.Valid() {
+		t.Fatalf("expected numbered stream to be valid, got %v (%v)", report.Violations, err)
+	}
+
+	converted := &bytes.Buffer{}
+	writeNDJSONLine(converted, lines[0]+"\n")
+	if !strings.HasPrefix(converted.String(), `{"event":"BEGIN_BLOCK","seq":"1",`) {
+		t.Errorf("expected the NDJSON line to carry the sequence number, got %s", converted.String())
+	}
+}
+
+func TestSequenceNumbersBlockSegments(t *testing.T) {
+	defer func(enabled bool, shardSize int) {
+		SequenceNumbersEnabled, BlockShardSizeInBytes = enabled, shardSize
+	}(SequenceNumbersEnabled, BlockShardSizeInBytes)
+	SequenceNumbersEnabled = true
+	BlockShardSizeInBytes = 256
+
+	block := types.NewBlockWithHeader(&types.Header{Number: big.NewInt(1)})
+
+	output := &bytes.Buffer{}
+	ctx := NewContext(NewDelegateToWriterPrinter(output))
+	txContext := NewSpeculativeExecutionContext(1024)
+
+	ctx.StartBlock(block)
+	txContext.StartTransaction(types.NewTransaction(0, common.Address{1}, big.NewInt(0), 21000, big.NewInt(1), nil), 0, nil)
+	txContext.StartCall("CALL", 21000, 0)
+	for i := 0; i < 8; i++ {
+		txContext.RecordLog(&types.Log{Address: common.Address{1}})
+	}
+	txContext.EndCall(0, nil)
+	txContext.EndTransaction(&types.Receipt{GasUsed: 21000})
+	ctx.FlushTransaction(txContext)
+	ctx.FinalizeBlock(block)
+	ctx.EndBlock(block, nil)
+
+	// Each segment's size must be the one of the numbered lines following its header
+	stream := output.String()
+	segments := 0
+	for len(stream) > 0 {
+		end := strings.IndexByte(stream, '\n') + 1
+		line := stream[:end]
+		stream = stream[end:]
+
+		_, _, event, fields, ok := splitTaggedLine(strings.TrimSuffix(line, "\n"))
+		if !ok || event != "BLOCK_SEGMENT" {
+			continue
+		}
+
+		segments++
+		size, err := strconv.Atoi(fields[2])
+		if err != nil || size > len(stream) {
+			t.Fatalf("invalid segment size in %q", line)
+		}
+		if segment := stream[:size]; !strings.HasSuffix(segment, "\n") || (len(stream) > size && !strings.HasPrefix(stream[size:], "FIRE #")) {
+			t.Fatalf("segment size %d does not match the lines written:\n%s", size, stream)
+		}
+		stream = stream[size:]
+	}
+	if segments < 2 {
+		t.Fatalf("expected transaction to be split in several segments, got %d:\n%s", segments, output.String())
+	}
+
+	report, err := Check(strings.NewReader(output.String()))
+	if err != nil || !report.Valid() {
+		t.Fatalf("expected numbered segmented stream to be valid, got %v (%v)", report.Violations, err)
+	}
+}
+
+func TestRecordBlockHashRead(t *testing.T) {
+	output := &bytes.Buffer{}
+	ctx := NewContext(NewDelegateToWriterPrinter(output))
+	ctx.inTransaction.Store(true)
+
+	ctx.RecordBlockHashRead(big.NewInt(42), common.Hash{3})
+
+	event, fields, _ := splitLine(strings.TrimSpace(output.String()))
+	if event != "BLOCKHASH_READ" || strings.Join(fields, " ") != "0 42 "+Hash(common.Hash{3}) {
+		t.Fatalf("unexpected blockhash read line %q", output.String())
+	}
+}
+
+func TestLogsBloomCheck(t *testing.T) {
+	defer func(enabled, strict bool) { LogsBloomCheckEnabled, StrictEnabled = enabled, strict }(LogsBloomCheckEnabled, StrictEnabled)
+	LogsBloomCheckEnabled = true
+	StrictEnabled = false
+
+	output := &bytes.Buffer{}
+	ctx := NewContext(NewDelegateToWriterPrinter(output))
+
+	kept := &types.Log{Address: common.Address{1}, Topics: []common.Hash{{1}}}
+	reverted := &types.Log{Address: common.Address{2}, Topics: []common.Hash{{2}}}
+	receipt := &types.Receipt{Logs: []*types.Log{kept}}
+	receipt.Bloom = types.CreateBloom(types.Receipts{receipt})
+
+	// Logs of the failed sub-call are reverted and not part of the receipt's bloom
+	ctx.StartTransaction(types.NewTransaction(0, common.Address{}, big.NewInt(0), 21000, big.NewInt(1), nil), 0, nil)
+	ctx.StartCall("CALL", 100, 0)
+	ctx.RecordLog(kept)
+	ctx.StartCall("CALL", 50, 50)
+	ctx.RecordLog(reverted)
+	ctx.RecordCallFailed(10, CallFailureCode("reverted"), "execution reverted")
+	ctx.EndCall(10, nil)
+	ctx.EndCall(60, nil)
+	ctx.EndTransaction(receipt)
+
+	if strings.Contains(output.String(), "FIRE ERROR") {
+		t.Fatalf("unexpected bloom mismatch:\n%s", output.String())
+	}
+
+	// A log missing from the captured ones makes the blooms differ
+	ctx.StartTransaction(types.NewTransaction(1, common.Address{}, big.NewInt(0), 21000, big.NewInt(1), nil), 1, nil)
+	ctx.StartCall("CALL", 100, 0)
+	ctx.EndCall(60, nil)
+	ctx.EndTransaction(receipt)
+
+	if !strings.Contains(output.String(), "FIRE ERROR transaction "+receipt.TxHash.Hex()+" receipt bloom does not match the bloom of its 0 captured log(s), receipt has 1 log(s)") {
+		t.Fatalf("expected bloom mismatch to be reported, got:\n%s", output.String())
+	}
+}
+
+func TestOrderingCheckpoint(t *testing.T) {
+	defer func(enabled bool) { OrderingCheckpointsEnabled = enabled }(OrderingCheckpointsEnabled)
+	OrderingCheckpointsEnabled = true
+
+	block := types.NewBlockWithHeader(&types.Header{Number: big.NewInt(1)})
+
+	output := &bytes.Buffer{}
+	ctx := NewContext(NewDelegateToWriterPrinter(output))
+	txContext := NewSpeculativeExecutionContext(1024)
+
+	ctx.StartBlock(block)
+	for i := 0; i < 2; i++ {
+		txContext.StartTransaction(types.NewTransaction(uint64(i), common.Address{1}, big.NewInt(0), 21000, big.NewInt(1), nil), uint(i), nil)
+		txContext.RecordTrxFrom(common.Address{2}, nil)
+		txContext.StartCall("CALL", 21000, 0)
+		txContext.RecordLog(&types.Log{Address: common.Address{1}})
+		txContext.EndCall(0, nil)
+		txContext.EndTransaction(&types.Receipt{GasUsed: 21000})
+		ctx.FlushTransaction(txContext)
+	}
+	ctx.FinalizeBlock(block)
+	ctx.EndBlock(block, nil)
+
+	lines := strings.Split(strings.TrimSpace(output.String()), "\n")
+	expected := `FIRE FINALIZE_BLOCK 1 full 10 {"block":1,"call":4,"log":2,"trx":6}`
+	if lines[len(lines)-2] != expected {
+		t.Fatalf("unexpected checkpoint, have:\n%s\nwant:\n%s", lines[len(lines)-2], expected)
+	}
+
+	report, err := Check(strings.NewReader(output.String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Violations) != 0 {
+		t.Errorf("unexpected violations %v", report.Violations)
+	}
+
+	// A log lost on the way is caught by the checkpoint
+	partial := append(append([]string{}, lines[:4]...), lines[5:]...)
+	report, err = Check(strings.NewReader(strings.Join(partial, "\n") + "\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Violations) != 1 || report.Violations[0].Message != "FINALIZE_BLOCK checkpoint reports 2 log event(s), 1 received" {
+		t.Errorf("expected a checkpoint violation, got %v", report.Violations)
+	}
+}
+
+func TestReturnDataLimit(t *testing.T) {
+	defer func(limit int) { ReturnDataLimitInBytes = limit }(ReturnDataLimitInBytes)
+
+	endCall := func(returnValue []byte) []string {
+		output := &bytes.Buffer{}
+		ctx := NewContext(NewDelegateToWriterPrinter(output))
+		ctx.StartTransaction(types.NewTransaction(0, common.Address{1}, big.NewInt(0), 21000, big.NewInt(1), nil), 0, nil)
+		ctx.StartCall("CALL", 21000, 0)
+		output.Reset()
+		ctx.EndCall(0, returnValue)
+
+		_, fields, _ := splitLine(strings.TrimSpace(output.String()))
+		return fields
+	}
+
+	ReturnDataLimitInBytes = 0
+	if fields := endCall([]byte{1, 2, 3}); len(fields) != 6 || fields[2] != "010203" {
+		t.Errorf("unexpected untruncated EVM_END_CALL fields %v", fields)
+	}
+
+	ReturnDataLimitInBytes = 2
+	if fields := endCall([]byte{1, 2, 3}); len(fields) != 8 || fields[2] != "0102" || fields[6] != "false" || fields[7] != "3" {
+		t.Errorf("unexpected truncated EVM_END_CALL fields %v", fields)
+	}
+	if fields := endCall(nil); len(fields) != 8 || fields[2] != "." || fields[7] != "0" {
+		t.Errorf("unexpected empty EVM_END_CALL fields %v", fields)
+	}
+}
+
+func TestGasChangeCoalescing(t *testing.T) {
+	defer func(enabled bool) { GasChangeCoalescingEnabled = enabled }(GasChangeCoalescingEnabled)
+	GasChangeCoalescingEnabled = true
+
+	output := &bytes.Buffer{}
+	ctx := NewContext(NewDelegateToWriterPrinter(output))
+	ctx.inTransaction.Store(true)
+
+	ctx.StartCall("CALL", 100, 0)
+	ctx.RecordGasConsume(100, 3, CodeCopyGasChangeReason)
+	ctx.RecordGasConsume(97, 3, CodeCopyGasChangeReason)
+	ctx.RecordGasConsume(94, 3, CodeCopyGasChangeReason)
+	ctx.RecordGasConsume(91, 5, CallDataCopyGasChangeReason)
+	ctx.RecordGasConsume(86, 5, CallDataCopyGasChangeReason)
+	ctx.RecordLog(&types.Log{Address: common.Address{1}})
+	ctx.RecordGasConsume(81, 5, CallDataCopyGasChangeReason)
+	// Not starting at the gas left by the previous change, it's not merged
+	ctx.RecordGasConsume(70, 5, CallDataCopyGasChangeReason)
+	ctx.EndCall(65, nil)
+
+	var changes []string
+	for _, line := range strings.Split(strings.TrimSpace(output.String()), "\n") {
+		event, fields, _ := splitLine(line)
+		if event == "GAS_CHANGE" {
+			event += " " + strings.Join(append(fields[1:4:4], fields[5]), " ")
+		}
+		changes = append(changes, event)
+	}
+
+	expected := []string{
+		"EVM_RUN_CALL",
+		"GAS_CHANGE 100 91 " + string(CodeCopyGasChangeReason) + " 3",
+		"GAS_CHANGE 91 81 " + string(CallDataCopyGasChangeReason) + " 2",
+		"ADD_LOG",
+		"GAS_CHANGE 81 76 " + string(CallDataCopyGasChangeReason) + " 1",
+		"GAS_CHANGE 70 65 " + string(CallDataCopyGasChangeReason) + " 1",
+		"EVM_END_CALL",
+	}
+	if strings.Join(changes, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("unexpected gas changes:\n%s\nwant:\n%s", strings.Join(changes, "\n"), strings.Join(expected, "\n"))
+	}
+}
+
+func TestTrxGasRefund(t *testing.T) {
+	defer func(enabled bool) { TrxGasRefundsEnabled = enabled }(TrxGasRefundsEnabled)
+	TrxGasRefundsEnabled = true
+
+	output := &bytes.Buffer{}
+	ctx := NewContext(NewDelegateToWriterPrinter(output))
+	ctx.StartTransaction(types.NewTransaction(0, common.Address{1}, big.NewInt(0), 100000, big.NewInt(1), nil), 0, nil)
+	begin := output.String()
+	output.Reset()
+
+	ctx.RecordTrxGasRefund(1000, 0, 0, HalfGasUsedRefundCapRule)
+	if output.Len() != 0 {
+		t.Fatalf("expected no accumulated refund to emit nothing, got %q", output.String())
+	}
+
+	ctx.RecordTrxGasRefund(1000, 15000, 10500, HalfGasUsedRefundCapRule)
+	expected := "FIRE TRX_GAS_REFUND 1000 15000 10500 half_gas_used\n"
+	if output.String() != expected {
+		t.Fatalf("unexpected refund, have:\n%swant:\n%s", output.String(), expected)
+	}
+
+	report, err := Check(strings.NewReader(begin + output.String() + "FIRE TRX_GAS_REFUND 1000 100 200 eip3529\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var violations []string
+	for _, violation := range report.Violations {
+		if strings.Contains(violation.Message, "TRX_GAS_REFUND") {
+			violations = append(violations, violation.String())
+		}
+	}
+	if len(violations) != 1 || !strings.Contains(violations[0], "line 3: TRX_GAS_REFUND applied refund 200 exceeds the accumulated refund 100") {
+		t.Errorf("expected only the applied refund exceeding the accumulated one to be reported, got %v", violations)
+	}
+}
+
+func TestStreamHeaderFeatures(t *testing.T) {
+	defer func(shardSize int, preimages bool) {
+		BlockShardSizeInBytes, StorageKeyPreimagesEnabled = shardSize, preimages
+	}(BlockShardSizeInBytes, StorageKeyPreimagesEnabled)
+	BlockShardSizeInBytes, StorageKeyPreimagesEnabled = 256, true
+
+	output := &bytes.Buffer{}
+	NewContext(NewDelegateToWriterPrinter(output)).StreamHeader("1.0.0", "abc", "3.0", GethVariant)
+
+	var header struct {
+		Features map[string]interface{} `json:"features"`
+	}
+	if err := json.Unmarshal([]byte(strings.TrimPrefix(strings.TrimSpace(output.String()), "FIRE STREAM_HEADER ")), &header); err != nil {
+		t.Fatalf("invalid STREAM_HEADER line %q: %s", output.String(), err)
+	}
+
+	if len(header.Features) != len(featureToggles) {
+		t.Errorf("expected every feature toggle in the manifest, have %d features, want %d", len(header.Features), len(featureToggles))
+	}
+	for name, expected := range map[string]interface{}{
+		"block_shard_size":      float64(256),
+		"storage_key_preimages": true,
+		"code_store":            false,
+		"output_format":         string(TextOutputFormat),
+	} {
+		if have := header.Features[name]; have != expected {
+			t.Errorf("feature %q mismatch, have %v, want %v", name, have, expected)
+		}
+	}
+}
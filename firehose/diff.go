@@ -0,0 +1,215 @@
+package firehose
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// diffIgnoredEvents are the events depending on the node's run (timings, segmentation,
+// reference node) rather than on the chain data, they are left out of the comparison.
+var diffIgnoredEvents = map[string]bool{
+	"TRIE_COMMIT":       true,
+	"SLOW_TRX":          true,
+	"SLOW_TRX_PROGRESS": true,
+	"BLOCK_SEGMENT":     true,
+	"BLOCK_SEGMENTS":    true,
+	"DIVERGENCE":        true,
+}
+
+// BlockDifference is the first semantic difference found between the two captures of a
+// block. The line numbers are 0 and the events empty for the capture missing the block or
+// the event.
+type BlockDifference struct {
+	Block  uint64
+	LineA  uint64
+	LineB  uint64
+	EventA string
+	EventB string
+}
+
+func (d BlockDifference) String() string {
+	switch {
+	case d.LineA == 0 && d.EventA == "" && d.EventB == "":
+		return fmt.Sprintf("block %d: missing from first capture", d.Block)
+	case d.LineB == 0 && d.EventA == "" && d.EventB == "":
+		return fmt.Sprintf("block %d: missing from second capture", d.Block)
+	}
+
+	return fmt.Sprintf("block %d:\n  a (line %d): %s\n  b (line %d): %s", d.Block, d.LineA, orMissing(d.EventA), d.LineB, orMissing(d.EventB))
+}
+
+func orMissing(event string) string {
+	if event == "" {
+		return "<missing>"
+	}
+	return event
+}
+
+// DiffReport summarizes the comparison of two Firehose logs.
+type DiffReport struct {
+	BlocksCompared uint64
+	Differences    []BlockDifference
+}
+
+// Identical returns `true` if no differences were found.
+func (r *DiffReport) Identical() bool {
+	return len(r.Differences) == 0
+}
+
+// Diff compares the Firehose logs read from `a` and `b`, captures of the same block range,
+// block by block. The comparison is semantic: ordinals, events that depend on the node's
+// run (see `diffIgnoredEvents`) and lines outside of blocks are ignored. Only the first
+// difference of each block is reported since the following ones are usually a consequence
+// of it. Both logs are streamed, blocks present in a single log are reported as missing
+// from the other one.
+func Diff(a, b io.Reader) (*DiffReport, error) {
+	readerA, readerB := newDiffReader(a), newDiffReader(b)
+	report := &DiffReport{}
+
+	blockA, err := readerA.next()
+	if err != nil {
+		return nil, fmt.Errorf("first capture: %w", err)
+	}
+	blockB, err := readerB.next()
+	if err != nil {
+		return nil, fmt.Errorf("second capture: %w", err)
+	}
+
+	for blockA != nil || blockB != nil {
+		advanceA, advanceB := true, true
+
+		switch {
+		case blockB == nil || (blockA != nil && blockA.number < blockB.number):
+			report.Differences = append(report.Differences, BlockDifference{Block: blockA.number, LineA: blockA.line})
+			advanceB = false
+		case blockA == nil || blockB.number < blockA.number:
+			report.Differences = append(report.Differences, BlockDifference{Block: blockB.number, LineB: blockB.line})
+			advanceA = false
+		default:
+			report.BlocksCompared++
+			if difference, found := compareDiffBlocks(blockA, blockB); found {
+				report.Differences = append(report.Differences, difference)
+			}
+		}
+
+		if advanceA {
+			if blockA, err = readerA.next(); err != nil {
+				return nil, fmt.Errorf("first capture: %w", err)
+			}
+		}
+		if advanceB {
+			if blockB, err = readerB.next(); err != nil {
+				return nil, fmt.Errorf("second capture: %w", err)
+			}
+		}
+	}
+
+	return report, nil
+}
+
+func compareDiffBlocks(a, b *diffBlock) (BlockDifference, bool) {
+	for i := 0; i < len(a.events) || i < len(b.events); i++ {
+		difference := BlockDifference{Block: a.number}
+		if i < len(a.events) {
+			difference.LineA, difference.EventA = a.events[i].line, a.events[i].text
+		}
+		if i < len(b.events) {
+			difference.LineB, difference.EventB = b.events[i].line, b.events[i].text
+		}
+
+		if difference.EventA != difference.EventB {
+			return difference, true
+		}
+	}
+
+	return BlockDifference{}, false
+}
+
+type diffEvent struct {
+	line uint64
+	text string
+}
+
+type diffBlock struct {
+	number uint64
+	line   uint64
+	events []diffEvent
+	// progress is set for a block progress FINALIZE_BLOCK, along its preceding BLOCK_PROGRESS
+	progress bool
+}
+
+type diffReader struct {
+	scanner *bufio.Scanner
+	line    uint64
+}
+
+func newDiffReader(reader io.Reader) *diffReader {
+	scanner := bufio.NewScanner(reader)
+	// Some lines (code changes, end block) can be huge, give plenty of room to the scanner
+	scanner.Buffer(make([]byte, 0, 1024*1024), 512*1024*1024)
+
+	return &diffReader{scanner: scanner}
+}
+
+// next returns the next block of the log, `nil` once the log is exhausted. A block spans
+// from BEGIN_BLOCK to END_BLOCK or CANCEL_BLOCK, a FINALIZE_BLOCK outside of a block (block
+// progress mode), along its preceding BLOCK_PROGRESS if any, is a block on its own.
+func (r *diffReader) next() (*diffBlock, error) {
+	var block *diffBlock
+
+	for r.scanner.Scan() {
+		r.line++
+
+		event, fields, ok := splitLine(r.scanner.Text())
+		if !ok || diffIgnoredEvents[event] {
+			continue
+		}
+
+		if block == nil {
+			if event != "BEGIN_BLOCK" && event != "FINALIZE_BLOCK" && event != "BLOCK_PROGRESS" {
+				continue
+			}
+			if len(fields) == 0 {
+				return nil, fmt.Errorf("line %d: event %s has no block number", r.line, event)
+			}
+
+			number, err := strconv.ParseUint(fields[0], 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: event %s block number %q is not a valid number", r.line, event, fields[0])
+			}
+			block = &diffBlock{number: number, line: r.line, progress: event != "BEGIN_BLOCK"}
+		}
+
+		block.events = append(block.events, diffEvent{line: r.line, text: normalizeDiffEvent(event, fields)})
+
+		if event == "END_BLOCK" || event == "CANCEL_BLOCK" || (event == "FINALIZE_BLOCK" && block.progress) {
+			return block, nil
+		}
+	}
+
+	if err := r.scanner.Err(); err != nil {
+		return nil, fmt.Errorf("read line %d: %w", r.line+1, err)
+	}
+
+	// A block truncated by the end of the log is still compared, its missing tail shows up
+	return block, nil
+}
+
+// normalizeDiffEvent renders the event without its ordinal, which depends on the amount of
+// events emitted and not on their content.
+func normalizeDiffEvent(event string, fields []string) string {
+	schema, found := eventSchemas[event]
+	if !found || schema.ordinalField == -1 || schema.ordinalField >= len(fields) {
+		return strings.Join(append([]string{event}, fields...), " ")
+	}
+
+	normalized := make([]string, 0, len(fields))
+	normalized = append(normalized, event)
+	normalized = append(normalized, fields[:schema.ordinalField]...)
+	normalized = append(normalized, fields[schema.ordinalField+1:]...)
+
+	return strings.Join(normalized, " ")
+}
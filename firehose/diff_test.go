@@ -0,0 +1,100 @@
+package firehose
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	block := func(number string, body ...string) string {
+		lines := append([]string{"FIRE BEGIN_BLOCK " + number + " " + strings.Repeat("01", 32) + " " + strings.Repeat("00", 32) + " 1000 1 600"}, body...)
+		lines = append(lines, "FIRE END_BLOCK "+number+" 100 {}")
+		return strings.Join(lines, "\n") + "\n"
+	}
+
+	tests := []struct {
+		name            string
+		a               string
+		b               string
+		wantCompared    uint64
+		wantDifferences []string
+	}{
+		{
+			name:         "identical",
+			a:            block("1", "FIRE GAS_CHANGE 1 100 50 call 3"),
+			b:            block("1", "FIRE GAS_CHANGE 1 100 50 call 3"),
+			wantCompared: 1,
+		},
+		{
+			name:         "ordinals and run dependent events ignored",
+			a:            "FIRE INIT 3.0 geth 1.0\n" + block("1", "FIRE GAS_CHANGE 1 100 50 call 3", "FIRE SLOW_TRX 00 1000 21000") + "FIRE TRIE_COMMIT 1 10 100 5000\n",
+			b:            "FIRE INIT 3.0 geth 1.1\n" + block("1", "FIRE GAS_CHANGE 1 100 50 call 7") + "FIRE TRIE_COMMIT 1 10 100 9000\n",
+			wantCompared: 1,
+		},
+		{
+			name:            "changed event",
+			a:               block("1", "FIRE GAS_CHANGE 1 100 50 call 3"),
+			b:               block("1", "FIRE GAS_CHANGE 1 100 60 call 3"),
+			wantCompared:    1,
+			wantDifferences: []string{"block 1:\n  a (line 2): GAS_CHANGE 1 100 50 call\n  b (line 2): GAS_CHANGE 1 100 60 call"},
+		},
+		{
+			name:            "extra event",
+			a:               block("1"),
+			b:               block("1", "FIRE GAS_CHANGE 1 100 50 call 3"),
+			wantCompared:    1,
+			wantDifferences: []string{"block 1:\n  a (line 2): END_BLOCK 1 100 {}\n  b (line 2): GAS_CHANGE 1 100 50 call"},
+		},
+		{
+			name:            "truncated capture",
+			a:               block("1", "FIRE GAS_CHANGE 1 100 50 call 3"),
+			b:               strings.Join(strings.Split(block("1", "FIRE GAS_CHANGE 1 100 50 call 3"), "\n")[:2], "\n"),
+			wantCompared:    1,
+			wantDifferences: []string{"block 1:\n  a (line 3): END_BLOCK 1 100 {}\n  b (line 0): <missing>"},
+		},
+		{
+			name:            "missing blocks",
+			a:               block("1") + block("2"),
+			b:               block("2") + block("3"),
+			wantCompared:    1,
+			wantDifferences: []string{"block 1: missing from second capture", "block 3: missing from first capture"},
+		},
+		{
+			name:         "block progress",
+			a:            "FIRE FINALIZE_BLOCK 1 progress\nFIRE FINALIZE_BLOCK 2 progress\n",
+			b:            "FIRE FINALIZE_BLOCK 1 progress\nFIRE FINALIZE_BLOCK 2 progress\n",
+			wantCompared: 2,
+		},
+		{
+			name:            "block progress details",
+			a:               "FIRE BLOCK_PROGRESS 1 01 00 10 0\nFIRE FINALIZE_BLOCK 1 progress\nFIRE BLOCK_PROGRESS 2 02 01 20 0\nFIRE FINALIZE_BLOCK 2 progress\n",
+			b:               "FIRE BLOCK_PROGRESS 1 01 00 10 0\nFIRE FINALIZE_BLOCK 1 progress\nFIRE BLOCK_PROGRESS 2 03 01 20 0\nFIRE FINALIZE_BLOCK 2 progress\n",
+			wantCompared:    2,
+			wantDifferences: []string{"block 2:\n  a (line 3): BLOCK_PROGRESS 2 02 01 20 0\n  b (line 3): BLOCK_PROGRESS 2 03 01 20 0"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			report, err := Diff(strings.NewReader(test.a), strings.NewReader(test.b))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if report.BlocksCompared != test.wantCompared {
+				t.Errorf("compared %d blocks, want %d", report.BlocksCompared, test.wantCompared)
+			}
+
+			var differences []string
+			for _, difference := range report.Differences {
+				differences = append(differences, difference.String())
+			}
+			if strings.Join(differences, "\n") != strings.Join(test.wantDifferences, "\n") {
+				t.Errorf("got differences:\n%s\nwant:\n%s", strings.Join(differences, "\n"), strings.Join(test.wantDifferences, "\n"))
+			}
+			if report.Identical() != (len(test.wantDifferences) == 0) {
+				t.Errorf("identical is %t", report.Identical())
+			}
+		})
+	}
+}
@@ -0,0 +1,214 @@
+package firehose
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/metrics"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+var (
+	divergencesCounter         = metrics.NewRegisteredCounter("firehose/differential/divergences", nil)
+	differentialDroppedCounter = metrics.NewRegisteredCounter("firehose/differential/dropped", nil)
+)
+
+// differentialQueueSize is the amount of blocks waiting to be validated against the reference
+// node, blocks are dropped (and not validated) when the reference node can't keep up.
+const differentialQueueSize = 128
+
+// differentialRequestTimeout bounds the time spent fetching the receipts of a single block.
+const differentialRequestTimeout = 30 * time.Second
+
+type differentialBlock struct {
+	number   uint64
+	receipts types.Receipts
+}
+
+type differentialValidator struct {
+	client *rpc.Client
+	blocks chan differentialBlock
+
+	// divergences found in the background, waiting to be emitted from the sync path
+	divergencesLock sync.Mutex
+	divergences     [][]string
+}
+
+// EnableDifferentialValidation activates the cross-client differential validation mode. Each
+// block emitted through the sync context has its receipts compared against the ones fetched
+// from the reference node reachable at `endpoint` (like an upstream Geth or an Erigon node),
+// a DIVERGENCE event is emitted for each gas used, status or logs difference found.
+//
+// Validation happens in the background and never slows down the emission, the divergences
+// found being emitted between blocks by the next `ValidateAgainstReference`. It must be called
+// at initialization time, before any block is processed.
+func EnableDifferentialValidation(endpoint string) error {
+	validator, err := newDifferentialValidator(endpoint)
+	if err != nil {
+		return err
+	}
+
+	syncContext.differential = validator
+	return nil
+}
+
+func newDifferentialValidator(endpoint string) (*differentialValidator, error) {
+	client, err := rpc.Dial(endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("dial reference node %q: %w", endpoint, err)
+	}
+
+	validator := &differentialValidator{
+		client: client,
+		blocks: make(chan differentialBlock, differentialQueueSize),
+	}
+	go validator.run()
+
+	return validator, nil
+}
+
+// ValidateAgainstReference queues the block's receipts to be compared against the reference
+// node's ones, it's a no-op when the differential validation mode is not enabled. It must be
+// called once the block ended, the DIVERGENCE events found so far for the previous blocks
+// being emitted beforehand, outside of any block scope.
+func (ctx *Context) ValidateAgainstReference(block *types.Block, receipts types.Receipts) {
+	if ctx == nil || ctx.differential == nil {
+		return
+	}
+	defer ctx.guard()()
+
+	ctx.printDivergences()
+
+	select {
+	case ctx.differential.blocks <- differentialBlock{number: block.NumberU64(), receipts: receipts}:
+	default:
+		differentialDroppedCounter.Inc(1)
+		log.Warn("Firehose differential validation queue full, block not validated", "number", block.NumberU64())
+	}
+}
+
+func (v *differentialValidator) run() {
+	for block := range v.blocks {
+		if err := v.validate(block); err != nil {
+			log.Warn("Firehose differential validation failed", "number", block.number, "err", err)
+		}
+	}
+}
+
+func (v *differentialValidator) validate(block differentialBlock) error {
+	references := make([]*types.Receipt, len(block.receipts))
+	batch := make([]rpc.BatchElem, len(block.receipts))
+	for i, receipt := range block.receipts {
+		batch[i] = rpc.BatchElem{
+			Method: "eth_getTransactionReceipt",
+			Args:   []interface{}{receipt.TxHash},
+			Result: &references[i],
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), differentialRequestTimeout)
+	defer cancel()
+
+	if err := v.client.BatchCallContext(ctx, batch); err != nil {
+		return fmt.Errorf("fetch reference receipts: %w", err)
+	}
+
+	for i, receipt := range block.receipts {
+		if batch[i].Error != nil {
+			return fmt.Errorf("fetch reference receipt %s: %w", receipt.TxHash.Hex(), batch[i].Error)
+		}
+
+		reference := references[i]
+		if reference == nil {
+			v.diverged(block.number, receipt.TxHash, "receipt", "present", "missing")
+			continue
+		}
+
+		if receipt.GasUsed != reference.GasUsed {
+			v.diverged(block.number, receipt.TxHash, "gas_used", Uint64(receipt.GasUsed), Uint64(reference.GasUsed))
+		}
+
+		if local, remote := receiptStatus(receipt), receiptStatus(reference); local != remote {
+			v.diverged(block.number, receipt.TxHash, "status", local, remote)
+		}
+
+		if local, remote := logsHash(receipt.Logs), logsHash(reference.Logs); local != remote {
+			v.diverged(block.number, receipt.TxHash, "logs", local, remote)
+		}
+	}
+
+	return nil
+}
+
+func (v *differentialValidator) diverged(blockNumber uint64, txHash common.Hash, field, local, reference string) {
+	divergencesCounter.Inc(1)
+
+	v.divergencesLock.Lock()
+	defer v.divergencesLock.Unlock()
+
+	v.divergences = append(v.divergences, []string{"DIVERGENCE",
+		Uint64(blockNumber),
+		Hash(txHash),
+		field,
+		local,
+		reference,
+	})
+}
+
+// takeDivergences returns the divergences found since the last call.
+func (v *differentialValidator) takeDivergences() [][]string {
+	v.divergencesLock.Lock()
+	defer v.divergencesLock.Unlock()
+
+	divergences := v.divergences
+	v.divergences = nil
+
+	return divergences
+}
+
+// printDivergences emits the divergences found in the background since the last call. They
+// are emitted between blocks and are therefore not accounted in any block's checkpoint.
+func (ctx *Context) printDivergences() {
+	if ctx.inBlock.Load() {
+		ctx.invariantViolated("emitting divergences while within a block scope")
+		return
+	}
+
+	divergences := ctx.differential.takeDivergences()
+	if len(divergences) == 0 {
+		return
+	}
+
+	ctx.flushTxLock.Lock()
+	defer ctx.flushTxLock.Unlock()
+
+	for _, fields := range divergences {
+		ctx.printer.Print(fields...)
+	}
+}
+
+// receiptStatus returns the post state root of pre-Byzantium receipts, the status otherwise.
+func receiptStatus(receipt *types.Receipt) string {
+	if len(receipt.PostState) > 0 {
+		return Hex(receipt.PostState)
+	}
+
+	return Uint64(receipt.Status)
+}
+
+// logsHash returns the hash of the consensus fields (address, topics and data) of the logs.
+func logsHash(logs []*types.Log) string {
+	encoded, err := rlp.EncodeToBytes(logs)
+	if err != nil {
+		panic(fmt.Errorf("unable to RLP encode logs: %w", err))
+	}
+
+	return Hash(crypto.Keccak256Hash(encoded))
+}
@@ -0,0 +1,91 @@
+package firehose
+
+import (
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+type referenceEthAPI struct {
+	receipts map[common.Hash]map[string]interface{}
+}
+
+func (api *referenceEthAPI) GetTransactionReceipt(hash common.Hash) map[string]interface{} {
+	return api.receipts[hash]
+}
+
+func TestDifferentialValidation(t *testing.T) {
+	matching, diverging, missing := common.HexToHash("0x01"), common.HexToHash("0x02"), common.HexToHash("0x03")
+	referenceReceipt := func(hash common.Hash, status string, gasUsed string) map[string]interface{} {
+		return map[string]interface{}{
+			"transactionHash":   hash,
+			"status":            status,
+			"gasUsed":           gasUsed,
+			"cumulativeGasUsed": "0x5208",
+			"logsBloom":         types.Bloom{},
+			"logs":              []*types.Log{},
+		}
+	}
+
+	server := rpc.NewServer()
+	defer server.Stop()
+	server.RegisterName("eth", &referenceEthAPI{receipts: map[common.Hash]map[string]interface{}{
+		matching:  referenceReceipt(matching, "0x1", "0x5208"),
+		diverging: referenceReceipt(diverging, "0x0", "0x5209"),
+	}})
+
+	validator := &differentialValidator{client: rpc.DialInProc(server), blocks: make(chan differentialBlock, 1)}
+
+	receipts := types.Receipts{
+		{TxHash: matching, Status: types.ReceiptStatusSuccessful, GasUsed: 21000, Logs: []*types.Log{}},
+		{TxHash: diverging, Status: types.ReceiptStatusSuccessful, GasUsed: 21000, Logs: []*types.Log{}},
+		{TxHash: missing, Status: types.ReceiptStatusSuccessful, GasUsed: 21000, Logs: []*types.Log{}},
+	}
+
+	if err := validator.validate(differentialBlock{number: 10, receipts: receipts}); err != nil {
+		t.Fatalf("validate: %s", err)
+	}
+
+	// Divergences are only emitted from the sync path, once the following block ended
+	printer := NewToBufferPrinter(1024)
+	ctx := NewContext(printer)
+	ctx.differential = validator
+	block := types.NewBlockWithHeader(&types.Header{Number: big.NewInt(11)})
+	ctx.StartBlock(block)
+	ctx.FinalizeBlock(block)
+	ctx.EndBlock(block, nil)
+	ctx.ValidateAgainstReference(block, nil)
+
+	expected := []string{
+		"FIRE DIVERGENCE 10 " + Hash(diverging) + " gas_used 21000 21001",
+		"FIRE DIVERGENCE 10 " + Hash(diverging) + " status 1 0",
+		"FIRE DIVERGENCE 10 " + Hash(missing) + " receipt present missing",
+	}
+
+	lines := strings.Split(strings.TrimSpace(printer.Buffer().String()), "\n")
+	if len(lines) < len(expected) || strings.Join(lines[len(lines)-len(expected):], "\n") != strings.Join(expected, "\n") {
+		t.Errorf("unexpected divergences, got:\n%s\nwant trailing:\n%s", printer.Buffer().String(), strings.Join(expected, "\n"))
+	}
+	if queued := len(validator.blocks); queued != 1 {
+		t.Errorf("expected block to be queued for validation, %d queued", queued)
+	}
+
+	report, err := Check(strings.NewReader(printer.Buffer().String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Violations) != 0 {
+		t.Errorf("unexpected violations %v", report.Violations)
+	}
+
+	// Nothing is left to emit afterwards
+	printer.Buffer().Reset()
+	ctx.ValidateAgainstReference(block, nil)
+	if printer.Buffer().Len() != 0 {
+		t.Errorf("expected divergences to be emitted once, got:\n%s", printer.Buffer().String())
+	}
+}
@@ -0,0 +1,144 @@
+package firehose
+
+import (
+	"bytes"
+	"encoding/json"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/metrics"
+)
+
+var (
+	dryRunBlocksCounter = metrics.NewRegisteredCounter("firehose/dryrun/blocks", nil)
+	dryRunEventsMeter   = metrics.NewRegisteredMeter("firehose/dryrun/events", nil)
+	dryRunBytesMeter    = metrics.NewRegisteredMeter("firehose/dryrun/bytes", nil)
+)
+
+// DryRunBlockStats are the statistics of the output produced for a single block.
+type DryRunBlockStats struct {
+	Number       uint64
+	Events       uint64
+	Bytes        uint64
+	Transactions uint64
+	Calls        uint64
+	Elapsed      time.Duration
+}
+
+// DryRunWriter is an `io.Writer` discarding the Firehose output while counting it, the whole
+// instrumentation runs as usual but nothing is emitted. The statistics of each completed
+// block are logged, which makes it possible to measure the instrumentation overhead and
+// validate its stability on a node before enabling the actual capture.
+type DryRunWriter struct {
+	lock        sync.Mutex
+	partialLine []byte
+
+	inBlock bool
+	block   DryRunBlockStats
+	start   time.Time
+
+	// onBlock, when set, receives the statistics of each completed block
+	onBlock func(stats DryRunBlockStats)
+}
+
+// NewDryRunWriter creates a writer discarding the output and logging its per-block statistics.
+func NewDryRunWriter() *DryRunWriter {
+	return &DryRunWriter{}
+}
+
+func (w *DryRunWriter) Write(data []byte) (int, error) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	dryRunBytesMeter.Mark(int64(len(data)))
+
+	w.partialLine = append(w.partialLine, data...)
+	for {
+		end := bytes.IndexByte(w.partialLine, '\n')
+		if end == -1 {
+			break
+		}
+
+		w.countLine(w.partialLine[:end+1])
+		w.partialLine = w.partialLine[end+1:]
+	}
+
+	return len(data), nil
+}
+
+func (w *DryRunWriter) countLine(line []byte) {
+	event, number, _ := lineEvent(line)
+	dryRunEventsMeter.Mark(1)
+
+	switch event {
+	case "BEGIN_BLOCK":
+		w.inBlock, w.start = true, time.Now()
+		w.block = DryRunBlockStats{Number: number}
+	case "CANCEL_BLOCK":
+		w.inBlock = false
+		return
+	}
+
+	if !w.inBlock {
+		return
+	}
+
+	w.block.Events++
+	w.block.Bytes += uint64(len(line))
+
+	switch event {
+	case "BEGIN_APPLY_TRX":
+		w.block.Transactions++
+	case "EVM_RUN_CALL":
+		w.block.Calls++
+	case "END_BLOCK":
+		w.inBlock = false
+		w.block.Elapsed = time.Since(w.start)
+		dryRunBlocksCounter.Inc(1)
+
+		log.Info("Firehose dry-run block", "number", w.block.Number, "events", w.block.Events, "bytes", w.block.Bytes,
+			"trxs", w.block.Transactions, "calls", w.block.Calls, "elapsed", common.PrettyDuration(w.block.Elapsed))
+		if w.onBlock != nil {
+			w.onBlock(w.block)
+		}
+	}
+}
+
+// lineEvent extracts the event name of a line, both in text and NDJSON output formats,
+// along with the block number and time for BEGIN_BLOCK lines.
+func lineEvent(line []byte) (event string, number uint64, timestamp uint64) {
+	if bytes.HasPrefix(line, []byte(linePrefix)) {
+		event, fields, _ := splitLine(string(line))
+		if event == "BEGIN_BLOCK" && len(fields) > 3 {
+			number, _ = strconv.ParseUint(fields[0], 10, 64)
+			timestamp, _ = strconv.ParseUint(fields[3], 10, 64)
+		}
+		return event, number, timestamp
+	}
+
+	if bytes.HasPrefix(line, []byte(`{"event":"BEGIN_BLOCK"`)) {
+		var object struct {
+			Number string `json:"number"`
+			Time   string `json:"time"`
+		}
+		if err := json.Unmarshal(line, &object); err == nil {
+			number, _ = strconv.ParseUint(object.Number, 10, 64)
+			timestamp, _ = strconv.ParseUint(object.Time, 10, 64)
+		}
+		return "BEGIN_BLOCK", number, timestamp
+	}
+
+	// The event is always the first property of NDJSON lines, `{"event":"NAME",...}`
+	const eventPrefix = `{"event":"`
+	if bytes.HasPrefix(line, []byte(eventPrefix)) {
+		rest := line[len(eventPrefix):]
+		if end := bytes.IndexByte(rest, '"'); end != -1 {
+			return string(rest[:end]), 0, 0
+		}
+	}
+
+	return "", 0, 0
+}
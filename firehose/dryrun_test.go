@@ -0,0 +1,30 @@
+package firehose
+
+import (
+	"testing"
+)
+
+func TestDryRunWriter(t *testing.T) {
+	var blocks []DryRunBlockStats
+	writer := NewDryRunWriter()
+	writer.onBlock = func(stats DryRunBlockStats) { blocks = append(blocks, stats) }
+
+	writer.Write([]byte("FIRE BEGIN_BLOCK 1 aa bb 10 1 500\nFIRE BEGIN_APPLY_TRX aa\nFIRE EVM_RUN_"))
+	writer.Write([]byte("CALL CALL 1 1 100 0\nFIRE END_APPLY_TRX\nFIRE END_BLOCK 1 500 {}\n"))
+	writer.Write([]byte("FIRE BEGIN_BLOCK 2 aa bb 10 0 500\nFIRE CANCEL_BLOCK 2 invalid block\n"))
+	writer.Write([]byte(`{"event":"BEGIN_BLOCK","number":"3"}` + "\n" + `{"event":"EVM_RUN_CALL","call_type":"CALL"}` + "\n" + `{"event":"END_BLOCK","number":"3"}` + "\n"))
+
+	if len(blocks) != 2 {
+		t.Fatalf("got %d blocks, want 2", len(blocks))
+	}
+
+	first := blocks[0]
+	if first.Number != 1 || first.Events != 5 || first.Transactions != 1 || first.Calls != 1 || first.Bytes != 134 {
+		t.Errorf("unexpected first block stats %+v", first)
+	}
+
+	second := blocks[1]
+	if second.Number != 3 || second.Events != 3 || second.Transactions != 0 || second.Calls != 1 {
+		t.Errorf("unexpected second block stats %+v", second)
+	}
+}
@@ -0,0 +1,106 @@
+package firehose
+
+import (
+	"bytes"
+	"io"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/metrics"
+)
+
+var (
+	dualEmitPrimaryLinesCounter   = metrics.NewRegisteredCounter("firehose/dualemit/primary/lines", nil)
+	dualEmitSecondaryLinesCounter = metrics.NewRegisteredCounter("firehose/dualemit/secondary/lines", nil)
+	dualEmitLineDriftGauge        = metrics.NewRegisteredGauge("firehose/dualemit/drift", nil)
+	dualEmitSecondaryErrorsMeter  = metrics.NewRegisteredMeter("firehose/dualemit/secondary/errors", nil)
+)
+
+// syncSecondaryWriter and syncSecondaryFormat are the secondary output of the sync context,
+// see `SetSecondarySyncContextWriter`.
+var syncSecondaryWriter io.Writer
+var syncSecondaryFormat = TextOutputFormat
+
+// SetSecondarySyncContextWriter makes the sync context emit its output to `writer` too, in the
+// given format, along the primary output. It's meant for reader upgrades, where the legacy
+// and the new formats must be produced simultaneously to validate the new reader against the
+// old one. The output middlewares only apply to the primary output.
+//
+// The line counts of both outputs are tracked under `firehose/dualemit/`, the `drift` gauge
+// being the amount of lines emitted to the primary output but not to the secondary one. It
+// must be called at initialization time, before any block is processed.
+func SetSecondarySyncContextWriter(writer io.Writer, format OutputFormat) error {
+	if err := validateOutputFormat(format); err != nil {
+		return err
+	}
+
+	syncSecondaryWriter, syncSecondaryFormat = writer, format
+	SetSyncContextWriter(syncContextWriter)
+
+	return nil
+}
+
+// dualEmitWriter hands each write of formatted Firehose lines to both outputs, each output
+// converting it to its own format. A failure of the secondary output is reported but never
+// affects the primary one, the canonical stream.
+type dualEmitWriter struct {
+	primary   io.Writer
+	secondary io.Writer
+
+	lock            sync.Mutex
+	primaryLines    int64
+	secondaryLines  int64
+	secondaryFailed bool
+}
+
+func newDualEmitWriter(primary io.Writer, primaryFormat OutputFormat, secondary io.Writer, secondaryFormat OutputFormat) *dualEmitWriter {
+	w := &dualEmitWriter{}
+	w.primary = withOutputFormat(w.lineCounter(primary, &w.primaryLines, dualEmitPrimaryLinesCounter), primaryFormat)
+	w.secondary = withOutputFormat(w.lineCounter(secondary, &w.secondaryLines, dualEmitSecondaryLinesCounter), secondaryFormat)
+
+	return w
+}
+
+func (w *dualEmitWriter) Write(data []byte) (int, error) {
+	written, err := w.primary.Write(data)
+
+	if _, secondaryErr := w.secondary.Write(data); secondaryErr != nil {
+		dualEmitSecondaryErrorsMeter.Mark(1)
+
+		w.lock.Lock()
+		if !w.secondaryFailed {
+			log.Error("Firehose secondary output write failed, the outputs diverge", "err", secondaryErr)
+		}
+		w.secondaryFailed = true
+		w.lock.Unlock()
+	}
+
+	return written, err
+}
+
+// lineCounter counts the lines successfully written to `writer` into `count`, the lines being
+// counted once converted to their output format.
+func (w *dualEmitWriter) lineCounter(writer io.Writer, count *int64, counter metrics.Counter) io.Writer {
+	return writerFunc(func(data []byte) (int, error) {
+		written, err := writer.Write(data)
+		lines := int64(bytes.Count(data[:written], []byte("\n")))
+
+		w.lock.Lock()
+		*count += lines
+		dualEmitLineDriftGauge.Update(w.primaryLines - w.secondaryLines)
+		w.lock.Unlock()
+
+		counter.Inc(lines)
+		return written, err
+	})
+}
+
+// withOutputFormat returns a writer converting the text lines it receives to `format` before
+// writing them to `writer`.
+func withOutputFormat(writer io.Writer, format OutputFormat) io.Writer {
+	if format == NDJSONOutputFormat {
+		return NewNDJSONWriter(writer)
+	}
+
+	return writer
+}
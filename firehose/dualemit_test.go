@@ -0,0 +1,43 @@
+package firehose
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestDualEmitWriter(t *testing.T) {
+	primary, secondary := &bytes.Buffer{}, &bytes.Buffer{}
+	writer := newDualEmitWriter(primary, TextOutputFormat, secondary, NDJSONOutputFormat)
+
+	writer.Write([]byte("FIRE BEGIN_BLOCK 1 aa bb 10 0 500\nFIRE FINALIZE_BLOCK 1 full\n"))
+	writer.Write([]byte("FIRE END_BLOCK 1 500 {}\n"))
+
+	if primary.String() != "FIRE BEGIN_BLOCK 1 aa bb 10 0 500\nFIRE FINALIZE_BLOCK 1 full\nFIRE END_BLOCK 1 500 {}\n" {
+		t.Errorf("unexpected primary output %q", primary.String())
+	}
+
+	expected := `{"event":"BEGIN_BLOCK","number":"1","hash":"aa","parent_hash":"bb","time":"10","trx_count":"0","size":"500"}` + "\n" +
+		`{"event":"FINALIZE_BLOCK","number":"1","mode":"full"}` + "\n" +
+		`{"event":"END_BLOCK","number":"1","size":"500","meta":{}}` + "\n"
+	if secondary.String() != expected {
+		t.Errorf("unexpected secondary output, got:\n%s\nwant:\n%s", secondary.String(), expected)
+	}
+
+	if writer.primaryLines != 3 || writer.secondaryLines != 3 {
+		t.Errorf("got %d primary and %d secondary lines, want 3 each", writer.primaryLines, writer.secondaryLines)
+	}
+}
+
+func TestDualEmitWriterSecondaryFailure(t *testing.T) {
+	primary := &bytes.Buffer{}
+	failing := writerFunc(func(data []byte) (int, error) { return 0, errors.New("disk full") })
+	writer := newDualEmitWriter(primary, TextOutputFormat, failing, TextOutputFormat)
+
+	if _, err := writer.Write([]byte("FIRE FINALIZE_BLOCK 1 full\n")); err != nil {
+		t.Fatalf("secondary failure leaked to the primary output: %s", err)
+	}
+	if primary.String() != "FIRE FINALIZE_BLOCK 1 full\n" || writer.primaryLines != 1 || writer.secondaryLines != 0 {
+		t.Errorf("unexpected state after secondary failure, primary %q, lines %d/%d", primary.String(), writer.primaryLines, writer.secondaryLines)
+	}
+}
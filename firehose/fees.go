@@ -0,0 +1,106 @@
+package firehose
+
+import (
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// TxFees resolves the canonical fee fields of a transaction according to its type, it must
+// be used by every call site starting a transaction so that the fee fields never drift
+// between them.
+//
+// The `gasPrice` is the effective gas price paid by the transaction, `maxFeePerGas` and
+// `maxPriorityFeePerGas` are `nil` unless the transaction is a dynamic fee transaction.
+func TxFees(tx *types.Transaction, baseFee *big.Int) (gasPrice, maxFeePerGas, maxPriorityFeePerGas *big.Int) {
+	// London fork not active in this branch yet, all transactions are legacy ones. When it's the case,
+	// switch on `tx.Type()` and for dynamic fee transactions, resolve `maxFeePerGas` to `tx.GasFeeCap()`,
+	// `maxPriorityFeePerGas` to `tx.GasTipCap()` and the effective gas price to `min(tx.GasTipCap() + baseFee, tx.GasFeeCap())`
+	// when `baseFee` is known (and remove this comment).
+	return tx.GasPrice(), nil, nil
+}
+
+// FeeKind tells how the fees of a transaction must be interpreted, it's printed explicitly so
+// that consumers don't have to infer zero-fee and system transactions from the gas price.
+type FeeKind string
+
+const (
+	// StandardFeeKind is a transaction paying its fees according to its gas price.
+	StandardFeeKind = FeeKind("standard")
+	// ZeroFeeKind is a regular transaction with a zero gas price.
+	ZeroFeeKind = FeeKind("zero_fee")
+	// SystemFeeKind is a transaction injected by the protocol itself (like the genesis
+	// allocation pseudo transaction), its zero gas price must not be considered as a fee.
+	SystemFeeKind = FeeKind("system")
+)
+
+// IsSystemTransaction, when set, tells if a transaction is injected by the protocol. Chains
+// with system transactions (Bor state syncs, Congress validator transactions, ...) set it at
+// initialization time, there are none on this chain so it's `nil` by default.
+var IsSystemTransaction func(tx *types.Transaction) bool
+
+// TxFeeKind resolves the fee kind of a transaction whose effective gas price is `gasPrice`.
+func TxFeeKind(tx *types.Transaction, gasPrice *big.Int) FeeKind {
+	if IsSystemTransaction != nil && IsSystemTransaction(tx) {
+		return SystemFeeKind
+	}
+
+	return GasPriceFeeKind(gasPrice)
+}
+
+// GasPriceFeeKind resolves the fee kind of a message that can't be a system transaction.
+func GasPriceFeeKind(gasPrice *big.Int) FeeKind {
+	if gasPrice == nil || gasPrice.Sign() == 0 {
+		return ZeroFeeKind
+	}
+
+	return StandardFeeKind
+}
+
+// accumulateFeeCredit adds a transaction fee credited to the fee recipient to the block's
+// total.
+func (ctx *Context) accumulateFeeCredit(addr common.Address, oldBalance, newBalance *big.Int) {
+	if ctx.blockFees == nil {
+		ctx.blockFees = new(big.Int)
+	}
+
+	ctx.feeRecipient = addr
+	ctx.blockFees.Add(ctx.blockFees, new(big.Int).Sub(newBalance, oldBalance))
+}
+
+// mergeFeeCredit adds the fees credited within the transaction context being flushed to the
+// block's total.
+//
+// Must be called with `flushTxLock` held.
+func (ctx *Context) mergeFeeCredit(txContext *Context) {
+	if txContext.blockFees == nil {
+		return
+	}
+
+	if ctx.blockFees == nil {
+		ctx.blockFees = new(big.Int)
+	}
+
+	ctx.feeRecipient = txContext.feeRecipient
+	ctx.blockFees.Add(ctx.blockFees, txContext.blockFees)
+}
+
+// printFeeRecipientCredit emits the FEE_RECIPIENT_CREDIT event summarizing the transaction
+// fees credited to the block's fee recipient. The recipient is the one credited by the
+// transactions, which differs from the header's coinbase on Clique chains, falling back to the
+// header's coinbase when the block has no transaction.
+func (ctx *Context) printFeeRecipientCredit(block *types.Block) {
+	recipient, fees := block.Coinbase(), ctx.blockFees
+	if fees == nil {
+		fees = common.Big0
+	} else {
+		recipient = ctx.feeRecipient
+	}
+
+	ctx.print("FEE_RECIPIENT_CREDIT",
+		Uint64(block.NumberU64()),
+		Addr(recipient),
+		BigInt(fees),
+	)
+}
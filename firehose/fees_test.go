@@ -0,0 +1,89 @@
+package firehose
+
+import (
+	"bytes"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+func TestTxFeeKind(t *testing.T) {
+	defer func(detector func(tx *types.Transaction) bool) { IsSystemTransaction = detector }(IsSystemTransaction)
+
+	paying := types.NewTransaction(0, common.Address{1}, big.NewInt(0), 21000, big.NewInt(1), nil)
+	free := types.NewTransaction(0, common.Address{2}, big.NewInt(0), 21000, big.NewInt(0), nil)
+
+	if kind := TxFeeKind(paying, paying.GasPrice()); kind != StandardFeeKind {
+		t.Errorf("paying transaction got %s, want %s", kind, StandardFeeKind)
+	}
+	if kind := TxFeeKind(free, free.GasPrice()); kind != ZeroFeeKind {
+		t.Errorf("zero gas price transaction got %s, want %s", kind, ZeroFeeKind)
+	}
+
+	IsSystemTransaction = func(tx *types.Transaction) bool { return *tx.To() == common.Address{2} }
+	if kind := TxFeeKind(free, free.GasPrice()); kind != SystemFeeKind {
+		t.Errorf("system transaction got %s, want %s", kind, SystemFeeKind)
+	}
+	if kind := TxFeeKind(paying, paying.GasPrice()); kind != StandardFeeKind {
+		t.Errorf("paying transaction got %s, want %s", kind, StandardFeeKind)
+	}
+}
+
+func TestFeeRecipientCredit(t *testing.T) {
+	defer func(enabled bool) { FeeRecipientCreditEnabled = enabled }(FeeRecipientCreditEnabled)
+	FeeRecipientCreditEnabled = true
+
+	signer, coinbase := common.Address{1}, common.Address{2}
+	block := types.NewBlockWithHeader(&types.Header{Number: big.NewInt(1), Coinbase: coinbase})
+
+	output := &bytes.Buffer{}
+	ctx := NewContext(NewDelegateToWriterPrinter(output))
+	txContext := NewSpeculativeExecutionContext(1024)
+
+	// The fees of flushed transaction contexts and of the block context itself are summed up,
+	// credited to the transactions' recipient rather than the header's coinbase
+	ctx.StartBlock(block)
+	txContext.StartTransaction(types.NewTransaction(0, common.Address{3}, big.NewInt(0), 21000, big.NewInt(1), nil), 0, nil)
+	txContext.RecordTrxFrom(common.Address{4}, nil)
+	txContext.RecordBalanceChange(signer, big.NewInt(0), big.NewInt(21000), RewardTransactionFeeBalanceChangeReason)
+	txContext.EndTransaction(&types.Receipt{GasUsed: 21000})
+	ctx.FlushTransaction(txContext)
+
+	ctx.StartTransaction(types.NewTransaction(1, common.Address{3}, big.NewInt(0), 21000, big.NewInt(2), nil), 1, nil)
+	ctx.RecordTrxFrom(common.Address{4}, nil)
+	ctx.RecordBalanceChange(signer, big.NewInt(21000), big.NewInt(63000), RewardTransactionFeeBalanceChangeReason)
+	ctx.RecordBalanceChange(common.Address{4}, big.NewInt(100), big.NewInt(50), GasBuyBalanceChangeReason)
+	ctx.EndTransaction(&types.Receipt{GasUsed: 21000})
+	ctx.FinalizeBlock(block)
+	ctx.EndBlock(block, nil)
+
+	lines := strings.Split(strings.TrimSpace(output.String()), "\n")
+	expected := "FIRE FEE_RECIPIENT_CREDIT 1 " + Addr(signer) + " " + BigInt(big.NewInt(63000))
+	if lines[len(lines)-3] != expected {
+		t.Fatalf("unexpected fee recipient credit, have:\n%s\nwant:\n%s", lines[len(lines)-3], expected)
+	}
+
+	report, err := Check(strings.NewReader(output.String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Violations) != 0 {
+		t.Errorf("unexpected violations %v", report.Violations)
+	}
+
+	// A block without transactions credits nothing to the header's coinbase
+	output.Reset()
+	block = types.NewBlockWithHeader(&types.Header{Number: big.NewInt(2), Coinbase: coinbase})
+	ctx.StartBlock(block)
+	ctx.FinalizeBlock(block)
+	ctx.EndBlock(block, nil)
+
+	lines = strings.Split(strings.TrimSpace(output.String()), "\n")
+	expected = "FIRE FEE_RECIPIENT_CREDIT 2 " + Addr(coinbase) + " ."
+	if lines[1] != expected {
+		t.Fatalf("unexpected empty fee recipient credit, have:\n%s\nwant:\n%s", lines[1], expected)
+	}
+}
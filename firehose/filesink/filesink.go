@@ -0,0 +1,265 @@
+// Package filesink implements a Firehose output sink writing the stream to a local file,
+// optionally building along a block index sidecar file mapping each block number to the
+// byte range of the block in the output file, so that downstream tooling can random-access
+// the produced archives without scanning them.
+package filesink
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// IndexSuffix is appended to the output file path to form the block index sidecar path.
+const IndexSuffix = ".idx"
+
+// indexEntrySize is the size in bytes of an encoded IndexEntry.
+const indexEntrySize = 24
+
+// IndexEntry locates a block within the output file, the block spans from the start of its
+// BEGIN_BLOCK line up to the end of its END_BLOCK line.
+type IndexEntry struct {
+	Number uint64
+	Offset uint64
+	Length uint64
+}
+
+// Writer is an `io.Writer` appending the Firehose output stream to a file. When the index is
+// enabled, a fixed size big-endian record (number, offset, length) is appended to the sidecar
+// file for each completed block. Cancelled blocks are not indexed.
+type Writer struct {
+	lock  sync.Mutex
+	file  *os.File
+	index *os.File
+
+	offset      uint64
+	partialLine []byte
+	blockNumber uint64
+	blockOffset uint64
+	inBlock     bool
+}
+
+// Open opens the output file at `path` for appending, along its block index sidecar at
+// `path + IndexSuffix` when `withIndex` is set.
+func Open(path string, withIndex bool) (*Writer, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("open output file: %w", err)
+	}
+
+	stat, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("stat output file: %w", err)
+	}
+
+	w := &Writer{file: file, offset: uint64(stat.Size())}
+	if withIndex {
+		w.index, err = os.OpenFile(path+IndexSuffix, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			file.Close()
+			return nil, fmt.Errorf("open block index file: %w", err)
+		}
+
+		if err := w.truncatePartialIndexEntry(); err != nil {
+			w.Close()
+			return nil, err
+		}
+	}
+
+	return w, nil
+}
+
+// truncatePartialIndexEntry drops the trailing partial entry left in the block index when
+// the node was stopped while writing it, the entries appended from there on would otherwise
+// be misaligned.
+func (w *Writer) truncatePartialIndexEntry() error {
+	stat, err := w.index.Stat()
+	if err != nil {
+		return fmt.Errorf("stat block index file: %w", err)
+	}
+
+	partial := stat.Size() % indexEntrySize
+	if partial == 0 {
+		return nil
+	}
+
+	log.Warn("Truncating partial Firehose block index entry", "path", w.index.Name(), "bytes", partial)
+	if err := w.index.Truncate(stat.Size() - partial); err != nil {
+		return fmt.Errorf("truncate block index file: %w", err)
+	}
+
+	return nil
+}
+
+func (w *Writer) Write(data []byte) (int, error) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	written, err := w.file.Write(data)
+	if w.index != nil {
+		w.indexLines(data[:written])
+	}
+
+	return written, err
+}
+
+func (w *Writer) indexLines(data []byte) {
+	w.partialLine = append(w.partialLine, data...)
+	for {
+		end := bytes.IndexByte(w.partialLine, '\n')
+		if end == -1 {
+			break
+		}
+
+		w.indexLine(w.partialLine[:end+1])
+
+		w.offset += uint64(end + 1)
+		w.partialLine = w.partialLine[end+1:]
+	}
+}
+
+func (w *Writer) indexLine(line []byte) {
+	event, number, ok := blockEvent(line)
+	if !ok {
+		return
+	}
+
+	switch event {
+	case "BEGIN_BLOCK":
+		w.blockNumber, w.blockOffset, w.inBlock = number, w.offset, true
+
+	case "CANCEL_BLOCK":
+		w.inBlock = false
+
+	case "END_BLOCK":
+		if !w.inBlock || number != w.blockNumber {
+			log.Warn("Firehose END_BLOCK without matching BEGIN_BLOCK, block not indexed", "number", number)
+			w.inBlock = false
+			return
+		}
+		w.inBlock = false
+
+		entry := IndexEntry{Number: number, Offset: w.blockOffset, Length: w.offset + uint64(len(line)) - w.blockOffset}
+		if _, err := w.index.Write(entry.encode()); err != nil {
+			log.Error("Failed to write Firehose block index entry", "number", number, "err", err)
+		}
+	}
+}
+
+// Close closes the output file and its block index sidecar.
+func (w *Writer) Close() error {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	err := w.file.Close()
+	if w.index != nil {
+		if indexErr := w.index.Close(); err == nil {
+			err = indexErr
+		}
+	}
+
+	return err
+}
+
+// blockEvent extracts the event name and block number of BEGIN_BLOCK, END_BLOCK and
+// CANCEL_BLOCK lines, both in text and NDJSON output formats.
+func blockEvent(line []byte) (event string, number uint64, ok bool) {
+	var rawNumber string
+
+	switch {
+	case bytes.HasPrefix(line, []byte("FIRE ")):
+		rest := bytes.TrimSpace(line[len("FIRE "):])
+		for bytes.HasPrefix(rest, []byte("#")) || bytes.HasPrefix(rest, []byte("@")) {
+			// Sequence number and monotonic timestamp of the line, see
+			// `firehose.SequenceNumbersEnabled` and `firehose.MonotonicTimestampsEnabled`
+			i := bytes.IndexByte(rest, ' ')
+			if i == -1 {
+				return "", 0, false
+			}
+			rest = rest[i+1:]
+		}
+
+		fields := bytes.SplitN(rest, []byte(" "), 3)
+		if len(fields) < 2 {
+			return "", 0, false
+		}
+		event, rawNumber = string(fields[0]), string(fields[1])
+
+	case bytes.HasPrefix(line, []byte(`{"event":"BEGIN_BLOCK"`)), bytes.HasPrefix(line, []byte(`{"event":"END_BLOCK"`)), bytes.HasPrefix(line, []byte(`{"event":"CANCEL_BLOCK"`)):
+		var object struct {
+			Event  string `json:"event"`
+			Number string `json:"number"`
+		}
+		if err := json.Unmarshal(line, &object); err != nil {
+			return "", 0, false
+		}
+		event, rawNumber = object.Event, object.Number
+
+	default:
+		return "", 0, false
+	}
+
+	if event != "BEGIN_BLOCK" && event != "END_BLOCK" && event != "CANCEL_BLOCK" {
+		return "", 0, false
+	}
+
+	number, err := strconv.ParseUint(rawNumber, 10, 64)
+	if err != nil {
+		return "", 0, false
+	}
+
+	return event, number, true
+}
+
+func (e IndexEntry) encode() []byte {
+	out := make([]byte, indexEntrySize)
+	binary.BigEndian.PutUint64(out[0:8], e.Number)
+	binary.BigEndian.PutUint64(out[8:16], e.Offset)
+	binary.BigEndian.PutUint64(out[16:24], e.Length)
+
+	return out
+}
+
+// ReadIndex reads all the entries of a block index sidecar, a truncated trailing entry (the
+// node being stopped while writing it) is ignored.
+func ReadIndex(reader io.Reader) ([]IndexEntry, error) {
+	var entries []IndexEntry
+
+	buffer := make([]byte, indexEntrySize)
+	for {
+		if _, err := io.ReadFull(reader, buffer); err != nil {
+			if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+				return entries, nil
+			}
+			return nil, err
+		}
+
+		entries = append(entries, IndexEntry{
+			Number: binary.BigEndian.Uint64(buffer[0:8]),
+			Offset: binary.BigEndian.Uint64(buffer[8:16]),
+			Length: binary.BigEndian.Uint64(buffer[16:24]),
+		})
+	}
+}
+
+// Lookup returns the most recent entry of `entries` for block `number`, a block being
+// indexed more than once when it's processed again (e.g. on reorgs). The entries must be
+// in the order they were read.
+func Lookup(entries []IndexEntry, number uint64) (IndexEntry, bool) {
+	for i := len(entries) - 1; i >= 0; i-- {
+		if entries[i].Number == number {
+			return entries[i], true
+		}
+	}
+
+	return IndexEntry{}, false
+}
@@ -0,0 +1,137 @@
+package filesink
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWriterBlockIndex(t *testing.T) {
+	dir, err := ioutil.TempDir("", "filesink")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "output.dmlog")
+	block1 := "FIRE @100 BEGIN_BLOCK 1 aa bb 10 0 500\nFIRE @101 FINALIZE_BLOCK 1 full\nFIRE @102 END_BLOCK 1 500 {}\n"
+	cancelled := "FIRE BEGIN_BLOCK 2 aa bb 10 0 500\nFIRE CANCEL_BLOCK 2 invalid block\n"
+	block2 := `{"event":"BEGIN_BLOCK","number":"2"}` + "\n" + `{"event":"END_BLOCK","number":"2","size":"500","meta":{}}` + "\n"
+
+	writer, err := Open(path, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Write split in the middle of a line to ensure partial lines are accounted correctly
+	stream := "preamble\n" + block1 + cancelled + block2
+	writer.Write([]byte(stream[:20]))
+	writer.Write([]byte(stream[20:]))
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	output, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(output) != stream {
+		t.Fatalf("output mismatch, have %q, want %q", output, stream)
+	}
+
+	index, err := ioutil.ReadFile(path + IndexSuffix)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A truncated trailing entry must be ignored
+	entries, err := ReadIndex(bytes.NewReader(append(index, 0x01, 0x02)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("expected 2 index entries, got %d", len(entries))
+	}
+
+	for _, expected := range []struct {
+		number uint64
+		block  string
+	}{{1, block1}, {2, block2}} {
+		entry, found := Lookup(entries, expected.number)
+		if !found {
+			t.Fatalf("block %d not found in index", expected.number)
+		}
+		if have := string(output[entry.Offset : entry.Offset+entry.Length]); have != expected.block {
+			t.Errorf("block %d range mismatch, have %q, want %q", expected.number, have, expected.block)
+		}
+	}
+}
+
+func TestWriterBlockIndexCrashRecovery(t *testing.T) {
+	dir, err := ioutil.TempDir("", "filesink")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "output.dmlog")
+	block1 := "FIRE BEGIN_BLOCK 1 aa bb 10 0 500\nFIRE END_BLOCK 1 500 {}\n"
+	block2 := "FIRE BEGIN_BLOCK 2 aa bb 10 0 500\nFIRE END_BLOCK 2 500 {}\n"
+
+	writer, err := Open(path, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writer.Write([]byte(block1))
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Node stopped while writing the next index entry
+	index, err := os.OpenFile(path+IndexSuffix, os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	index.Write([]byte{0x01, 0x02, 0x03})
+	index.Close()
+
+	writer, err = Open(path, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writer.Write([]byte(block2))
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	output, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := ioutil.ReadFile(path + IndexSuffix)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data) != 2*indexEntrySize {
+		t.Fatalf("expected partial index entry to be truncated, index has %d bytes, want %d", len(data), 2*indexEntrySize)
+	}
+
+	entries, err := ReadIndex(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range []struct {
+		number uint64
+		block  string
+	}{{1, block1}, {2, block2}} {
+		entry, found := Lookup(entries, expected.number)
+		if !found {
+			t.Fatalf("block %d not found in index", expected.number)
+		}
+		if have := string(output[entry.Offset : entry.Offset+entry.Length]); have != expected.block {
+			t.Errorf("block %d range mismatch, have %q, want %q", expected.number, have, expected.block)
+		}
+	}
+}
@@ -0,0 +1,35 @@
+package firehose
+
+import (
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// RecordSkeletonBlock emits the skeleton of a block imported without being executed, see
+// `FollowerModeEnabled`: the block's BEGIN_BLOCK and END_BLOCK events enclosing, for each of
+// its transactions, the transaction's envelope (BEGIN_APPLY_TRX and TRX_FROM) and its outcome
+// as given by its receipt (END_APPLY_TRX). There are no calls nor state changes, the block is
+// finalized in `skeleton` mode so that readers can tell it apart from an executed block.
+//
+// The `senders` are the senders of the block's transactions, in the same order, and the
+// `receipts` must have their derived fields (like the logs' block index) set.
+func (ctx *Context) RecordSkeletonBlock(block *types.Block, receipts types.Receipts, senders []common.Address) {
+	if ctx == nil {
+		return
+	}
+	defer ctx.guard()()
+
+	if ctx.inBlock.Load() {
+		ctx.invariantViolated("trying to record skeleton block while in block context")
+		ctx.exitBlock()
+	}
+
+	ctx.StartBlock(block)
+	for i, tx := range block.Transactions() {
+		ctx.StartTransaction(tx, uint(i), nil)
+		ctx.RecordTrxFrom(senders[i], nil)
+		ctx.EndTransaction(receipts[i])
+	}
+	ctx.finalizeBlock(block, skeletonFinalizeMode)
+	ctx.EndBlock(block, nil)
+}
@@ -0,0 +1,48 @@
+package firehose
+
+import (
+	"bytes"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+func TestRecordSkeletonBlock(t *testing.T) {
+	to := common.Address{2}
+	txs := []*types.Transaction{
+		types.NewTransaction(0, to, big.NewInt(1), 21000, big.NewInt(1), nil),
+		types.NewContractCreation(1, big.NewInt(0), 50000, big.NewInt(1), []byte{0x60}),
+	}
+	block := types.NewBlock(&types.Header{Number: big.NewInt(7)}, txs, nil, nil)
+	receipts := types.Receipts{
+		{Status: types.ReceiptStatusSuccessful, GasUsed: 21000, CumulativeGasUsed: 21000},
+		{Status: types.ReceiptStatusSuccessful, GasUsed: 30000, CumulativeGasUsed: 51000, Logs: []*types.Log{{Address: to, Index: 0}}},
+	}
+	senders := []common.Address{{1}, {1}}
+
+	output := &bytes.Buffer{}
+	ctx := NewContext(NewDelegateToWriterPrinter(output))
+	ctx.RecordSkeletonBlock(block, receipts, senders)
+
+	var events []string
+	for _, line := range strings.Split(strings.TrimSpace(output.String()), "\n") {
+		event, fields, _ := splitLine(line)
+		if event == "FINALIZE_BLOCK" {
+			event += " " + fields[1]
+		}
+		events = append(events, event)
+	}
+
+	expected := "BEGIN_BLOCK BEGIN_APPLY_TRX TRX_FROM END_APPLY_TRX BEGIN_APPLY_TRX TRX_FROM END_APPLY_TRX FINALIZE_BLOCK skeleton END_BLOCK"
+	if strings.Join(events, " ") != expected {
+		t.Fatalf("unexpected skeleton block events, have:\n%s\nwant:\n%s", strings.Join(events, " "), expected)
+	}
+
+	report, err := Check(strings.NewReader(output.String()))
+	if err != nil || !report.Valid() {
+		t.Fatalf("expected skeleton block to be valid, got %v (%v)", report.Violations, err)
+	}
+}
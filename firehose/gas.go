@@ -0,0 +1,96 @@
+package firehose
+
+// pendingGasChange is a GAS_CHANGE held back while the following gas changes can be merged
+// into it, see `GasChangeCoalescingEnabled`.
+type pendingGasChange struct {
+	callIndex string
+	oldValue  uint64
+	newValue  uint64
+	reason    GasChangeReason
+	ordinal   uint64
+	count     uint64
+}
+
+// recordGasChange emits a GAS_CHANGE, or when `GasChangeCoalescingEnabled` is set, merges it
+// into the pending one if it directly follows it, for the same reason in the same call.
+func (ctx *Context) recordGasChange(oldValue, newValue uint64, reason GasChangeReason) {
+	callIndex := ctx.callIndex()
+
+	if !GasChangeCoalescingEnabled {
+		ctx.print("GAS_CHANGE",
+			callIndex,
+			Uint64(oldValue),
+			Uint64(newValue),
+			string(reason),
+			Uint64(ctx.informationalOrdinal()),
+		)
+		return
+	}
+
+	if change := ctx.pendingGasChange; change != nil && change.callIndex == callIndex && change.reason == reason && change.newValue == oldValue {
+		change.newValue = newValue
+		change.count++
+		return
+	}
+
+	ctx.flushGasChange()
+	ctx.pendingGasChange = &pendingGasChange{
+		callIndex: callIndex,
+		oldValue:  oldValue,
+		newValue:  newValue,
+		reason:    reason,
+		ordinal:   ctx.informationalOrdinal(),
+		count:     1,
+	}
+}
+
+// flushGasChange prints the pending GAS_CHANGE, if any, with the amount of changes merged in
+// it. It's called before any other event is printed so that only consecutive changes are
+// merged.
+func (ctx *Context) flushGasChange() {
+	change := ctx.pendingGasChange
+	if change == nil {
+		return
+	}
+
+	ctx.pendingGasChange = nil
+	ctx.print("GAS_CHANGE",
+		change.callIndex,
+		Uint64(change.oldValue),
+		Uint64(change.newValue),
+		string(change.reason),
+		Uint64(change.ordinal),
+		Uint64(change.count),
+	)
+}
+
+// RefundCapRule identifies the rule capping the refund counter applied at the end of a
+// transaction, it changes across fork boundaries.
+type RefundCapRule string
+
+const (
+	// HalfGasUsedRefundCapRule caps the refund to half of the gas used, the rule before
+	// the London fork.
+	HalfGasUsedRefundCapRule = RefundCapRule("half_gas_used")
+	// EIP3529RefundCapRule caps the refund to a fifth of the gas used, the rule from the
+	// London fork on.
+	EIP3529RefundCapRule = RefundCapRule("eip3529")
+)
+
+// RecordTrxGasRefund emits, when `TrxGasRefundsEnabled` is set, a TRX_GAS_REFUND giving the
+// refund counter accumulated by the transaction and the refund actually applied to the
+// `gasLeft` once capped according to `rule`. Transactions that accumulated no refund emit
+// nothing.
+func (ctx *Context) RecordTrxGasRefund(gasLeft, accumulated, applied uint64, rule RefundCapRule) {
+	if ctx == nil || !TrxGasRefundsEnabled || accumulated == 0 {
+		return
+	}
+	defer ctx.guard()()
+
+	ctx.print("TRX_GAS_REFUND",
+		Uint64(gasLeft),
+		Uint64(accumulated),
+		Uint64(applied),
+		string(rule),
+	)
+}
@@ -1,5 +1,11 @@
 package firehose
 
+import (
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
 // Enabled determines if firehose instrumentation is enabled. Controlling
 // firehose behavior is then controlled via other flag like.
 var Enabled = false
@@ -31,12 +37,283 @@ var MiningEnabled = false
 // precedence over this setting.
 var BlockProgressEnabled = false
 
+// BlockProgressDetailsEnabled emits, right before each block progress FINALIZE_BLOCK line, a
+// BLOCK_PROGRESS event giving the block's hash, parent hash, timestamp and transaction count.
+// Orchestrators relying on progress-only nodes can then detect forks (parent hash not matching
+// the previous block's hash) and stalls (timestamps lagging behind) precisely.
+var BlockProgressDetailsEnabled = false
+
+// FollowerModeEnabled emits the blocks imported without being executed, which is the case of
+// the blocks imported along their receipts during a fast sync, as skeleton blocks (see
+// `Context.RecordSkeletonBlock`): the transactions' envelopes sourced from the block bodies and
+// their outcome sourced from the receipts, without calls nor state changes. It supports
+// lightweight Firehose producers on chains where the execution is handled elsewhere.
+var FollowerModeEnabled = false
+
+// CallAccessSetsEnabled enables the CALL_ACCESS_SET event emitted right before each
+// EVM_END_CALL, it lists the unique addresses and storage slots accessed by the call (its own
+// target included, its children's accesses excluded), each classified as warm or cold under
+// EIP-2929 rules, i.e. warm when already accessed earlier in the transaction (the sender and
+// the recipient of the transaction being warm from the start). Reverted accesses are not
+// rolled back. It gives gas modelling and parallelization research ready-made access sets.
+var CallAccessSetsEnabled = false
+
+// StartBlockNumber is the first block fully instrumented by the sync context, blocks below
+// it only emit block progress (the FINALIZE_BLOCK line) as if `BlockProgressEnabled` was set.
+// It's useful to consumers only needing recent history since it avoids paying the full
+// instrumentation cost while bootstrapping a node mid-chain.
+var StartBlockNumber uint64 = 0
+
+// CallInstrumentationEnabled determines if read-only RPC executions can be instrumented
+// through the `debug_callWithFirehoseTrace` RPC, each execution accumulating its Firehose
+// log in a speculative execution buffer returned to the caller.
+//
+// This is an opt-in feature, useful for wallet simulation services, and it's independent
+// of the `Enabled` setting since it never prints anything to standard output.
+var CallInstrumentationEnabled = false
+
+// SpillThresholdInBytes moves, when greater than 0, the lines accumulated by a speculative
+// execution buffer (a transaction being executed) to a temporary file once they exceed this
+// size, they are transparently read back when the transaction is flushed or its log is
+// requested. Instrumenting giant transactions (e.g. rollup batches with huge calldata) then
+// can't exhaust the node's memory.
+var SpillThresholdInBytes = 0
+
+// CallBufferLimitInBytes bounds, when greater than 0, the Firehose log accumulated by each
+// `debug_callWithFirehoseTrace` execution. A call whose log exceeds it is aborted and fails
+// with `ErrBufferLimitExceeded`, protecting the node against requests crafted to blow up
+// the speculative execution buffer. Requests can lower it but never raise it.
+var CallBufferLimitInBytes = 64 * 1024 * 1024
+
+// ReturnDataLimitInBytes truncates, when greater than 0, the return data printed in
+// EVM_END_CALL to this size, bounding the output of calls returning huge payloads. The full
+// size of the return data is then printed as an extra `return_data_size` field (the `aborted`
+// field preceding it being always printed) so that consumers still get accurate sizing.
+var ReturnDataLimitInBytes = 0
+
+// ReducedOrdinalsEnabled enables reduced ordinals mode where only events requiring
+// cross-family ordering (transactions, calls, logs, balance and storage changes) consume
+// an ordinal. Purely informational events (gas, nonce, code changes and account creations)
+// instead reference the current ordinal value, reducing the amount of distinct ordinals
+// emitted per block.
+var ReducedOrdinalsEnabled = false
+
+// BlockShardSizeInBytes enables block sharding when greater than 0. On some chains,
+// a single block instrumentation can exceed pipe buffer sizes and stall emission. When
+// sharding is enabled, transactions data is emitted in numbered segments of at most
+// this size (aligned on line boundaries), each introduced by a BLOCK_SEGMENT line, and a
+// BLOCK_SEGMENTS manifest line precedes END_BLOCK so the reader can reassemble the block.
+var BlockShardSizeInBytes = 0
+
+// CompactCodeChangesEnabled replaces CODE_CHANGE by CODE_CHANGE_REF which prints code
+// hashes and lengths instead of full code bytes, the full new code bytes being printed only
+// for newly deployed code. This cuts payloads of large contract upgrades while keeping the
+// code reconstructable through its hash.
+var CompactCodeChangesEnabled = false
+
+// ContentAddressedCodeStore, when set, enables the content-addressed code mode: code changes
+// are emitted as CODE_CHANGE_REF carrying the code hashes only, the code bytes being stored
+// once in this store (proxies and clones repeat the same code over and over). A CODE_BLOB
+// event announces each code the first time it's stored. It takes precedence over
+// `CompactCodeChangesEnabled`.
+var ContentAddressedCodeStore CodeStore
+
+// StrictEnabled determines how violations of the instrumentation invariants (like
+// entering a block while already in a block) are handled. In strict mode, the default,
+// a violation panics. In non-strict mode, an ERROR event is emitted, the violation is
+// counted in the `firehose/invariant/violations` metric and recovery is attempted, this
+// is preferred on production indexing nodes where availability beats crash-on-anomaly.
+var StrictEnabled = true
+
+// RecoveryLimit is the amount of invariant violations that can be recovered from in
+// non-strict mode by a context (its flushed transactions included) within
+// `RecoveryWindowInBlocks` blocks. Once exceeded, the context's emission is halted: a FATAL
+// event is written to its output stream, which then stays silent, rather than continuing to
+// produce possibly corrupt data, the streams of other contexts being unaffected. Halts are
+// counted in the `firehose/invariant/halts` metric. 0, the default, never halts the emission.
+var RecoveryLimit = 0
+
+// RecoveryWindowInBlocks is the amount of blocks, the current one included, in which the
+// recovered invariant violations are accounted against `RecoveryLimit`, 0 accounts all of
+// them since the process start.
+var RecoveryWindowInBlocks uint64 = 100
+
+// TrieCommitStatsEnabled enables the TRIE_COMMIT event giving, for each block, the amount
+// of trie nodes and bytes persisted to the database along the state commit duration. A block's
+// state is committed after its END_BLOCK, as such, its statistics are emitted right after
+// the FINALIZE_BLOCK line of the following block. It can be used to correlate archive mode
+// growth and pruning behavior with the node's configuration.
+var TrieCommitStatsEnabled = false
+
+// TrxFromPubkeyEnabled makes TRX_FROM include the 64 bytes uncompressed public key of the
+// transaction's sender, recovered from the transaction's signature. It's useful to downstream
+// systems like signature clustering, at the expense of an extra signature recovery per
+// transaction.
+var TrxFromPubkeyEnabled = false
+
+// StorageWipesEnabled emits a STORAGE_WIPED event when a contract self-destructs, giving its
+// storage root as last computed by the state (slots written earlier in the same block are not
+// accounted in it). The whole storage of the contract is deleted without any STORAGE_CHANGE
+// being emitted, flat-state consumers can then invalidate all of its slots at once. Like the
+// other changes, it's reverted along its call.
+var StorageWipesEnabled = false
+
+// BlockHashReadsEnabled emits a BLOCKHASH_READ event, giving the queried block number and the
+// returned hash, each time a contract executes the BLOCKHASH opcode. It helps detecting the
+// use of block hashes as a randomness source and rebuilding the execution inputs downstream.
+var BlockHashReadsEnabled = false
+
+// MonotonicTimestampsEnabled prefixes every event with the time at which it was printed, in
+// nanoseconds of a monotonic clock started with the process, in the form `FIRE @<ns> EVENT`.
+// A CLOCK_ANCHOR event following each BEGIN_BLOCK ties the monotonic clock to the wall clock.
+// Compared to the delivery time, it enables latency analysis of the pipeline (execution,
+// serialization, delivery) from captured streams. Transaction events keep the time they were
+// executed at even when flushed later.
+var MonotonicTimestampsEnabled = false
+
+// SequenceNumbersEnabled prefixes every line written to the output with its sequence number
+// in the stream, starting at 1 and increasing by 1 per line, in the form `FIRE #<seq> EVENT`
+// (before the monotonic timestamp, if any). Unlike ordinals, it's assigned when the line is
+// written, transaction lines flushed later included, so that a reader seeing a gap or a
+// repeated number knows lines were dropped or duplicated on their way (e.g. by a faulty
+// pipe) and can discard the block in progress and request its retransmission. Lines framed
+// by `MiningLinePrefix` are not numbered, they are not part of the canonical stream.
+var SequenceNumbersEnabled = false
+
+// StorageKeyPreimagesEnabled makes STORAGE_CHANGE include the preimage of the storage key
+// when the key is the Keccak256 hash of data hashed earlier in the same transaction, which
+// is how Solidity derives mapping slot keys. Indexers can then decode mapping keys without
+// maintaining their own Keccak256 reverse index.
+var StorageKeyPreimagesEnabled = false
+
+// SlowTransactionThreshold enables, when greater than 0, the SLOW_TRX diagnostic event
+// emitted right before END_APPLY_TRX for transactions whose execution took longer than this
+// wall-clock duration, giving the elapsed time and the gas used. While the transaction is
+// still executing, a SLOW_TRX_PROGRESS event giving the gas used so far is also emitted each
+// time this duration elapses. It aids the performance forensics of pathological contracts
+// (e.g. long-running EVM loops).
+var SlowTransactionThreshold time.Duration = 0
+
+// GasChangeCoalescingEnabled merges consecutive GAS_CHANGE of a call having the same reason,
+// each one starting at the gas the previous one left, into a single GAS_CHANGE going from the
+// first old value to the last new value. A trailing `count` field, printed on every
+// GAS_CHANGE, gives the amount of changes merged. Tight loops emitting thousands of
+// identical gas changes then produce a single line.
+var GasChangeCoalescingEnabled = false
+
+// TrxGasRefundsEnabled emits a TRX_GAS_REFUND event when a transaction's refund counter is
+// applied, giving both the accumulated refund and the applied one along the rule that capped
+// it, so that downstream gas accounting matches consensus exactly across fork boundaries.
+var TrxGasRefundsEnabled = false
+
+// NetBalanceChangesEnabled accumulates the balance changes of an address for a given reason
+// within a call into a single BALANCE_CHANGE giving the net change, printed when the call
+// is left (a sub-call starts or the call ends). Hot contracts emitting dozens of tiny changes
+// for the same address produce much less data, at the expense of exactness: the individual
+// changes and their ordering relative to the call's other events are lost. The mode is
+// advertised by a trailing `net` field in INIT.
+var NetBalanceChangesEnabled = false
+
+// UncleBlocksEnabled emits, right before END_BLOCK, an UNCLE_BLOCK event giving the body
+// (transactions and uncles) of each of the block's uncles available locally, which is the
+// case when the node imported the uncle as a side chain block. Uncles whose body is unknown
+// are skipped, consumers building complete uncles datasets then need no extra RPC calls.
+var UncleBlocksEnabled = false
+
+// LogsBloomCheckEnabled makes each transaction's receipt bloom, printed in END_APPLY_TRX,
+// verified against the bloom computed from the logs captured through ADD_LOG (those of failed
+// calls excluded). A mismatch means a code path emitting logs is not instrumented, it's
+// reported as an invariant violation, see `StrictEnabled`.
+var LogsBloomCheckEnabled = false
+
+// OrderingCheckpointsEnabled makes FINALIZE_BLOCK carry an ordering checkpoint of the block:
+// the amount of ordinals it consumed (including those of its transactions) and a JSON object
+// giving the count of events emitted since BEGIN_BLOCK per family (see `eventFamilies`). The
+// reader can then validate it received the complete set of events for the block, guarding
+// against partial delivery, before accepting END_BLOCK. Events following FINALIZE_BLOCK (block
+// rewards, TRIE_COMMIT) are not part of the checkpoint.
+var OrderingCheckpointsEnabled = false
+
+// FeeRecipientCreditEnabled emits, right before FINALIZE_BLOCK, a FEE_RECIPIENT_CREDIT event
+// giving the fee recipient of the block and the total of the transaction fees credited to it,
+// sparing revenue analytics from summing the `reward_transaction_fee` balance changes. This
+// chain has no base fee, nothing is burned, the total is the whole fees paid by the block's
+// transactions.
+var FeeRecipientCreditEnabled = false
+
+// StateProofAccounts are the accounts, along their selected storage slots, for which an
+// ACCOUNT_PROOF and STORAGE_PROOF events are emitted in each block once it's finalized (block
+// rewards applied), proving their values against the block's state root. Downstream consumers
+// can then verify the streamed balances, nonces and slots without trusting the node. Empty,
+// the default, disables it, see `ParseStateProofAccounts`.
+var StateProofAccounts map[common.Address][]common.Hash
+
+// GenesisAllocFromStateEnabled makes the genesis block accounts emitted by walking the
+// genesis state, as committed in the database, instead of the genesis spec. Accounts' code
+// and storage are then read by `GenesisAllocReaders` parallel readers, the emission keeping
+// the same ordering, which significantly speeds up block 0 production of chains with huge
+// genesis. It requires the preimages of the genesis state, which are committed with it.
+var GenesisAllocFromStateEnabled = false
+
+// GenesisAllocReaders is the amount of parallel readers used when `GenesisAllocFromStateEnabled`
+// is set, 0 uses one reader per CPU.
+var GenesisAllocReaders = 0
+
 // GenesisConfig keeps globally for the process the genesis config of the chain.
 // The genesis config extracted from the initialization code of Geth, otherwise
-// the operator will need to set the flag `--firehose-genesis-file` pointing
+// the operator will need to set the flag `--firehose.genesisfile` pointing
 // it to correct genesis.json file for the chain.
 //
 // **Note** We use `interface{}` here instead of `*core.Genesis` because we otherwise
 // have a compilation cycle because `core` package already uses `firehose` package.
 // Consumer of this library make the cast back to the correct types when needed.
 var GenesisConfig interface{}
+
+// featureToggles are the Firehose toggles shaping the emitted stream, it's the single list
+// the features manifest is built from. Any new toggle changing what's emitted must be added
+// here so that the stream stays self-describing.
+var featureToggles = []struct {
+	name  string
+	value func() interface{}
+}{
+	{"enabled", func() interface{} { return Enabled }},
+	{"sync_instrumentation", func() interface{} { return SyncInstrumentationEnabled }},
+	{"mining", func() interface{} { return MiningEnabled }},
+	{"output_format", func() interface{} { return string(syncOutputFormat) }},
+	{"block_progress", func() interface{} { return BlockProgressEnabled }},
+	{"block_progress_details", func() interface{} { return BlockProgressDetailsEnabled }},
+	{"follower_mode", func() interface{} { return FollowerModeEnabled }},
+	{"call_access_sets", func() interface{} { return CallAccessSetsEnabled }},
+	{"return_data_limit", func() interface{} { return ReturnDataLimitInBytes }},
+	{"reduced_ordinals", func() interface{} { return ReducedOrdinalsEnabled }},
+	{"block_shard_size", func() interface{} { return BlockShardSizeInBytes }},
+	{"compact_code_changes", func() interface{} { return CompactCodeChangesEnabled }},
+	{"code_store", func() interface{} { return ContentAddressedCodeStore != nil }},
+	{"trie_commit_stats", func() interface{} { return TrieCommitStatsEnabled }},
+	{"trx_from_pubkey", func() interface{} { return TrxFromPubkeyEnabled }},
+	{"storage_wipes", func() interface{} { return StorageWipesEnabled }},
+	{"block_hash_reads", func() interface{} { return BlockHashReadsEnabled }},
+	{"monotonic_timestamps", func() interface{} { return MonotonicTimestampsEnabled }},
+	{"sequence_numbers", func() interface{} { return SequenceNumbersEnabled }},
+	{"storage_key_preimages", func() interface{} { return StorageKeyPreimagesEnabled }},
+	{"slow_trx_threshold", func() interface{} { return SlowTransactionThreshold.String() }},
+	{"gas_change_coalescing", func() interface{} { return GasChangeCoalescingEnabled }},
+	{"trx_gas_refunds", func() interface{} { return TrxGasRefundsEnabled }},
+	{"net_balance_changes", func() interface{} { return NetBalanceChangesEnabled }},
+	{"uncle_blocks", func() interface{} { return UncleBlocksEnabled }},
+	{"logs_bloom_check", func() interface{} { return LogsBloomCheckEnabled }},
+	{"ordering_checkpoints", func() interface{} { return OrderingCheckpointsEnabled }},
+	{"fee_recipient_credit", func() interface{} { return FeeRecipientCreditEnabled }},
+	{"state_proof_accounts", func() interface{} { return len(StateProofAccounts) }},
+}
+
+// featuresManifest returns the current value of all Firehose feature toggles keyed by name,
+// used to make the emitted stream self-describing.
+func featuresManifest() map[string]interface{} {
+	manifest := make(map[string]interface{}, len(featureToggles))
+	for _, toggle := range featureToggles {
+		manifest[toggle.name] = toggle.value()
+	}
+
+	return manifest
+}
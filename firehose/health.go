@@ -0,0 +1,132 @@
+package firehose
+
+import (
+	"sync"
+
+	"go.uber.org/atomic"
+)
+
+// Health is a point in time snapshot of the Firehose pipeline health, used to expose
+// the pipeline state to operators.
+type Health struct {
+	CurrentBlock         uint64 `json:"current_block"`
+	ChainHeadBlock       uint64 `json:"chain_head_block"`
+	BlockLag             uint64 `json:"block_lag"`
+	ImportedBlock        uint64 `json:"imported_block"`
+	EmissionLag          uint64 `json:"emission_lag"`
+	BufferOccupancyBytes uint64 `json:"buffer_occupancy_bytes"`
+	BufferCapacityBytes  uint64 `json:"buffer_capacity_bytes"`
+	LastError            string `json:"last_error"`
+	Halted               bool   `json:"halted"`
+}
+
+// healthTracker tracks the health of the pipeline of a context, each context owns one so
+// that the chain instances of a process report their own health, see `ContextProvider`.
+type healthTracker struct {
+	currentBlock         *atomic.Uint64
+	bufferOccupancyBytes *atomic.Uint64
+	bufferCapacityBytes  *atomic.Uint64
+
+	lock                 sync.Mutex
+	lastError            string
+	chainHeadProvider    func() uint64
+	importedHeadProvider func() uint64
+}
+
+func newHealthTracker() *healthTracker {
+	return &healthTracker{
+		currentBlock:         atomic.NewUint64(0),
+		bufferOccupancyBytes: atomic.NewUint64(0),
+		bufferCapacityBytes:  atomic.NewUint64(0),
+	}
+}
+
+func (t *healthTracker) recordBlock(number uint64) {
+	t.currentBlock.Store(number)
+}
+
+// recordBuffer accounts the bytes accumulated by printer, spilled ones included, the spill
+// file growing as needed, it's accounted as part of the capacity too.
+func (t *healthTracker) recordBuffer(printer *ToBufferPrinter) {
+	t.bufferOccupancyBytes.Store(uint64(printer.Len()))
+	t.bufferCapacityBytes.Store(uint64(printer.buffer.Cap() + printer.spilledBytes))
+}
+
+func (t *healthTracker) recordError(err string) {
+	if t == nil {
+		return
+	}
+
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	t.lastError = err
+}
+
+// SetChainHeadProvider registers the function used to resolve the highest block known to
+// the network, it's used to compute the lag of the sync context's pipeline against the chain
+// head.
+func SetChainHeadProvider(provider func() uint64) {
+	tracker := syncContext.health
+	tracker.lock.Lock()
+	defer tracker.lock.Unlock()
+
+	tracker.chainHeadProvider = provider
+}
+
+// SetImportedHeadProvider registers the function used to resolve the head of the local chain,
+// it's used to compute the emission lag of the sync context, the amount of blocks imported
+// but not yet flushed to the Firehose output.
+func SetImportedHeadProvider(provider func() uint64) {
+	tracker := syncContext.health
+	tracker.lock.Lock()
+	defer tracker.lock.Unlock()
+
+	tracker.importedHeadProvider = provider
+}
+
+// CurrentHealth returns a snapshot of the current health of the sync context's pipeline.
+func CurrentHealth() Health {
+	return syncContext.Health()
+}
+
+// Health returns a snapshot of the current health of the context's pipeline.
+func (ctx *Context) Health() Health {
+	tracker := ctx.health
+
+	tracker.lock.Lock()
+	lastError := tracker.lastError
+	chainHeadProvider := tracker.chainHeadProvider
+	importedHeadProvider := tracker.importedHeadProvider
+	tracker.lock.Unlock()
+
+	health := Health{
+		CurrentBlock:         tracker.currentBlock.Load(),
+		BufferOccupancyBytes: tracker.bufferOccupancyBytes.Load(),
+		BufferCapacityBytes:  tracker.bufferCapacityBytes.Load(),
+		LastError:            lastError,
+		Halted:               ctx.EmissionHalted(),
+	}
+
+	if chainHeadProvider != nil {
+		health.ChainHeadBlock = chainHeadProvider()
+	}
+
+	// When not syncing, the network head is unknown (or behind us), we are then at the head
+	if health.ChainHeadBlock < health.CurrentBlock {
+		health.ChainHeadBlock = health.CurrentBlock
+	}
+
+	health.BlockLag = health.ChainHeadBlock - health.CurrentBlock
+
+	// A block is emitted right before becoming the new head, the current block can then be
+	// briefly ahead of the imported one
+	if importedHeadProvider != nil {
+		health.ImportedBlock = importedHeadProvider()
+	}
+	if health.ImportedBlock > health.CurrentBlock {
+		health.EmissionLag = health.ImportedBlock - health.CurrentBlock
+	}
+
+	return health
+}
@@ -0,0 +1,62 @@
+package firehose
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+func TestHealthProgressBlocks(t *testing.T) {
+	ctx := NewContext(NewDelegateToWriterPrinter(&bytes.Buffer{}))
+	ctx.health.importedHeadProvider = func() uint64 { return 5 }
+
+	// Only finalized in block progress mode, the block never ends
+	ctx.FinalizeBlock(types.NewBlockWithHeader(&types.Header{Number: big.NewInt(5)}))
+
+	health := ctx.Health()
+	if health.CurrentBlock != 5 || health.EmissionLag != 0 {
+		t.Fatalf("expected progress block to be accounted as emitted, have current block %d and emission lag %d", health.CurrentBlock, health.EmissionLag)
+	}
+
+	block := types.NewBlockWithHeader(&types.Header{Number: big.NewInt(6)})
+	ctx.StartBlock(block)
+	ctx.FinalizeBlock(block)
+	if current := ctx.Health().CurrentBlock; current != 5 {
+		t.Fatalf("expected full block to be accounted once ended only, have current block %d", current)
+	}
+
+	ctx.EndBlock(block, nil)
+	if current := ctx.Health().CurrentBlock; current != 6 {
+		t.Fatalf("expected ended block to be accounted as emitted, have current block %d", current)
+	}
+}
+
+func TestHealthSpilledBuffer(t *testing.T) {
+	defer func(threshold int) { SpillThresholdInBytes = threshold }(SpillThresholdInBytes)
+	SpillThresholdInBytes = 64
+
+	ctx := NewContext(NewDelegateToWriterPrinter(&bytes.Buffer{}))
+	txContext := ctx.NewTransactionContext(16)
+
+	for i := 0; i < 8; i++ {
+		txContext.printer.Print("EVM_LOG", "0123456789abcdef")
+	}
+
+	printer := txContext.printer.(*ToBufferPrinter)
+	if !printer.Spilled() {
+		t.Fatal("expected transaction buffer to be spilled")
+	}
+	accumulated := uint64(printer.Len())
+
+	ctx.FlushTransaction(txContext)
+
+	health := ctx.Health()
+	if health.BufferOccupancyBytes != accumulated {
+		t.Fatalf("expected spilled bytes to be accounted in buffer occupancy, have %d, want %d", health.BufferOccupancyBytes, accumulated)
+	}
+	if health.BufferCapacityBytes < health.BufferOccupancyBytes {
+		t.Fatalf("expected buffer capacity %d to be at least buffer occupancy %d", health.BufferCapacityBytes, health.BufferOccupancyBytes)
+	}
+}
@@ -0,0 +1,63 @@
+package firehose
+
+import (
+	"encoding/binary"
+	"unsafe"
+)
+
+// hexPairs maps each byte to its two lowercase hexadecimal digits packed in little endian
+// order, encoding a byte then takes a single lookup instead of one per nibble.
+var hexPairs = func() (table [256]uint16) {
+	const digits = "0123456789abcdef"
+	for i := range table {
+		table[i] = uint16(digits[i>>4]) | uint16(digits[i&0x0f])<<8
+	}
+	return table
+}()
+
+// AppendHex appends the lowercase hexadecimal encoding of `in` to `dst` and returns the
+// extended slice, `dst` is only reallocated when its capacity is insufficient so callers
+// reusing a preallocated buffer encode without any allocation.
+func AppendHex(dst []byte, in []byte) []byte {
+	offset := len(dst)
+	if needed := offset + 2*len(in); needed > cap(dst) {
+		grown := make([]byte, offset, needed)
+		copy(grown, dst)
+		dst = grown
+	}
+	dst = dst[:offset+2*len(in)]
+
+	encodeHex(dst[offset:], in)
+	return dst
+}
+
+// encodeHex writes the hexadecimal encoding of `in` to `dst`, which must be exactly twice as
+// long as `in`.
+func encodeHex(dst []byte, in []byte) {
+	if len(in) == 0 {
+		return
+	}
+
+	// Bytes are encoded four at a time, their digits being written with a single store
+	i := 0
+	for ; i+4 <= len(in); i += 4 {
+		binary.LittleEndian.PutUint64(dst[2*i:],
+			uint64(hexPairs[in[i]])|uint64(hexPairs[in[i+1]])<<16|uint64(hexPairs[in[i+2]])<<32|uint64(hexPairs[in[i+3]])<<48)
+	}
+	for ; i < len(in); i++ {
+		binary.LittleEndian.PutUint16(dst[2*i:], hexPairs[in[i]])
+	}
+}
+
+// encodeHexString is the equivalent of `hex.EncodeToString` performing a single allocation,
+// the encoded buffer is never modified once encoded and is turned into the returned string
+// without being copied.
+func encodeHexString(in []byte) string {
+	if len(in) == 0 {
+		return ""
+	}
+
+	buf := make([]byte, 2*len(in))
+	encodeHex(buf, in)
+	return *(*string)(unsafe.Pointer(&buf))
+}
@@ -0,0 +1,60 @@
+package firehose
+
+import (
+	"bytes"
+	"encoding/hex"
+	"math/rand"
+	"testing"
+)
+
+func TestEncodeHex(t *testing.T) {
+	for _, size := range []int{0, 1, 20, 32, 1024} {
+		in := make([]byte, size)
+		rand.Read(in)
+
+		if got, want := encodeHexString(in), hex.EncodeToString(in); got != want {
+			t.Errorf("size %d: encodeHexString got %s, want %s", size, got, want)
+		}
+
+		prefix := []byte("0x")
+		if got, want := AppendHex(prefix, in), append([]byte("0x"), hex.EncodeToString(in)...); !bytes.Equal(got, want) {
+			t.Errorf("size %d: AppendHex got %s, want %s", size, got, want)
+		}
+	}
+}
+
+func TestAppendHexReusesBuffer(t *testing.T) {
+	buf := make([]byte, 0, 64)
+	out := AppendHex(buf, bytes.Repeat([]byte{0xab}, 32))
+	if &out[0] != &buf[:1][0] {
+		t.Error("expected AppendHex to reuse the buffer with enough capacity")
+	}
+}
+
+func benchmarkHex(b *testing.B, size int, encode func([]byte) string) {
+	in := make([]byte, size)
+	rand.Read(in)
+
+	b.SetBytes(int64(size))
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		encode(in)
+	}
+}
+
+func BenchmarkHexStdlib32B(b *testing.B) { benchmarkHex(b, 32, hex.EncodeToString) }
+func BenchmarkHexEncode32B(b *testing.B) { benchmarkHex(b, 32, encodeHexString) }
+func BenchmarkHexStdlib1KB(b *testing.B) { benchmarkHex(b, 1024, hex.EncodeToString) }
+func BenchmarkHexEncode1KB(b *testing.B) { benchmarkHex(b, 1024, encodeHexString) }
+
+func BenchmarkAppendHex1KB(b *testing.B) {
+	in := make([]byte, 1024)
+	rand.Read(in)
+	buf := make([]byte, 0, 2*len(in))
+
+	b.SetBytes(int64(len(in)))
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		buf = AppendHex(buf[:0], in)
+	}
+}
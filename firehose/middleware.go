@@ -0,0 +1,223 @@
+package firehose
+
+import (
+	"compress/gzip"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"io/ioutil"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// WriterMiddleware transforms the output stream before handing it to the `next` writer of
+// the chain, it's applied once to each write, a write carrying one or more complete lines.
+type WriterMiddleware func(next io.Writer) io.Writer
+
+// WriterMiddlewareFactory creates a middleware from its configuration parameter, the part
+// after the `:` in the middleware specification (empty when absent).
+type WriterMiddlewareFactory func(param string) (WriterMiddleware, error)
+
+var writerMiddlewaresLock sync.Mutex
+var writerMiddlewares = map[string]WriterMiddlewareFactory{
+	"gzip":     newGzipMiddleware,
+	"checksum": newChecksumMiddleware,
+	"frame":    newFrameMiddleware,
+}
+
+// syncOutputMiddlewares are the middlewares applied to the sync context output, see
+// `SetOutputMiddlewares`, and syncMiddlewareWriters the writers they created on the active
+// chain, which are closed by `CloseOutputMiddlewares`.
+var syncOutputMiddlewares []WriterMiddleware
+var syncMiddlewareWriters []io.Writer
+
+// RegisterWriterMiddleware makes a middleware available under `name` in the middleware
+// specifications, it must be called at initialization time. Registering an already known
+// name replaces it.
+func RegisterWriterMiddleware(name string, factory WriterMiddlewareFactory) {
+	writerMiddlewaresLock.Lock()
+	defer writerMiddlewaresLock.Unlock()
+
+	writerMiddlewares[name] = factory
+}
+
+// ParseWriterMiddlewares creates the middlewares of a comma separated specification like
+// `gzip,checksum,frame`, each element being a registered middleware name optionally
+// followed by `:<param>`. The middlewares are returned in the order data goes through them.
+func ParseWriterMiddlewares(spec string) ([]WriterMiddleware, error) {
+	writerMiddlewaresLock.Lock()
+	defer writerMiddlewaresLock.Unlock()
+
+	var middlewares []WriterMiddleware
+	for _, element := range strings.Split(spec, ",") {
+		element = strings.TrimSpace(element)
+		if element == "" {
+			continue
+		}
+
+		name, param := element, ""
+		if i := strings.IndexByte(element, ':'); i != -1 {
+			name, param = element[:i], element[i+1:]
+		}
+
+		factory, found := writerMiddlewares[name]
+		if !found {
+			return nil, fmt.Errorf("unknown output middleware %q, valid middlewares are %s", name, strings.Join(knownWriterMiddlewares(), ", "))
+		}
+
+		middleware, err := factory(param)
+		if err != nil {
+			return nil, fmt.Errorf("output middleware %q: %w", name, err)
+		}
+
+		middlewares = append(middlewares, middleware)
+	}
+
+	return middlewares, nil
+}
+
+// ChainWriterMiddlewares returns a writer sending the data through each of the middlewares,
+// in order, before it reaches `sink`. The returned slice holds the writers created by the
+// middlewares, in the same order.
+func ChainWriterMiddlewares(sink io.Writer, middlewares []WriterMiddleware) (io.Writer, []io.Writer) {
+	writers := make([]io.Writer, len(middlewares))
+
+	writer := sink
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		writer = middlewares[i](writer)
+		writers[i] = writer
+	}
+
+	return writer, writers
+}
+
+// SetOutputMiddlewares changes the middlewares applied to the sync context output, between
+// the output format conversion and the sink, `spec` being parsed by `ParseWriterMiddlewares`.
+// It must be called at initialization time, before any block is processed.
+func SetOutputMiddlewares(spec string) error {
+	middlewares, err := ParseWriterMiddlewares(spec)
+	if err != nil {
+		return err
+	}
+
+	syncOutputMiddlewares = middlewares
+	SetSyncContextWriter(syncContextWriter)
+
+	return nil
+}
+
+// CloseOutputMiddlewares closes the middlewares of the sync context output that need to
+// terminate their stream (e.g. the gzip footer), it must be called once the node stopped
+// processing blocks and before the sink is closed.
+func CloseOutputMiddlewares() error {
+	var firstErr error
+	for _, writer := range syncMiddlewareWriters {
+		if closer, ok := writer.(io.Closer); ok {
+			if err := closer.Close(); err != nil && firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+
+	return firstErr
+}
+
+func knownWriterMiddlewares() []string {
+	names := make([]string, 0, len(writerMiddlewares))
+	for name := range writerMiddlewares {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// newGzipMiddleware compresses the stream as a single gzip member, flushed after each write
+// so that a consumer reading the stream live is never starved. The param is the compression
+// level, the default level being used when empty.
+func newGzipMiddleware(param string) (WriterMiddleware, error) {
+	level := gzip.DefaultCompression
+	if param != "" {
+		if _, err := fmt.Sscanf(param, "%d", &level); err != nil {
+			return nil, fmt.Errorf("invalid compression level %q", param)
+		}
+	}
+
+	if _, err := gzip.NewWriterLevel(ioutil.Discard, level); err != nil {
+		return nil, err
+	}
+
+	return func(next io.Writer) io.Writer {
+		writer, _ := gzip.NewWriterLevel(next, level)
+		return &gzipWriter{writer: writer}
+	}, nil
+}
+
+type gzipWriter struct {
+	writer *gzip.Writer
+}
+
+func (w *gzipWriter) Write(data []byte) (int, error) {
+	written, err := w.writer.Write(data)
+	if err != nil {
+		return written, err
+	}
+
+	return written, w.writer.Flush()
+}
+
+func (w *gzipWriter) Close() error {
+	return w.writer.Close()
+}
+
+// newChecksumMiddleware appends the big-endian CRC-32 (Castagnoli) of each write to it, it
+// is meant to be followed by a framing middleware so that the consumer can delimit the
+// payloads it verifies.
+func newChecksumMiddleware(param string) (WriterMiddleware, error) {
+	if param != "" {
+		return nil, fmt.Errorf("unexpected parameter %q", param)
+	}
+
+	table := crc32.MakeTable(crc32.Castagnoli)
+
+	return func(next io.Writer) io.Writer {
+		return writerFunc(func(data []byte) (int, error) {
+			out := make([]byte, len(data)+4)
+			copy(out, data)
+			binary.BigEndian.PutUint32(out[len(data):], crc32.Checksum(data, table))
+
+			if _, err := next.Write(out); err != nil {
+				return 0, err
+			}
+			return len(data), nil
+		})
+	}, nil
+}
+
+// newFrameMiddleware prefixes each write with its big-endian uint32 length.
+func newFrameMiddleware(param string) (WriterMiddleware, error) {
+	if param != "" {
+		return nil, fmt.Errorf("unexpected parameter %q", param)
+	}
+
+	return func(next io.Writer) io.Writer {
+		return writerFunc(func(data []byte) (int, error) {
+			out := make([]byte, len(data)+4)
+			binary.BigEndian.PutUint32(out, uint32(len(data)))
+			copy(out[4:], data)
+
+			if _, err := next.Write(out); err != nil {
+				return 0, err
+			}
+			return len(data), nil
+		})
+	}, nil
+}
+
+type writerFunc func(data []byte) (int, error)
+
+func (f writerFunc) Write(data []byte) (int, error) {
+	return f(data)
+}
@@ -0,0 +1,66 @@
+package firehose
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"hash/crc32"
+	"io/ioutil"
+	"testing"
+)
+
+func TestWriterMiddlewaresChain(t *testing.T) {
+	middlewares, err := ParseWriterMiddlewares("gzip:9, checksum,frame")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sink := &bytes.Buffer{}
+	writer, writers := ChainWriterMiddlewares(sink, middlewares)
+
+	printer := NewDelegateToWriterPrinter(writer)
+	printer.Print("BEGIN_BLOCK", "1")
+	printer.Print("END_BLOCK", "1")
+
+	if err := writers[0].(*gzipWriter).Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Each frame holds a checksummed chunk of the gzip stream
+	compressed := &bytes.Buffer{}
+	for data := sink.Bytes(); len(data) > 0; {
+		length := binary.BigEndian.Uint32(data)
+		payload, checksum := data[4:4+length-4], binary.BigEndian.Uint32(data[4+length-4:])
+		if crc32.Checksum(payload, crc32.MakeTable(crc32.Castagnoli)) != checksum {
+			t.Fatalf("invalid checksum for frame %x", payload)
+		}
+
+		compressed.Write(payload)
+		data = data[4+length:]
+	}
+
+	reader, err := gzip.NewReader(compressed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines, err := ioutil.ReadAll(reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if expected := "FIRE BEGIN_BLOCK 1\nFIRE END_BLOCK 1\n"; string(lines) != expected {
+		t.Fatalf("unexpected output, got %q, want %q", lines, expected)
+	}
+}
+
+func TestParseWriterMiddlewares(t *testing.T) {
+	if middlewares, err := ParseWriterMiddlewares(""); err != nil || len(middlewares) != 0 {
+		t.Fatalf("expected no middleware, got %d (err %v)", len(middlewares), err)
+	}
+
+	for _, spec := range []string{"unknown", "gzip:fast", "gzip:42", "frame:4"} {
+		if _, err := ParseWriterMiddlewares(spec); err == nil {
+			t.Errorf("expected an error for %q", spec)
+		}
+	}
+}
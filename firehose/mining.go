@@ -0,0 +1,83 @@
+package firehose
+
+import (
+	"io"
+	"os"
+	"strings"
+	"sync"
+)
+
+// MiningLinePrefix frames every line emitted by the mining context when it shares its
+// output with the sync context, readers of the canonical stream only consider lines
+// starting with `FIRE ` and as such, skip framed mining lines entirely.
+const MiningLinePrefix = "MINING "
+
+// stdout is shared by the sync and mining contexts so that lines they emit concurrently
+// are never interleaved within each other.
+var stdout = &lockedWriter{writer: os.Stdout}
+
+var miningContext *Context = newMiningContext()
+
+// newMiningContext creates the mining context, it shares the mempool replacements tracked by
+// the sync context, the transactions it mines coming from the same pool.
+func newMiningContext() *Context {
+	ctx := NewContext(&framingPrinter{
+		prefix:   MiningLinePrefix,
+		delegate: NewDelegateToWriterPrinter(stdout),
+	})
+	ctx.replacements = syncContext.replacements
+
+	return ctx
+}
+
+// SetMiningContextWriter routes the mining context output to a dedicated writer, keeping
+// it entirely separated from the sync context output. Lines are not framed in this case.
+// It must be called at initialization time, before any block is mined.
+func SetMiningContextWriter(writer io.Writer) {
+	miningContext.setPrinter(NewDelegateToWriterPrinter(writer))
+}
+
+// MaybeMiningContext is used by the miner to record the speculative execution of the
+// transactions it includes in the blocks it produces. It returns `NoOpContext` unless
+// both firehose and mining are enabled.
+//
+// Mining-origin traces are never part of the canonical stream, they are either framed
+// with `MiningLinePrefix` or written to a dedicated output (see `SetMiningContextWriter`).
+func MaybeMiningContext() *Context {
+	if !Enabled || !MiningEnabled {
+		return NoOpContext
+	}
+
+	return miningContext
+}
+
+// framingPrinter prefixes every line printed through its delegate with `prefix`.
+type framingPrinter struct {
+	prefix   string
+	delegate *DelegateToWriterPrinter
+}
+
+func (p *framingPrinter) Print(input ...string) {
+	p.delegate.PrintRaw(p.prefix + formatLine(input))
+}
+
+func (p *framingPrinter) PrintRaw(lines string) {
+	if lines == "" {
+		return
+	}
+
+	framed := p.prefix + strings.Replace(strings.TrimSuffix(lines, "\n"), "\n", "\n"+p.prefix, -1) + "\n"
+	p.delegate.PrintRaw(framed)
+}
+
+type lockedWriter struct {
+	lock   sync.Mutex
+	writer io.Writer
+}
+
+func (w *lockedWriter) Write(data []byte) (int, error) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	return w.writer.Write(data)
+}
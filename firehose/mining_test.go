@@ -0,0 +1,29 @@
+package firehose
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestFramingPrinter(t *testing.T) {
+	output := &bytes.Buffer{}
+	printer := &framingPrinter{prefix: MiningLinePrefix, delegate: NewDelegateToWriterPrinter(output)}
+
+	printer.Print("TRX_FROM", "00")
+	printer.PrintRaw("FIRE EVM_RUN_CALL CALL 1 2 100 0\nFIRE EVM_END_CALL 1 50 . 3 100 0\n")
+	printer.PrintRaw("")
+
+	expected := "MINING FIRE TRX_FROM 00\nMINING FIRE EVM_RUN_CALL CALL 1 2 100 0\nMINING FIRE EVM_END_CALL 1 50 . 3 100 0\n"
+	if output.String() != expected {
+		t.Fatalf("unexpected output, got:\n%s\nwant:\n%s", output.String(), expected)
+	}
+
+	// Framed lines must be invisible to readers of the canonical stream
+	report, err := Check(output)
+	if err != nil {
+		t.Fatalf("check: %s", err)
+	}
+	if len(report.Events) != 0 || !report.Valid() {
+		t.Fatalf("expected framed lines to be ignored, got events %v and violations %v", report.Events, report.Violations)
+	}
+}
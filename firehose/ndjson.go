@@ -0,0 +1,182 @@
+package firehose
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// OutputFormat is the format of the lines written by the sync context.
+type OutputFormat string
+
+const (
+	// TextOutputFormat is the standard space separated `FIRE <EVENT> <fields...>` format.
+	TextOutputFormat OutputFormat = "text"
+
+	// NDJSONOutputFormat renders each event as a single line JSON object, the event name
+	// being under the `event` key and each field under its name in the event registry.
+	NDJSONOutputFormat OutputFormat = "ndjson"
+)
+
+// SetOutputFormat changes the format of the sync context output, it must be called at
+// initialization time, before any block is processed. The mining context output, which is
+// never part of the canonical stream, always remains in the text format.
+func SetOutputFormat(format OutputFormat) error {
+	if err := validateOutputFormat(format); err != nil {
+		return err
+	}
+
+	syncOutputFormat = format
+	SetSyncContextWriter(syncContextWriter)
+
+	return nil
+}
+
+func validateOutputFormat(format OutputFormat) error {
+	switch format {
+	case TextOutputFormat, NDJSONOutputFormat:
+		return nil
+	default:
+		return fmt.Errorf("unknown output format %q, valid formats are %q and %q", format, TextOutputFormat, NDJSONOutputFormat)
+	}
+}
+
+// NewNDJSONWriter returns an `io.Writer` converting each Firehose line it receives into a
+// JSON object before writing it to `writer`, lines that are not Firehose lines are written
+// as-is.
+//
+// Field values are all rendered as JSON strings, numbers included since they routinely
+// exceed the precision of JSON consumers, except fields holding a JSON document which are
+// embedded as-is (or `null` when empty). Fields unknown to the event registry are rendered
+// in an `extra` array.
+func NewNDJSONWriter(writer io.Writer) io.Writer {
+	return &ndjsonWriter{writer: writer}
+}
+
+type ndjsonWriter struct {
+	writer io.Writer
+
+	lock        sync.Mutex
+	partialLine []byte
+	converted   bytes.Buffer
+}
+
+// Write converts the complete lines of data, partial lines are kept until they are completed
+// by a subsequent write. The data is always fully consumed.
+func (w *ndjsonWriter) Write(data []byte) (int, error) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	w.partialLine = append(w.partialLine, data...)
+	end := bytes.LastIndexByte(w.partialLine, '\n')
+	if end == -1 {
+		return len(data), nil
+	}
+
+	w.converted.Reset()
+	for _, line := range bytes.SplitAfter(w.partialLine[:end+1], []byte("\n")) {
+		if len(line) > 0 {
+			writeNDJSONLine(&w.converted, string(line))
+		}
+	}
+	w.partialLine = append(w.partialLine[:0], w.partialLine[end+1:]...)
+
+	if _, err := w.writer.Write(w.converted.Bytes()); err != nil {
+		return len(data), err
+	}
+
+	return len(data), nil
+}
+
+func writeNDJSONLine(out *bytes.Buffer, line string) {
+	sequence, timestamp, event, fields, ok := splitTaggedLine(line)
+	if !ok {
+		out.WriteString(line)
+		return
+	}
+
+	out.WriteString(`{"event":`)
+	writeJSONString(out, event)
+
+	if sequence != "" {
+		out.WriteString(`,"seq":`)
+		writeJSONString(out, sequence)
+	}
+
+	if timestamp != "" {
+		out.WriteString(`,"mono_ns":`)
+		writeJSONString(out, timestamp)
+	}
+
+	schema, found := eventSchemas[event]
+	if !found {
+		out.WriteString(`,"fields":`)
+		writeJSONStrings(out, fields)
+		out.WriteString("}\n")
+		return
+	}
+
+	named := fields
+	if len(named) > len(schema.fields) {
+		named = named[:len(schema.fields)]
+	}
+
+	for i, value := range named {
+		out.WriteByte(',')
+		writeJSONString(out, schema.fields[i])
+		out.WriteByte(':')
+
+		if containsIndex(schema.jsonFields, i) {
+			writeJSONDocument(out, value)
+		} else {
+			writeJSONString(out, value)
+		}
+	}
+
+	if len(fields) > len(named) {
+		out.WriteString(`,"extra":`)
+		writeJSONStrings(out, fields[len(named):])
+	}
+
+	out.WriteString("}\n")
+}
+
+func writeJSONDocument(out *bytes.Buffer, value string) {
+	if value == "." || value == "" {
+		out.WriteString("null")
+		return
+	}
+
+	if !json.Valid([]byte(value)) {
+		writeJSONString(out, value)
+		return
+	}
+
+	json.Compact(out, []byte(value))
+}
+
+func writeJSONString(out *bytes.Buffer, value string) {
+	encoded, _ := json.Marshal(value)
+	out.Write(encoded)
+}
+
+func writeJSONStrings(out *bytes.Buffer, values []string) {
+	if values == nil {
+		values = []string{}
+	}
+
+	encoded, _ := json.Marshal(values)
+	out.Write(encoded)
+}
+
+func containsIndex(indexes []int, index int) bool {
+	for _, candidate := range indexes {
+		if candidate == index {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,34 @@
+package firehose
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestNDJSONWriter(t *testing.T) {
+	output := &bytes.Buffer{}
+	writer := NewNDJSONWriter(output)
+
+	writer.Write([]byte("FIRE BEGIN_BLOCK 1 aa bb 10 2 500\nFIRE EVM_REVERTED 1 08c379a0 \"not enough funds\"\nFIRE TRX_"))
+	writer.Write([]byte("FROM 00 11\nFIRE EVM_REVERTED 1 . .\nFIRE UNKNOWN_EVENT a b\nFIRE FINALIZE_BLOCK 1 full 10 {\"log\":1} extra\nnot a firehose line\n"))
+
+	expected := `{"event":"BEGIN_BLOCK","number":"1","hash":"aa","parent_hash":"bb","time":"10","trx_count":"2","size":"500"}` + "\n" +
+		`{"event":"EVM_REVERTED","call_index":"1","selector":"08c379a0","reason":"not enough funds"}` + "\n" +
+		`{"event":"TRX_FROM","from":"00","pubkey":"11"}` + "\n" +
+		`{"event":"EVM_REVERTED","call_index":"1","selector":".","reason":null}` + "\n" +
+		`{"event":"UNKNOWN_EVENT","fields":["a","b"]}` + "\n" +
+		`{"event":"FINALIZE_BLOCK","number":"1","mode":"full","ordinals":"10","events":{"log":1},"extra":["extra"]}` + "\n" +
+		"not a firehose line\n"
+
+	if output.String() != expected {
+		t.Fatalf("unexpected output, got:\n%s\nwant:\n%s", output.String(), expected)
+	}
+}
+
+func TestEventSchemasFieldNames(t *testing.T) {
+	for event, schema := range eventSchemas {
+		if len(schema.fields) != schema.fieldCount+schema.optionalFieldCount {
+			t.Errorf("event %s has %d field names, expected %d", event, len(schema.fields), schema.fieldCount+schema.optionalFieldCount)
+		}
+	}
+}
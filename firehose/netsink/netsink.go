@@ -0,0 +1,277 @@
+// Package netsink implements the retry policy shared by the Firehose network output sinks:
+// failed writes are retried with an exponential backoff and a circuit breaker stops the
+// connection attempts for a while once the remote end is deemed down. Writes block until
+// delivered, the Firehose stream can't have holes, so that a remote failure neither crashes
+// the node nor makes it spin in a hot reconnection loop.
+package netsink
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/metrics"
+)
+
+// ErrClosed is returned by the writes of a closed Writer.
+var ErrClosed = errors.New("sink closed")
+
+// Config is the retry policy of a Writer.
+type Config struct {
+	// InitialBackoff is the delay before retrying the first failure, each following
+	// failure doubles it up to MaxBackoff.
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+
+	// FailureThreshold is the amount of consecutive failures opening the circuit breaker,
+	// no attempt is then made for Cooldown after which a single probe attempt decides if
+	// the circuit closes again.
+	FailureThreshold int
+	Cooldown         time.Duration
+}
+
+// DefaultConfig is the retry policy used when none is configured.
+var DefaultConfig = Config{
+	InitialBackoff:   100 * time.Millisecond,
+	MaxBackoff:       10 * time.Second,
+	FailureThreshold: 5,
+	Cooldown:         30 * time.Second,
+}
+
+// Backoff returns the delay to wait after the given amount of consecutive failures.
+func (c Config) Backoff(failures int) time.Duration {
+	delay := c.InitialBackoff
+	for i := 1; i < failures && delay < c.MaxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > c.MaxBackoff {
+		delay = c.MaxBackoff
+	}
+	return delay
+}
+
+// BreakerState is the state of a circuit breaker.
+type BreakerState int
+
+const (
+	// BreakerClosed lets every attempt through.
+	BreakerClosed BreakerState = iota
+	// BreakerOpen refuses the attempts until the cooldown elapsed.
+	BreakerOpen
+	// BreakerHalfOpen lets a single probe attempt through.
+	BreakerHalfOpen
+)
+
+func (s BreakerState) String() string {
+	switch s {
+	case BreakerClosed:
+		return "closed"
+	case BreakerOpen:
+		return "open"
+	case BreakerHalfOpen:
+		return "half-open"
+	default:
+		return fmt.Sprintf("unknown(%d)", int(s))
+	}
+}
+
+// breaker is the circuit breaker state machine, it's not safe for concurrent use.
+type breaker struct {
+	config Config
+
+	state     BreakerState
+	failures  int
+	openUntil time.Time
+}
+
+// allow returns how long to wait before the next attempt is allowed, 0 when allowed now.
+func (b *breaker) allow(now time.Time) time.Duration {
+	if b.state == BreakerOpen {
+		if now.Before(b.openUntil) {
+			return b.openUntil.Sub(now)
+		}
+		b.state = BreakerHalfOpen
+	}
+	return 0
+}
+
+// success records a successful attempt, closing the circuit.
+func (b *breaker) success() {
+	b.state, b.failures = BreakerClosed, 0
+}
+
+// failure records a failed attempt, it returns `true` if the circuit opened because of it.
+func (b *breaker) failure(now time.Time) bool {
+	b.failures++
+	if b.state == BreakerHalfOpen || (b.config.FailureThreshold > 0 && b.failures >= b.config.FailureThreshold) {
+		b.state, b.openUntil = BreakerOpen, now.Add(b.config.Cooldown)
+		return true
+	}
+	return false
+}
+
+// Writer is an `io.Writer` delivering its writes through a connection established by its
+// connect function, reconnecting and retrying according to its Config until the write is
+// delivered or the writer is closed.
+type Writer struct {
+	name    string
+	config  Config
+	connect func() (io.WriteCloser, error)
+
+	lock    sync.Mutex
+	conn    io.WriteCloser
+	breaker *breaker
+
+	// state mirrors the breaker's state so that it can be read while a write is pending
+	state int32
+
+	done      chan struct{}
+	closeOnce sync.Once
+
+	stateGauge     metrics.Gauge
+	failuresMeter  metrics.Meter
+	tripsCounter   metrics.Counter
+	reconnectMeter metrics.Meter
+}
+
+// NewWriter creates a writer for the sink `name`, used in logs and metrics
+// (`firehose/sink/<name>/...`), obtaining its connections from `connect`. No connection is
+// made until the first write.
+func NewWriter(name string, config Config, connect func() (io.WriteCloser, error)) *Writer {
+	return &Writer{
+		name:           name,
+		config:         config,
+		connect:        connect,
+		breaker:        &breaker{config: config},
+		done:           make(chan struct{}),
+		stateGauge:     metrics.GetOrRegisterGauge("firehose/sink/"+name+"/breaker/state", nil),
+		failuresMeter:  metrics.GetOrRegisterMeter("firehose/sink/"+name+"/failures", nil),
+		tripsCounter:   metrics.GetOrRegisterCounter("firehose/sink/"+name+"/breaker/trips", nil),
+		reconnectMeter: metrics.GetOrRegisterMeter("firehose/sink/"+name+"/reconnects", nil),
+	}
+}
+
+// Connect establishes the connection right away, it's meant to report configuration errors
+// at startup, the failure doesn't count toward the circuit breaker.
+func (w *Writer) Connect() error {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	conn, err := w.connect()
+	if err != nil {
+		return err
+	}
+	w.conn = conn
+	return nil
+}
+
+// State returns the state of the writer's circuit breaker.
+func (w *Writer) State() BreakerState {
+	return BreakerState(atomic.LoadInt32(&w.state))
+}
+
+func (w *Writer) setState(state BreakerState) {
+	atomic.StoreInt32(&w.state, int32(state))
+	w.stateGauge.Update(int64(state))
+}
+
+// Write delivers `data`, blocking while the remote end is unreachable. It only fails once
+// the writer is closed, returning the amount of bytes delivered so far.
+func (w *Writer) Write(data []byte) (int, error) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	written := 0
+	for {
+		if wait := w.breaker.allow(time.Now()); wait > 0 {
+			if !w.sleep(wait) {
+				return written, ErrClosed
+			}
+			continue
+		}
+		w.setState(w.breaker.state)
+
+		n, err := w.attempt(data[written:])
+		written += n
+		if err == nil {
+			if w.breaker.state != BreakerClosed || w.breaker.failures > 0 {
+				log.Info("Firehose sink delivering again", "sink", w.name)
+			}
+			w.breaker.success()
+			w.setState(BreakerClosed)
+			return written, nil
+		}
+
+		w.failuresMeter.Mark(1)
+		if w.breaker.failure(time.Now()) {
+			w.tripsCounter.Inc(1)
+			w.setState(BreakerOpen)
+			log.Error("Firehose sink unreachable, pausing delivery", "sink", w.name, "failures", w.breaker.failures, "cooldown", w.config.Cooldown, "err", err)
+			continue
+		}
+
+		log.Warn("Firehose sink write failed, retrying", "sink", w.name, "failures", w.breaker.failures, "err", err)
+		if !w.sleep(w.config.Backoff(w.breaker.failures)) {
+			return written, ErrClosed
+		}
+	}
+}
+
+// attempt writes `data` on the current connection, establishing it if needed. A failed
+// connection is dropped so that the next attempt reconnects.
+func (w *Writer) attempt(data []byte) (int, error) {
+	select {
+	case <-w.done:
+		return 0, ErrClosed
+	default:
+	}
+
+	if w.conn == nil {
+		conn, err := w.connect()
+		if err != nil {
+			return 0, err
+		}
+		w.conn = conn
+		w.reconnectMeter.Mark(1)
+	}
+
+	n, err := w.conn.Write(data)
+	if err != nil {
+		w.conn.Close()
+		w.conn = nil
+	}
+	return n, err
+}
+
+// sleep waits for `delay`, it returns `false` if the writer was closed meanwhile.
+func (w *Writer) sleep(delay time.Duration) bool {
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return true
+	case <-w.done:
+		return false
+	}
+}
+
+// Close interrupts any pending write and closes the connection.
+func (w *Writer) Close() error {
+	w.closeOnce.Do(func() { close(w.done) })
+
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	if w.conn == nil {
+		return nil
+	}
+
+	err := w.conn.Close()
+	w.conn = nil
+	return err
+}
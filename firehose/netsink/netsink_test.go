@@ -0,0 +1,91 @@
+package netsink
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+	"time"
+)
+
+type bufferConn struct {
+	*bytes.Buffer
+	failWrites int
+}
+
+func (c *bufferConn) Write(data []byte) (int, error) {
+	if c.failWrites > 0 {
+		c.failWrites--
+		// Partial write, the remaining bytes must be resent on the next connection
+		n, _ := c.Buffer.Write(data[:1])
+		return n, errors.New("broken pipe")
+	}
+	return c.Buffer.Write(data)
+}
+
+func (c *bufferConn) Close() error { return nil }
+
+func TestConfigBackoff(t *testing.T) {
+	config := Config{InitialBackoff: 100 * time.Millisecond, MaxBackoff: time.Second}
+	for failures, want := range []time.Duration{100, 100, 200, 400, 800, 1000, 1000} {
+		if got := config.Backoff(failures); got != want*time.Millisecond {
+			t.Errorf("backoff after %d failures got %s, want %s", failures, got, want*time.Millisecond)
+		}
+	}
+}
+
+func TestWriterRetriesUntilDelivered(t *testing.T) {
+	received := new(bytes.Buffer)
+	connectFailures := 2
+	conn := &bufferConn{Buffer: received, failWrites: 1}
+
+	writer := NewWriter("test-retry", Config{InitialBackoff: time.Millisecond, MaxBackoff: 4 * time.Millisecond, FailureThreshold: 10, Cooldown: time.Millisecond}, func() (io.WriteCloser, error) {
+		if connectFailures > 0 {
+			connectFailures--
+			return nil, errors.New("connection refused")
+		}
+		return conn, nil
+	})
+	defer writer.Close()
+
+	n, err := writer.Write([]byte("FIRE BLOCK\n"))
+	if err != nil || n != len("FIRE BLOCK\n") {
+		t.Fatalf("write got (%d, %v), want (%d, nil)", n, err, len("FIRE BLOCK\n"))
+	}
+	if received.String() != "FIRE BLOCK\n" {
+		t.Fatalf("received %q, want %q", received.String(), "FIRE BLOCK\n")
+	}
+	if state := writer.State(); state != BreakerClosed {
+		t.Fatalf("breaker got %s, want %s", state, BreakerClosed)
+	}
+}
+
+func TestWriterBreakerTrips(t *testing.T) {
+	connects := 0
+	writer := NewWriter("test-breaker", Config{InitialBackoff: time.Millisecond, MaxBackoff: time.Millisecond, FailureThreshold: 3, Cooldown: time.Hour}, func() (io.WriteCloser, error) {
+		connects++
+		return nil, errors.New("connection refused")
+	})
+
+	done := make(chan error)
+	go func() {
+		_, err := writer.Write([]byte("FIRE BLOCK\n"))
+		done <- err
+	}()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for writer.State() != BreakerOpen {
+		if time.Now().After(deadline) {
+			t.Fatal("breaker never opened")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	writer.Close()
+	if err := <-done; err != ErrClosed {
+		t.Fatalf("write got %v, want %v", err, ErrClosed)
+	}
+	if connects != 3 {
+		t.Fatalf("got %d connection attempts, want 3 before the breaker opened", connects)
+	}
+}
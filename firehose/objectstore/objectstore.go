@@ -0,0 +1,250 @@
+// Package objectstore implements a Firehose output sink that bundles blocks together and
+// uploads the bundles as objects to an S3 compatible object storage (AWS S3 or Google Cloud
+// Storage through its S3 interoperability API), bypassing local disk entirely.
+package objectstore
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// DefaultNameFormat is the default object name format, it receives the first and last
+// block number of the bundle.
+const DefaultNameFormat = "%010d-%010d.dmlog"
+
+// Uploader uploads a named object to the object storage.
+type Uploader interface {
+	Upload(name string, data []byte) error
+}
+
+// Config holds the configuration of the object storage sink.
+type Config struct {
+	// URL is the destination of the bundles in the form `s3://<bucket>/<prefix>`.
+	URL string
+
+	// Endpoint overrides the S3 endpoint, use `https://storage.googleapis.com` for Google
+	// Cloud Storage. Empty means the default AWS endpoint.
+	Endpoint string
+
+	// Region of the bucket.
+	Region string
+
+	// BlocksPerBundle is the amount of blocks accumulated before a bundle is uploaded.
+	BlocksPerBundle uint64
+
+	// NameFormat is the `fmt` format of object names, receiving the first and last block
+	// number of the bundle, the prefix of the URL is prepended to it.
+	NameFormat string
+
+	// MaxRetries is the amount of times a failed upload is retried before giving up.
+	MaxRetries int
+}
+
+type s3Uploader struct {
+	client *s3.S3
+	bucket string
+	prefix string
+}
+
+// NewS3Uploader creates an Uploader writing objects to the bucket and prefix of the
+// configured URL.
+func NewS3Uploader(config *Config) (Uploader, error) {
+	destination, err := url.Parse(config.URL)
+	if err != nil {
+		return nil, fmt.Errorf("parse object store url %q: %w", config.URL, err)
+	}
+	if destination.Scheme != "s3" || destination.Host == "" {
+		return nil, fmt.Errorf("object store url %q must be of the form s3://<bucket>/<prefix>", config.URL)
+	}
+
+	awsConfig := &aws.Config{Region: aws.String(config.Region)}
+	if config.Endpoint != "" {
+		awsConfig.Endpoint = aws.String(config.Endpoint)
+		awsConfig.S3ForcePathStyle = aws.Bool(true)
+	}
+
+	session, err := session.NewSession(awsConfig)
+	if err != nil {
+		return nil, fmt.Errorf("create object store session: %w", err)
+	}
+
+	return &s3Uploader{
+		client: s3.New(session),
+		bucket: destination.Host,
+		prefix: strings.Trim(destination.Path, "/"),
+	}, nil
+}
+
+func (u *s3Uploader) Upload(name string, data []byte) error {
+	key := name
+	if u.prefix != "" {
+		key = u.prefix + "/" + name
+	}
+
+	_, err := u.client.PutObject(&s3.PutObjectInput{
+		Bucket: aws.String(u.bucket),
+		Key:    aws.String(key),
+		Body:   bytes.NewReader(data),
+	})
+	return err
+}
+
+// Writer is an `io.Writer` receiving the Firehose output stream, it accumulates the
+// output per block and uploads a bundle every `BlocksPerBundle` blocks.
+type Writer struct {
+	uploader   Uploader
+	config     *Config
+	retryDelay time.Duration
+
+	lock        sync.Mutex
+	partialLine []byte
+	bundle      bytes.Buffer
+	blockCount  uint64
+	firstBlock  uint64
+	lastBlock   uint64
+}
+
+// NewWriter creates a Writer uploading its bundles through `uploader`.
+func NewWriter(uploader Uploader, config *Config) *Writer {
+	return &Writer{
+		uploader:   uploader,
+		config:     config,
+		retryDelay: time.Second,
+	}
+}
+
+// Write accumulates the data, only complete lines are accounted in the bundle, partial
+// lines are kept until they are completed by a subsequent write.
+//
+// The data is always fully consumed. When a bundle fails to be uploaded after all
+// retries, it's kept and extended with the following blocks, its upload being attempted
+// again on the next completed block.
+func (w *Writer) Write(data []byte) (int, error) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	w.partialLine = append(w.partialLine, data...)
+	for {
+		end := bytes.IndexByte(w.partialLine, '\n')
+		if end == -1 {
+			break
+		}
+
+		w.writeLine(w.partialLine[:end+1])
+		w.partialLine = w.partialLine[end+1:]
+	}
+
+	return len(data), nil
+}
+
+func (w *Writer) writeLine(line []byte) {
+	w.bundle.Write(line)
+
+	rawNumber, ok := endBlockNumber(line)
+	if !ok {
+		return
+	}
+
+	number, err := strconv.ParseUint(rawNumber, 10, 64)
+	if err != nil {
+		log.Error("Invalid Firehose END_BLOCK number, block not accounted in bundle", "number", rawNumber, "err", err)
+		return
+	}
+
+	if w.blockCount == 0 {
+		w.firstBlock = number
+	}
+	w.lastBlock = number
+	w.blockCount++
+
+	if w.blockCount >= w.config.BlocksPerBundle {
+		if err := w.flush(); err != nil {
+			log.Error("Failed to upload Firehose bundle, will retry on next block", "err", err)
+		}
+	}
+}
+
+// endBlockNumber returns the raw block number of line if it's an END_BLOCK
+// event, either in the text form or in the NDJSON form (see `firehose.NDJSONOutputFormat`).
+func endBlockNumber(line []byte) (string, bool) {
+	if bytes.HasPrefix(line, []byte(`{"event":"END_BLOCK"`)) {
+		var object struct {
+			Number string `json:"number"`
+		}
+		if err := json.Unmarshal(line, &object); err != nil {
+			return "", false
+		}
+		return object.Number, true
+	}
+
+	if !bytes.HasPrefix(line, []byte("FIRE ")) {
+		return "", false
+	}
+
+	body := line[len("FIRE "):]
+	for bytes.HasPrefix(body, []byte("#")) || bytes.HasPrefix(body, []byte("@")) {
+		// Sequence number and monotonic timestamp of the line, see
+		// `firehose.SequenceNumbersEnabled` and `firehose.MonotonicTimestampsEnabled`
+		i := bytes.IndexByte(body, ' ')
+		if i == -1 {
+			return "", false
+		}
+		body = body[i+1:]
+	}
+
+	if !bytes.HasPrefix(body, []byte("END_BLOCK ")) {
+		return "", false
+	}
+
+	fields := bytes.SplitN(body, []byte(" "), 3)
+	return string(fields[1]), true
+}
+
+// Flush uploads the bundle accumulated so far, even if it's not complete yet.
+func (w *Writer) Flush() error {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	return w.flush()
+}
+
+func (w *Writer) flush() error {
+	if w.blockCount == 0 {
+		return nil
+	}
+
+	name := fmt.Sprintf(w.config.NameFormat, w.firstBlock, w.lastBlock)
+
+	var err error
+	for attempt := 0; attempt <= w.config.MaxRetries; attempt++ {
+		if attempt > 0 {
+			log.Warn("Retrying Firehose bundle upload", "name", name, "attempt", attempt, "err", err)
+			time.Sleep(w.retryDelay * time.Duration(attempt))
+		}
+
+		if err = w.uploader.Upload(name, w.bundle.Bytes()); err == nil {
+			break
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("upload bundle %q: %w", name, err)
+	}
+
+	log.Debug("Uploaded Firehose bundle", "name", name, "blocks", w.blockCount, "size", w.bundle.Len())
+
+	w.bundle.Reset()
+	w.blockCount = 0
+
+	return nil
+}
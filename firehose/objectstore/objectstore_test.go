@@ -0,0 +1,83 @@
+package objectstore
+
+import (
+	"errors"
+	"testing"
+)
+
+type memoryUploader struct {
+	objects  map[string]string
+	failures int
+}
+
+func (u *memoryUploader) Upload(name string, data []byte) error {
+	if u.failures > 0 {
+		u.failures--
+		return errors.New("upload failed")
+	}
+
+	u.objects[name] = string(data)
+	return nil
+}
+
+func block(number string) string {
+	return "FIRE BEGIN_BLOCK " + number + "\nFIRE END_BLOCK " + number + " 10 {}\n"
+}
+
+func TestWriterBundles(t *testing.T) {
+	uploader := &memoryUploader{objects: map[string]string{}}
+	writer := NewWriter(uploader, &Config{BlocksPerBundle: 2, NameFormat: DefaultNameFormat})
+
+	// Write split in the middle of a line to ensure partial lines are kept
+	stream := block("1") + block("2") + block("3")
+	writer.Write([]byte(stream[:10]))
+	writer.Write([]byte(stream[10:]))
+
+	if len(uploader.objects) != 1 {
+		t.Fatalf("expected 1 uploaded object, got %d", len(uploader.objects))
+	}
+	if have, want := uploader.objects["0000000001-0000000002.dmlog"], block("1")+block("2"); have != want {
+		t.Errorf("bundle content mismatch, have %q, want %q", have, want)
+	}
+
+	if err := writer.Flush(); err != nil {
+		t.Fatalf("unexpected flush error: %s", err)
+	}
+	if have, want := uploader.objects["0000000003-0000000003.dmlog"], block("3"); have != want {
+		t.Errorf("flushed bundle content mismatch, have %q, want %q", have, want)
+	}
+}
+
+func TestWriterRetries(t *testing.T) {
+	uploader := &memoryUploader{objects: map[string]string{}, failures: 2}
+	writer := NewWriter(uploader, &Config{BlocksPerBundle: 1, NameFormat: DefaultNameFormat, MaxRetries: 0})
+	writer.retryDelay = 0
+
+	// First two uploads fail, bundle is kept and extended until an upload succeeds
+	writer.Write([]byte(block("1") + block("2") + block("3")))
+
+	if len(uploader.objects) != 1 {
+		t.Fatalf("expected 1 uploaded object, got %d", len(uploader.objects))
+	}
+	if have, want := uploader.objects["0000000001-0000000003.dmlog"], block("1")+block("2")+block("3"); have != want {
+		t.Errorf("bundle content mismatch, have %q, want %q", have, want)
+	}
+}
+
+func TestWriterBundlesNDJSON(t *testing.T) {
+	uploader := &memoryUploader{objects: map[string]string{}}
+	writer := NewWriter(uploader, &Config{BlocksPerBundle: 2, NameFormat: DefaultNameFormat})
+
+	ndjsonBlock := func(number string) string {
+		return `{"event":"BEGIN_BLOCK","number":"` + number + `"}` + "\n" + `{"event":"END_BLOCK","number":"` + number + `","size":"10","meta":{}}` + "\n"
+	}
+
+	writer.Write([]byte(ndjsonBlock("1") + ndjsonBlock("2") + ndjsonBlock("3")))
+
+	if len(uploader.objects) != 1 {
+		t.Fatalf("expected 1 uploaded object, got %d", len(uploader.objects))
+	}
+	if have, want := uploader.objects["0000000001-0000000002.dmlog"], ndjsonBlock("1")+ndjsonBlock("2"); have != want {
+		t.Errorf("bundle content mismatch, have %q, want %q", have, want)
+	}
+}
@@ -1,6 +1,7 @@
 package firehose
 
 import (
+	"bufio"
 	"bytes"
 	"encoding/hex"
 	"encoding/json"
@@ -11,17 +12,97 @@ import (
 	"os"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/metrics"
 )
 
+// spillChunkSizeInBytes is the size of the chunks in which spilled lines are written to and
+// read back from their temporary file.
+const spillChunkSizeInBytes = 1024 * 1024
+
+var spilledTransactionsCounter = metrics.NewRegisteredCounter("firehose/spill/transactions", nil)
+
+// processStart is the origin of the monotonic timestamps, see `MonotonicTimestampsEnabled`.
+var processStart = time.Now()
+
+func monotonicNanos() int64 {
+	return int64(time.Since(processStart))
+}
+
+// formatLine formats the Firehose line of an event, prefixed with its monotonic timestamp
+// when `MonotonicTimestampsEnabled` is set.
+func formatLine(input []string) string {
+	if MonotonicTimestampsEnabled {
+		return linePrefix + timestampPrefix + strconv.FormatInt(monotonicNanos(), 10) + " " + strings.Join(input, " ") + "\n"
+	}
+
+	return linePrefix + strings.Join(input, " ") + "\n"
+}
+
 type Printer interface {
 	Print(input ...string)
 }
 
+// RawPrinter is implemented by printers that are able to output already formatted
+// Firehose lines, like the one accumulated by a `ToBufferPrinter`.
+type RawPrinter interface {
+	PrintRaw(lines string)
+}
+
+// SegmentPrinter is implemented by printers altering the lines they write, like numbering
+// them (see `SequenceNumbersEnabled`). They output a BLOCK_SEGMENT header along with the
+// segment's lines at once, the size closing the header being computed from the lines as
+// written.
+type SegmentPrinter interface {
+	PrintSegment(header []string, lines string)
+}
+
+// printSegment outputs the `header` BLOCK_SEGMENT line, completed by the segment's size,
+// followed by the segment's `lines` through `printer`.
+func printSegment(printer Printer, header []string, lines string) {
+	if v, ok := printer.(SegmentPrinter); ok {
+		v.PrintSegment(header, lines)
+		return
+	}
+
+	printer.Print(append(header, Uint64(uint64(len(lines))))...)
+	printRaw(printer, lines)
+}
+
+// printRaw outputs already formatted lines through `printer` when it supports it, falling
+// back to standard output otherwise.
+func printRaw(printer Printer, lines string) {
+	if v, ok := printer.(RawPrinter); ok {
+		v.PrintRaw(lines)
+		return
+	}
+
+	fmt.Print(lines)
+}
+
+func NewDelegateToWriterPrinter(writer io.Writer) *DelegateToWriterPrinter {
+	return &DelegateToWriterPrinter{writer: writer}
+}
+
 type DelegateToWriterPrinter struct {
 	writer io.Writer
+
+	// lock orders the numbering and the writing of lines, see `SequenceNumbersEnabled`
+	lock     sync.Mutex
+	sequence uint64
+
+	// recoveries is the recovery guard of the context printing through it, the output is
+	// halted once it trips, see `RecoveryLimit`
+	recoveries *recoveryGuard
+	health     *healthTracker
+
+	// fatalPrinted is set once the FATAL event of an halted emission was written
+	fatalPrinted bool
 }
 
 func (p *DelegateToWriterPrinter) Disabled() bool {
@@ -29,7 +110,76 @@ func (p *DelegateToWriterPrinter) Disabled() bool {
 }
 
 func (p *DelegateToWriterPrinter) Print(input ...string) {
-	line := "FIRE " + strings.Join(input, " ") + "\n"
+	p.PrintRaw(formatLine(input))
+}
+
+// PrintRaw writes already formatted Firehose lines as-is to the underlying writer.
+func (p *DelegateToWriterPrinter) PrintRaw(line string) {
+	if reason, halted := p.recoveries.haltReason(); halted {
+		p.printHalted(reason)
+		return
+	}
+
+	if SequenceNumbersEnabled {
+		p.lock.Lock()
+		defer p.lock.Unlock()
+
+		line = p.numberLines(line)
+	}
+
+	p.write(line)
+}
+
+// PrintSegment writes the BLOCK_SEGMENT `header` line followed by the segment's `lines`, the
+// segment's size being the one of the lines once numbered when `SequenceNumbersEnabled` is
+// set.
+func (p *DelegateToWriterPrinter) PrintSegment(header []string, lines string) {
+	if reason, halted := p.recoveries.haltReason(); halted {
+		p.printHalted(reason)
+		return
+	}
+
+	if !SequenceNumbersEnabled {
+		p.write(formatLine(append(header, Uint64(uint64(len(lines))))) + lines)
+		return
+	}
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	// The header's number precedes the ones of the segment's lines, it's reserved while they
+	// are numbered
+	p.sequence++
+	headerSequence := p.sequence
+	lines = p.numberLines(lines)
+
+	var out strings.Builder
+	writeNumberedLine(&out, formatLine(append(header, Uint64(uint64(len(lines))))), headerSequence)
+	out.WriteString(lines)
+
+	p.write(out.String())
+}
+
+// printHalted writes the FATAL event once the emission is halted, any other line being
+// dropped, see `RecoveryLimit`.
+func (p *DelegateToWriterPrinter) printHalted(reason string) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if p.fatalPrinted {
+		return
+	}
+	p.fatalPrinted = true
+
+	line := formatLine([]string{"FATAL", reason})
+	if SequenceNumbersEnabled {
+		line = p.numberLines(line)
+	}
+
+	p.write(line)
+}
+
+func (p *DelegateToWriterPrinter) write(line string) {
 	var written int
 	var err error
 	loops := 10
@@ -49,11 +199,62 @@ func (p *DelegateToWriterPrinter) Print(input ...string) {
 
 	errstr := fmt.Sprintf("\nFIREHOSE FAILED WRITING %dx: %s\n", loops, err)
 	ioutil.WriteFile("/tmp/firehose_writer_failed_print.log", []byte(errstr), 0644)
+	p.health.recordError(strings.TrimSpace(errstr))
 	fmt.Fprint(p.writer, errstr)
 }
 
+// numberLines inserts the sequence number token in each Firehose line of `lines`, other
+// lines (like framed mining lines) are left untouched.
+func (p *DelegateToWriterPrinter) numberLines(lines string) string {
+	var out strings.Builder
+	out.Grow(len(lines) + 16*strings.Count(lines, "\n"))
+
+	for len(lines) > 0 {
+		end := strings.IndexByte(lines, '\n') + 1
+		if end == 0 {
+			end = len(lines)
+		}
+		line := lines[:end]
+		lines = lines[end:]
+
+		if !strings.HasPrefix(line, linePrefix) {
+			out.WriteString(line)
+			continue
+		}
+
+		p.sequence++
+		writeNumberedLine(&out, line, p.sequence)
+	}
+
+	return out.String()
+}
+
+// writeNumberedLine writes the Firehose `line` to `out` with the `sequence` number token
+// inserted after its prefix.
+func writeNumberedLine(out *strings.Builder, line string, sequence uint64) {
+	out.WriteString(linePrefix)
+	out.WriteString(sequencePrefix)
+	out.WriteString(strconv.FormatUint(sequence, 10))
+	out.WriteByte(' ')
+	out.WriteString(line[len(linePrefix):])
+}
+
 type ToBufferPrinter struct {
 	buffer *bytes.Buffer
+
+	limitInBytes int
+	exceeded     bool
+	onExceeded   func()
+
+	// Spill state, the buffered lines are moved to `spillFile` once they exceed
+	// `SpillThresholdInBytes`, `spilledBytes` being the amount of bytes written to it.
+	// `spillErr` is the first error writing to the spill file, the lines printed from
+	// there on are lost.
+	spillFile    *os.File
+	spillWriter  *bufio.Writer
+	spilledBytes int
+	spillFailed  bool
+	spillErr     error
 }
 
 func NewToBufferPrinter(initialAllocationSizeInBytes int) *ToBufferPrinter {
@@ -64,14 +265,186 @@ func NewToBufferPrinter(initialAllocationSizeInBytes int) *ToBufferPrinter {
 
 func (p *ToBufferPrinter) Reset() {
 	p.buffer.Reset()
+	p.removeSpillFile()
+	p.spillFailed, p.spillErr = false, nil
 }
 
 func (p *ToBufferPrinter) Disabled() bool {
 	return false
 }
 
+// NewBoundedToBufferPrinter creates a ToBufferPrinter accumulating at most `limitInBytes`
+// bytes, see `Print` for the behavior once the limit is exceeded.
+func NewBoundedToBufferPrinter(initialAllocationSizeInBytes int, limitInBytes int) *ToBufferPrinter {
+	printer := NewToBufferPrinter(initialAllocationSizeInBytes)
+	printer.limitInBytes = limitInBytes
+
+	return printer
+}
+
+// Print appends the line to the buffer. When the printer is bounded and the line would make
+// the buffer exceed its limit, the buffer is discarded, the `OnExceeded` handler is invoked
+// and every subsequent line is dropped.
 func (p *ToBufferPrinter) Print(input ...string) {
-	p.buffer.WriteString("FIRE " + strings.Join(input, " ") + "\n")
+	if p.exceeded {
+		return
+	}
+
+	line := formatLine(input)
+	if p.limitInBytes > 0 && p.Len()+len(line) > p.limitInBytes {
+		p.exceeded = true
+		p.buffer = &bytes.Buffer{}
+		p.removeSpillFile()
+
+		if p.onExceeded != nil {
+			p.onExceeded()
+		}
+		return
+	}
+
+	if p.spillErr != nil {
+		return
+	}
+
+	if p.spillFile == nil && SpillThresholdInBytes > 0 && !p.spillFailed && p.buffer.Len()+len(line) > SpillThresholdInBytes {
+		if err := p.spill(); err != nil {
+			p.spillErr = err
+			return
+		}
+	}
+
+	if p.spillFile != nil {
+		if _, err := p.spillWriter.WriteString(line); err != nil {
+			p.spillErr = fmt.Errorf("firehose write spill file %s: %w", p.spillFile.Name(), err)
+			return
+		}
+		p.spilledBytes += len(line)
+		return
+	}
+
+	p.buffer.WriteString(line)
+}
+
+// Len returns the amount of bytes accumulated, in memory and spilled.
+func (p *ToBufferPrinter) Len() int {
+	return p.spilledBytes + p.buffer.Len()
+}
+
+// Spilled returns `true` if the accumulated lines were moved to a temporary file.
+func (p *ToBufferPrinter) Spilled() bool {
+	return p.spillFile != nil
+}
+
+// SpillErr returns the error that occurred while writing the accumulated lines to the spill
+// file, if any, in which case lines were lost.
+func (p *ToBufferPrinter) SpillErr() error {
+	return p.spillErr
+}
+
+// Contents returns the accumulated lines, reading them back from the spill file if needed.
+func (p *ToBufferPrinter) Contents() ([]byte, error) {
+	if p.spillFile == nil {
+		return p.buffer.Bytes(), p.spillErr
+	}
+
+	contents := make([]byte, 0, p.Len())
+	err := p.forEachChunk(func(chunk []byte) {
+		contents = append(contents, chunk...)
+	})
+	if err == nil {
+		err = p.spillErr
+	}
+	return contents, err
+}
+
+// forEachChunk calls `fn` with the accumulated lines, in order, in chunks made of complete
+// lines so that spilled lines are never loaded in memory at once. The chunk is only valid
+// for the duration of the call.
+func (p *ToBufferPrinter) forEachChunk(fn func(chunk []byte)) error {
+	if p.spillFile == nil {
+		if p.buffer.Len() > 0 {
+			fn(p.buffer.Bytes())
+		}
+		return nil
+	}
+
+	if err := p.spillWriter.Flush(); err != nil {
+		return fmt.Errorf("firehose flush spill file %s: %w", p.spillFile.Name(), err)
+	}
+	if _, err := p.spillFile.Seek(0, io.SeekStart); err != nil {
+		return fmt.Errorf("firehose rewind spill file %s: %w", p.spillFile.Name(), err)
+	}
+	// Lines keep being appended after a read, the file is always written at its end
+	defer p.spillFile.Seek(0, io.SeekEnd)
+
+	reader := bufio.NewReaderSize(p.spillFile, spillChunkSizeInBytes)
+	chunk := make([]byte, 0, spillChunkSizeInBytes)
+	for {
+		line, err := reader.ReadSlice('\n')
+		if err == bufio.ErrBufferFull {
+			// A line bigger than the reader's buffer, it's completed by the next reads
+			chunk = append(chunk, line...)
+			continue
+		}
+
+		chunk = append(chunk, line...)
+		if len(chunk) >= spillChunkSizeInBytes || (err != nil && len(chunk) > 0) {
+			fn(chunk)
+			chunk = chunk[:0]
+		}
+
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("firehose read spill file %s: %w", p.spillFile.Name(), err)
+		}
+	}
+}
+
+// spill moves the buffered lines to a temporary file, the subsequent lines being appended
+// to it. If the file can't be created, the lines stay in memory.
+func (p *ToBufferPrinter) spill() error {
+	file, err := ioutil.TempFile("", "firehose-spill-")
+	if err != nil {
+		p.spillFailed = true
+		log.Warn("Failed to create Firehose spill file, keeping transaction in memory", "err", err)
+		return nil
+	}
+	// Unlinked right away where supported, the file is then reclaimed even if the printer is
+	// dropped without being reset
+	os.Remove(file.Name())
+
+	p.spillFile, p.spillWriter = file, bufio.NewWriterSize(file, spillChunkSizeInBytes)
+	if _, err := p.spillWriter.Write(p.buffer.Bytes()); err != nil {
+		return fmt.Errorf("firehose write spill file %s: %w", file.Name(), err)
+	}
+	p.spilledBytes = p.buffer.Len()
+
+	// Release the memory, which is the whole point of spilling
+	p.buffer = &bytes.Buffer{}
+	spilledTransactionsCounter.Inc(1)
+	return nil
+}
+
+func (p *ToBufferPrinter) removeSpillFile() {
+	if p.spillFile == nil {
+		return
+	}
+
+	p.spillFile.Close()
+	os.Remove(p.spillFile.Name())
+	p.spillFile, p.spillWriter, p.spilledBytes = nil, nil, 0
+}
+
+// OnExceeded registers the handler invoked once when the buffer limit is exceeded.
+func (p *ToBufferPrinter) OnExceeded(handler func()) {
+	p.onExceeded = handler
+}
+
+// Exceeded returns `true` if the buffer limit has been exceeded.
+func (p *ToBufferPrinter) Exceeded() bool {
+	return p.exceeded
 }
 
 func (p *ToBufferPrinter) Buffer() *bytes.Buffer {
@@ -79,7 +452,17 @@ func (p *ToBufferPrinter) Buffer() *bytes.Buffer {
 }
 
 func Addr(in common.Address) string {
-	return hex.EncodeToString(in[:])
+	return encodeHexString(in[:])
+}
+
+// OptionalAddr prints an address that can be absent, like the recipient of a contract creation,
+// as `.` when `nil`.
+func OptionalAddr(in *common.Address) string {
+	if in == nil {
+		return "."
+	}
+
+	return Addr(*in)
 }
 
 func Bool(in bool) string {
@@ -91,7 +474,7 @@ func Bool(in bool) string {
 }
 
 func Hash(in common.Hash) string {
-	return hex.EncodeToString(in[:])
+	return encodeHexString(in[:])
 }
 
 func Hex(in []byte) string {
@@ -99,7 +482,7 @@ func Hex(in []byte) string {
 		return "."
 	}
 
-	return hex.EncodeToString(in)
+	return encodeHexString(in)
 }
 
 func BigInt(in *big.Int) string {
@@ -131,7 +514,7 @@ func ReportHeaderComparisonResult(actual *types.Header, expected *types.Header)
 	ReportToUser("There is a mismatch between Firehose genesis block and actual chain's stored genesis block, the actual genesis")
 	ReportToUser("block's hash field extracted from Geth's database does not fit with hash of genesis block generated")
 	ReportToUser("from Firehose determined genesis config, you might need to provide the correct 'genesis.json' file")
-	ReportToUser("via --firehose-genesis-file")
+	ReportToUser("via --firehose.genesisfile")
 	ReportToUser("")
 	ReportToUser("Comparison of the actual Firehose recomputed genesis block <> expected Geth genesis block")
 
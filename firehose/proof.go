@@ -0,0 +1,85 @@
+package firehose
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+// ParseStateProofAccounts parses the accounts for which state proofs are emitted, see
+// `StateProofAccounts`. The value is a comma separated list of addresses, each optionally
+// followed by the storage slots to prove, separated by colons, like
+// `0xaddr1,0xaddr2:0xslot1:0xslot2`.
+func ParseStateProofAccounts(value string) (map[common.Address][]common.Hash, error) {
+	accounts := map[common.Address][]common.Hash{}
+	for _, entry := range strings.Split(value, ",") {
+		if entry = strings.TrimSpace(entry); entry == "" {
+			continue
+		}
+
+		parts := strings.Split(entry, ":")
+		if !common.IsHexAddress(parts[0]) {
+			return nil, fmt.Errorf("invalid state proof address %q", parts[0])
+		}
+
+		addr := common.HexToAddress(parts[0])
+		for _, slot := range parts[1:] {
+			key, err := hexutil.Decode(slot)
+			if err != nil || len(key) > common.HashLength {
+				return nil, fmt.Errorf("invalid state proof slot %q of address %s", slot, parts[0])
+			}
+			accounts[addr] = append(accounts[addr], common.BytesToHash(key))
+		}
+
+		if _, found := accounts[addr]; !found {
+			accounts[addr] = nil
+		}
+	}
+
+	return accounts, nil
+}
+
+// RecordAccountProof emits an ACCOUNT_PROOF giving the Merkle proof of the account at `addr`
+// in the state trie whose root is `root`, the proof nodes being the RLP encoded trie nodes
+// from the root to the account's leaf (or the proof of its absence).
+func (ctx *Context) RecordAccountProof(root common.Hash, addr common.Address, proof [][]byte) {
+	if ctx == nil {
+		return
+	}
+	defer ctx.guard()()
+
+	ctx.print("ACCOUNT_PROOF",
+		Uint64(ctx.blockNumber),
+		Hash(root),
+		Addr(addr),
+		JSON(proofNodes(proof)),
+	)
+}
+
+// RecordStorageProof emits a STORAGE_PROOF giving the Merkle proof of the `key` storage slot
+// of the account at `addr`, rooted at the storage root proven by the account's ACCOUNT_PROOF.
+func (ctx *Context) RecordStorageProof(addr common.Address, key, value common.Hash, proof [][]byte) {
+	if ctx == nil {
+		return
+	}
+	defer ctx.guard()()
+
+	ctx.print("STORAGE_PROOF",
+		Uint64(ctx.blockNumber),
+		Addr(addr),
+		Hash(key),
+		Hash(value),
+		JSON(proofNodes(proof)),
+	)
+}
+
+func proofNodes(proof [][]byte) []hexutil.Bytes {
+	nodes := make([]hexutil.Bytes, len(proof))
+	for i, node := range proof {
+		nodes[i] = node
+	}
+
+	return nodes
+}
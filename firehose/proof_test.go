@@ -0,0 +1,33 @@
+package firehose
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestParseStateProofAccounts(t *testing.T) {
+	accounts, err := ParseStateProofAccounts("0x0100000000000000000000000000000000000000, 0x0200000000000000000000000000000000000000:0x01:0x02")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[common.Address][]common.Hash{
+		{1}: nil,
+		{2}: {common.BigToHash(common.Big1), common.BigToHash(common.Big2)},
+	}
+	if !reflect.DeepEqual(accounts, expected) {
+		t.Errorf("unexpected accounts %v", accounts)
+	}
+
+	if accounts, err := ParseStateProofAccounts(""); err != nil || len(accounts) != 0 {
+		t.Errorf("expected no accounts, got %v (%v)", accounts, err)
+	}
+
+	for _, invalid := range []string{"0x01", "0x0100000000000000000000000000000000000000:zz"} {
+		if _, err := ParseStateProofAccounts(invalid); err == nil {
+			t.Errorf("expected %q to be invalid", invalid)
+		}
+	}
+}
@@ -0,0 +1,132 @@
+package firehose
+
+import (
+	"io"
+)
+
+// ContextProvider resolves the Firehose contexts of a chain instance. A process running a
+// single chain uses the `DefaultContextProvider`, backed by the package level sync context
+// and flags, while an embedder running several chains gives each of them its own provider
+// (see `NewContextProvider`) so that their streams never mix.
+type ContextProvider interface {
+	// Enabled returns `true` if Firehose instrumentation is enabled for the chain.
+	Enabled() bool
+
+	// GenesisConfig returns the genesis of the chain, see the package level `GenesisConfig`.
+	GenesisConfig() interface{}
+
+	// SyncContext returns the chain's sync context without checking if instrumentation is
+	// enabled, see the package level `SyncContext`.
+	SyncContext() *Context
+
+	// MaybeSyncContextForBlock returns the chain's sync context if block `number` must be
+	// fully instrumented, `NoOpContext` otherwise.
+	MaybeSyncContextForBlock(number uint64) *Context
+
+	// BlockProgressEnabledForBlock returns `true` if the block progress line must be emitted
+	// for block `number` when it's not fully instrumented.
+	BlockProgressEnabledForBlock(number uint64) bool
+
+	// Health returns a snapshot of the current health of the chain's pipeline.
+	Health() Health
+}
+
+// DefaultContextProvider is the provider backed by the package level sync context, output
+// and flags.
+var DefaultContextProvider ContextProvider = globalContextProvider{}
+
+type globalContextProvider struct{}
+
+func (globalContextProvider) Enabled() bool              { return Enabled }
+func (globalContextProvider) GenesisConfig() interface{} { return GenesisConfig }
+func (globalContextProvider) SyncContext() *Context      { return SyncContext() }
+
+func (globalContextProvider) MaybeSyncContextForBlock(number uint64) *Context {
+	return MaybeSyncContextForBlock(number)
+}
+
+func (globalContextProvider) BlockProgressEnabledForBlock(number uint64) bool {
+	return BlockProgressEnabledForBlock(number)
+}
+
+func (globalContextProvider) Health() Health {
+	return CurrentHealth()
+}
+
+// ProviderConfig holds the per chain instance settings of a provider created through
+// `NewContextProvider`. The instrumentation features (calls, compact code changes, strict
+// mode, ...) remain process wide and are controlled by the package level flags.
+type ProviderConfig struct {
+	// Enabled turns on full instrumentation of the chain's blocks.
+	Enabled bool
+
+	// BlockProgress emits the block progress lines for blocks not fully instrumented.
+	BlockProgress bool
+
+	// StartBlockNumber is the first block fully instrumented, see `StartBlockNumber`.
+	StartBlockNumber uint64
+
+	// GenesisConfig is the genesis of the chain, required when instrumentation is enabled
+	// and the chain starts from its genesis block.
+	GenesisConfig interface{}
+
+	// ChainHeadProvider and ImportedHeadProvider resolve the network and local heads of the
+	// chain for its health, see `SetChainHeadProvider` and `SetImportedHeadProvider`.
+	ChainHeadProvider    func() uint64
+	ImportedHeadProvider func() uint64
+
+	// ReferenceEndpoint activates the differential validation of the chain's blocks against
+	// the reference node reachable at this endpoint, see `EnableDifferentialValidation`.
+	ReferenceEndpoint string
+}
+
+// NewContextProvider creates a provider owning its own sync context writing to `writer`,
+// isolated from the package level sync context and from any other provider: its recovery
+// guard, health, differential validation and mempool replacements are its own.
+func NewContextProvider(writer io.Writer, config ProviderConfig) (ContextProvider, error) {
+	syncContext := NewContext(NewDelegateToWriterPrinter(writer))
+	syncContext.health.chainHeadProvider = config.ChainHeadProvider
+	syncContext.health.importedHeadProvider = config.ImportedHeadProvider
+
+	if config.ReferenceEndpoint != "" {
+		validator, err := newDifferentialValidator(config.ReferenceEndpoint)
+		if err != nil {
+			return nil, err
+		}
+		syncContext.differential = validator
+	}
+
+	return &instanceContextProvider{
+		config:      config,
+		syncContext: syncContext,
+	}, nil
+}
+
+type instanceContextProvider struct {
+	config      ProviderConfig
+	syncContext *Context
+}
+
+func (p *instanceContextProvider) Enabled() bool              { return p.config.Enabled }
+func (p *instanceContextProvider) GenesisConfig() interface{} { return p.config.GenesisConfig }
+func (p *instanceContextProvider) SyncContext() *Context      { return p.syncContext }
+
+func (p *instanceContextProvider) MaybeSyncContextForBlock(number uint64) *Context {
+	if !p.config.Enabled || number < p.config.StartBlockNumber {
+		return NoOpContext
+	}
+
+	return p.syncContext
+}
+
+func (p *instanceContextProvider) BlockProgressEnabledForBlock(number uint64) bool {
+	if p.config.BlockProgress {
+		return true
+	}
+
+	return p.config.Enabled && number < p.config.StartBlockNumber
+}
+
+func (p *instanceContextProvider) Health() Health {
+	return p.syncContext.Health()
+}
@@ -0,0 +1,119 @@
+package firehose
+
+import "sort"
+
+// ChangeReasonsVersion is the version of the registry of valid `BalanceChangeReason` and
+// `GasChangeReason` values below. It must be bumped each time a reason is added, renamed
+// or removed, readers receive it along the valid reasons in the INIT_REASONS event.
+const ChangeReasonsVersion = 1
+
+// Valid `BalanceChangeReason` values, all balance changes must use one of them.
+const (
+	RewardMineUncleBalanceChangeReason      = BalanceChangeReason("reward_mine_uncle")
+	RewardMineBlockBalanceChangeReason      = BalanceChangeReason("reward_mine_block")
+	RewardTransactionFeeBalanceChangeReason = BalanceChangeReason("reward_transaction_fee")
+	DAORefundContractBalanceChangeReason    = BalanceChangeReason("dao_refund_contract")
+	DAOAdjustBalanceChangeReason            = BalanceChangeReason("dao_adjust_balance")
+	GenesisBalanceChangeReason              = BalanceChangeReason("genesis_balance")
+	TransferBalanceChangeReason             = BalanceChangeReason("transfer")
+	GasBuyBalanceChangeReason               = BalanceChangeReason("gas_buy")
+	GasRefundBalanceChangeReason            = BalanceChangeReason("gas_refund")
+	SuicideRefundBalanceChangeReason        = BalanceChangeReason("suicide_refund")
+	SuicideWithdrawBalanceChangeReason      = BalanceChangeReason("suicide_withdraw")
+)
+
+// IgnoredBalanceChangeReason **On purposely defined using a different syntax, check `BalanceChangeReason` type doc**
+var IgnoredBalanceChangeReason BalanceChangeReason = "ignored"
+
+// Valid `GasChangeReason` values, all recorded gas changes must use one of them.
+const (
+	IntrinsicGasChangeReason            = GasChangeReason("intrinsic_gas")
+	CodeStorageGasChangeReason          = GasChangeReason("code_storage")
+	ContractCreationGasChangeReason     = GasChangeReason("contract_creation")
+	ContractCreation2GasChangeReason    = GasChangeReason("contract_creation2")
+	PrecompiledContractGasChangeReason  = GasChangeReason("precompiled_contract")
+	CallGasChangeReason                 = GasChangeReason("call")
+	StaticCallGasChangeReason           = GasChangeReason("static_call")
+	CallCodeGasChangeReason             = GasChangeReason("call_code")
+	DelegateCallGasChangeReason         = GasChangeReason("delegate_call")
+	ReturnGasChangeReason               = GasChangeReason("return")
+	RevertGasChangeReason               = GasChangeReason("revert")
+	EventLogGasChangeReason             = GasChangeReason("event_log")
+	SelfDestructGasChangeReason         = GasChangeReason("self_destruct")
+	CallDataCopyGasChangeReason         = GasChangeReason("call_data_copy")
+	CodeCopyGasChangeReason             = GasChangeReason("code_copy")
+	ExtCodeCopyGasChangeReason          = GasChangeReason("ext_code_copy")
+	ReturnDataCopyGasChangeReason       = GasChangeReason("return_data_copy")
+	RefundAfterExecutionGasChangeReason = GasChangeReason("refund_after_execution")
+	FailedExecutionGasChangeReason      = GasChangeReason("failed_execution")
+)
+
+// IgnoredGasChangeReason **On purposely defined using a different syntax, check `GasChangeReason` type doc**
+var IgnoredGasChangeReason GasChangeReason = "ignored"
+
+var balanceChangeReasons = map[BalanceChangeReason]bool{
+	RewardMineUncleBalanceChangeReason:      true,
+	RewardMineBlockBalanceChangeReason:      true,
+	RewardTransactionFeeBalanceChangeReason: true,
+	DAORefundContractBalanceChangeReason:    true,
+	DAOAdjustBalanceChangeReason:            true,
+	GenesisBalanceChangeReason:              true,
+	TransferBalanceChangeReason:             true,
+	GasBuyBalanceChangeReason:               true,
+	GasRefundBalanceChangeReason:            true,
+	SuicideRefundBalanceChangeReason:        true,
+	SuicideWithdrawBalanceChangeReason:      true,
+}
+
+var gasChangeReasons = map[GasChangeReason]bool{
+	IntrinsicGasChangeReason:            true,
+	CodeStorageGasChangeReason:          true,
+	ContractCreationGasChangeReason:     true,
+	ContractCreation2GasChangeReason:    true,
+	PrecompiledContractGasChangeReason:  true,
+	CallGasChangeReason:                 true,
+	StaticCallGasChangeReason:           true,
+	CallCodeGasChangeReason:             true,
+	DelegateCallGasChangeReason:         true,
+	ReturnGasChangeReason:               true,
+	RevertGasChangeReason:               true,
+	EventLogGasChangeReason:             true,
+	SelfDestructGasChangeReason:         true,
+	CallDataCopyGasChangeReason:         true,
+	CodeCopyGasChangeReason:             true,
+	ExtCodeCopyGasChangeReason:          true,
+	ReturnDataCopyGasChangeReason:       true,
+	RefundAfterExecutionGasChangeReason: true,
+	FailedExecutionGasChangeReason:      true,
+}
+
+// Valid returns `true` if the reason is part of the registry of valid balance change reasons.
+func (r BalanceChangeReason) Valid() bool {
+	return balanceChangeReasons[r]
+}
+
+// Valid returns `true` if the reason is part of the registry of valid gas change reasons.
+func (r GasChangeReason) Valid() bool {
+	return gasChangeReasons[r]
+}
+
+// changeReasonsManifest returns all valid balance and gas change reasons, used to inform
+// the reader about the reasons it can expect from this node.
+func changeReasonsManifest() map[string][]string {
+	manifest := map[string][]string{
+		"balance": make([]string, 0, len(balanceChangeReasons)),
+		"gas":     make([]string, 0, len(gasChangeReasons)),
+	}
+
+	for reason := range balanceChangeReasons {
+		manifest["balance"] = append(manifest["balance"], string(reason))
+	}
+	for reason := range gasChangeReasons {
+		manifest["gas"] = append(manifest["gas"], string(reason))
+	}
+
+	sort.Strings(manifest["balance"])
+	sort.Strings(manifest["gas"])
+
+	return manifest
+}
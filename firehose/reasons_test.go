@@ -0,0 +1,78 @@
+package firehose
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+var (
+	reasonValueRegexp    = regexp.MustCompile(`^[a-z0-9_]+$`)
+	reasonCallSiteRegexp = regexp.MustCompile(`(Balance|Gas)ChangeReason\("([^"]*)"\)`)
+)
+
+func TestChangeReasonsRegistry(t *testing.T) {
+	for reason := range balanceChangeReasons {
+		if !reasonValueRegexp.MatchString(string(reason)) {
+			t.Errorf("balance change reason %q does not match the extraction regex", reason)
+		}
+	}
+
+	for reason := range gasChangeReasons {
+		if !reasonValueRegexp.MatchString(string(reason)) {
+			t.Errorf("gas change reason %q does not match the extraction regex", reason)
+		}
+	}
+
+	if IgnoredBalanceChangeReason.Valid() || IgnoredGasChangeReason.Valid() {
+		t.Errorf("ignored reasons must not be part of the registry")
+	}
+}
+
+// TestChangeReasonsCallSites enumerates all the reasons defined through the type wrapper
+// across the repository and ensures they are all registered.
+func TestChangeReasonsCallSites(t *testing.T) {
+	err := filepath.Walk("..", func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if info.IsDir() {
+			if name := info.Name(); name == "build" || name == "node_modules" || (strings.HasPrefix(name, ".") && name != "..") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if !strings.HasSuffix(path, ".go") || strings.HasSuffix(path, "_test.go") {
+			return nil
+		}
+
+		content, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+
+		for _, match := range reasonCallSiteRegexp.FindAllStringSubmatch(string(content), -1) {
+			valid := false
+			switch match[1] {
+			case "Balance":
+				valid = BalanceChangeReason(match[2]).Valid()
+			case "Gas":
+				valid = GasChangeReason(match[2]).Valid()
+			}
+
+			if !valid {
+				t.Errorf("%s: %s is not registered in reasons.go", path, match[0])
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("walk repository: %s", err)
+	}
+}
@@ -0,0 +1,124 @@
+package firehose
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/metrics"
+	"go.uber.org/atomic"
+)
+
+var invariantHaltsCounter = metrics.NewRegisteredCounter("firehose/invariant/halts", nil)
+
+// recoveryGuard tracks the invariant violations recovered from in non-strict mode by the
+// contexts emitting to a stream, see `RecoveryLimit`. Each context owns one, attached to its
+// printer so that only its own stream is halted.
+type recoveryGuard struct {
+	halted *atomic.Bool
+
+	lock sync.Mutex
+	// blocks is the amount of blocks ended so far, violations are dated with it
+	blocks     uint64
+	violations []uint64
+	reason     string
+}
+
+func newRecoveryGuard() *recoveryGuard {
+	return &recoveryGuard{halted: atomic.NewBool(false)}
+}
+
+func (g *recoveryGuard) recordBlock() {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	g.blocks++
+	g.prune()
+}
+
+// recordViolation accounts a recovered invariant violation and returns true if it's the one
+// making the guard trip, the emission being halted from then on.
+func (g *recoveryGuard) recordViolation(message string) bool {
+	if RecoveryLimit <= 0 || g.halted.Load() {
+		return false
+	}
+
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	g.violations = append(g.violations, g.blocks)
+	g.prune()
+
+	if len(g.violations) <= RecoveryLimit {
+		return false
+	}
+
+	g.reason = fmt.Sprintf("%d invariant violations recovered within %d blocks, halting emission, last one: %s", len(g.violations), RecoveryWindowInBlocks, message)
+	g.halted.Store(true)
+	invariantHaltsCounter.Inc(1)
+	log.Error("Firehose emission halted", "violations", len(g.violations), "window", RecoveryWindowInBlocks, "last", message)
+
+	return true
+}
+
+// prune drops the violations that happened before the window, the window including the
+// current block.
+func (g *recoveryGuard) prune() {
+	if RecoveryWindowInBlocks == 0 {
+		return
+	}
+
+	i := 0
+	for i < len(g.violations) && g.blocks-g.violations[i] >= RecoveryWindowInBlocks {
+		i++
+	}
+	g.violations = g.violations[i:]
+}
+
+// haltReason returns the reason for which the emission was halted, if it was.
+func (g *recoveryGuard) haltReason() (string, bool) {
+	if g == nil || !g.halted.Load() {
+		return "", false
+	}
+
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	return g.reason, true
+}
+
+// attachPrinter makes the writer printer behind `printer`, if any, report to the health of
+// `ctx` and stop its output once the recovery guard of `ctx` halts the emission.
+func attachPrinter(printer Printer, ctx *Context) {
+	switch v := printer.(type) {
+	case *DelegateToWriterPrinter:
+		v.recoveries, v.health = ctx.recoveries, ctx.health
+	case *framingPrinter:
+		v.delegate.recoveries, v.delegate.health = ctx.recoveries, ctx.health
+	case *subscriptionPrinter:
+		attachPrinter(v.delegate, ctx)
+	}
+}
+
+// recordRecovery accounts a recovered invariant violation, emitting the FATAL event if it's
+// the one halting the context's emission.
+func (ctx *Context) recordRecovery(message string) {
+	ctx.health.recordError(message)
+
+	if ctx.recoveries.recordViolation(message) {
+		reason, _ := ctx.recoveries.haltReason()
+		ctx.print("FATAL", reason)
+	}
+}
+
+// EmissionHalted returns true if the context's emission was halted because too many
+// invariant violations were recovered from, see `RecoveryLimit`.
+func (ctx *Context) EmissionHalted() bool {
+	return ctx.recoveries.halted.Load()
+}
+
+// EmissionHalted returns true if the sync context's emission was halted, see
+// `Context.EmissionHalted`.
+func EmissionHalted() bool {
+	return syncContext.EmissionHalted()
+}
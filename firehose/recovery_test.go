@@ -0,0 +1,113 @@
+package firehose
+
+import (
+	"bytes"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+func TestRecoveryLimit(t *testing.T) {
+	defer func(strict bool, limit int, window uint64) {
+		StrictEnabled, RecoveryLimit, RecoveryWindowInBlocks = strict, limit, window
+	}(StrictEnabled, RecoveryLimit, RecoveryWindowInBlocks)
+
+	StrictEnabled = false
+	RecoveryLimit = 2
+	RecoveryWindowInBlocks = 2
+
+	output := &bytes.Buffer{}
+	ctx := NewContext(NewDelegateToWriterPrinter(output))
+
+	runBlock := func(number int64, violations int) {
+		block := types.NewBlockWithHeader(&types.Header{Number: big.NewInt(number)})
+		ctx.StartBlock(block)
+		for i := 0; i < violations; i++ {
+			ctx.RecordTrxFrom(common.Address{}, nil)
+		}
+		ctx.FinalizeBlock(block)
+		ctx.EndBlock(block, nil)
+	}
+
+	// Violations of block 1 are out of the window once block 3 starts
+	runBlock(1, 2)
+	runBlock(2, 0)
+	runBlock(3, 2)
+	if ctx.EmissionHalted() {
+		t.Fatalf("expected emission to continue while within the recovery limit")
+	}
+
+	output.Reset()
+	runBlock(4, 1)
+	if !ctx.EmissionHalted() {
+		t.Fatalf("expected emission to be halted once the recovery limit is exceeded")
+	}
+
+	var events []string
+	for _, line := range strings.Split(strings.TrimSpace(output.String()), "\n") {
+		event, _, _ := splitLine(line)
+		events = append(events, event)
+	}
+
+	expected := []string{"BEGIN_BLOCK", "ERROR", "FATAL"}
+	if strings.Join(events, "\n") != strings.Join(expected, "\n") {
+		t.Fatalf("unexpected halted emission events, have:\n%s\nwant:\n%s", strings.Join(events, "\n"), strings.Join(expected, "\n"))
+	}
+
+	report, err := Check(strings.NewReader(output.String()))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if report.Valid() {
+		t.Errorf("expected FATAL event to be reported as a violation")
+	}
+
+	// The halted stream writes the FATAL event once and then stays silent
+	output.Reset()
+	ctx.printer.Print("BEGIN_BLOCK", "5")
+	if output.Len() != 0 {
+		t.Errorf("unexpected halted stream output %q", output.String())
+	}
+
+	// Streams of the other contexts are left untouched
+	other := &bytes.Buffer{}
+	otherContext := NewContext(NewDelegateToWriterPrinter(other))
+	otherContext.printer.Print("BEGIN_BLOCK", "5")
+	if other.String() != "FIRE BEGIN_BLOCK 5\n" || otherContext.EmissionHalted() {
+		t.Errorf("unexpected other stream output %q", other.String())
+	}
+}
+
+func TestRecoveryLimitSpeculativeViolations(t *testing.T) {
+	defer func(strict bool, limit int, window uint64) {
+		StrictEnabled, RecoveryLimit, RecoveryWindowInBlocks = strict, limit, window
+	}(StrictEnabled, RecoveryLimit, RecoveryWindowInBlocks)
+
+	StrictEnabled = false
+	RecoveryLimit = 1
+	RecoveryWindowInBlocks = 0
+
+	output := &bytes.Buffer{}
+	ctx := NewContext(NewDelegateToWriterPrinter(output))
+	txContext := NewSpeculativeExecutionContext(1024)
+
+	block := types.NewBlockWithHeader(&types.Header{Number: big.NewInt(1)})
+	ctx.StartBlock(block)
+	txContext.EndTransaction(&types.Receipt{})
+	txContext.EndTransaction(&types.Receipt{})
+	if ctx.EmissionHalted() || txContext.EmissionHalted() {
+		t.Fatalf("expected violations to be accounted once the transaction is flushed")
+	}
+
+	ctx.FlushTransaction(txContext)
+	if !ctx.EmissionHalted() {
+		t.Fatalf("expected flushed violations to halt the emission")
+	}
+
+	if lines := strings.Split(strings.TrimSpace(output.String()), "\n"); len(lines) != 4 || !strings.HasPrefix(lines[3], "FIRE FATAL ") {
+		t.Errorf("unexpected halted emission output %q", output.String())
+	}
+}
@@ -0,0 +1,107 @@
+package firehose
+
+import (
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// trxReplacementsLimit bounds the amount of sender and nonce pairs tracked, the oldest pair
+// being forgotten once it's reached.
+const trxReplacementsLimit = 16384
+
+type trxReplacementKey struct {
+	from  common.Address
+	nonce uint64
+}
+
+// trxReplacements tracks, per sender and nonce, the hashes of the mempool transactions that
+// took part in a replacement. The pairs are kept after the inclusion of one of them so that
+// the linkage is emitted again if the inclusion is reorganized into another block.
+type trxReplacements struct {
+	lock   sync.Mutex
+	limit  int
+	hashes map[trxReplacementKey][]common.Hash
+	order  []trxReplacementKey
+}
+
+func newTrxReplacements(limit int) *trxReplacements {
+	return &trxReplacements{limit: limit, hashes: map[trxReplacementKey][]common.Hash{}}
+}
+
+func (r *trxReplacements) add(key trxReplacementKey, hashes ...common.Hash) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	known, found := r.hashes[key]
+	if !found {
+		if len(r.order) >= r.limit {
+			delete(r.hashes, r.order[0])
+			r.order = r.order[1:]
+		}
+		r.order = append(r.order, key)
+	}
+
+	for _, hash := range hashes {
+		if !containsHash(known, hash) {
+			known = append(known, hash)
+		}
+	}
+	r.hashes[key] = known
+}
+
+func (r *trxReplacements) get(key trxReplacementKey) []common.Hash {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	return r.hashes[key]
+}
+
+func containsHash(hashes []common.Hash, hash common.Hash) bool {
+	for _, candidate := range hashes {
+		if candidate == hash {
+			return true
+		}
+	}
+	return false
+}
+
+// RecordTrxPoolReplaced records that the mempool transaction `old` of `from` was replaced by
+// `tx`, both having been streamed through TRX_ENTER_POOL. Nothing is emitted, the linkage is
+// emitted by `RecordTrxReplacements` once one of the transactions is included in a block.
+func (ctx *Context) RecordTrxPoolReplaced(from common.Address, old *types.Transaction, tx *types.Transaction) {
+	if ctx == nil {
+		return
+	}
+	defer ctx.guard()()
+
+	ctx.replacements.add(trxReplacementKey{from, tx.Nonce()}, old.Hash(), tx.Hash())
+}
+
+// RecordTrxReplacements emits a TRX_REPLACED event linking each mempool transaction of `from`
+// dropped in favor of `tx`, the included transaction, through a replacement (same sender and
+// nonce), so that pending transaction streams can reconcile the transactions they saw.
+func (ctx *Context) RecordTrxReplacements(from common.Address, tx *types.Transaction) {
+	if ctx == nil {
+		return
+	}
+	defer ctx.guard()()
+
+	if !ctx.inTransaction.Load() {
+		ctx.invariantViolated("the RecordTrxReplacements should have been call within a transaction, something is deeply wrong")
+		return
+	}
+
+	hash := tx.Hash()
+	for _, replaced := range ctx.replacements.get(trxReplacementKey{from, tx.Nonce()}) {
+		if replaced == hash {
+			continue
+		}
+
+		ctx.print("TRX_REPLACED",
+			Hash(replaced),
+			Hash(hash),
+		)
+	}
+}
@@ -0,0 +1,54 @@
+package firehose
+
+import (
+	"bytes"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+func TestRecordTrxReplacements(t *testing.T) {
+	from := common.Address{1}
+	first := types.NewTransaction(3, common.Address{2}, big.NewInt(1), 21000, big.NewInt(1), nil)
+	second := types.NewTransaction(3, common.Address{2}, big.NewInt(1), 21000, big.NewInt(2), nil)
+	third := types.NewTransaction(3, common.Address{2}, big.NewInt(1), 21000, big.NewInt(3), nil)
+
+	poolContext := NewContext(NewDelegateToWriterPrinter(&bytes.Buffer{}))
+	poolContext.replacements = newTrxReplacements(2)
+	poolContext.RecordTrxPoolReplaced(from, first, second)
+	poolContext.RecordTrxPoolReplaced(from, second, third)
+
+	// The second transaction is included, the first and third ones were dropped in its favor
+	printer := NewToBufferPrinter(1024)
+	ctx := poolContext.NewTransactionContext(1024)
+	ctx.setPrinter(printer)
+	ctx.inTransaction.Store(true)
+	ctx.RecordTrxReplacements(from, second)
+
+	expected := "FIRE TRX_REPLACED " + Hash(first.Hash()) + " " + Hash(second.Hash()) + "\n" +
+		"FIRE TRX_REPLACED " + Hash(third.Hash()) + " " + Hash(second.Hash()) + "\n"
+	if printer.Buffer().String() != expected {
+		t.Fatalf("expected %q, got %q", expected, printer.Buffer().String())
+	}
+
+	// Contexts of other chain instances don't know the pool's replacements
+	other := NewSpeculativeExecutionContext(1024)
+	other.inTransaction.Store(true)
+	other.RecordTrxReplacements(from, second)
+	if contents, _ := other.printer.(*ToBufferPrinter).Contents(); len(contents) != 0 {
+		t.Errorf("unexpected output %q", contents)
+	}
+
+	// Other senders or nonces are not linked, the oldest pair is forgotten past the limit
+	printer.Reset()
+	ctx.RecordTrxReplacements(common.Address{9}, second)
+	poolContext.replacements.add(trxReplacementKey{from, 4}, common.Hash{4})
+	poolContext.replacements.add(trxReplacementKey{from, 5}, common.Hash{5})
+	ctx.RecordTrxReplacements(from, second)
+	if strings.TrimSpace(printer.Buffer().String()) != "" {
+		t.Errorf("unexpected output %q", printer.Buffer().String())
+	}
+}
@@ -0,0 +1,94 @@
+package firehose
+
+import (
+	"bytes"
+	"fmt"
+	"math/big"
+)
+
+var (
+	// revertErrorSelector is the 4 bytes selector of Solidity `Error(string)` revert data
+	revertErrorSelector = []byte{0x08, 0xc3, 0x79, 0xa0}
+
+	// revertPanicSelector is the 4 bytes selector of Solidity `Panic(uint256)` revert data
+	revertPanicSelector = []byte{0x4e, 0x48, 0x7b, 0x71}
+)
+
+// panicCodeDescriptions maps Solidity well-known panic codes to their human readable description,
+// see https://docs.soliditylang.org/en/latest/control-structures.html#panic-via-assert-and-error-via-require
+var panicCodeDescriptions = map[uint64]string{
+	0x00: "generic compiler inserted panic",
+	0x01: "assert(false)",
+	0x11: "arithmetic underflow or overflow",
+	0x12: "division or modulo by zero",
+	0x21: "enum overflow",
+	0x22: "invalid encoded storage byte array accessed",
+	0x31: "out-of-bounds array access; popping on an empty array",
+	0x32: "out-of-bounds access of an array or bytesN",
+	0x41: "out of memory",
+	0x51: "uninitialized function",
+}
+
+// decodeRevertReason decodes the ABI encoded `Error(string)` or `Panic(uint256)` revert data
+// returning the selector that matched as well as the human readable reason. When the data
+// cannot be decoded, `ok` is `false`.
+func decodeRevertReason(data []byte) (selector []byte, reason string, ok bool) {
+	if len(data) < 4 {
+		return nil, "", false
+	}
+
+	selector, payload := data[:4], data[4:]
+
+	switch {
+	case bytes.Equal(selector, revertErrorSelector):
+		// Layout is offset (32 bytes), then at offset, length (32 bytes) followed by the string bytes
+		offset, valid := readWordAsInt(payload, 0)
+		if !valid {
+			return nil, "", false
+		}
+
+		length, valid := readWordAsInt(payload, offset)
+		if !valid {
+			return nil, "", false
+		}
+
+		// The length word being read, `offset + 32` is within the payload, the length is
+		// compared against what remains without any addition that could wrap around
+		if length > uint64(len(payload))-offset-32 {
+			return nil, "", false
+		}
+
+		return selector, string(payload[offset+32 : offset+32+length]), true
+
+	case bytes.Equal(selector, revertPanicSelector):
+		if len(payload) < 32 {
+			return nil, "", false
+		}
+
+		code := new(big.Int).SetBytes(payload[:32])
+		if code.IsUint64() {
+			if description, found := panicCodeDescriptions[code.Uint64()]; found {
+				return selector, fmt.Sprintf("panic code 0x%x (%s)", code, description), true
+			}
+		}
+
+		return selector, fmt.Sprintf("panic code 0x%x", code), true
+	}
+
+	return nil, "", false
+}
+
+// readWordAsInt reads the 32 bytes word found at `offset` in `data` as an unsigned integer,
+// returns `false` if the word is out of bounds or does not fit in an `uint64`.
+func readWordAsInt(data []byte, offset uint64) (uint64, bool) {
+	if offset > uint64(len(data)) || uint64(len(data))-offset < 32 {
+		return 0, false
+	}
+
+	value := new(big.Int).SetBytes(data[offset : offset+32])
+	if !value.IsUint64() {
+		return 0, false
+	}
+
+	return value.Uint64(), true
+}
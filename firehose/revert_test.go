@@ -0,0 +1,68 @@
+package firehose
+
+import (
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestDecodeRevertReason(t *testing.T) {
+	tests := []struct {
+		name         string
+		data         string
+		wantSelector string
+		wantReason   string
+		wantOk       bool
+	}{
+		{
+			name:         "error string",
+			data:         "0x08c379a0" + "0000000000000000000000000000000000000000000000000000000000000020" + "000000000000000000000000000000000000000000000000000000000000000e" + "6e6f7420656e6f7567682065746800000000000000000000000000000000000000",
+			wantSelector: "08c379a0",
+			wantReason:   "not enough eth",
+			wantOk:       true,
+		},
+		{
+			name:         "panic known code",
+			data:         "0x4e487b71" + "0000000000000000000000000000000000000000000000000000000000000011",
+			wantSelector: "4e487b71",
+			wantReason:   "panic code 0x11 (arithmetic underflow or overflow)",
+			wantOk:       true,
+		},
+		{
+			name:         "panic unknown code",
+			data:         "0x4e487b71" + "00000000000000000000000000000000000000000000000000000000000000ff",
+			wantSelector: "4e487b71",
+			wantReason:   "panic code 0xff",
+			wantOk:       true,
+		},
+		{
+			name:   "error string truncated",
+			data:   "0x08c379a0" + "0000000000000000000000000000000000000000000000000000000000000020" + "00000000000000000000000000000000000000000000000000000000000000ff",
+			wantOk: false,
+		},
+		{
+			name:   "error string length overflowing",
+			data:   "0x08c379a0" + "0000000000000000000000000000000000000000000000000000000000000020" + "000000000000000000000000000000000000000000000000ffffffffffffffe0",
+			wantOk: false,
+		},
+		{name: "custom error", data: "0xdeadbeef", wantOk: false},
+		{name: "empty", data: "0x", wantOk: false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			selector, reason, ok := decodeRevertReason(common.FromHex(test.data))
+			if ok != test.wantOk {
+				t.Fatalf("ok mismatch, have %t, want %t", ok, test.wantOk)
+			}
+
+			if Hex(selector) != test.wantSelector && test.wantOk {
+				t.Errorf("selector mismatch, have %s, want %s", Hex(selector), test.wantSelector)
+			}
+
+			if reason != test.wantReason {
+				t.Errorf("reason mismatch, have %q, want %q", reason, test.wantReason)
+			}
+		})
+	}
+}
@@ -0,0 +1,167 @@
+package firehose
+
+import (
+	"encoding/hex"
+	"strings"
+)
+
+// linePrefix is the prefix of every Firehose line printed on the output
+const linePrefix = "FIRE "
+
+// timestampPrefix starts the optional monotonic timestamp token following `linePrefix`, see
+// `MonotonicTimestampsEnabled`.
+const timestampPrefix = "@"
+
+// sequencePrefix starts the optional sequence number token following `linePrefix`, it comes
+// before the monotonic timestamp token, see `SequenceNumbersEnabled`.
+const sequencePrefix = "#"
+
+// eventSchema describes the layout of the fields (excluding the event name) of a given
+// Firehose event line as printed by the `Context`.
+type eventSchema struct {
+	// fieldCount is the exact amount of fields expected, or the minimum amount of fields
+	// when `freeFormTail` is set.
+	fieldCount int
+
+	// freeFormTail is set when the last field is free-form and can contain spaces.
+	freeFormTail bool
+
+	// optionalFieldCount is the amount of trailing fields that are only printed when an
+	// opt-in feature is enabled, they come after the `fieldCount` mandatory fields.
+	optionalFieldCount int
+
+	// hexFields are the index of the fields that must be valid hexadecimal (or `.`).
+	hexFields []int
+
+	// ordinalField is the index of the ordinal field, -1 when the event has no ordinal.
+	ordinalField int
+
+	// jsonFields are the index of the fields holding a JSON document (or `.`).
+	jsonFields []int
+
+	// fields are the names of the mandatory fields followed by the optional ones, they are
+	// used as keys when the event is rendered as a JSON object.
+	fields []string
+}
+
+var eventSchemas = map[string]eventSchema{
+	"STREAM_HEADER":        {fieldCount: 1, freeFormTail: true, ordinalField: -1, jsonFields: []int{0}, fields: []string{"header"}},
+	"INIT":                 {fieldCount: 3, optionalFieldCount: 1, ordinalField: -1, fields: []string{"version", "variant", "node_version", "balance_changes"}},
+	"INIT_REASONS":         {fieldCount: 2, ordinalField: -1, jsonFields: []int{1}, fields: []string{"version", "reasons"}},
+	"ERROR":                {fieldCount: 1, freeFormTail: true, ordinalField: -1, fields: []string{"message"}},
+	"FATAL":                {fieldCount: 1, freeFormTail: true, ordinalField: -1, fields: []string{"reason"}},
+	"RESUME":               {fieldCount: 2, ordinalField: -1, fields: []string{"last_block", "restarts"}},
+	"CLOCK_ANCHOR":         {fieldCount: 2, ordinalField: -1, fields: []string{"monotonic_ns", "wall_ns"}},
+	"BEGIN_BLOCK":          {fieldCount: 6, optionalFieldCount: 1, hexFields: []int{1, 2}, ordinalField: -1, jsonFields: []int{6}, fields: []string{"number", "hash", "parent_hash", "time", "trx_count", "size", "annotations"}},
+	"FINALIZE_BLOCK":       {fieldCount: 2, optionalFieldCount: 2, ordinalField: -1, jsonFields: []int{3}, fields: []string{"number", "mode", "ordinals", "events"}},
+	"FEE_RECIPIENT_CREDIT": {fieldCount: 3, hexFields: []int{1, 2}, ordinalField: -1, fields: []string{"block_number", "recipient", "fees"}},
+	"BLOCK_PROGRESS":       {fieldCount: 5, hexFields: []int{1, 2}, ordinalField: -1, fields: []string{"number", "hash", "parent_hash", "time", "trx_count"}},
+	"TRIE_COMMIT":          {fieldCount: 4, ordinalField: -1, fields: []string{"block_number", "nodes", "bytes", "duration_ns"}},
+	"END_BLOCK":            {fieldCount: 3, freeFormTail: true, ordinalField: -1, jsonFields: []int{2}, fields: []string{"number", "size", "meta"}},
+	"UNCLE_BLOCK":          {fieldCount: 4, freeFormTail: true, hexFields: []int{2}, ordinalField: -1, jsonFields: []int{3}, fields: []string{"index", "number", "hash", "body"}},
+	"BLOCK_FINALIZED":      {fieldCount: 2, hexFields: []int{1}, ordinalField: -1, fields: []string{"number", "hash"}},
+	"CANCEL_BLOCK":         {fieldCount: 2, freeFormTail: true, ordinalField: -1, fields: []string{"number", "reason"}},
+	"BLOCK_SEGMENT":        {fieldCount: 3, ordinalField: -1, fields: []string{"block_number", "segment", "size"}},
+	"BLOCK_SEGMENTS":       {fieldCount: 2, ordinalField: -1, fields: []string{"block_number", "segment_count"}},
+	"BEGIN_SYSTEM_CALL":    {fieldCount: 5, hexFields: []int{1, 2}, ordinalField: 3, fields: []string{"name", "caller", "target", "ordinal", "creation"}},
+	"END_SYSTEM_CALL":      {fieldCount: 1, ordinalField: 0, fields: []string{"ordinal"}},
+	"BEGIN_APPLY_TRX":      {fieldCount: 17, hexFields: []int{0, 1, 2, 3, 4, 5, 7, 9, 10, 11, 12}, ordinalField: 14, fields: []string{"hash", "to", "value", "v", "r", "s", "gas_limit", "gas_price", "nonce", "input", "access_list", "max_fee_per_gas", "max_priority_fee_per_gas", "type", "ordinal", "index", "fee_kind"}},
+	"SKIPPED_TRX":          {fieldCount: 4, freeFormTail: true, hexFields: []int{0, 1}, ordinalField: -1, fields: []string{"hash", "rlp", "reason", "error"}},
+	"TRX_FROM":             {fieldCount: 1, optionalFieldCount: 1, hexFields: []int{0, 1}, ordinalField: -1, fields: []string{"from", "pubkey"}},
+	"TRX_REPLACED":         {fieldCount: 2, hexFields: []int{0, 1}, ordinalField: -1, fields: []string{"replaced_hash", "included_hash"}},
+	"TRX_ABORTED":          {fieldCount: 1, freeFormTail: true, ordinalField: -1, fields: []string{"reason"}},
+	"SLOW_TRX":             {fieldCount: 3, hexFields: []int{0}, ordinalField: -1, fields: []string{"hash", "elapsed_ns", "gas_used"}},
+	"SLOW_TRX_PROGRESS":    {fieldCount: 3, hexFields: []int{0}, ordinalField: -1, fields: []string{"hash", "elapsed_ns", "gas_used"}},
+	"END_APPLY_TRX":        {fieldCount: 6, freeFormTail: true, hexFields: []int{1, 3}, ordinalField: 4, jsonFields: []int{5}, fields: []string{"gas_used", "post_state", "cumulative_gas_used", "logs_bloom", "ordinal", "logs"}},
+	"TRX_GAS_REFUND":       {fieldCount: 4, ordinalField: -1, fields: []string{"gas_left", "accumulated", "applied", "cap_rule"}},
+	"EVM_RUN_CALL":         {fieldCount: 5, ordinalField: 2, fields: []string{"call_type", "call_index", "ordinal", "gas_at_start", "parent_gas_remaining"}},
+	"EVM_PARAM":            {fieldCount: 7, hexFields: []int{2, 3, 4, 6}, ordinalField: -1, fields: []string{"call_type", "call_index", "caller", "address", "value", "gas_limit", "input"}},
+	"ACCOUNT_WITHOUT_CODE": {fieldCount: 1, ordinalField: -1, fields: []string{"call_index"}},
+	"EVM_CALL_FAILED":      {fieldCount: 7, freeFormTail: true, hexFields: []int{4}, ordinalField: -1, fields: []string{"call_index", "gas_left", "code", "pc", "opcode", "depth", "reason"}},
+	"EVM_REVERTED":         {fieldCount: 3, freeFormTail: true, hexFields: []int{1}, ordinalField: -1, jsonFields: []int{2}, fields: []string{"call_index", "selector", "reason"}},
+	"CALL_ACCESS_SET":      {fieldCount: 2, ordinalField: -1, jsonFields: []int{1}, fields: []string{"call_index", "access_set"}},
+	"EVM_END_CALL":         {fieldCount: 6, optionalFieldCount: 2, hexFields: []int{2}, ordinalField: 3, fields: []string{"call_index", "gas_left", "return_data", "ordinal", "gas_at_start", "parent_gas_remaining", "aborted", "return_data_size"}},
+	"BLOCKHASH_READ":       {fieldCount: 3, hexFields: []int{2}, ordinalField: -1, fields: []string{"call_index", "number", "hash"}},
+	"EVM_KECCAK":           {fieldCount: 3, hexFields: []int{1, 2}, ordinalField: -1, fields: []string{"call_index", "hash", "data"}},
+	"GAS_CHANGE":           {fieldCount: 5, optionalFieldCount: 1, ordinalField: 4, fields: []string{"call_index", "old_value", "new_value", "reason", "ordinal", "count"}},
+	"STORAGE_CHANGE":       {fieldCount: 6, optionalFieldCount: 1, hexFields: []int{1, 2, 3, 4, 6}, ordinalField: 5, fields: []string{"call_index", "address", "key", "old_value", "new_value", "ordinal", "key_preimage"}},
+	"BALANCE_CHANGE":       {fieldCount: 6, hexFields: []int{1, 2, 3}, ordinalField: 5, fields: []string{"call_index", "address", "old_value", "new_value", "reason", "ordinal"}},
+	"ADD_LOG":              {fieldCount: 6, hexFields: []int{2, 4}, ordinalField: 5, fields: []string{"call_index", "block_index", "address", "topics", "data", "ordinal"}},
+	"SUICIDE_CHANGE":       {fieldCount: 4, hexFields: []int{1, 3}, ordinalField: -1, fields: []string{"call_index", "address", "suicided", "balance_before"}},
+	"STORAGE_WIPED":        {fieldCount: 3, hexFields: []int{1, 2}, ordinalField: -1, fields: []string{"call_index", "address", "storage_root"}},
+	"CREATED_ACCOUNT":      {fieldCount: 3, hexFields: []int{1}, ordinalField: 2, fields: []string{"call_index", "address", "ordinal"}},
+	"CODE_CHANGE":          {fieldCount: 7, hexFields: []int{1, 2, 3, 4, 5}, ordinalField: 6, fields: []string{"call_index", "address", "old_code_hash", "old_code", "new_code_hash", "new_code", "ordinal"}},
+	"CODE_CHANGE_REF":      {fieldCount: 8, hexFields: []int{1, 2, 4, 6}, ordinalField: 7, fields: []string{"call_index", "address", "old_code_hash", "old_code_length", "new_code_hash", "new_code_length", "new_code", "ordinal"}},
+	"CODE_BLOB":            {fieldCount: 2, hexFields: []int{0}, ordinalField: -1, fields: []string{"hash", "length"}},
+	"NONCE_CHANGE":         {fieldCount: 5, hexFields: []int{1}, ordinalField: 4, fields: []string{"call_index", "address", "old_value", "new_value", "ordinal"}},
+	"ACCOUNT_PROOF":        {fieldCount: 4, hexFields: []int{1, 2}, ordinalField: -1, jsonFields: []int{3}, fields: []string{"block_number", "state_root", "address", "proof"}},
+	"STORAGE_PROOF":        {fieldCount: 5, hexFields: []int{1, 2, 3}, ordinalField: -1, jsonFields: []int{4}, fields: []string{"block_number", "address", "key", "value", "proof"}},
+	"DIVERGENCE":           {fieldCount: 5, hexFields: []int{1}, ordinalField: -1, fields: []string{"block_number", "trx_hash", "field", "local", "reference"}},
+	"TRX_ENTER_POOL":       {fieldCount: 11, hexFields: []int{0, 1, 2, 3, 4, 5, 6, 8, 10}, ordinalField: -1, fields: []string{"hash", "from", "to", "value", "v", "r", "s", "gas_limit", "gas_price", "nonce", "input"}},
+	"TRX_DISCARDED":        {fieldCount: 11, hexFields: []int{0, 1, 2, 3, 4, 5, 6, 8, 10}, ordinalField: -1, fields: []string{"hash", "from", "to", "value", "v", "r", "s", "gas_limit", "gas_price", "nonce", "input"}},
+}
+
+// splitLine splits a Firehose line into its event name and fields according to the
+// event's schema, `ok` is `false` if the line is not a Firehose line. The sequence number and
+// the monotonic timestamp of the line, if any, are skipped.
+func splitLine(line string) (event string, fields []string, ok bool) {
+	_, _, event, fields, ok = splitTaggedLine(line)
+	return
+}
+
+// splitTaggedLine is `splitLine` also returning the sequence number and the monotonic
+// timestamp of the line, empty when the line has none.
+func splitTaggedLine(line string) (sequence string, timestamp string, event string, fields []string, ok bool) {
+	if !strings.HasPrefix(line, linePrefix) {
+		return "", "", "", nil, false
+	}
+
+	line = strings.TrimSuffix(line[len(linePrefix):], "\n")
+	if strings.HasPrefix(line, sequencePrefix) {
+		sequence, line = splitToken(line[len(sequencePrefix):])
+	}
+	if strings.HasPrefix(line, timestampPrefix) {
+		timestamp, line = splitToken(line[len(timestampPrefix):])
+	}
+
+	event, fields, ok = splitEvent(line)
+	return
+}
+
+func splitToken(line string) (token string, rest string) {
+	if i := strings.IndexByte(line, ' '); i != -1 {
+		return line[:i], line[i+1:]
+	}
+
+	return line, ""
+}
+
+func splitEvent(line string) (event string, fields []string, ok bool) {
+	event = line
+	rest := ""
+	if i := strings.IndexByte(line, ' '); i != -1 {
+		event, rest = line[:i], line[i+1:]
+	}
+
+	if rest == "" {
+		return event, nil, true
+	}
+
+	schema, found := eventSchemas[event]
+	if found && schema.freeFormTail {
+		return event, strings.SplitN(rest, " ", schema.fieldCount), true
+	}
+
+	return event, strings.Split(rest, " "), true
+}
+
+// isValidHex returns `true` if the field is a valid Firehose hexadecimal value, either
+// `.` for empty or a non-prefixed hexadecimal string.
+func isValidHex(field string) bool {
+	if field == "." {
+		return true
+	}
+
+	_, err := hex.DecodeString(field)
+	return err == nil
+}
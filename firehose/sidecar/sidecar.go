@@ -0,0 +1,393 @@
+// Package sidecar implements a Firehose output sink supervising the console reader process:
+// the node launches the reader binary itself with the Firehose output wired to its standard
+// input, replacing the external shell pipeline usually connecting them. The reader is
+// restarted when it exits or stops consuming its input, the restart being announced by a
+// CANCEL/RESUME handshake so that no reader ever sees a partial block.
+package sidecar
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/firehose/netsink"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/metrics"
+)
+
+var restartsCounter = metrics.NewRegisteredCounter("firehose/sidecar/restarts", nil)
+
+// ErrClosed is returned by the writes of a closed Writer.
+var ErrClosed = errors.New("sidecar closed")
+
+// cancelTimeout bounds the time spent handing the CANCEL_BLOCK line to a reader being
+// replaced, and closeTimeout the time a reader has to drain its input once closed.
+const (
+	cancelTimeout = time.Second
+	closeTimeout  = 10 * time.Second
+)
+
+// Config describes the supervised reader process.
+type Config struct {
+	// Path and Args are the reader binary and its arguments.
+	Path string
+	Args []string
+
+	// StallTimeout is the time a write may stay blocked on a reader not consuming its
+	// input before the reader is deemed unhealthy and restarted, 0 disables the detection.
+	StallTimeout time.Duration
+
+	// Restart is the delay policy between consecutive failed restarts, only its backoff
+	// settings are used.
+	Restart netsink.Config
+
+	// NDJSON tells that the output is in the NDJSON format, the handshake lines are
+	// emitted in the same format as the stream.
+	NDJSON bool
+}
+
+// Writer is an `io.Writer` delivering the Firehose output to the standard input of the reader
+// process it supervises. The lines of the block in progress are retained so that, when the
+// reader is restarted, the reader being replaced receives a `CANCEL_BLOCK <number>
+// reader_restart` line (best effort, it may be dead already) and the new reader a
+// `RESUME <last_block> <restarts>` line followed by the block in progress from its
+// BEGIN_BLOCK line, `last_block` being the last block fully delivered.
+type Writer struct {
+	config Config
+
+	lock     sync.Mutex
+	reader   *reader
+	restarts int
+	failures int
+
+	// block holds the complete lines of the block in progress, partialLine the last line
+	// written when still incomplete
+	block       []byte
+	partialLine []byte
+	inBlock     bool
+	blockNumber uint64
+	lastBlock   uint64
+
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// Open launches the reader described by `config`, the launch failing right away so that
+// configuration errors are reported at startup.
+func Open(config Config) (*Writer, error) {
+	w := &Writer{config: config, done: make(chan struct{})}
+
+	reader, err := launch(config)
+	if err != nil {
+		return nil, err
+	}
+	w.reader = reader
+
+	return w, nil
+}
+
+// Write delivers `data` to the reader, restarting it as many times as needed. It only fails
+// once the writer is closed.
+func (w *Writer) Write(data []byte) (int, error) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	for {
+		select {
+		case <-w.done:
+			return 0, ErrClosed
+		default:
+		}
+
+		if w.reader != nil && w.reader.hasExited() {
+			w.replace(fmt.Errorf("reader exited: %v", w.reader.exitErr))
+		}
+
+		if w.reader == nil {
+			if err := w.restart(); err != nil {
+				if !w.fail(err) {
+					return 0, ErrClosed
+				}
+				continue
+			}
+		}
+
+		if err := w.reader.write(data, w.config.StallTimeout); err != nil {
+			w.replace(err)
+			if !w.fail(err) {
+				return 0, ErrClosed
+			}
+			continue
+		}
+
+		w.failures = 0
+		w.track(data)
+		return len(data), nil
+	}
+}
+
+// replace hands the CANCEL_BLOCK line to the current reader when a block is in progress and
+// stops it, the next write restarting a new one.
+func (w *Writer) replace(cause error) {
+	log.Warn("Firehose reader unhealthy, restarting it", "path", w.config.Path, "err", cause)
+
+	if w.inBlock && !w.reader.hasExited() {
+		w.reader.write(w.cancelLine(), cancelTimeout)
+	}
+
+	w.reader.stop()
+	w.reader = nil
+}
+
+// restart launches a new reader and hands it the RESUME line followed by the block in
+// progress.
+func (w *Writer) restart() error {
+	reader, err := launch(w.config)
+	if err != nil {
+		return err
+	}
+
+	w.restarts++
+	restartsCounter.Inc(1)
+
+	handshake := append(w.resumeLine(), w.block...)
+	handshake = append(handshake, w.partialLine...)
+	if err := reader.write(handshake, w.config.StallTimeout); err != nil {
+		reader.stop()
+		return fmt.Errorf("resume reader: %w", err)
+	}
+
+	log.Info("Firehose reader restarted", "path", w.config.Path, "restarts", w.restarts, "last_block", w.lastBlock, "replayed_bytes", len(handshake))
+	w.reader = reader
+
+	return nil
+}
+
+// fail records a failed delivery and waits before the next attempt, it returns `false` if
+// the writer was closed meanwhile.
+func (w *Writer) fail(err error) bool {
+	w.failures++
+	log.Warn("Firehose reader delivery failed, retrying", "path", w.config.Path, "failures", w.failures, "err", err)
+
+	timer := time.NewTimer(w.config.Restart.Backoff(w.failures))
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return true
+	case <-w.done:
+		return false
+	}
+}
+
+// track retains the lines of the block in progress out of the data delivered.
+func (w *Writer) track(data []byte) {
+	w.partialLine = append(w.partialLine, data...)
+	for {
+		end := bytes.IndexByte(w.partialLine, '\n')
+		if end == -1 {
+			break
+		}
+
+		w.trackLine(w.partialLine[:end+1])
+		w.partialLine = w.partialLine[end+1:]
+	}
+
+	// The consumed lines are not referenced anymore, the partial line doesn't keep them alive
+	w.partialLine = append([]byte(nil), w.partialLine...)
+}
+
+func (w *Writer) trackLine(line []byte) {
+	event, number, ok := blockEvent(line)
+	switch {
+	case ok && event == "BEGIN_BLOCK":
+		w.block = append(w.block[:0], line...)
+		w.inBlock, w.blockNumber = true, number
+
+	case !w.inBlock:
+		return
+
+	case ok && (event == "END_BLOCK" || event == "CANCEL_BLOCK"):
+		if event == "END_BLOCK" {
+			w.lastBlock = number
+		}
+		w.block = w.block[:0]
+		w.inBlock = false
+
+	default:
+		w.block = append(w.block, line...)
+	}
+}
+
+func (w *Writer) cancelLine() []byte {
+	if w.config.NDJSON {
+		return []byte(fmt.Sprintf(`{"event":"CANCEL_BLOCK","number":"%d","reason":"reader_restart"}`+"\n", w.blockNumber))
+	}
+	return []byte(fmt.Sprintf("FIRE CANCEL_BLOCK %d reader_restart\n", w.blockNumber))
+}
+
+func (w *Writer) resumeLine() []byte {
+	if w.config.NDJSON {
+		return []byte(fmt.Sprintf(`{"event":"RESUME","last_block":"%d","restarts":"%d"}`+"\n", w.lastBlock, w.restarts))
+	}
+	return []byte(fmt.Sprintf("FIRE RESUME %d %d\n", w.lastBlock, w.restarts))
+}
+
+// Close closes the reader's standard input, giving it some time to drain it before it's
+// killed, and interrupts any write being retried.
+func (w *Writer) Close() error {
+	w.closeOnce.Do(func() { close(w.done) })
+
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	if w.reader == nil {
+		return nil
+	}
+
+	err := w.reader.close(closeTimeout)
+	w.reader = nil
+
+	return err
+}
+
+// reader is a running reader process.
+type reader struct {
+	cmd   *exec.Cmd
+	stdin *os.File
+
+	exited  chan struct{}
+	exitErr error
+}
+
+func launch(config Config) (*reader, error) {
+	readEnd, writeEnd, err := os.Pipe()
+	if err != nil {
+		return nil, fmt.Errorf("create reader pipe: %w", err)
+	}
+
+	cmd := exec.Command(config.Path, config.Args...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = readEnd, os.Stdout, os.Stderr
+	if err := cmd.Start(); err != nil {
+		readEnd.Close()
+		writeEnd.Close()
+		return nil, fmt.Errorf("start reader %q: %w", config.Path, err)
+	}
+
+	// The reader owns the read end now, keeping it open would prevent its exit from
+	// failing our writes
+	readEnd.Close()
+
+	r := &reader{cmd: cmd, stdin: writeEnd, exited: make(chan struct{})}
+	go func() {
+		r.exitErr = cmd.Wait()
+		close(r.exited)
+	}()
+
+	return r, nil
+}
+
+func (r *reader) hasExited() bool {
+	select {
+	case <-r.exited:
+		return true
+	default:
+		return false
+	}
+}
+
+// write writes `data` to the reader's standard input, failing if it's blocked for longer
+// than `timeout` (0 meaning no limit).
+func (r *reader) write(data []byte, timeout time.Duration) error {
+	deadline := time.Time{}
+	if timeout > 0 {
+		deadline = time.Now().Add(timeout)
+	}
+	if err := r.stdin.SetWriteDeadline(deadline); err != nil {
+		return err
+	}
+
+	_, err := r.stdin.Write(data)
+	return err
+}
+
+// stop kills the reader right away.
+func (r *reader) stop() {
+	r.stdin.Close()
+	r.cmd.Process.Kill()
+	<-r.exited
+}
+
+// close closes the reader's standard input and waits up to `timeout` for it to exit before
+// killing it.
+func (r *reader) close(timeout time.Duration) error {
+	r.stdin.Close()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case <-r.exited:
+		return r.exitErr
+	case <-timer.C:
+		r.cmd.Process.Kill()
+		<-r.exited
+		return fmt.Errorf("reader did not exit within %s, killed", timeout)
+	}
+}
+
+// blockEvent extracts the event name and block number of BEGIN_BLOCK, END_BLOCK and
+// CANCEL_BLOCK lines, both in text and NDJSON output formats.
+func blockEvent(line []byte) (event string, number uint64, ok bool) {
+	var rawNumber string
+
+	switch {
+	case bytes.HasPrefix(line, []byte("FIRE ")):
+		rest := bytes.TrimSpace(line[len("FIRE "):])
+		for bytes.HasPrefix(rest, []byte("#")) || bytes.HasPrefix(rest, []byte("@")) {
+			// Sequence number and monotonic timestamp of the line, see
+			// `firehose.SequenceNumbersEnabled` and `firehose.MonotonicTimestampsEnabled`
+			i := bytes.IndexByte(rest, ' ')
+			if i == -1 {
+				return "", 0, false
+			}
+			rest = rest[i+1:]
+		}
+
+		fields := bytes.SplitN(rest, []byte(" "), 3)
+		if len(fields) < 2 {
+			return "", 0, false
+		}
+		event, rawNumber = string(fields[0]), string(fields[1])
+
+	case bytes.HasPrefix(line, []byte(`{"event":"BEGIN_BLOCK"`)), bytes.HasPrefix(line, []byte(`{"event":"END_BLOCK"`)), bytes.HasPrefix(line, []byte(`{"event":"CANCEL_BLOCK"`)):
+		var object struct {
+			Event  string `json:"event"`
+			Number string `json:"number"`
+		}
+		if err := json.Unmarshal(line, &object); err != nil {
+			return "", 0, false
+		}
+		event, rawNumber = object.Event, object.Number
+
+	default:
+		return "", 0, false
+	}
+
+	if event != "BEGIN_BLOCK" && event != "END_BLOCK" && event != "CANCEL_BLOCK" {
+		return "", 0, false
+	}
+
+	number, err := strconv.ParseUint(rawNumber, 10, 64)
+	if err != nil {
+		return "", 0, false
+	}
+
+	return event, number, true
+}
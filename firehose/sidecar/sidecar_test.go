@@ -0,0 +1,80 @@
+package sidecar
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/firehose/netsink"
+)
+
+func TestWriterRestartResumesBlock(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sidecar")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	output := filepath.Join(dir, "output")
+	writer, err := Open(Config{
+		Path:    "sh",
+		Args:    []string{"-c", "cat >> " + output},
+		Restart: netsink.Config{InitialBackoff: time.Millisecond, MaxBackoff: time.Millisecond},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	write := func(data string) {
+		if _, err := writer.Write([]byte(data)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	write("FIRE BEGIN_BLOCK 1 aa bb 10 0 500\nFIRE END_BLOCK 1 500 {}\n")
+	write("FIRE BEGIN_BLOCK 2 cc aa 20 0 500\nFIRE FINALIZE_BLOCK 2 full\nFIRE END_")
+
+	// The reader dies in the middle of block 2, once it consumed its input, the next write
+	// restarts it
+	for start := time.Now(); fileSize(output) != 128; time.Sleep(time.Millisecond) {
+		if time.Since(start) > 5*time.Second {
+			t.Fatalf("reader did not consume its input, %d bytes written", fileSize(output))
+		}
+	}
+	writer.reader.cmd.Process.Kill()
+	<-writer.reader.exited
+
+	write("BLOCK 2 500 {}\n")
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	content, err := ioutil.ReadFile(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "FIRE BEGIN_BLOCK 1 aa bb 10 0 500\nFIRE END_BLOCK 1 500 {}\n" +
+		"FIRE BEGIN_BLOCK 2 cc aa 20 0 500\nFIRE FINALIZE_BLOCK 2 full\nFIRE END_" +
+		"FIRE RESUME 1 1\n" +
+		"FIRE BEGIN_BLOCK 2 cc aa 20 0 500\nFIRE FINALIZE_BLOCK 2 full\nFIRE END_BLOCK 2 500 {}\n"
+	if string(content) != expected {
+		t.Errorf("unexpected reader input\nexpected %q\ngot      %q", expected, content)
+	}
+}
+
+func fileSize(path string) int64 {
+	stat, err := os.Stat(path)
+	if err != nil {
+		return 0
+	}
+	return stat.Size()
+}
+
+func TestWriterStartFailure(t *testing.T) {
+	if _, err := Open(Config{Path: filepath.Join(os.TempDir(), "no-such-firehose-reader")}); err == nil {
+		t.Fatal("expected an error launching a missing reader")
+	}
+}
@@ -0,0 +1,191 @@
+package firehose
+
+import (
+	"bytes"
+	"io"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/metrics"
+)
+
+var sizingBlockBytesHistogram = metrics.NewRegisteredHistogram("firehose/sizing/block/bytes", nil, metrics.NewExpDecaySample(1028, 0.015))
+
+// syncSizingInterval is the interval of the sizing pass summaries, 0 when disabled, see
+// `SetOutputSizing`.
+var syncSizingInterval time.Duration = 0
+
+// SetOutputSizing enables the sizing pass when `interval` is non zero: the bytes emitted by the
+// sync context are accounted per block and per event family into the `firehose/sizing/`
+// metrics, and a summary projecting the daily output size is logged at this interval.
+// Combined with the dry-run mode, it forecasts the storage and bandwidth needs of a chain
+// before enabling the capture. The bytes are accounted in the output format, before the
+// output middlewares. It must be called at initialization time, before any block is processed.
+func SetOutputSizing(interval time.Duration) {
+	syncSizingInterval = interval
+	SetSyncContextWriter(syncContextWriter)
+}
+
+// eventFamilies groups the events by what they describe, events not listed belong to the
+// `other` family.
+var eventFamilies = map[string]string{
+	"BEGIN_BLOCK":          "block",
+	"FINALIZE_BLOCK":       "block",
+	"BLOCK_PROGRESS":       "block",
+	"FEE_RECIPIENT_CREDIT": "block",
+	"END_BLOCK":            "block",
+	"CANCEL_BLOCK":         "block",
+	"UNCLE_BLOCK":          "block",
+	"BLOCK_SEGMENT":        "block",
+	"BLOCK_SEGMENTS":       "block",
+	"BEGIN_SYSTEM_CALL":    "trx",
+	"END_SYSTEM_CALL":      "trx",
+	"BEGIN_APPLY_TRX":      "trx",
+	"SKIPPED_TRX":          "trx",
+	"TRX_FROM":             "trx",
+	"TRX_REPLACED":         "trx",
+	"TRX_ABORTED":          "trx",
+	"END_APPLY_TRX":        "trx",
+	"EVM_RUN_CALL":         "call",
+	"EVM_PARAM":            "call",
+	"EVM_CALL_FAILED":      "call",
+	"EVM_REVERTED":         "call",
+	"EVM_END_CALL":         "call",
+	"EVM_KECCAK":           "call",
+	"BLOCKHASH_READ":       "call",
+	"CALL_ACCESS_SET":      "call",
+	"GAS_CHANGE":           "gas",
+	"TRX_GAS_REFUND":       "gas",
+	"STORAGE_CHANGE":       "state",
+	"STORAGE_WIPED":        "state",
+	"BALANCE_CHANGE":       "state",
+	"NONCE_CHANGE":         "state",
+	"CODE_CHANGE":          "state",
+	"CODE_CHANGE_REF":      "state",
+	"CODE_BLOB":            "state",
+	"SUICIDE_CHANGE":       "state",
+	"CREATED_ACCOUNT":      "state",
+	"ACCOUNT_PROOF":        "state",
+	"STORAGE_PROOF":        "state",
+	"ADD_LOG":              "log",
+}
+
+func eventFamily(event string) string {
+	if family, found := eventFamilies[event]; found {
+		return family
+	}
+	return "other"
+}
+
+// sizingWriter accounts the bytes written through it before handing them to the next writer.
+type sizingWriter struct {
+	next     io.Writer
+	interval time.Duration
+
+	lock        sync.Mutex
+	partialLine []byte
+	blockBytes  uint64
+	meters      map[string]metrics.Meter
+
+	// Totals since the last summary, `firstBlockTime` and `lastBlockTime` being the time of
+	// the first and last block accounted
+	since          time.Time
+	blocks         uint64
+	bytes          uint64
+	familyTotals   map[string]uint64
+	firstBlockTime uint64
+	lastBlockTime  uint64
+}
+
+func newSizingWriter(next io.Writer, interval time.Duration) *sizingWriter {
+	return &sizingWriter{
+		next:         next,
+		interval:     interval,
+		meters:       map[string]metrics.Meter{},
+		since:        time.Now(),
+		familyTotals: map[string]uint64{},
+	}
+}
+
+func (w *sizingWriter) Write(data []byte) (int, error) {
+	written, err := w.next.Write(data)
+
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	w.partialLine = append(w.partialLine, data[:written]...)
+	for {
+		end := bytes.IndexByte(w.partialLine, '\n')
+		if end == -1 {
+			break
+		}
+
+		w.accountLine(w.partialLine[:end+1])
+		w.partialLine = w.partialLine[end+1:]
+	}
+
+	return written, err
+}
+
+func (w *sizingWriter) accountLine(line []byte) {
+	event, _, blockTime := lineEvent(line)
+	family := eventFamily(event)
+
+	meter, found := w.meters[family]
+	if !found {
+		meter = metrics.GetOrRegisterMeter("firehose/sizing/family/"+family+"/bytes", nil)
+		w.meters[family] = meter
+	}
+	meter.Mark(int64(len(line)))
+
+	w.blockBytes += uint64(len(line))
+	w.bytes += uint64(len(line))
+	w.familyTotals[family] += uint64(len(line))
+
+	switch event {
+	case "BEGIN_BLOCK":
+		w.blockBytes = uint64(len(line))
+		if w.firstBlockTime == 0 {
+			w.firstBlockTime = blockTime
+		}
+		w.lastBlockTime = blockTime
+	case "END_BLOCK":
+		sizingBlockBytesHistogram.Update(int64(w.blockBytes))
+		w.blocks++
+
+		if time.Since(w.since) >= w.interval {
+			w.logSummary()
+		}
+	}
+}
+
+// logSummary logs the output size accounted since the last summary and resets the totals. The
+// daily output size is projected from the chain time covered by the accounted blocks, not
+// the wall time, so that it holds while the node is catching up too.
+func (w *sizingWriter) logSummary() {
+	families := make([]string, 0, len(w.familyTotals))
+	for family := range w.familyTotals {
+		families = append(families, family)
+	}
+	sort.Strings(families)
+
+	ctx := []interface{}{
+		"blocks", w.blocks,
+		"bytes", common.StorageSize(w.bytes),
+		"per_block", common.StorageSize(w.bytes / w.blocks),
+	}
+	if w.lastBlockTime > w.firstBlockTime {
+		ctx = append(ctx, "per_day", common.StorageSize(float64(w.bytes)*24*60*60/float64(w.lastBlockTime-w.firstBlockTime)))
+	}
+	for _, family := range families {
+		ctx = append(ctx, family, common.StorageSize(w.familyTotals[family]))
+	}
+	log.Info("Firehose output sizing", ctx...)
+
+	w.since, w.blocks, w.bytes = time.Now(), 0, 0
+	w.familyTotals = map[string]uint64{}
+	w.firstBlockTime, w.lastBlockTime = 0, 0
+}
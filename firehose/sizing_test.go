@@ -0,0 +1,46 @@
+package firehose
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestSizingWriter(t *testing.T) {
+	output := &bytes.Buffer{}
+	writer := newSizingWriter(output, time.Hour)
+
+	block1 := "FIRE BEGIN_BLOCK 1 aa bb 100 1 500\nFIRE BEGIN_APPLY_TRX aa\nFIRE STORAGE_CHANGE 1 aa\nFIRE END_APPLY_TRX\nFIRE END_BLOCK 1 500 {}\n"
+	block2 := `{"event":"BEGIN_BLOCK","number":"2","time":"112"}` + "\n" + `{"event":"ADD_LOG","call_index":"1"}` + "\n" + `{"event":"END_BLOCK","number":"2"}` + "\n"
+
+	writer.Write([]byte(block1[:30]))
+	writer.Write([]byte(block1[30:] + block2))
+
+	if output.String() != block1+block2 {
+		t.Fatalf("output altered by the sizing pass: %q", output.String())
+	}
+
+	if writer.blocks != 2 || writer.bytes != uint64(len(block1)+len(block2)) {
+		t.Errorf("got %d blocks and %d bytes, want 2 blocks and %d bytes", writer.blocks, writer.bytes, len(block1)+len(block2))
+	}
+	if writer.firstBlockTime != 100 || writer.lastBlockTime != 112 {
+		t.Errorf("got block times %d to %d, want 100 to 112", writer.firstBlockTime, writer.lastBlockTime)
+	}
+
+	expected := map[string]uint64{
+		"block": uint64(len("FIRE BEGIN_BLOCK 1 aa bb 100 1 500\nFIRE END_BLOCK 1 500 {}\n") + len(`{"event":"BEGIN_BLOCK","number":"2","time":"112"}`+"\n"+`{"event":"END_BLOCK","number":"2"}`+"\n")),
+		"trx":   uint64(len("FIRE BEGIN_APPLY_TRX aa\nFIRE END_APPLY_TRX\n")),
+		"state": uint64(len("FIRE STORAGE_CHANGE 1 aa\n")),
+		"log":   uint64(len(`{"event":"ADD_LOG","call_index":"1"}` + "\n")),
+	}
+	for family, want := range expected {
+		if got := writer.familyTotals[family]; got != want {
+			t.Errorf("family %s got %d bytes, want %d", family, got, want)
+		}
+	}
+
+	writer.logSummary()
+	if writer.blocks != 0 || writer.bytes != 0 || len(writer.familyTotals) != 0 || writer.firstBlockTime != 0 {
+		t.Errorf("totals not reset by the summary")
+	}
+}
@@ -0,0 +1,131 @@
+// Package socketsink implements a Firehose output sink streaming the output to a remote
+// collector over a TCP connection, optionally secured with TLS (and client certificate
+// authentication) so that the stream is never plaintext when crossing host boundaries.
+package socketsink
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"time"
+
+	"github.com/ethereum/go-ethereum/firehose/netsink"
+)
+
+// dialTimeout bounds the time spent establishing the connection (TLS handshake included).
+const dialTimeout = 10 * time.Second
+
+// TLSConfig holds the certificate files securing the connection, all paths are PEM files.
+type TLSConfig struct {
+	// CertFile and KeyFile are the client certificate and its private key presented to the
+	// collector for mutual authentication, both must be set or none.
+	CertFile string
+	KeyFile  string
+
+	// CAFile is the certificate authority used to verify the collector certificate, the
+	// system roots are used when empty.
+	CAFile string
+}
+
+// Enabled returns `true` if any of the TLS files is configured.
+func (c *TLSConfig) Enabled() bool {
+	return c != nil && (c.CertFile != "" || c.KeyFile != "" || c.CAFile != "")
+}
+
+// Load builds the `tls.Config` used to dial `address`.
+func (c *TLSConfig) Load(address string) (*tls.Config, error) {
+	if (c.CertFile == "") != (c.KeyFile == "") {
+		return nil, errors.New("both the TLS certificate and key files must be provided")
+	}
+
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, fmt.Errorf("invalid address %q: %w", address, err)
+	}
+
+	config := &tls.Config{ServerName: host, MinVersion: tls.VersionTLS12}
+
+	if c.CertFile != "" {
+		certificate, err := tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("load TLS certificate: %w", err)
+		}
+		config.Certificates = []tls.Certificate{certificate}
+	}
+
+	if c.CAFile != "" {
+		data, err := ioutil.ReadFile(c.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("read TLS CA file: %w", err)
+		}
+
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(data) {
+			return nil, fmt.Errorf("no valid certificate found in TLS CA file %q", c.CAFile)
+		}
+		config.RootCAs = pool
+	}
+
+	return config, nil
+}
+
+// Writer is an `io.Writer` sending the Firehose output stream to a remote collector. The
+// connection is established on first write and re-established after a failure, writes
+// being retried according to the writer's retry policy until delivered.
+type Writer struct {
+	address   string
+	tlsConfig *tls.Config
+	sink      *netsink.Writer
+}
+
+// Open creates a writer streaming to the TCP `address` (`host:port`), over TLS when
+// `tlsConfig` is enabled, retrying failed writes according to `retry`. The connection is
+// attempted right away so that configuration errors are reported at startup.
+func Open(address string, tlsConfig *TLSConfig, retry netsink.Config) (*Writer, error) {
+	w := &Writer{address: address}
+
+	if tlsConfig.Enabled() {
+		config, err := tlsConfig.Load(address)
+		if err != nil {
+			return nil, err
+		}
+		w.tlsConfig = config
+	}
+
+	w.sink = netsink.NewWriter("socket", retry, w.connect)
+	if err := w.sink.Connect(); err != nil {
+		return nil, err
+	}
+
+	return w, nil
+}
+
+func (w *Writer) Write(data []byte) (int, error) {
+	return w.sink.Write(data)
+}
+
+func (w *Writer) connect() (io.WriteCloser, error) {
+	dialer := &net.Dialer{Timeout: dialTimeout}
+
+	var conn net.Conn
+	var err error
+	if w.tlsConfig != nil {
+		conn, err = tls.DialWithDialer(dialer, "tcp", w.address, w.tlsConfig)
+	} else {
+		conn, err = dialer.Dial("tcp", w.address)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("connect to %s: %w", w.address, err)
+	}
+
+	return conn, nil
+}
+
+// Close closes the connection to the collector, interrupting any write being retried.
+func (w *Writer) Close() error {
+	return w.sink.Close()
+}
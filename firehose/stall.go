@@ -0,0 +1,128 @@
+package firehose
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/metrics"
+)
+
+var emissionLagGauge = metrics.NewRegisteredGauge("firehose/emission/lag", nil)
+
+// emissionLagCheckInterval is the interval at which the emission lag is sampled.
+const emissionLagCheckInterval = 5 * time.Second
+
+// stallWebhookTimeout bounds the time spent notifying the stall webhook.
+const stallWebhookTimeout = 10 * time.Second
+
+// StallAlarmConfig configures the alarm raised when the Firehose output falls behind the
+// imported chain head.
+type StallAlarmConfig struct {
+	// MaxLag is the amount of blocks the emission can lag behind the imported head before
+	// being considered stalled, 0 disables the alarm (the lag metric is still tracked).
+	MaxLag uint64
+
+	// Duration is the time the lag must stay above MaxLag before the alarm is raised.
+	Duration time.Duration
+
+	// Webhook is an URL receiving a JSON POST request when the alarm is raised and when
+	// the emission recovers, optional.
+	Webhook string
+}
+
+// StallAlert is the body posted to the stall webhook.
+type StallAlert struct {
+	Stalled       bool   `json:"stalled"`
+	ImportedBlock uint64 `json:"imported_block"`
+	CurrentBlock  uint64 `json:"current_block"`
+	EmissionLag   uint64 `json:"emission_lag"`
+	Since         int64  `json:"since"`
+}
+
+// StartEmissionLagMonitor periodically samples the emission lag, the amount of blocks
+// imported but not yet flushed to the Firehose output, exposing it as the
+// `firehose/emission/lag` metric and raising the stall alarm according to `config`. Blocks
+// below the start block are not emitted by design, the alarm is not raised while the
+// imported head is below it.
+func StartEmissionLagMonitor(config StallAlarmConfig) {
+	monitor := &emissionLagMonitor{config: config, client: &http.Client{Timeout: stallWebhookTimeout}}
+
+	go func() {
+		for now := range time.Tick(emissionLagCheckInterval) {
+			monitor.check(CurrentHealth(), now)
+		}
+	}()
+}
+
+type emissionLagMonitor struct {
+	config StallAlarmConfig
+	client *http.Client
+
+	exceededSince time.Time
+	alarmed       bool
+}
+
+// check samples the emission lag of `health`, it returns the alert sent if any.
+func (m *emissionLagMonitor) check(health Health, now time.Time) *StallAlert {
+	emissionLagGauge.Update(int64(health.EmissionLag))
+
+	if m.config.MaxLag == 0 {
+		return nil
+	}
+
+	if health.EmissionLag <= m.config.MaxLag || health.ImportedBlock < StartBlockNumber {
+		m.exceededSince = time.Time{}
+		if !m.alarmed {
+			return nil
+		}
+
+		m.alarmed = false
+		log.Info("Firehose block emission recovered", "imported", health.ImportedBlock, "current", health.CurrentBlock, "lag", health.EmissionLag)
+		return m.alert(&StallAlert{ImportedBlock: health.ImportedBlock, CurrentBlock: health.CurrentBlock, EmissionLag: health.EmissionLag, Since: now.Unix()})
+	}
+
+	if m.exceededSince.IsZero() {
+		m.exceededSince = now
+	}
+	if m.alarmed || now.Sub(m.exceededSince) < m.config.Duration {
+		return nil
+	}
+
+	m.alarmed = true
+	log.Error("Firehose block emission stalled", "imported", health.ImportedBlock, "current", health.CurrentBlock, "lag", health.EmissionLag,
+		"max", m.config.MaxLag, "since", m.exceededSince)
+	return m.alert(&StallAlert{Stalled: true, ImportedBlock: health.ImportedBlock, CurrentBlock: health.CurrentBlock, EmissionLag: health.EmissionLag, Since: m.exceededSince.Unix()})
+}
+
+func (m *emissionLagMonitor) alert(alert *StallAlert) *StallAlert {
+	if m.config.Webhook != "" {
+		go func() {
+			if err := m.notify(alert); err != nil {
+				log.Warn("Failed to notify Firehose stall webhook", "url", m.config.Webhook, "err", err)
+			}
+		}()
+	}
+	return alert
+}
+
+func (m *emissionLagMonitor) notify(alert *StallAlert) error {
+	body, err := json.Marshal(alert)
+	if err != nil {
+		return err
+	}
+
+	resp, err := m.client.Post(m.config.Webhook, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook responded with status %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+package firehose
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestEmissionLagMonitor(t *testing.T) {
+	alerts := make(chan StallAlert, 2)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var alert StallAlert
+		if err := json.NewDecoder(r.Body).Decode(&alert); err != nil {
+			t.Error(err)
+		}
+		alerts <- alert
+	}))
+	defer server.Close()
+
+	monitor := &emissionLagMonitor{
+		config: StallAlarmConfig{MaxLag: 10, Duration: time.Minute, Webhook: server.URL},
+		client: server.Client(),
+	}
+
+	start := time.Unix(1000, 0)
+	lagging := Health{ImportedBlock: 100, CurrentBlock: 80, EmissionLag: 20}
+
+	if alert := monitor.check(Health{ImportedBlock: 100, CurrentBlock: 95, EmissionLag: 5}, start); alert != nil {
+		t.Fatalf("unexpected alert %+v below max lag", alert)
+	}
+	if alert := monitor.check(lagging, start); alert != nil {
+		t.Fatalf("unexpected alert %+v as soon as lag is exceeded", alert)
+	}
+	if alert := monitor.check(lagging, start.Add(30*time.Second)); alert != nil {
+		t.Fatalf("unexpected alert %+v before duration elapsed", alert)
+	}
+
+	alert := monitor.check(lagging, start.Add(time.Minute))
+	if alert == nil || !alert.Stalled || alert.EmissionLag != 20 || alert.Since != start.Unix() {
+		t.Fatalf("unexpected stall alert %+v", alert)
+	}
+	if received := <-alerts; received != *alert {
+		t.Fatalf("webhook received %+v, want %+v", received, *alert)
+	}
+
+	if alert := monitor.check(lagging, start.Add(2*time.Minute)); alert != nil {
+		t.Fatalf("unexpected alert %+v while already stalled", alert)
+	}
+
+	alert = monitor.check(Health{ImportedBlock: 101, CurrentBlock: 101}, start.Add(3*time.Minute))
+	if alert == nil || alert.Stalled {
+		t.Fatalf("unexpected recovery alert %+v", alert)
+	}
+	if received := <-alerts; received.Stalled || received.ImportedBlock != 101 {
+		t.Fatalf("webhook received unexpected recovery %+v", received)
+	}
+}
@@ -0,0 +1,251 @@
+package firehose
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math/bits"
+	"sort"
+	"strconv"
+)
+
+// StatsUsage is the amount of lines and bytes of an event or a family of events.
+type StatsUsage struct {
+	Count uint64
+	Bytes uint64
+}
+
+// StatsHistogram is a histogram whose buckets are powers of two, bucket `i` counting the
+// values in `[2^(i-1), 2^i)` (bucket 0 counting the zeros).
+type StatsHistogram struct {
+	Buckets []uint64
+	Total   uint64
+	Sum     uint64
+	Max     uint64
+}
+
+func (h *StatsHistogram) add(value uint64) {
+	bucket := bits.Len64(value)
+	for len(h.Buckets) <= bucket {
+		h.Buckets = append(h.Buckets, 0)
+	}
+
+	h.Buckets[bucket]++
+	h.Total++
+	h.Sum += value
+	if value > h.Max {
+		h.Max = value
+	}
+}
+
+// BucketBounds returns the inclusive lower and exclusive upper bounds of bucket `i`.
+func (h *StatsHistogram) BucketBounds(i int) (uint64, uint64) {
+	if i == 0 {
+		return 0, 1
+	}
+	return 1 << uint(i-1), 1 << uint(i)
+}
+
+// StatsTransaction is the size of a transaction in the log.
+type StatsTransaction struct {
+	Block   uint64
+	Hash    string
+	Bytes   uint64
+	GasUsed uint64
+}
+
+// StatsCall is the size of a call in the log, its bytes being those of the events emitted
+// while it was the active call, its sub-calls' events excluded.
+type StatsCall struct {
+	Block     uint64
+	TrxHash   string
+	CallIndex string
+	Bytes     uint64
+}
+
+// StatsReport summarizes the content of a Firehose log, see `Stats`.
+type StatsReport struct {
+	Lines        uint64
+	Bytes        uint64
+	Blocks       uint64
+	Transactions uint64
+	Calls        uint64
+
+	// Families and Events give the usage per event family (see `eventFamilies`) and per
+	// event, lines that are not Firehose events are accounted under the `non-firehose` family
+	Families map[string]*StatsUsage
+	Events   map[string]*StatsUsage
+
+	BlockBytes       StatsHistogram
+	TransactionBytes StatsHistogram
+	TransactionGas   StatsHistogram
+
+	// LargestTransactions and LargestCalls are the largest ones in bytes, largest first
+	LargestTransactions []StatsTransaction
+	LargestCalls        []StatsCall
+}
+
+const nonFirehoseFamily = "non-firehose"
+
+// Stats reads the Firehose log from `reader` and summarizes it: line and byte usage per
+// event family and event, block and transaction size histograms, transaction gas
+// distribution and the `top` largest transactions and calls. It helps deciding which
+// optional event families are worth disabling for a given workload.
+func Stats(reader io.Reader, top int) (*StatsReport, error) {
+	stats := &statsCollector{
+		top: top,
+		report: &StatsReport{
+			Families: map[string]*StatsUsage{},
+			Events:   map[string]*StatsUsage{},
+		},
+	}
+
+	scanner := bufio.NewScanner(reader)
+	// Some lines (code changes, end block) can be huge, give plenty of room to the scanner
+	scanner.Buffer(make([]byte, 0, 1024*1024), 512*1024*1024)
+
+	for scanner.Scan() {
+		stats.report.Lines++
+		stats.collectLine(scanner.Text())
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("read line %d: %w", stats.report.Lines+1, err)
+	}
+
+	return stats.report, nil
+}
+
+type statsCollector struct {
+	report *StatsReport
+	top    int
+
+	block      uint64
+	blockBytes uint64
+	inBlock    bool
+	trx        *StatsTransaction
+	calls      []*StatsCall
+}
+
+func (s *statsCollector) collectLine(line string) {
+	size := uint64(len(line)) + 1
+	s.report.Bytes += size
+
+	event, fields, ok := splitLine(line)
+	if !ok {
+		usage(s.report.Families, nonFirehoseFamily, size)
+		return
+	}
+
+	usage(s.report.Families, eventFamily(event), size)
+	usage(s.report.Events, event, size)
+
+	switch event {
+	case "BEGIN_BLOCK":
+		s.report.Blocks++
+		s.inBlock = true
+		s.blockBytes = 0
+		if len(fields) > 0 {
+			s.block, _ = strconv.ParseUint(fields[0], 10, 64)
+		}
+
+	case "BEGIN_APPLY_TRX":
+		s.report.Transactions++
+		s.trx = &StatsTransaction{Block: s.block}
+		if len(fields) > 0 {
+			s.trx.Hash = fields[0]
+		}
+
+	case "EVM_RUN_CALL":
+		s.report.Calls++
+		call := &StatsCall{Block: s.block}
+		if s.trx != nil {
+			call.TrxHash = s.trx.Hash
+		}
+		if len(fields) > 1 {
+			call.CallIndex = fields[1]
+		}
+		s.calls = append(s.calls, call)
+	}
+
+	if s.inBlock {
+		s.blockBytes += size
+	}
+	if s.trx != nil {
+		s.trx.Bytes += size
+	}
+	if len(s.calls) > 0 {
+		s.calls[len(s.calls)-1].Bytes += size
+	}
+
+	switch event {
+	case "EVM_END_CALL":
+		if len(s.calls) > 0 {
+			s.addLargestCall(*s.calls[len(s.calls)-1])
+			s.calls = s.calls[:len(s.calls)-1]
+		}
+
+	case "END_APPLY_TRX":
+		if s.trx == nil {
+			break
+		}
+		if len(fields) > 0 {
+			s.trx.GasUsed, _ = strconv.ParseUint(fields[0], 10, 64)
+		}
+
+		s.report.TransactionBytes.add(s.trx.Bytes)
+		s.report.TransactionGas.add(s.trx.GasUsed)
+		s.addLargestTransaction(*s.trx)
+		s.trx = nil
+		s.calls = nil
+
+	case "END_BLOCK", "CANCEL_BLOCK":
+		if s.inBlock {
+			s.report.BlockBytes.add(s.blockBytes)
+		}
+		s.inBlock = false
+		s.trx = nil
+		s.calls = nil
+	}
+}
+
+func usage(usages map[string]*StatsUsage, key string, size uint64) {
+	entry, found := usages[key]
+	if !found {
+		entry = &StatsUsage{}
+		usages[key] = entry
+	}
+
+	entry.Count++
+	entry.Bytes += size
+}
+
+func (s *statsCollector) addLargestTransaction(trx StatsTransaction) {
+	largest := &s.report.LargestTransactions
+	i := sort.Search(len(*largest), func(i int) bool { return (*largest)[i].Bytes < trx.Bytes })
+	if i >= s.top {
+		return
+	}
+
+	*largest = append(*largest, StatsTransaction{})
+	copy((*largest)[i+1:], (*largest)[i:])
+	(*largest)[i] = trx
+	if len(*largest) > s.top {
+		*largest = (*largest)[:s.top]
+	}
+}
+
+func (s *statsCollector) addLargestCall(call StatsCall) {
+	largest := &s.report.LargestCalls
+	i := sort.Search(len(*largest), func(i int) bool { return (*largest)[i].Bytes < call.Bytes })
+	if i >= s.top {
+		return
+	}
+
+	*largest = append(*largest, StatsCall{})
+	copy((*largest)[i+1:], (*largest)[i:])
+	(*largest)[i] = call
+	if len(*largest) > s.top {
+		*largest = (*largest)[:s.top]
+	}
+}
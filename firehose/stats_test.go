@@ -0,0 +1,62 @@
+package firehose
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestStats(t *testing.T) {
+	log := strings.Join([]string{
+		"INFO [01-01|00:00:00.000] Starting node",
+		"FIRE BEGIN_BLOCK 1",
+		"FIRE BEGIN_APPLY_TRX aa",
+		"FIRE EVM_RUN_CALL CALL 1",
+		"FIRE EVM_RUN_CALL CALL 2",
+		"FIRE EVM_END_CALL 2 0",
+		"FIRE EVM_END_CALL 1 0",
+		"FIRE END_APPLY_TRX 21000",
+		"FIRE BEGIN_APPLY_TRX bb",
+		"FIRE END_APPLY_TRX 50000",
+		"FIRE END_BLOCK 1",
+	}, "\n") + "\n"
+
+	report, err := Stats(strings.NewReader(log), 1)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if report.Lines != 11 || report.Bytes != uint64(len(log)) {
+		t.Errorf("unexpected totals, have %d lines and %d bytes", report.Lines, report.Bytes)
+	}
+	if report.Blocks != 1 || report.Transactions != 2 || report.Calls != 2 {
+		t.Errorf("unexpected counts, have %d blocks, %d transactions and %d calls", report.Blocks, report.Transactions, report.Calls)
+	}
+
+	if usage := report.Families[nonFirehoseFamily]; usage == nil || usage.Count != 1 {
+		t.Errorf("expected non-Firehose line to be accounted, have %+v", usage)
+	}
+	if usage := report.Events["EVM_RUN_CALL"]; usage == nil || usage.Count != 2 || usage.Bytes != uint64(2*len("FIRE EVM_RUN_CALL CALL 1\n")) {
+		t.Errorf("unexpected EVM_RUN_CALL usage %+v", usage)
+	}
+
+	if report.BlockBytes.Total != 1 || report.TransactionBytes.Total != 2 {
+		t.Errorf("unexpected histogram totals, have %d blocks and %d transactions", report.BlockBytes.Total, report.TransactionBytes.Total)
+	}
+	if report.TransactionGas.Sum != 71000 || report.TransactionGas.Max != 50000 {
+		t.Errorf("unexpected gas distribution, have sum %d and max %d", report.TransactionGas.Sum, report.TransactionGas.Max)
+	}
+
+	if len(report.LargestTransactions) != 1 || report.LargestTransactions[0].Hash != "aa" || report.LargestTransactions[0].GasUsed != 21000 {
+		t.Errorf("unexpected largest transactions %+v", report.LargestTransactions)
+	}
+
+	// The outer call's bytes exclude those of its sub-call, which are the same size but
+	// attributed to the sub-call, the first one ending wins the tie
+	if len(report.LargestCalls) != 1 || report.LargestCalls[0].CallIndex != "2" {
+		t.Errorf("unexpected largest calls %+v", report.LargestCalls)
+	}
+
+	if low, high := report.TransactionGas.BucketBounds(16); low != 32768 || high != 65536 {
+		t.Errorf("unexpected bucket bounds [%d, %d)", low, high)
+	}
+}
@@ -0,0 +1,153 @@
+package firehose
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// Event is a Firehose event delivered in-process to the subscribers of a context, see
+// `Context.Subscribe`. The fields are the printed ones, in the order of the event registry.
+type Event struct {
+	Name   string
+	Fields []string
+}
+
+// Field returns the value of the field `name` as named in the event registry, `false` if the
+// event has no such field.
+func (e Event) Field(name string) (string, bool) {
+	schema, found := eventSchemas[e.Name]
+	if !found {
+		return "", false
+	}
+
+	for i, field := range schema.fields {
+		if field == name && i < len(e.Fields) {
+			return e.Fields[i], true
+		}
+	}
+
+	return "", false
+}
+
+// Subscribe delivers every event emitted by the context to `ch`, alongside printing it, so
+// that Go programs embedding the node can build live indexes without parsing the text
+// output. Transaction events are delivered when the transaction is flushed to the context.
+// When the context was created with a `nil` printer, the events are only delivered.
+//
+// The delivery is synchronous, an event is in the channel (or received) once the call
+// emitting it returned: a subscriber seeing END_BLOCK has seen the whole block. A slow
+// subscriber slows the instrumentation down, it's up to it to buffer the channel.
+func (ctx *Context) Subscribe(ch chan<- Event) {
+	if ctx == nil {
+		return
+	}
+
+	printer, ok := ctx.printer.(*subscriptionPrinter)
+	if !ok {
+		printer = &subscriptionPrinter{delegate: ctx.printer}
+		ctx.printer = printer
+	}
+
+	printer.lock.Lock()
+	printer.channels = append(printer.channels, ch)
+	printer.lock.Unlock()
+}
+
+// Unsubscribe stops the delivery of events to `ch`, it's not closed.
+func (ctx *Context) Unsubscribe(ch chan<- Event) {
+	if ctx == nil {
+		return
+	}
+
+	if printer, ok := ctx.printer.(*subscriptionPrinter); ok {
+		printer.lock.Lock()
+		defer printer.lock.Unlock()
+
+		for i, candidate := range printer.channels {
+			if candidate == ch {
+				printer.channels = append(printer.channels[:i:i], printer.channels[i+1:]...)
+				return
+			}
+		}
+	}
+}
+
+// setPrinter changes the printer of the context, keeping its subscriptions.
+func (ctx *Context) setPrinter(printer Printer) {
+	attachPrinter(printer, ctx)
+
+	if subscriptions, ok := ctx.printer.(*subscriptionPrinter); ok {
+		subscriptions.delegate = printer
+		return
+	}
+
+	ctx.printer = printer
+}
+
+// bufferPrinter returns the buffer printer of a speculative context, `false` for the other
+// contexts.
+func (ctx *Context) bufferPrinter() (*ToBufferPrinter, bool) {
+	printer := ctx.printer
+	if subscriptions, ok := printer.(*subscriptionPrinter); ok {
+		printer = subscriptions.delegate
+	}
+
+	v, ok := printer.(*ToBufferPrinter)
+	return v, ok
+}
+
+// subscriptionPrinter delivers the events printed through it to its channels after printing
+// them through its delegate, if any.
+type subscriptionPrinter struct {
+	delegate Printer
+
+	lock     sync.RWMutex
+	channels []chan<- Event
+}
+
+func (p *subscriptionPrinter) Print(input ...string) {
+	if p.delegate != nil {
+		p.delegate.Print(input...)
+	}
+
+	if len(input) > 0 {
+		p.deliver(Event{Name: input[0], Fields: input[1:]})
+	}
+}
+
+func (p *subscriptionPrinter) PrintRaw(lines string) {
+	if v, ok := p.delegate.(RawPrinter); ok {
+		v.PrintRaw(lines)
+	} else if p.delegate != nil {
+		fmt.Print(lines)
+	}
+
+	p.deliverLines(lines)
+}
+
+func (p *subscriptionPrinter) PrintSegment(header []string, lines string) {
+	if p.delegate != nil {
+		printSegment(p.delegate, header, lines)
+	}
+
+	p.deliver(Event{Name: header[0], Fields: append(header[1:len(header):len(header)], Uint64(uint64(len(lines))))})
+	p.deliverLines(lines)
+}
+
+func (p *subscriptionPrinter) deliverLines(lines string) {
+	for _, line := range strings.SplitAfter(lines, "\n") {
+		if event, fields, ok := splitLine(line); ok {
+			p.deliver(Event{Name: event, Fields: fields})
+		}
+	}
+}
+
+func (p *subscriptionPrinter) deliver(event Event) {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+
+	for _, ch := range p.channels {
+		ch <- event
+	}
+}
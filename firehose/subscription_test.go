@@ -0,0 +1,65 @@
+package firehose
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+func TestSubscribe(t *testing.T) {
+	block := types.NewBlockWithHeader(&types.Header{Number: big.NewInt(7)})
+
+	events := make(chan Event, 16)
+	ctx := NewContext(nil)
+	ctx.Subscribe(events)
+
+	txContext := NewSpeculativeExecutionContext(1024)
+	txContext.printer.Print("TRX_FROM", "aa")
+
+	ctx.StartBlock(block)
+	ctx.FlushTransaction(txContext)
+	ctx.EndBlock(block, nil)
+
+	var names []string
+	for len(events) > 0 {
+		event := <-events
+		names = append(names, event.Name)
+
+		if event.Name == "BEGIN_BLOCK" {
+			if number, ok := event.Field("number"); !ok || number != "7" {
+				t.Errorf("expected BEGIN_BLOCK number field 7, got %q", number)
+			}
+		}
+		if event.Name == "TRX_FROM" {
+			if from, ok := event.Field("from"); !ok || from != "aa" {
+				t.Errorf("expected flushed TRX_FROM from field aa, got %q", from)
+			}
+		}
+	}
+
+	if len(names) != 3 || names[0] != "BEGIN_BLOCK" || names[1] != "TRX_FROM" || names[2] != "END_BLOCK" {
+		t.Fatalf("unexpected events %v", names)
+	}
+
+	ctx.Unsubscribe(events)
+	ctx.StartBlock(block)
+	if len(events) != 0 {
+		t.Errorf("unexpected event delivered after unsubscribe")
+	}
+}
+
+func TestSubscribeSpeculativeContext(t *testing.T) {
+	events := make(chan Event, 1)
+	ctx := NewSpeculativeExecutionContext(1024)
+	ctx.Subscribe(events)
+
+	ctx.printer.Print("TRX_FROM", "aa")
+	if !bytes.Equal(ctx.FirehoseLog(), []byte("FIRE TRX_FROM aa\n")) {
+		t.Errorf("expected the speculative log to be kept, got %q", ctx.FirehoseLog())
+	}
+	if event := <-events; event.Name != "TRX_FROM" {
+		t.Errorf("unexpected event %v", event)
+	}
+}
@@ -39,11 +39,10 @@ func (s *ExtendedStack) MustPeek() string {
 //                regex `BalanceChangeReason\("[a-z0-9_]+"\)`. All other values that should not
 //                be matched can be defined here using `var X BalanceChangeReason = "something"`
 //                since does not match the above regexp.
+//
+//                All valid values are registered in `reasons.go`, use the constants defined there.
 type BalanceChangeReason string
 
-// IgnoredBalanceChangeReason **On purposely defined using a different syntax, check `BalanceChangeReason` type doc above**
-var IgnoredBalanceChangeReason BalanceChangeReason = "ignored"
-
 // GasChangeReason denotes a reason why a given gas cost was incurred for an operation.
 //
 // **Important!** For easier extraction of all possible `GasChangeReason`, ensure you always
@@ -51,13 +50,28 @@ var IgnoredBalanceChangeReason BalanceChangeReason = "ignored"
 //                regex `GasChangeReason\("[a-z0-9_]+"\)`. All other values that should not
 //                be matched can be defined here using `var X GasChangeReason = "something"`
 //                since does not match the above regexp.
+//
+//                All valid values are registered in `reasons.go`, use the constants defined there.
 type GasChangeReason string
 
-// RefundAfterExecutionGasChangeReason to be used for all gas refund operation
-var RefundAfterExecutionGasChangeReason = GasChangeReason("refund_after_execution")
+// CallFailureCode denotes a stable code identifying why a given call failed, printed
+// alongside the free-form reason which wording changes between versions.
+//
+// **Important!** For easier extraction of all possible `CallFailureCode`, ensure you always
+//                define valid value using the type wrapper so it matches the extraction
+//                regex `CallFailureCode\("[a-z0-9_]+"\)`.
+type CallFailureCode string
+
+// UnknownCallFailureCode to be used when the failure reason could not be mapped to a known code
+var UnknownCallFailureCode = CallFailureCode("unknown")
 
-// FailedExecutionGasChangeReason to be used for all call failure remaining gas burning operation
-var FailedExecutionGasChangeReason = GasChangeReason("failed_execution")
+// SkippedTransactionReason denotes the pre-check failure classification of a transaction
+// that was skipped by the execution.
+//
+// **Important!** For easier extraction of all possible `SkippedTransactionReason`, ensure you always
+//                define valid value using the type wrapper so it matches the extraction
+//                regex `SkippedTransactionReason\("[a-z0-9_]+"\)`.
+type SkippedTransactionReason string
 
-// IgnoredGasChangeReason **On purposely defined using a different syntax, check `GasChangeReason` type doc above**
-var IgnoredGasChangeReason GasChangeReason = "ignored"
+// UnknownSkippedTransactionReason to be used when the failure could not be mapped to a known reason
+var UnknownSkippedTransactionReason = SkippedTransactionReason("unknown")
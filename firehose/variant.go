@@ -0,0 +1,136 @@
+package firehose
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// VariantCapabilities are the fork-specific features of a chain variant as bits, readers
+// receive them along the variant so that they don't have to hard-code them per variant.
+type VariantCapabilities uint32
+
+const (
+	// UnclesCapability is set when blocks can reference uncles.
+	UnclesCapability VariantCapabilities = 1 << iota
+	// TotalDifficultyCapability is set when the chain's total difficulty is meaningful.
+	TotalDifficultyCapability
+	// SystemTransactionsCapability is set when the protocol injects transactions in blocks
+	// (see `IsSystemTransaction`).
+	SystemTransactionsCapability
+	// SystemCallsCapability is set when the consensus engine performs calls outside of any
+	// transaction (validator set updates, reward distribution, ...).
+	SystemCallsCapability
+	// StateSyncsCapability is set when blocks carry state sync events bridged from a parent
+	// chain.
+	StateSyncsCapability
+)
+
+var capabilityNames = []struct {
+	capability VariantCapabilities
+	name       string
+}{
+	{UnclesCapability, "uncles"},
+	{TotalDifficultyCapability, "total_difficulty"},
+	{SystemTransactionsCapability, "system_transactions"},
+	{SystemCallsCapability, "system_calls"},
+	{StateSyncsCapability, "state_syncs"},
+}
+
+// Has returns `true` if all the `capabilities` bits are set.
+func (c VariantCapabilities) Has(capabilities VariantCapabilities) bool {
+	return c&capabilities == capabilities
+}
+
+// Names returns the name of each capability set, in bit order.
+func (c VariantCapabilities) Names() []string {
+	names := []string{}
+	for _, entry := range capabilityNames {
+		if c.Has(entry.capability) {
+			names = append(names, entry.name)
+		}
+	}
+
+	return names
+}
+
+// Variant is a chain variant the instrumentation can be built for, its name is the one
+// printed in the INIT event.
+type Variant struct {
+	Name         string
+	Capabilities VariantCapabilities
+}
+
+// Known chain variants, the variant of a build is selected through `params.Variant` and
+// validated at startup by `SetVariant`.
+var (
+	GethVariant     = Variant{"geth", UnclesCapability | TotalDifficultyCapability}
+	BorVariant      = Variant{"bor", TotalDifficultyCapability | SystemCallsCapability | StateSyncsCapability}
+	CongressVariant = Variant{"congress", TotalDifficultyCapability | SystemTransactionsCapability | SystemCallsCapability}
+	OperaVariant    = Variant{"opera", SystemTransactionsCapability | SystemCallsCapability}
+)
+
+var variants = map[string]Variant{
+	GethVariant.Name:     GethVariant,
+	BorVariant.Name:      BorVariant,
+	CongressVariant.Name: CongressVariant,
+	OperaVariant.Name:    OperaVariant,
+}
+
+// ActiveVariant is the variant of the running node, see `SetVariant`.
+var ActiveVariant = GethVariant
+
+// LookupVariant returns the known variant named `name`.
+func LookupVariant(name string) (Variant, error) {
+	variant, found := variants[name]
+	if !found {
+		return Variant{}, fmt.Errorf("unknown chain variant %q, known variants are geth, bor, congress and opera", name)
+	}
+
+	return variant, nil
+}
+
+// SetVariant validates and activates the variant named `name`, it must be called at
+// initialization time, before the stream header is emitted.
+func SetVariant(name string) error {
+	variant, err := LookupVariant(name)
+	if err != nil {
+		return err
+	}
+
+	ActiveVariant = variant
+	return nil
+}
+
+// BlockConsensusMetadata, when set, gives the consensus specific metadata of a block, printed
+// under the `consensus` key of the END_BLOCK metadata (epoch and event IDs of Lachesis based
+// chains, ...). Chains whose blocks carry such metadata set it at initialization time, there
+// is none on this chain so it's `nil` by default.
+var BlockConsensusMetadata func(block *types.Block) map[string]interface{}
+
+// endBlockMeta returns the END_BLOCK metadata of the block according to the active variant:
+// the uncles and the total difficulty are only part of it when the variant has the matching
+// capability, readers knowing which from the variant announced in INIT.
+func endBlockMeta(block *types.Block, totalDifficulty *big.Int) map[string]interface{} {
+	meta := map[string]interface{}{
+		"header": block.Header(),
+	}
+
+	if ActiveVariant.Capabilities.Has(UnclesCapability) {
+		meta["uncles"] = block.Body().Uncles
+	}
+
+	if ActiveVariant.Capabilities.Has(TotalDifficultyCapability) {
+		meta["totalDifficulty"] = (*hexutil.Big)(totalDifficulty)
+	}
+
+	if BlockConsensusMetadata != nil {
+		if consensus := BlockConsensusMetadata(block); len(consensus) > 0 {
+			meta["consensus"] = consensus
+		}
+	}
+
+	return meta
+}
@@ -0,0 +1,62 @@
+package firehose
+
+import (
+	"math/big"
+	"reflect"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+func TestLookupVariant(t *testing.T) {
+	for name, variant := range variants {
+		found, err := LookupVariant(name)
+		if err != nil || found != variant || found.Name != name {
+			t.Errorf("variant %q: expected %v, got %v (err %v)", name, variant, found, err)
+		}
+	}
+
+	if _, err := LookupVariant("Geth"); err == nil {
+		t.Errorf("expected variant names to be validated as-is")
+	}
+}
+
+func TestVariantCapabilities(t *testing.T) {
+	if !GethVariant.Capabilities.Has(UnclesCapability) || BorVariant.Capabilities.Has(UnclesCapability) {
+		t.Errorf("unexpected uncles capability")
+	}
+
+	names := BorVariant.Capabilities.Names()
+	if expected := []string{"total_difficulty", "system_calls", "state_syncs"}; !reflect.DeepEqual(names, expected) {
+		t.Errorf("expected capabilities %v, got %v", expected, names)
+	}
+}
+
+func TestEndBlockMetaVariant(t *testing.T) {
+	defer func(variant Variant, metadata func(block *types.Block) map[string]interface{}) {
+		ActiveVariant, BlockConsensusMetadata = variant, metadata
+	}(ActiveVariant, BlockConsensusMetadata)
+
+	block := types.NewBlockWithHeader(&types.Header{Number: big.NewInt(1)})
+
+	ActiveVariant = GethVariant
+	if meta := endBlockMeta(block, big.NewInt(1)); meta["uncles"] == nil || meta["totalDifficulty"] == nil || meta["consensus"] != nil {
+		t.Errorf("unexpected geth END_BLOCK metadata %v", meta)
+	}
+
+	ActiveVariant = OperaVariant
+	BlockConsensusMetadata = func(block *types.Block) map[string]interface{} {
+		return map[string]interface{}{"epoch": 7, "events": []string{"0x01"}}
+	}
+
+	meta := endBlockMeta(block, big.NewInt(1))
+	if _, found := meta["uncles"]; found {
+		t.Errorf("expected no uncles for a variant without uncles")
+	}
+	if _, found := meta["totalDifficulty"]; found {
+		t.Errorf("expected no total difficulty for a variant without total difficulty")
+	}
+	if consensus, ok := meta["consensus"].(map[string]interface{}); !ok || consensus["epoch"] != 7 {
+		t.Errorf("unexpected consensus metadata %v", meta["consensus"])
+	}
+}
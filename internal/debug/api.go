@@ -34,6 +34,7 @@ import (
 	"sync"
 	"time"
 
+	"github.com/ethereum/go-ethereum/firehose"
 	"github.com/ethereum/go-ethereum/log"
 )
 
@@ -49,18 +50,111 @@ type HandlerT struct {
 	cpuFile   string
 	traceW    io.WriteCloser
 	traceFile string
+
+	logMu           sync.Mutex
+	verbosityTimer  *time.Timer
+	verbosityRevert log.Lvl
+	vmoduleTimer    *time.Timer
+	vmoduleRevert   string
 }
 
 // Verbosity sets the log verbosity ceiling. The verbosity of individual packages
-// and source files can be raised using Vmodule.
-func (*HandlerT) Verbosity(level int) {
+// and source files can be raised using Vmodule. A pending revert scheduled by
+// SetVerbosityForDuration is cancelled.
+func (h *HandlerT) Verbosity(level int) {
+	h.logMu.Lock()
+	defer h.logMu.Unlock()
+
+	stopTimer(&h.verbosityTimer)
 	glogger.Verbosity(log.Lvl(level))
 }
 
+// SetVerbosityForDuration sets the log verbosity ceiling for nsec nanoseconds,
+// the previous verbosity being restored afterwards. Calling it again before the
+// revert extends the temporary verbosity, the verbosity in place before the
+// first call being the one restored.
+func (h *HandlerT) SetVerbosityForDuration(level int, nsec uint) {
+	h.logMu.Lock()
+	defer h.logMu.Unlock()
+
+	previous := glogger.GetVerbosity()
+	if h.verbosityTimer != nil {
+		h.verbosityTimer.Stop()
+		previous = h.verbosityRevert
+	}
+	h.verbosityRevert = previous
+
+	glogger.Verbosity(log.Lvl(level))
+	log.Info("Log verbosity changed temporarily", "level", level, "revert", previous, "duration", time.Duration(nsec))
+
+	var timer *time.Timer
+	timer = time.AfterFunc(time.Duration(nsec), func() {
+		h.logMu.Lock()
+		defer h.logMu.Unlock()
+
+		// Superseded by a later call while waiting for the lock
+		if h.verbosityTimer != timer {
+			return
+		}
+		glogger.Verbosity(previous)
+		h.verbosityTimer = nil
+		log.Info("Log verbosity reverted", "level", previous)
+	})
+	h.verbosityTimer = timer
+}
+
 // Vmodule sets the log verbosity pattern. See package log for details on the
-// pattern syntax.
-func (*HandlerT) Vmodule(pattern string) error {
-	return glogger.Vmodule(pattern)
+// pattern syntax. A pending revert scheduled by SetVmoduleForDuration is cancelled.
+func (h *HandlerT) Vmodule(pattern string) error {
+	h.logMu.Lock()
+	defer h.logMu.Unlock()
+
+	if err := glogger.Vmodule(pattern); err != nil {
+		return err
+	}
+	stopTimer(&h.vmoduleTimer)
+	return nil
+}
+
+// SetVmoduleForDuration sets the log verbosity pattern for nsec nanoseconds, the
+// previous pattern being restored afterwards, see SetVerbosityForDuration.
+func (h *HandlerT) SetVmoduleForDuration(pattern string, nsec uint) error {
+	h.logMu.Lock()
+	defer h.logMu.Unlock()
+
+	previous := glogger.GetVmodule()
+	if err := glogger.Vmodule(pattern); err != nil {
+		return err
+	}
+	if h.vmoduleTimer != nil {
+		h.vmoduleTimer.Stop()
+		previous = h.vmoduleRevert
+	}
+	h.vmoduleRevert = previous
+	log.Info("Log vmodule changed temporarily", "pattern", pattern, "revert", previous, "duration", time.Duration(nsec))
+
+	var timer *time.Timer
+	timer = time.AfterFunc(time.Duration(nsec), func() {
+		h.logMu.Lock()
+		defer h.logMu.Unlock()
+
+		// Superseded by a later call while waiting for the lock
+		if h.vmoduleTimer != timer {
+			return
+		}
+		glogger.Vmodule(previous)
+		h.vmoduleTimer = nil
+		log.Info("Log vmodule reverted", "pattern", previous)
+	})
+	h.vmoduleTimer = timer
+	return nil
+}
+
+func stopTimer(timer **time.Timer) {
+	if *timer != nil {
+		(*timer).Stop()
+		*timer = nil
+	}
 }
 
 // BacktraceAt sets the log backtrace location. See package log for details on
@@ -115,6 +209,46 @@ func (h *HandlerT) StartCPUProfile(file string) error {
 	return nil
 }
 
+// pushedCPUProfile is the name under which the continuous profile pusher holds the CPU
+// profiler, see `profilePusher`.
+const pushedCPUProfile = "<continuous profile push>"
+
+// startPushedCPUProfile starts a CPU profile written to w on behalf of the continuous profile
+// pusher. It returns false without starting anything when another CPU profile is in progress.
+func (h *HandlerT) startPushedCPUProfile(w io.Writer) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.cpuW != nil {
+		return false
+	}
+	if err := pprof.StartCPUProfile(w); err != nil {
+		return false
+	}
+	h.cpuW = nopWriteCloser{w}
+	h.cpuFile = pushedCPUProfile
+	return true
+}
+
+// stopPushedCPUProfile stops the CPU profile started by startPushedCPUProfile. It returns
+// false if it was stopped in the meantime, through the API, its content being incomplete.
+func (h *HandlerT) stopPushedCPUProfile() bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.cpuFile != pushedCPUProfile {
+		return false
+	}
+	pprof.StopCPUProfile()
+	h.cpuW = nil
+	h.cpuFile = ""
+	return true
+}
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error { return nil }
+
 // StopCPUProfile stops an ongoing CPU profile.
 func (h *HandlerT) StopCPUProfile() error {
 	h.mu.Lock()
@@ -207,6 +341,12 @@ func (*HandlerT) SetGCPercent(v int) int {
 	return debug.SetGCPercent(v)
 }
 
+// FirehoseHealth returns the Firehose pipeline health, including the emission lag against
+// the imported chain head.
+func (*HandlerT) FirehoseHealth() firehose.Health {
+	return firehose.CurrentHealth()
+}
+
 func writeProfile(name, file string) error {
 	p := pprof.Lookup(name)
 	log.Info("Writing profile records", "count", p.Count(), "type", name, "dump", file)
@@ -0,0 +1,158 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package debug
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/pprof"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// dumpProfiles are the profiles written by a dump when none are explicitly requested.
+var dumpProfiles = []string{"heap", "goroutine", "allocs"}
+
+// rssCheckInterval is the interval at which the resident set size is compared against the
+// automatic dump threshold.
+const rssCheckInterval = 10 * time.Second
+
+// profileDumper writes profiles to a directory, dumps are serialized so that concurrent
+// requests don't produce interleaved files.
+type profileDumper struct {
+	dir  string
+	lock sync.Mutex
+}
+
+// dump writes each of the profiles to `<dir>/<profile>-<timestamp>.pprof` and returns the
+// path of the written files.
+func (d *profileDumper) dump(profiles []string) ([]string, error) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	if err := os.MkdirAll(d.dir, 0700); err != nil {
+		return nil, err
+	}
+
+	timestamp := time.Now().UTC().Format("20060102T150405.000")
+	paths := make([]string, 0, len(profiles))
+	for _, name := range profiles {
+		profile := pprof.Lookup(name)
+		if profile == nil {
+			return paths, fmt.Errorf("unknown profile %q", name)
+		}
+
+		path := filepath.Join(d.dir, fmt.Sprintf("%s-%s.pprof", name, timestamp))
+		f, err := os.Create(path)
+		if err != nil {
+			return paths, err
+		}
+		err = profile.WriteTo(f, 0)
+		f.Close()
+		if err != nil {
+			return paths, err
+		}
+		paths = append(paths, path)
+	}
+	log.Info("Wrote profile dump", "profiles", strings.Join(profiles, ","), "dir", d.dir)
+	return paths, nil
+}
+
+// dumpHandler writes profiles on request, the request must carry the token either as a
+// bearer `Authorization` header or as the `token` query parameter. The profiles are taken
+// from the comma separated `profiles` query parameter, defaulting to heap, goroutine and
+// allocs. The written paths are returned as a JSON array.
+func dumpHandler(dumper *profileDumper, token string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed, use POST", http.StatusMethodNotAllowed)
+			return
+		}
+
+		provided := r.URL.Query().Get("token")
+		if auth := r.Header.Get("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+			provided = strings.TrimPrefix(auth, "Bearer ")
+		}
+		if subtle.ConstantTimeCompare([]byte(provided), []byte(token)) != 1 {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		profiles := dumpProfiles
+		if requested := r.URL.Query().Get("profiles"); requested != "" {
+			profiles = strings.Split(requested, ",")
+		}
+
+		paths, err := dumper.dump(profiles)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		w.Header().Add("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(paths)
+	})
+}
+
+// watchRSS dumps the default profiles each time the resident set size crosses `threshold`
+// bytes upward, so that a leak is captured once per excursion instead of on every check.
+func watchRSS(dumper *profileDumper, threshold uint64) {
+	above := false
+	for range time.Tick(rssCheckInterval) {
+		rss := residentSetSize()
+		if rss < threshold {
+			above = false
+			continue
+		}
+		if above {
+			continue
+		}
+		above = true
+
+		log.Warn("Resident set size crossed threshold, dumping profiles", "rss", rss, "threshold", threshold)
+		if _, err := dumper.dump(dumpProfiles); err != nil {
+			log.Error("Failed to dump profiles", "err", err)
+		}
+	}
+}
+
+// residentSetSize returns the resident set size of the process, read from procfs when
+// available, falling back to the memory obtained from the OS by the Go runtime otherwise.
+func residentSetSize() uint64 {
+	if statm, err := ioutil.ReadFile("/proc/self/statm"); err == nil {
+		if fields := bytes.Fields(statm); len(fields) > 1 {
+			if pages, err := strconv.ParseUint(string(fields[1]), 10, 64); err == nil {
+				return pages * uint64(os.Getpagesize())
+			}
+		}
+	}
+
+	var stats runtime.MemStats
+	runtime.ReadMemStats(&stats)
+	return stats.Sys
+}
@@ -0,0 +1,111 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package debug
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestProfileDumperDump(t *testing.T) {
+	dir, err := ioutil.TempDir("", "debug-dump")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	dumper := &profileDumper{dir: filepath.Join(dir, "profiles")}
+	paths, err := dumper.dump([]string{"heap", "goroutine"})
+	if err != nil {
+		t.Fatalf("dump failed: %v", err)
+	}
+	if len(paths) != 2 {
+		t.Fatalf("dumped profile count mismatch: have %d, want 2", len(paths))
+	}
+	for i, name := range []string{"heap", "goroutine"} {
+		if !strings.HasPrefix(filepath.Base(paths[i]), name+"-") {
+			t.Errorf("profile %d path mismatch: have %s, want %s-<timestamp>.pprof", i, paths[i], name)
+		}
+		if info, err := os.Stat(paths[i]); err != nil || info.Size() == 0 {
+			t.Errorf("profile %s not written: %v", paths[i], err)
+		}
+	}
+
+	if _, err := dumper.dump([]string{"unknown"}); err == nil {
+		t.Error("expected unknown profile to fail")
+	}
+}
+
+func TestDumpHandler(t *testing.T) {
+	dir, err := ioutil.TempDir("", "debug-dump")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	handler := dumpHandler(&profileDumper{dir: dir}, "secret")
+
+	tests := []struct {
+		method, target, auth string
+		status               int
+		profiles             int
+	}{
+		{http.MethodGet, "/?token=secret", "", http.StatusMethodNotAllowed, 0},
+		{http.MethodPost, "/", "", http.StatusUnauthorized, 0},
+		{http.MethodPost, "/?token=wrong", "", http.StatusUnauthorized, 0},
+		{http.MethodPost, "/", "Bearer wrong", http.StatusUnauthorized, 0},
+		{http.MethodPost, "/?profiles=unknown", "Bearer secret", http.StatusBadRequest, 0},
+		{http.MethodPost, "/?token=secret", "", http.StatusOK, len(dumpProfiles)},
+		{http.MethodPost, "/?profiles=heap,goroutine", "Bearer secret", http.StatusOK, 2},
+	}
+	for i, test := range tests {
+		req := httptest.NewRequest(test.method, test.target, nil)
+		if test.auth != "" {
+			req.Header.Set("Authorization", test.auth)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+
+		if rec.Code != test.status {
+			t.Errorf("test %d: status mismatch: have %d, want %d", i, rec.Code, test.status)
+			continue
+		}
+		if test.status != http.StatusOK {
+			continue
+		}
+
+		var paths []string
+		if err := json.NewDecoder(rec.Body).Decode(&paths); err != nil {
+			t.Errorf("test %d: invalid response: %v", i, err)
+		}
+		if len(paths) != test.profiles {
+			t.Errorf("test %d: dumped profile count mismatch: have %d, want %d", i, len(paths), test.profiles)
+		}
+	}
+}
+
+func TestResidentSetSize(t *testing.T) {
+	if rss := residentSetSize(); rss == 0 {
+		t.Error("expected a non zero resident set size")
+	}
+}
@@ -0,0 +1,157 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package debug
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/firehose"
+	"github.com/ethereum/go-ethereum/params"
+	"gopkg.in/urfave/cli.v1"
+)
+
+// firehoseHealthHandler serves the Firehose pipeline health as JSON by default or in
+// Prometheus text format when requested through `?format=prometheus`.
+func firehoseHealthHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		health := firehose.CurrentHealth()
+
+		if r.URL.Query().Get("format") == "prometheus" {
+			buf := new(bytes.Buffer)
+			writePrometheusGauge(buf, "firehose_current_block", health.CurrentBlock)
+			writePrometheusGauge(buf, "firehose_chain_head_block", health.ChainHeadBlock)
+			writePrometheusGauge(buf, "firehose_block_lag", health.BlockLag)
+			writePrometheusGauge(buf, "firehose_imported_block", health.ImportedBlock)
+			writePrometheusGauge(buf, "firehose_emission_lag", health.EmissionLag)
+			writePrometheusGauge(buf, "firehose_buffer_occupancy_bytes", health.BufferOccupancyBytes)
+			writePrometheusGauge(buf, "firehose_buffer_capacity_bytes", health.BufferCapacityBytes)
+
+			hasError := uint64(0)
+			if health.LastError != "" {
+				hasError = 1
+			}
+			writePrometheusGauge(buf, "firehose_has_error", hasError)
+
+			w.Header().Add("Content-Type", "text/plain")
+			w.Write(buf.Bytes())
+			return
+		}
+
+		w.Header().Add("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(health)
+	})
+}
+
+func writePrometheusGauge(buf *bytes.Buffer, name string, value uint64) {
+	fmt.Fprintf(buf, "# TYPE %s gauge\n%s %d\n\n", name, name, value)
+}
+
+// firehoseConfigSecretFlags are the Firehose flags whose value can hold credentials (API keys
+// in URLs, webhook tokens), only whether they are set is exposed.
+var firehoseConfigSecretFlags = map[string]bool{
+	firehoseReferenceRPCFlag.Name: true,
+	firehoseStallWebhookFlag.Name: true,
+}
+
+// firehoseConfig is the resolved Firehose configuration served by `firehoseConfigHandler`.
+type firehoseConfig struct {
+	FirehoseVersion      string `json:"firehose_version"`
+	ChangeReasonsVersion int    `json:"change_reasons_version"`
+	NodeVersion          string `json:"node_version"`
+	Variant              string `json:"variant"`
+
+	// Flags are the resolved values of all the Firehose flags, legacy names migrated, and
+	// DeprecatedFlags the legacy names used, keyed by legacy name
+	Flags           map[string]string `json:"flags"`
+	DeprecatedFlags map[string]string `json:"deprecated_flags"`
+
+	// Derived are the settings derived from the flags and the node's configuration
+	Derived map[string]interface{} `json:"derived"`
+}
+
+var (
+	firehoseConfigLock     sync.Mutex
+	firehoseResolvedConfig *firehoseConfig
+)
+
+// recordFirehoseConfig snapshots the resolved Firehose configuration once `Setup` applied it.
+func recordFirehoseConfig(ctx *cli.Context, genesisProvenance string) {
+	config := &firehoseConfig{
+		FirehoseVersion:      params.FirehoseVersion(),
+		ChangeReasonsVersion: firehose.ChangeReasonsVersion,
+		NodeVersion:          params.VersionWithMeta,
+		Variant:              firehose.ActiveVariant.Name,
+		Flags:                map[string]string{},
+		DeprecatedFlags:      map[string]string{},
+	}
+
+	for _, flag := range FirehoseFlags {
+		name := flag.GetName()
+		value := ctx.GlobalString(name)
+		if firehoseConfigSecretFlags[name] && value != "" {
+			value = "<redacted>"
+		}
+		config.Flags[name] = value
+	}
+
+	for current, legacy := range FirehoseFlagAliases() {
+		if ctx.GlobalIsSet(legacy) {
+			config.DeprecatedFlags[legacy] = current
+		}
+	}
+
+	// Archive nodes commit the state of every block, none is kept in memory
+	triesInMemory := uint64(core.TriesInMemory)
+	if ctx.GlobalString("gcmode") == "archive" {
+		triesInMemory = 0
+	}
+
+	config.Derived = map[string]interface{}{
+		"tries_in_memory":      triesInMemory,
+		"genesis_provenance":   genesisProvenance,
+		"state_proof_accounts": len(firehose.StateProofAccounts),
+		"annotations":          firehose.BlockAnnotations(),
+	}
+
+	firehoseConfigLock.Lock()
+	defer firehoseConfigLock.Unlock()
+
+	firehoseResolvedConfig = config
+}
+
+// firehoseConfigHandler serves the resolved Firehose configuration as JSON so that fleet
+// automation can verify the rollout of flag changes, 503 is returned until `Setup` resolved it.
+func firehoseConfigHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		firehoseConfigLock.Lock()
+		config := firehoseResolvedConfig
+		firehoseConfigLock.Unlock()
+
+		if config == nil {
+			http.Error(w, "firehose configuration not resolved yet", http.StatusServiceUnavailable)
+			return
+		}
+
+		w.Header().Add("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(config)
+	})
+}
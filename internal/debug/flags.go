@@ -24,9 +24,16 @@ import (
 	_ "net/http/pprof"
 	"os"
 	"runtime"
+	"strings"
+	"time"
 
 	"github.com/ethereum/go-ethereum/core"
 	"github.com/ethereum/go-ethereum/firehose"
+	"github.com/ethereum/go-ethereum/firehose/filesink"
+	"github.com/ethereum/go-ethereum/firehose/netsink"
+	"github.com/ethereum/go-ethereum/firehose/objectstore"
+	"github.com/ethereum/go-ethereum/firehose/sidecar"
+	"github.com/ethereum/go-ethereum/firehose/socketsink"
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/ethereum/go-ethereum/metrics"
 	"github.com/ethereum/go-ethereum/metrics/exp"
@@ -73,6 +80,36 @@ var (
 		Usage: "pprof HTTP server listening interface",
 		Value: "127.0.0.1",
 	}
+	pprofDumpDirFlag = cli.StringFlag{
+		Name:  "pprof.dumpdir",
+		Usage: "Directory where heap, goroutine and allocs profile dumps are written, enables the /debug/dump endpoint of the pprof server (requires --pprof.dumptoken)",
+	}
+	pprofDumpTokenFlag = cli.StringFlag{
+		Name:  "pprof.dumptoken",
+		Usage: "Token authenticating requests to the /debug/dump endpoint, as a bearer 'Authorization' header or a 'token' query parameter",
+	}
+	pprofDumpRSSFlag = cli.Uint64Flag{
+		Name:  "pprof.dumprss",
+		Usage: "Automatically dump profiles to --pprof.dumpdir when the resident set size crosses this amount of megabytes (0 = disabled)",
+	}
+	pprofPushURLFlag = cli.StringFlag{
+		Name:  "pprof.push.url",
+		Usage: "Continuously capture CPU and heap profiles and push them to this Pyroscope compatible server URL",
+	}
+	pprofPushIntervalFlag = cli.DurationFlag{
+		Name:  "pprof.push.interval",
+		Usage: "Duration of each continuous profiling capture",
+		Value: 15 * time.Second,
+	}
+	pprofPushAppFlag = cli.StringFlag{
+		Name:  "pprof.push.app",
+		Usage: "Application name the continuous profiles are pushed under",
+		Value: "geth",
+	}
+	pprofPushTokenFlag = cli.StringFlag{
+		Name:  "pprof.push.token",
+		Usage: "Bearer token authenticating the continuous profiles pushes",
+	}
 	memprofilerateFlag = cli.IntFlag{
 		Name:  "memprofilerate",
 		Usage: "Turn on memory profiling with the given rate",
@@ -90,47 +127,498 @@ var (
 		Name:  "trace",
 		Usage: "Write execution trace to the given file",
 	}
+	logFileFlag = cli.StringFlag{
+		Name:  "log.file",
+		Usage: "Write logs to the given file, in addition to the standard error output",
+	}
+	logRotateMaxSizeFlag = cli.IntFlag{
+		Name:  "log.rotate.maxsize",
+		Usage: "Size in megabytes after which the log file is rotated (0 = no size based rotation)",
+		Value: 100,
+	}
+	logRotateIntervalFlag = cli.DurationFlag{
+		Name:  "log.rotate.interval",
+		Usage: "Age after which the log file is rotated (0 = no time based rotation)",
+	}
+	logRotateMaxBackupsFlag = cli.IntFlag{
+		Name:  "log.rotate.maxbackups",
+		Usage: "Maximum number of rotated log files kept, the oldest being deleted (0 = keep all)",
+		Value: 10,
+	}
+	logRotateCompressFlag = cli.BoolFlag{
+		Name:  "log.rotate.compress",
+		Usage: "Compress rotated log files using gzip",
+	}
+	logSampleWindowFlag = cli.DurationFlag{
+		Name:  "log.sample.window",
+		Usage: "Rate-limit identical log messages to --log.sample.burst occurrences per window, reporting how many were suppressed (0 = disabled)",
+	}
+	logOTLPEndpointFlag = cli.StringFlag{
+		Name:  "log.otlp.endpoint",
+		Usage: "Export logs to an OpenTelemetry collector at this OTLP/HTTP logs URL (e.g. http://localhost:4318/v1/logs)",
+	}
+	logOTLPHeadersFlag = cli.StringFlag{
+		Name:  "log.otlp.headers",
+		Usage: "Comma separated list of <name>=<value> HTTP headers sent along exported logs (e.g. authentication)",
+	}
+	logOTLPServiceFlag = cli.StringFlag{
+		Name:  "log.otlp.service",
+		Usage: "Service name reported along exported logs",
+		Value: "geth",
+	}
+	logSampleBurstFlag = cli.IntFlag{
+		Name:  "log.sample.burst",
+		Usage: "Number of identical log messages let through per --log.sample.window",
+		Value: 10,
+	}
 
 	// Firehose Flags
 	firehoseEnabledFlag = cli.BoolFlag{
-		Name:  "firehose-enabled",
+		Name:  "firehose.enabled",
 		Usage: "Activate/deactivate Firehose instrumentation, disabled by default",
 	}
 	firehoseSyncInstrumentationFlag = cli.BoolTFlag{
-		Name:  "firehose-sync-instrumentation",
+		Name:  "firehose.sync",
 		Usage: "Activate/deactivate Firehose sync output instrumentation, enabled by default",
 	}
 	firehoseMiningEnabledFlag = cli.BoolFlag{
-		Name:  "firehose-mining-enabled",
+		Name:  "firehose.mining",
 		Usage: "Activate/deactivate mining code even if Firehose is active, required speculative execution on local miner node, disabled by default",
 	}
+	firehoseMiningOutputFlag = cli.StringFlag{
+		Name:  "firehose.mining.output",
+		Usage: "File receiving the speculative mining instrumentation when mining is enabled, when unset, mining lines are written to standard output framed with a 'MINING ' prefix so the canonical stream stays pristine",
+	}
+	firehoseOutputFormatFlag = cli.StringFlag{
+		Name:  "firehose.output.format",
+		Usage: "Format of the Firehose sync output, either 'text' for the standard space separated lines or 'ndjson' for one JSON object per event with named fields",
+		Value: string(firehose.TextOutputFormat),
+	}
+	firehoseSecondaryOutputFileFlag = cli.StringFlag{
+		Name:  "firehose.secondary.output.file",
+		Usage: "Emit the Firehose sync output to this file too, in the --firehose.secondary.output.format format, to validate a reader upgrade against both formats simultaneously",
+	}
+	firehoseSecondaryOutputFormatFlag = cli.StringFlag{
+		Name:  "firehose.secondary.output.format",
+		Usage: "Format of the Firehose secondary output, either 'text' or 'ndjson' (see --firehose.output.format)",
+		Value: string(firehose.NDJSONOutputFormat),
+	}
 	firehoseBlockProgressFlag = cli.BoolFlag{
-		Name:  "firehose-block-progress",
+		Name:  "firehose.blockprogress",
 		Usage: "Activate/deactivate Firehose block progress output instrumentation, disabled by default",
 	}
+	firehoseBlockProgressDetailsFlag = cli.BoolFlag{
+		Name:  "firehose.blockprogress.details",
+		Usage: "Emit a BLOCK_PROGRESS event giving the hash, parent hash, timestamp and transaction count of each block before its block progress FINALIZE_BLOCK line, disabled by default",
+	}
+	firehoseFollowerFlag = cli.BoolFlag{
+		Name:  "firehose.follower",
+		Usage: "Emit the blocks imported without execution (fast sync) as Firehose skeleton blocks, giving transaction envelopes and receipts but no calls nor state changes",
+	}
+	firehoseReducedOrdinalsFlag = cli.BoolFlag{
+		Name:  "firehose.reducedordinals",
+		Usage: "Activate/deactivate Firehose reduced ordinals mode where informational events (gas, nonce, code changes and account creations) do not consume an ordinal, disabled by default",
+	}
+	firehoseStartBlockFlag = cli.Uint64Flag{
+		Name:  "firehose.startblock",
+		Usage: "First block fully instrumented by Firehose, blocks below it only emit block progress, 0 (all blocks) by default",
+	}
+	firehoseNetBalanceChangesFlag = cli.BoolFlag{
+		Name:  "firehose.netbalancechanges",
+		Usage: "Emit a single net BALANCE_CHANGE per address and reason within a call instead of each individual change, INIT then carries a trailing 'net' field, disabled by default",
+	}
+	firehoseGasChangeCoalescingFlag = cli.BoolFlag{
+		Name:  "firehose.coalescegaschanges",
+		Usage: "Merge consecutive GAS_CHANGE of a call having the same reason into a single one carrying a trailing count of merged changes, disabled by default",
+	}
+	firehoseTrxGasRefundsFlag = cli.BoolFlag{
+		Name:  "firehose.trxgasrefunds",
+		Usage: "Emit a TRX_GAS_REFUND giving the accumulated and the applied (capped) refund of a transaction along the rule that capped it, disabled by default",
+	}
+	firehoseCallInstrumentationFlag = cli.BoolFlag{
+		Name:  "firehose.calls",
+		Usage: "Activate/deactivate Firehose instrumentation of read-only calls made through 'debug_callWithFirehoseTrace' RPC, disabled by default",
+	}
+	firehoseCallAccessSetsFlag = cli.BoolFlag{
+		Name:  "firehose.accesssets",
+		Usage: "Emit a CALL_ACCESS_SET event listing the addresses and storage slots accessed by each call with their EIP-2929 warm/cold classification, disabled by default",
+	}
+	firehoseCallBufferLimitFlag = cli.IntFlag{
+		Name:  "firehose.calls.bufferlimit",
+		Usage: "Maximum size in bytes of the Firehose log accumulated by a 'debug_callWithFirehoseTrace' execution, the call is aborted once exceeded, 0 means unlimited",
+		Value: firehose.CallBufferLimitInBytes,
+	}
+	firehoseReturnDataLimitFlag = cli.IntFlag{
+		Name:  "firehose.calls.returndatalimit",
+		Usage: "Maximum size in bytes of the return data printed in EVM_END_CALL, longer return data is truncated and its full size printed alongside, 0 means unlimited",
+	}
+	firehoseCallAbortModeFlag = cli.StringFlag{
+		Name:  "firehose.calls.onabort",
+		Usage: "Handling of the Firehose traced calls aborted by their timeout, 'fail' fails the request while 'close' returns the trace closed by TRX_ABORTED and aborted EVM_END_CALL events",
+		Value: string(firehose.CallAbortMode),
+	}
+	firehoseBlockShardSizeFlag = cli.IntFlag{
+		Name:  "firehose.blockshardsize",
+		Usage: "When greater than 0, Firehose emits transactions data in block segments of at most this amount of bytes, each segment being numbered and the block's segments count emitted before END_BLOCK, disabled (0) by default",
+		Value: 0,
+	}
+	firehoseSpillThresholdFlag = cli.IntFlag{
+		Name:  "firehose.spillthreshold",
+		Usage: "When greater than 0, the Firehose lines of a transaction being executed are moved to a temporary file once they exceed this amount of bytes, protecting memory against giant transactions, disabled (0) by default",
+	}
+	firehoseCompactCodeChangesFlag = cli.BoolFlag{
+		Name:  "firehose.compactcode",
+		Usage: "Activate/deactivate Firehose compact code changes where code hashes and lengths are printed instead of full code bytes (except for newly deployed code), disabled by default",
+	}
+	firehoseCodeStoreFlag = cli.StringFlag{
+		Name:  "firehose.codestore",
+		Usage: "Directory where Firehose stores each unique contract code once, named by its hash, code changes then only carry code hashes and a CODE_BLOB event announces each newly stored code, disabled by default",
+	}
+	firehoseStrictFlag = cli.BoolTFlag{
+		Name:  "firehose.strict",
+		Usage: "Activate/deactivate Firehose strict mode, when deactivated, instrumentation invariant violations emit an ERROR event instead of panicking, enabled by default",
+	}
+	firehoseRecoveryLimitFlag = cli.IntFlag{
+		Name:  "firehose.recoverylimit",
+		Usage: "In non-strict mode, maximum amount of invariant violations recovered within --firehose.recoverywindow blocks, once exceeded a FATAL event is emitted and the Firehose emission is halted, 0 (never halt) by default",
+	}
+	firehoseRecoveryWindowFlag = cli.Uint64Flag{
+		Name:  "firehose.recoverywindow",
+		Usage: "Amount of blocks in which recovered invariant violations are accounted against --firehose.recoverylimit, 0 accounts all of them since the process start",
+		Value: firehose.RecoveryWindowInBlocks,
+	}
+	firehoseOutputMiddlewaresFlag = cli.StringFlag{
+		Name:  "firehose.output.middlewares",
+		Usage: "Comma separated list of middlewares the Firehose sync output goes through, in order, before reaching its sink, each being 'gzip[:<level>]', 'checksum' (CRC-32C trailer per write) or 'frame' (uint32 length prefix per write), e.g. 'gzip,checksum,frame'",
+	}
+	firehoseOutputFileFlag = cli.StringFlag{
+		Name:  "firehose.output.file",
+		Usage: "When set, Firehose sync output is appended to this file instead of standard output",
+	}
+	firehoseBlockIndexFlag = cli.BoolFlag{
+		Name:  "firehose.output.blockindex",
+		Usage: "Build along the --firehose.output.file a '.idx' sidecar file mapping each block number to its byte range in the output file, enabling random access",
+	}
+	firehoseOutputSocketFlag = cli.StringFlag{
+		Name:  "firehose.output.socket",
+		Usage: "Stream the Firehose sync output to a remote collector listening on this TCP address (host:port) instead of standard output",
+	}
+	firehoseOutputTLSCertFlag = cli.StringFlag{
+		Name:  "firehose.output.tls.cert",
+		Usage: "PEM client certificate presented to the remote Firehose collector for mutual TLS authentication, enables TLS on the socket output",
+	}
+	firehoseOutputTLSKeyFlag = cli.StringFlag{
+		Name:  "firehose.output.tls.key",
+		Usage: "PEM private key of the client certificate given by --firehose.output.tls.cert",
+	}
+	firehoseOutputTLSCAFlag = cli.StringFlag{
+		Name:  "firehose.output.tls.ca",
+		Usage: "PEM certificate authority verifying the remote Firehose collector certificate (system roots when unset), enables TLS on the socket output",
+	}
+	firehoseOutputBackoffMaxFlag = cli.DurationFlag{
+		Name:  "firehose.output.backoff.max",
+		Usage: "Maximum delay between the retries of a failed Firehose socket output write, the delay doubling from 100ms on each consecutive failure",
+		Value: netsink.DefaultConfig.MaxBackoff,
+	}
+	firehoseOutputBreakerThresholdFlag = cli.IntFlag{
+		Name:  "firehose.output.breaker.threshold",
+		Usage: "Consecutive Firehose socket output failures after which the circuit breaker opens, pausing delivery for --firehose.output.breaker.cooldown (0 = never opens)",
+		Value: netsink.DefaultConfig.FailureThreshold,
+	}
+	firehoseOutputBreakerCooldownFlag = cli.DurationFlag{
+		Name:  "firehose.output.breaker.cooldown",
+		Usage: "Time the Firehose socket output circuit breaker stays open before a single delivery attempt is made again",
+		Value: netsink.DefaultConfig.Cooldown,
+	}
+	firehoseOutputReaderFlag = cli.StringFlag{
+		Name:  "firehose.output.reader",
+		Usage: "Path of the console reader binary launched and supervised by the node, the Firehose sync output being written to its standard input instead of standard output, it's restarted when it exits or stalls",
+	}
+	firehoseOutputReaderArgsFlag = cli.StringFlag{
+		Name:  "firehose.output.reader.args",
+		Usage: "Space separated arguments of the --firehose.output.reader binary",
+	}
+	firehoseOutputReaderStallTimeoutFlag = cli.DurationFlag{
+		Name:  "firehose.output.reader.stalltimeout",
+		Usage: "Time a write to the --firehose.output.reader standard input may stay blocked before the reader is deemed unhealthy and restarted (0 = never)",
+		Value: time.Minute,
+	}
+	firehoseDryRunFlag = cli.BoolFlag{
+		Name:  "firehose.dryrun",
+		Usage: "Run the whole Firehose instrumentation but discard its output, logging per-block statistics (events, bytes, transactions, calls), to measure its overhead and validate its stability before enabling the capture",
+	}
+	firehoseSizingIntervalFlag = cli.DurationFlag{
+		Name:  "firehose.sizing.interval",
+		Usage: "Account the Firehose output bytes per block and per event family into metrics and log, at this interval, a summary projecting the daily output size, for capacity planning (0 = disabled)",
+	}
+	firehoseObjectStoreURLFlag = cli.StringFlag{
+		Name:  "firehose.objectstore.url",
+		Usage: "When set, Firehose sync output is uploaded in bundles of blocks to this object store location instead of standard output, in the form s3://<bucket>/<prefix> (use --firehose.objectstore.endpoint for Google Cloud Storage)",
+		Value: "",
+	}
+	firehoseObjectStoreEndpointFlag = cli.StringFlag{
+		Name:  "firehose.objectstore.endpoint",
+		Usage: "Firehose object store S3 compatible endpoint, use 'https://storage.googleapis.com' for Google Cloud Storage, default AWS endpoint if empty",
+		Value: "",
+	}
+	firehoseObjectStoreRegionFlag = cli.StringFlag{
+		Name:  "firehose.objectstore.region",
+		Usage: "Firehose object store bucket region",
+		Value: "us-east-1",
+	}
+	firehoseObjectStoreBundleSizeFlag = cli.Uint64Flag{
+		Name:  "firehose.objectstore.bundlesize",
+		Usage: "Amount of blocks accumulated in a bundle before it's uploaded to the Firehose object store",
+		Value: 100,
+	}
+	firehoseObjectStoreNameFormatFlag = cli.StringFlag{
+		Name:  "firehose.objectstore.nameformat",
+		Usage: "Format of uploaded Firehose bundle object names, receives the bundle's first and last block numbers",
+		Value: objectstore.DefaultNameFormat,
+	}
+	firehoseObjectStoreRetriesFlag = cli.IntFlag{
+		Name:  "firehose.objectstore.retries",
+		Usage: "Amount of times a failed Firehose bundle upload is retried before giving up",
+		Value: 5,
+	}
+	firehoseTrxFromPubkeyFlag = cli.BoolFlag{
+		Name:  "firehose.trxfrompubkey",
+		Usage: "Include the sender's recovered 64 bytes uncompressed public key in TRX_FROM, disabled by default",
+	}
+	firehoseStorageKeyPreimagesFlag = cli.BoolFlag{
+		Name:  "firehose.storagekeypreimages",
+		Usage: "Include in STORAGE_CHANGE the preimage of the storage key when it was hashed earlier in the transaction (mapping keys), disabled by default",
+	}
+	firehoseStorageWipesFlag = cli.BoolFlag{
+		Name:  "firehose.storagewipes",
+		Usage: "Emit a STORAGE_WIPED event with the contract's storage root when it self-destructs, so that flat-state consumers can invalidate all of its slots",
+	}
+	firehoseBlockHashReadsFlag = cli.BoolFlag{
+		Name:  "firehose.blockhashreads",
+		Usage: "Emit a BLOCKHASH_READ event (queried block number, returned hash) each time a contract executes the BLOCKHASH opcode",
+	}
+	firehoseMonotonicTimestampsFlag = cli.BoolFlag{
+		Name:  "firehose.monotonictimestamps",
+		Usage: "Prefix every Firehose event with a nanosecond monotonic timestamp ('FIRE @<ns> EVENT ...'), anchored to the wall clock by a CLOCK_ANCHOR event after each BEGIN_BLOCK, for pipeline latency analysis",
+	}
+	firehoseAnnotationsFlag = cli.StringFlag{
+		Name:  "firehose.annotations",
+		Usage: "Comma-separated key=value annotations (e.g. 'datacenter=us-east-1,role=primary') attached to every Firehose BEGIN_BLOCK, they can be changed at runtime through 'admin_setFirehoseAnnotation'",
+	}
+	firehoseSequenceNumbersFlag = cli.BoolFlag{
+		Name:  "firehose.sequencenumbers",
+		Usage: "Prefix every Firehose line with its sequence number in the stream ('FIRE #<seq> EVENT ...') so that readers can detect dropped or duplicated lines",
+	}
+	firehoseOrderingCheckpointsFlag = cli.BoolFlag{
+		Name:  "firehose.checkpoints",
+		Usage: "Add to FINALIZE_BLOCK the amount of ordinals consumed by the block and its count of events per family so readers can detect partially delivered blocks, disabled by default",
+	}
+	firehoseUncleBlocksFlag = cli.BoolFlag{
+		Name:  "firehose.uncleblocks",
+		Usage: "Emit an UNCLE_BLOCK event with the transactions of each uncle whose body is available locally, disabled by default",
+	}
+	firehoseLogsBloomCheckFlag = cli.BoolFlag{
+		Name:  "firehose.bloomcheck",
+		Usage: "Verify each transaction's receipt bloom against the bloom computed from the logs captured by Firehose, a mismatch being an instrumentation invariant violation, disabled by default",
+	}
+	firehoseFeeRecipientCreditFlag = cli.BoolFlag{
+		Name:  "firehose.feerecipientcredit",
+		Usage: "Emit a FEE_RECIPIENT_CREDIT event before each FINALIZE_BLOCK giving the block's fee recipient and the total of the transaction fees credited to it, disabled by default",
+	}
+	firehoseStateProofsFlag = cli.StringFlag{
+		Name:  "firehose.stateproofs",
+		Usage: "Comma separated accounts, each optionally followed by colon separated storage slots (0xaddr1,0xaddr2:0xslot1:0xslot2), for which Merkle proofs against the state root are emitted in each block, disabled by default",
+	}
+	firehoseTrieCommitStatsFlag = cli.BoolFlag{
+		Name:  "firehose.triecommitstats",
+		Usage: "Emit a TRIE_COMMIT event giving the amount of trie nodes and bytes persisted to the database and the state commit duration of each block, disabled by default",
+	}
+	firehoseReferenceRPCFlag = cli.StringFlag{
+		Name:  "firehose.referencerpc",
+		Usage: "RPC endpoint of a reference node (upstream Geth, Erigon, ...) against which each block's receipts are compared, emitting DIVERGENCE events when gas used, status or logs differ",
+	}
+	firehoseSlowTrxThresholdFlag = cli.DurationFlag{
+		Name:  "firehose.slowtrxthreshold",
+		Usage: "Emit a SLOW_TRX diagnostic event for transactions whose execution takes longer than this duration, and a SLOW_TRX_PROGRESS one each time it elapses while still executing, 0 disables it",
+	}
+	firehoseStallMaxLagFlag = cli.Uint64Flag{
+		Name:  "firehose.stall.maxlag",
+		Usage: "Amount of blocks the Firehose output can lag behind the imported chain head before an error is logged when it lasts longer than --firehose.stall.duration, 0 disables the alarm",
+	}
+	firehoseStallDurationFlag = cli.DurationFlag{
+		Name:  "firehose.stall.duration",
+		Usage: "Time the Firehose emission lag must stay above --firehose.stall.maxlag before the stall alarm is raised",
+		Value: time.Minute,
+	}
+	firehoseStallWebhookFlag = cli.StringFlag{
+		Name:  "firehose.stall.webhook",
+		Usage: "URL receiving a JSON POST request when the Firehose stall alarm is raised and when the emission recovers",
+	}
+	firehoseForceTTYFlag = cli.BoolFlag{
+		Name:  "firehose.forcetty",
+		Usage: "Allow Firehose to print its output when standard output is a terminal, by default, the node refuses to start in this case since it's almost always an operator mistake",
+	}
 	firehoseGenesisFileFlag = cli.StringFlag{
-		Name:  "firehose-genesis-file",
+		Name:  "firehose.genesisfile",
 		Usage: "On private chains where the genesis config is not known to Geth, you **must** provide the 'genesis.json' file path for proper instrumentation of genesis block",
 		Value: "",
 	}
+	firehoseGenesisFromStateFlag = cli.BoolFlag{
+		Name:  "firehose.genesis.fromstate",
+		Usage: "Emit the genesis block accounts by walking the genesis state in the database instead of the genesis config, much faster for chains with huge genesis, disabled by default",
+	}
+	firehoseGenesisReadersFlag = cli.IntFlag{
+		Name:  "firehose.genesis.readers",
+		Usage: "Amount of parallel readers loading the genesis accounts code and storage when --firehose.genesis.fromstate is set, 0 (one per CPU) by default",
+	}
 )
 
 // Flags holds all command-line flags required for debugging.
 var Flags = []cli.Flag{
 	verbosityFlag, vmoduleFlag, backtraceAtFlag, debugFlag,
-	pprofFlag, pprofAddrFlag, pprofPortFlag,
+	pprofFlag, pprofAddrFlag, pprofPortFlag, pprofDumpDirFlag, pprofDumpTokenFlag, pprofDumpRSSFlag,
+	pprofPushURLFlag, pprofPushIntervalFlag, pprofPushAppFlag, pprofPushTokenFlag,
 	memprofilerateFlag, blockprofilerateFlag, cpuprofileFlag, traceFlag,
+	logFileFlag, logRotateMaxSizeFlag, logRotateIntervalFlag, logRotateMaxBackupsFlag, logRotateCompressFlag,
+	logSampleWindowFlag, logSampleBurstFlag, logOTLPEndpointFlag, logOTLPHeadersFlag, logOTLPServiceFlag,
 }
 
 // FirehoseFlags holds all StreamingFast Firehose related command-line flags.
 var FirehoseFlags = []cli.Flag{
-	firehoseEnabledFlag, firehoseSyncInstrumentationFlag, firehoseMiningEnabledFlag, firehoseBlockProgressFlag,
-	firehoseGenesisFileFlag,
+	firehoseEnabledFlag, firehoseSyncInstrumentationFlag, firehoseMiningEnabledFlag, firehoseMiningOutputFlag,
+	firehoseOutputFormatFlag, firehoseSecondaryOutputFileFlag, firehoseSecondaryOutputFormatFlag,
+	firehoseOutputMiddlewaresFlag, firehoseBlockProgressFlag, firehoseBlockProgressDetailsFlag, firehoseFollowerFlag,
+	firehoseStartBlockFlag, firehoseReducedOrdinalsFlag, firehoseNetBalanceChangesFlag, firehoseGasChangeCoalescingFlag,
+	firehoseTrxGasRefundsFlag, firehoseCallInstrumentationFlag, firehoseCallBufferLimitFlag, firehoseReturnDataLimitFlag,
+	firehoseCallAbortModeFlag, firehoseCallAccessSetsFlag, firehoseBlockShardSizeFlag, firehoseSpillThresholdFlag,
+	firehoseCompactCodeChangesFlag, firehoseCodeStoreFlag, firehoseStrictFlag, firehoseRecoveryLimitFlag,
+	firehoseRecoveryWindowFlag, firehoseOutputFileFlag, firehoseBlockIndexFlag, firehoseOutputSocketFlag,
+	firehoseOutputTLSCertFlag, firehoseOutputTLSKeyFlag, firehoseOutputTLSCAFlag, firehoseOutputBackoffMaxFlag,
+	firehoseOutputBreakerThresholdFlag, firehoseOutputBreakerCooldownFlag, firehoseOutputReaderFlag,
+	firehoseOutputReaderArgsFlag, firehoseOutputReaderStallTimeoutFlag, firehoseDryRunFlag, firehoseSizingIntervalFlag,
+	firehoseObjectStoreURLFlag, firehoseObjectStoreEndpointFlag, firehoseObjectStoreRegionFlag,
+	firehoseObjectStoreBundleSizeFlag, firehoseObjectStoreNameFormatFlag, firehoseObjectStoreRetriesFlag,
+	firehoseTrxFromPubkeyFlag, firehoseTrieCommitStatsFlag, firehoseFeeRecipientCreditFlag, firehoseStateProofsFlag,
+	firehoseUncleBlocksFlag, firehoseLogsBloomCheckFlag, firehoseStorageWipesFlag, firehoseBlockHashReadsFlag,
+	firehoseMonotonicTimestampsFlag, firehoseSequenceNumbersFlag, firehoseOrderingCheckpointsFlag,
+	firehoseAnnotationsFlag, firehoseReferenceRPCFlag, firehoseStorageKeyPreimagesFlag, firehoseSlowTrxThresholdFlag,
+	firehoseStallMaxLagFlag, firehoseStallDurationFlag, firehoseStallWebhookFlag, firehoseForceTTYFlag,
+	firehoseGenesisFileFlag, firehoseGenesisFromStateFlag, firehoseGenesisReadersFlag,
+}
+
+// firehoseFlagAliases maps Firehose flags to their legacy `firehose-*` name, legacy names are
+// still accepted but deprecated in favor of the `firehose.*` namespace.
+var firehoseFlagAliases = []struct {
+	flag   cli.Flag
+	legacy string
+}{
+	{firehoseEnabledFlag, "firehose-enabled"},
+	{firehoseSyncInstrumentationFlag, "firehose-sync-instrumentation"},
+	{firehoseMiningEnabledFlag, "firehose-mining-enabled"},
+	{firehoseMiningOutputFlag, "firehose-mining-output"},
+	{firehoseOutputFormatFlag, "firehose-output-format"},
+	{firehoseBlockProgressFlag, "firehose-block-progress"},
+	{firehoseReducedOrdinalsFlag, "firehose-reduced-ordinals"},
+	{firehoseCallInstrumentationFlag, "firehose-call-instrumentation"},
+	{firehoseBlockShardSizeFlag, "firehose-block-shard-size"},
+	{firehoseCompactCodeChangesFlag, "firehose-compact-code-changes"},
+	{firehoseStrictFlag, "firehose-strict"},
+	{firehoseOutputFileFlag, "firehose-output-file"},
+	{firehoseBlockIndexFlag, "firehose-block-index"},
+	{firehoseObjectStoreURLFlag, "firehose-object-store-url"},
+	{firehoseObjectStoreEndpointFlag, "firehose-object-store-endpoint"},
+	{firehoseObjectStoreRegionFlag, "firehose-object-store-region"},
+	{firehoseObjectStoreBundleSizeFlag, "firehose-object-store-bundle-size"},
+	{firehoseObjectStoreNameFormatFlag, "firehose-object-store-name-format"},
+	{firehoseObjectStoreRetriesFlag, "firehose-object-store-retries"},
+	{firehoseTrxFromPubkeyFlag, "firehose-trx-from-pubkey"},
+	{firehoseStorageKeyPreimagesFlag, "firehose-storage-key-preimages"},
+	{firehoseTrieCommitStatsFlag, "firehose-trie-commit-stats"},
+	{firehoseReferenceRPCFlag, "firehose-reference-rpc"},
+	{firehoseSlowTrxThresholdFlag, "firehose-slow-trx-threshold"},
+	{firehoseForceTTYFlag, "firehose-force-tty"},
+	{firehoseGenesisFileFlag, "firehose-genesis-file"},
+}
+
+// FirehoseDeprecatedFlags holds the legacy names of the Firehose flags, they are aliases of
+// the flags in FirehoseFlags and log a deprecation warning when used.
+var FirehoseDeprecatedFlags = legacyFirehoseFlags()
+
+// FirehoseFlagAliases returns the legacy name of each Firehose flag keyed by its current name.
+func FirehoseFlagAliases() map[string]string {
+	aliases := make(map[string]string, len(firehoseFlagAliases))
+	for _, alias := range firehoseFlagAliases {
+		aliases[alias.flag.GetName()] = alias.legacy
+	}
+
+	return aliases
+}
+
+func legacyFirehoseFlags() []cli.Flag {
+	flags := make([]cli.Flag, len(firehoseFlagAliases))
+	for i, alias := range firehoseFlagAliases {
+		usage := fmt.Sprintf("Deprecated, use --%s", alias.flag.GetName())
+
+		switch flag := alias.flag.(type) {
+		case cli.BoolFlag:
+			flag.Name, flag.Usage = alias.legacy, usage
+			flags[i] = flag
+		case cli.BoolTFlag:
+			flag.Name, flag.Usage = alias.legacy, usage
+			flags[i] = flag
+		case cli.StringFlag:
+			flag.Name, flag.Usage = alias.legacy, usage
+			flags[i] = flag
+		case cli.IntFlag:
+			flag.Name, flag.Usage = alias.legacy, usage
+			flags[i] = flag
+		case cli.Uint64Flag:
+			flag.Name, flag.Usage = alias.legacy, usage
+			flags[i] = flag
+		case cli.DurationFlag:
+			flag.Name, flag.Usage = alias.legacy, usage
+			flags[i] = flag
+		default:
+			panic(fmt.Sprintf("unsupported legacy Firehose flag type %T", flag))
+		}
+	}
+
+	return flags
+}
+
+// migrateFirehoseFlags carries the value of legacy Firehose flags over to their current name,
+// the current name having precedence when both are set.
+func migrateFirehoseFlags(ctx *cli.Context) error {
+	for _, alias := range firehoseFlagAliases {
+		if !ctx.GlobalIsSet(alias.legacy) {
+			continue
+		}
+
+		name := alias.flag.GetName()
+		log.Warn(fmt.Sprintf("The flag --%s is deprecated and will be removed in the future, please use --%s", alias.legacy, name))
+
+		if ctx.GlobalIsSet(name) {
+			continue
+		}
+
+		if err := ctx.GlobalSet(name, fmt.Sprint(ctx.GlobalGeneric(alias.legacy))); err != nil {
+			return fmt.Errorf("migrate flag --%s to --%s: %w", alias.legacy, name, err)
+		}
+	}
+
+	return nil
 }
 
 var (
 	ostream log.Handler
 	glogger *log.GlogHandler
+
+	firehoseObjectStoreWriter   *objectstore.Writer
+	firehoseFileWriter          *filesink.Writer
+	firehoseSocketWriter        *socketsink.Writer
+	firehoseReaderWriter        *sidecar.Writer
+	firehoseDryRunWriter        *firehose.DryRunWriter
+	firehoseSecondaryFileWriter *filesink.Writer
 )
 
 func init() {
@@ -148,6 +636,7 @@ func init() {
 func Setup(ctx *cli.Context, logdir string, genesis *core.Genesis) error {
 	// logging
 	log.PrintOrigins(ctx.GlobalBool(debugFlag.Name))
+	handlers := []log.Handler{ostream}
 	if logdir != "" {
 		rfh, err := log.RotatingFileHandler(
 			logdir,
@@ -157,8 +646,50 @@ func Setup(ctx *cli.Context, logdir string, genesis *core.Genesis) error {
 		if err != nil {
 			return err
 		}
-		glogger.SetHandler(log.MultiHandler(ostream, rfh))
+		handlers = append(handlers, rfh)
+	}
+	if logFile := ctx.GlobalString(logFileFlag.Name); logFile != "" {
+		fh, err := log.FileRotationHandler(logFile, log.RotationConfig{
+			MaxSize:    int64(ctx.GlobalInt(logRotateMaxSizeFlag.Name)) * 1024 * 1024,
+			Interval:   ctx.GlobalDuration(logRotateIntervalFlag.Name),
+			MaxBackups: ctx.GlobalInt(logRotateMaxBackupsFlag.Name),
+			Compress:   ctx.GlobalBool(logRotateCompressFlag.Name),
+		}, log.TerminalFormat(false))
+		if err != nil {
+			return fmt.Errorf("log file: %w", err)
+		}
+		handlers = append(handlers, fh)
+	}
+	if endpoint := ctx.GlobalString(logOTLPEndpointFlag.Name); endpoint != "" {
+		headers := make(map[string]string)
+		for _, header := range strings.Split(ctx.GlobalString(logOTLPHeadersFlag.Name), ",") {
+			if header = strings.TrimSpace(header); header == "" {
+				continue
+			}
+			parts := strings.SplitN(header, "=", 2)
+			if len(parts) != 2 {
+				return fmt.Errorf("invalid --%s header %q, expected <name>=<value>", logOTLPHeadersFlag.Name, header)
+			}
+			headers[strings.TrimSpace(parts[0])] = strings.TrimSpace(parts[1])
+		}
+		handlers = append(handlers, log.OTLPHandler(log.OTLPConfig{
+			Endpoint:    endpoint,
+			ServiceName: ctx.GlobalString(logOTLPServiceFlag.Name),
+			Headers:     headers,
+		}))
 	}
+	handler := handlers[0]
+	if len(handlers) > 1 {
+		handler = log.MultiHandler(handlers...)
+	}
+	if window := ctx.GlobalDuration(logSampleWindowFlag.Name); window > 0 {
+		burst := ctx.GlobalInt(logSampleBurstFlag.Name)
+		if burst < 1 {
+			return fmt.Errorf("--%s must be at least 1", logSampleBurstFlag.Name)
+		}
+		handler = log.SamplingHandler(window, burst, nil, handler)
+	}
+	glogger.SetHandler(handler)
 	glogger.Verbosity(log.Lvl(ctx.GlobalInt(verbosityFlag.Name)))
 	glogger.Vmodule(ctx.GlobalString(vmoduleFlag.Name))
 	glogger.BacktraceAt(ctx.GlobalString(backtraceAtFlag.Name))
@@ -178,6 +709,36 @@ func Setup(ctx *cli.Context, logdir string, genesis *core.Genesis) error {
 		}
 	}
 
+	// profile dumps
+	if dumpDir := ctx.GlobalString(pprofDumpDirFlag.Name); dumpDir != "" {
+		dumper := &profileDumper{dir: expandHome(dumpDir)}
+		if token := ctx.GlobalString(pprofDumpTokenFlag.Name); token != "" {
+			http.Handle("/debug/dump", dumpHandler(dumper, token))
+		} else if ctx.GlobalBool(pprofFlag.Name) {
+			log.Warn("Profile dump endpoint disabled, no token configured", "flag", pprofDumpTokenFlag.Name)
+		}
+		if threshold := ctx.GlobalUint64(pprofDumpRSSFlag.Name); threshold > 0 {
+			go watchRSS(dumper, threshold*1024*1024)
+		}
+	} else if ctx.GlobalUint64(pprofDumpRSSFlag.Name) > 0 || ctx.GlobalString(pprofDumpTokenFlag.Name) != "" {
+		return fmt.Errorf("profile dumps require --%s", pprofDumpDirFlag.Name)
+	}
+
+	// continuous profiling
+	if pushURL := ctx.GlobalString(pprofPushURLFlag.Name); pushURL != "" {
+		// The CPU profile spans the whole run, the pusher would never get to capture one
+		if ctx.GlobalString(cpuprofileFlag.Name) != "" {
+			return fmt.Errorf("continuous profiling cannot be used along --%s", cpuprofileFlag.Name)
+		}
+
+		pusher, err := newProfilePusher(pushURL, ctx.GlobalString(pprofPushAppFlag.Name), ctx.GlobalString(pprofPushTokenFlag.Name), ctx.GlobalDuration(pprofPushIntervalFlag.Name))
+		if err != nil {
+			return err
+		}
+		log.Info("Starting continuous profiling", "url", pushURL, "interval", pusher.interval)
+		go pusher.run()
+	}
+
 	// pprof server
 	if ctx.GlobalBool(pprofFlag.Name) {
 		address := fmt.Sprintf("%s:%d", ctx.GlobalString(pprofAddrFlag.Name), ctx.GlobalInt(pprofPortFlag.Name))
@@ -186,10 +747,243 @@ func Setup(ctx *cli.Context, logdir string, genesis *core.Genesis) error {
 
 	// Firehose
 	log.Info("Initializing firehose")
+	if err := migrateFirehoseFlags(ctx); err != nil {
+		return err
+	}
+
 	firehose.Enabled = ctx.GlobalBool(firehoseEnabledFlag.Name)
 	firehose.SyncInstrumentationEnabled = ctx.GlobalBoolT(firehoseSyncInstrumentationFlag.Name)
 	firehose.MiningEnabled = ctx.GlobalBool(firehoseMiningEnabledFlag.Name)
 	firehose.BlockProgressEnabled = ctx.GlobalBool(firehoseBlockProgressFlag.Name)
+	firehose.BlockProgressDetailsEnabled = ctx.GlobalBool(firehoseBlockProgressDetailsFlag.Name)
+	firehose.FollowerModeEnabled = ctx.GlobalBool(firehoseFollowerFlag.Name)
+	firehose.StartBlockNumber = ctx.GlobalUint64(firehoseStartBlockFlag.Name)
+	firehose.ReducedOrdinalsEnabled = ctx.GlobalBool(firehoseReducedOrdinalsFlag.Name)
+	firehose.NetBalanceChangesEnabled = ctx.GlobalBool(firehoseNetBalanceChangesFlag.Name)
+	firehose.GasChangeCoalescingEnabled = ctx.GlobalBool(firehoseGasChangeCoalescingFlag.Name)
+	firehose.TrxGasRefundsEnabled = ctx.GlobalBool(firehoseTrxGasRefundsFlag.Name)
+	firehose.CallInstrumentationEnabled = ctx.GlobalBool(firehoseCallInstrumentationFlag.Name)
+	firehose.CallBufferLimitInBytes = ctx.GlobalInt(firehoseCallBufferLimitFlag.Name)
+	firehose.ReturnDataLimitInBytes = ctx.GlobalInt(firehoseReturnDataLimitFlag.Name)
+	firehose.CallAccessSetsEnabled = ctx.GlobalBool(firehoseCallAccessSetsFlag.Name)
+	firehose.BlockShardSizeInBytes = ctx.GlobalInt(firehoseBlockShardSizeFlag.Name)
+	firehose.SpillThresholdInBytes = ctx.GlobalInt(firehoseSpillThresholdFlag.Name)
+	firehose.CompactCodeChangesEnabled = ctx.GlobalBool(firehoseCompactCodeChangesFlag.Name)
+	firehose.StrictEnabled = ctx.GlobalBoolT(firehoseStrictFlag.Name)
+	firehose.RecoveryLimit = ctx.GlobalInt(firehoseRecoveryLimitFlag.Name)
+	firehose.RecoveryWindowInBlocks = ctx.GlobalUint64(firehoseRecoveryWindowFlag.Name)
+	firehose.TrieCommitStatsEnabled = ctx.GlobalBool(firehoseTrieCommitStatsFlag.Name)
+	firehose.TrxFromPubkeyEnabled = ctx.GlobalBool(firehoseTrxFromPubkeyFlag.Name)
+	firehose.StorageKeyPreimagesEnabled = ctx.GlobalBool(firehoseStorageKeyPreimagesFlag.Name)
+	firehose.SlowTransactionThreshold = ctx.GlobalDuration(firehoseSlowTrxThresholdFlag.Name)
+	firehose.UncleBlocksEnabled = ctx.GlobalBool(firehoseUncleBlocksFlag.Name)
+	firehose.LogsBloomCheckEnabled = ctx.GlobalBool(firehoseLogsBloomCheckFlag.Name)
+	firehose.FeeRecipientCreditEnabled = ctx.GlobalBool(firehoseFeeRecipientCreditFlag.Name)
+	firehose.GenesisAllocFromStateEnabled = ctx.GlobalBool(firehoseGenesisFromStateFlag.Name)
+	firehose.GenesisAllocReaders = ctx.GlobalInt(firehoseGenesisReadersFlag.Name)
+	firehose.StorageWipesEnabled = ctx.GlobalBool(firehoseStorageWipesFlag.Name)
+	firehose.BlockHashReadsEnabled = ctx.GlobalBool(firehoseBlockHashReadsFlag.Name)
+	firehose.MonotonicTimestampsEnabled = ctx.GlobalBool(firehoseMonotonicTimestampsFlag.Name)
+	firehose.SequenceNumbersEnabled = ctx.GlobalBool(firehoseSequenceNumbersFlag.Name)
+	firehose.OrderingCheckpointsEnabled = ctx.GlobalBool(firehoseOrderingCheckpointsFlag.Name)
+
+	if specs := ctx.GlobalString(firehoseAnnotationsFlag.Name); specs != "" {
+		for _, spec := range strings.Split(specs, ",") {
+			key, value, err := firehose.ParseBlockAnnotation(strings.TrimSpace(spec))
+			if err == nil {
+				err = firehose.SetBlockAnnotation(key, value)
+			}
+			if err != nil {
+				return fmt.Errorf("firehose annotations: %w", err)
+			}
+		}
+	}
+
+	abortMode, err := firehose.ParseAbortMode(ctx.GlobalString(firehoseCallAbortModeFlag.Name))
+	if err != nil {
+		return fmt.Errorf("firehose calls abort mode: %w", err)
+	}
+	firehose.CallAbortMode = abortMode
+
+	stateProofAccounts, err := firehose.ParseStateProofAccounts(ctx.GlobalString(firehoseStateProofsFlag.Name))
+	if err != nil {
+		return fmt.Errorf("firehose state proofs: %w", err)
+	}
+	firehose.StateProofAccounts = stateProofAccounts
+
+	if dir := ctx.GlobalString(firehoseCodeStoreFlag.Name); dir != "" {
+		store, err := firehose.NewDirectoryCodeStore(dir)
+		if err != nil {
+			return fmt.Errorf("firehose code store: %w", err)
+		}
+		firehose.ContentAddressedCodeStore = store
+	}
+
+	if err := firehose.SetVariant(params.Variant); err != nil {
+		return fmt.Errorf("firehose: %w", err)
+	}
+
+	if err := firehose.SetOutputFormat(firehose.OutputFormat(ctx.GlobalString(firehoseOutputFormatFlag.Name))); err != nil {
+		return fmt.Errorf("firehose output format: %w", err)
+	}
+
+	if err := firehose.SetOutputMiddlewares(ctx.GlobalString(firehoseOutputMiddlewaresFlag.Name)); err != nil {
+		return fmt.Errorf("firehose output middlewares: %w", err)
+	}
+
+	firehose.SetOutputSizing(ctx.GlobalDuration(firehoseSizingIntervalFlag.Name))
+
+	if secondaryOutput := ctx.GlobalString(firehoseSecondaryOutputFileFlag.Name); secondaryOutput != "" {
+		writer, err := filesink.Open(secondaryOutput, false)
+		if err != nil {
+			return fmt.Errorf("firehose secondary output file: %w", err)
+		}
+
+		firehoseSecondaryFileWriter = writer
+		if err := firehose.SetSecondarySyncContextWriter(writer, firehose.OutputFormat(ctx.GlobalString(firehoseSecondaryOutputFormatFlag.Name))); err != nil {
+			return fmt.Errorf("firehose secondary output format: %w", err)
+		}
+	}
+
+	if miningOutput := ctx.GlobalString(firehoseMiningOutputFlag.Name); miningOutput != "" {
+		file, err := os.OpenFile(miningOutput, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			return fmt.Errorf("firehose open mining output: %w", err)
+		}
+
+		firehose.SetMiningContextWriter(file)
+	}
+
+	if outputFile := ctx.GlobalString(firehoseOutputFileFlag.Name); outputFile != "" {
+		writer, err := filesink.Open(outputFile, ctx.GlobalBool(firehoseBlockIndexFlag.Name))
+		if err != nil {
+			return fmt.Errorf("firehose output file: %w", err)
+		}
+
+		firehoseFileWriter = writer
+		firehose.SetSyncContextWriter(firehoseFileWriter)
+	} else if ctx.GlobalBool(firehoseBlockIndexFlag.Name) {
+		return fmt.Errorf("firehose block index requires --%s", firehoseOutputFileFlag.Name)
+	}
+
+	// The block index and the object store bundles are computed from the lines written to
+	// them, which are no longer readable once transformed by the middlewares.
+	if middlewares := ctx.GlobalString(firehoseOutputMiddlewaresFlag.Name); middlewares != "" {
+		if ctx.GlobalBool(firehoseBlockIndexFlag.Name) {
+			return fmt.Errorf("firehose output middlewares cannot be used along --%s", firehoseBlockIndexFlag.Name)
+		}
+		if ctx.GlobalString(firehoseObjectStoreURLFlag.Name) != "" {
+			return fmt.Errorf("firehose output middlewares cannot be used along --%s", firehoseObjectStoreURLFlag.Name)
+		}
+	}
+
+	if objectStoreURL := ctx.GlobalString(firehoseObjectStoreURLFlag.Name); objectStoreURL != "" {
+		if firehoseFileWriter != nil {
+			return fmt.Errorf("firehose object store cannot be used along another output sink")
+		}
+
+		config := &objectstore.Config{
+			URL:             objectStoreURL,
+			Endpoint:        ctx.GlobalString(firehoseObjectStoreEndpointFlag.Name),
+			Region:          ctx.GlobalString(firehoseObjectStoreRegionFlag.Name),
+			BlocksPerBundle: ctx.GlobalUint64(firehoseObjectStoreBundleSizeFlag.Name),
+			NameFormat:      ctx.GlobalString(firehoseObjectStoreNameFormatFlag.Name),
+			MaxRetries:      ctx.GlobalInt(firehoseObjectStoreRetriesFlag.Name),
+		}
+
+		uploader, err := objectstore.NewS3Uploader(config)
+		if err != nil {
+			return fmt.Errorf("firehose object store: %w", err)
+		}
+
+		firehoseObjectStoreWriter = objectstore.NewWriter(uploader, config)
+		firehose.SetSyncContextWriter(firehoseObjectStoreWriter)
+	}
+
+	tlsConfig := &socketsink.TLSConfig{
+		CertFile: ctx.GlobalString(firehoseOutputTLSCertFlag.Name),
+		KeyFile:  ctx.GlobalString(firehoseOutputTLSKeyFlag.Name),
+		CAFile:   ctx.GlobalString(firehoseOutputTLSCAFlag.Name),
+	}
+
+	if socketAddress := ctx.GlobalString(firehoseOutputSocketFlag.Name); socketAddress != "" {
+		if firehoseObjectStoreWriter != nil || firehoseFileWriter != nil {
+			return fmt.Errorf("firehose socket output cannot be used along another output sink")
+		}
+
+		retryConfig := netsink.DefaultConfig
+		retryConfig.MaxBackoff = ctx.GlobalDuration(firehoseOutputBackoffMaxFlag.Name)
+		retryConfig.FailureThreshold = ctx.GlobalInt(firehoseOutputBreakerThresholdFlag.Name)
+		retryConfig.Cooldown = ctx.GlobalDuration(firehoseOutputBreakerCooldownFlag.Name)
+
+		writer, err := socketsink.Open(socketAddress, tlsConfig, retryConfig)
+		if err != nil {
+			return fmt.Errorf("firehose socket output: %w", err)
+		}
+
+		firehoseSocketWriter = writer
+		firehose.SetSyncContextWriter(firehoseSocketWriter)
+	} else if tlsConfig.Enabled() {
+		return fmt.Errorf("firehose output TLS requires --%s", firehoseOutputSocketFlag.Name)
+	}
+
+	if readerPath := ctx.GlobalString(firehoseOutputReaderFlag.Name); readerPath != "" {
+		if firehoseObjectStoreWriter != nil || firehoseFileWriter != nil || firehoseSocketWriter != nil {
+			return fmt.Errorf("firehose output reader cannot be used along another output sink")
+		}
+		// The restart handshake lines are injected in the stream, which must remain readable
+		if ctx.GlobalString(firehoseOutputMiddlewaresFlag.Name) != "" {
+			return fmt.Errorf("firehose output middlewares cannot be used along --%s", firehoseOutputReaderFlag.Name)
+		}
+
+		writer, err := sidecar.Open(sidecar.Config{
+			Path:         readerPath,
+			Args:         strings.Fields(ctx.GlobalString(firehoseOutputReaderArgsFlag.Name)),
+			StallTimeout: ctx.GlobalDuration(firehoseOutputReaderStallTimeoutFlag.Name),
+			Restart:      netsink.DefaultConfig,
+			NDJSON:       firehose.OutputFormat(ctx.GlobalString(firehoseOutputFormatFlag.Name)) == firehose.NDJSONOutputFormat,
+		})
+		if err != nil {
+			return fmt.Errorf("firehose output reader: %w", err)
+		}
+
+		firehoseReaderWriter = writer
+		firehose.SetSyncContextWriter(firehoseReaderWriter)
+	}
+
+	if ctx.GlobalBool(firehoseDryRunFlag.Name) {
+		if firehoseObjectStoreWriter != nil || firehoseFileWriter != nil || firehoseSocketWriter != nil || firehoseReaderWriter != nil {
+			return fmt.Errorf("firehose dry-run cannot be used along an output sink, the output is discarded")
+		}
+
+		firehoseDryRunWriter = firehose.NewDryRunWriter()
+		firehose.SetSyncContextWriter(firehoseDryRunWriter)
+	}
+
+	// Firehose output is meant to be consumed by a reader process, printed to a terminal, the
+	// amount of data printed renders it unusable, so we refuse to start unless forced.
+	if firehose.Enabled && firehoseObjectStoreWriter == nil && firehoseFileWriter == nil && firehoseSocketWriter == nil && firehoseReaderWriter == nil && firehoseDryRunWriter == nil && (isatty.IsTerminal(os.Stdout.Fd()) || isatty.IsCygwinTerminal(os.Stdout.Fd())) {
+		if !ctx.GlobalBool(firehoseForceTTYFlag.Name) {
+			return fmt.Errorf("firehose is enabled but standard output is a terminal, redirect it to a pipe or a file, or use --%s to print to the terminal anyway", firehoseForceTTYFlag.Name)
+		}
+
+		log.Warn("Firehose output is printed to a terminal", "flag", firehoseForceTTYFlag.Name)
+	}
+
+	stallConfig := firehose.StallAlarmConfig{
+		MaxLag:   ctx.GlobalUint64(firehoseStallMaxLagFlag.Name),
+		Duration: ctx.GlobalDuration(firehoseStallDurationFlag.Name),
+		Webhook:  ctx.GlobalString(firehoseStallWebhookFlag.Name),
+	}
+	if firehose.Enabled {
+		firehose.StartEmissionLagMonitor(stallConfig)
+	}
+
+	if referenceRPC := ctx.GlobalString(firehoseReferenceRPCFlag.Name); referenceRPC != "" {
+		if err := firehose.EnableDifferentialValidation(referenceRPC); err != nil {
+			return fmt.Errorf("firehose differential validation: %w", err)
+		}
+	}
 
 	genesisProvenance := "unset"
 
@@ -221,13 +1015,72 @@ func Setup(ctx *cli.Context, logdir string, genesis *core.Genesis) error {
 		"enabled", firehose.Enabled,
 		"sync_instrumentation_enabled", firehose.SyncInstrumentationEnabled,
 		"mining_enabled", firehose.MiningEnabled,
+		"mining_output", ctx.GlobalString(firehoseMiningOutputFlag.Name),
+		"output_format", ctx.GlobalString(firehoseOutputFormatFlag.Name),
+		"output_middlewares", ctx.GlobalString(firehoseOutputMiddlewaresFlag.Name),
 		"block_progress_enabled", firehose.BlockProgressEnabled,
+		"block_progress_details_enabled", firehose.BlockProgressDetailsEnabled,
+		"follower_mode_enabled", firehose.FollowerModeEnabled,
+		"start_block", firehose.StartBlockNumber,
+		"reduced_ordinals_enabled", firehose.ReducedOrdinalsEnabled,
+		"net_balance_changes_enabled", firehose.NetBalanceChangesEnabled,
+		"gas_change_coalescing_enabled", firehose.GasChangeCoalescingEnabled,
+		"trx_gas_refunds_enabled", firehose.TrxGasRefundsEnabled,
+		"call_instrumentation_enabled", firehose.CallInstrumentationEnabled,
+		"call_buffer_limit", firehose.CallBufferLimitInBytes,
+		"return_data_limit", firehose.ReturnDataLimitInBytes,
+		"call_abort_mode", firehose.CallAbortMode,
+		"call_access_sets_enabled", firehose.CallAccessSetsEnabled,
+		"block_shard_size", firehose.BlockShardSizeInBytes,
+		"spill_threshold", firehose.SpillThresholdInBytes,
+		"compact_code_changes_enabled", firehose.CompactCodeChangesEnabled,
+		"code_store", ctx.GlobalString(firehoseCodeStoreFlag.Name),
+		"strict_enabled", firehose.StrictEnabled,
+		"recovery_limit", firehose.RecoveryLimit,
+		"recovery_window", firehose.RecoveryWindowInBlocks,
+		"trie_commit_stats_enabled", firehose.TrieCommitStatsEnabled,
+		"trx_from_pubkey_enabled", firehose.TrxFromPubkeyEnabled,
+		"storage_key_preimages_enabled", firehose.StorageKeyPreimagesEnabled,
+		"slow_trx_threshold", firehose.SlowTransactionThreshold,
+		"uncle_blocks_enabled", firehose.UncleBlocksEnabled,
+		"logs_bloom_check_enabled", firehose.LogsBloomCheckEnabled,
+		"fee_recipient_credit_enabled", firehose.FeeRecipientCreditEnabled,
+		"state_proof_accounts", len(firehose.StateProofAccounts),
+		"storage_wipes_enabled", firehose.StorageWipesEnabled,
+		"block_hash_reads_enabled", firehose.BlockHashReadsEnabled,
+		"monotonic_timestamps_enabled", firehose.MonotonicTimestampsEnabled,
+		"sequence_numbers_enabled", firehose.SequenceNumbersEnabled,
+		"ordering_checkpoints_enabled", firehose.OrderingCheckpointsEnabled,
+		"annotations", firehose.BlockAnnotations(),
+		"output_file", ctx.GlobalString(firehoseOutputFileFlag.Name),
+		"block_index_enabled", ctx.GlobalBool(firehoseBlockIndexFlag.Name),
+		"object_store_url", ctx.GlobalString(firehoseObjectStoreURLFlag.Name),
+		"output_socket", ctx.GlobalString(firehoseOutputSocketFlag.Name),
+		"output_reader", ctx.GlobalString(firehoseOutputReaderFlag.Name),
+		"output_reader_args", ctx.GlobalString(firehoseOutputReaderArgsFlag.Name),
+		"output_reader_stall_timeout", ctx.GlobalDuration(firehoseOutputReaderStallTimeoutFlag.Name),
+		"dry_run", ctx.GlobalBool(firehoseDryRunFlag.Name),
+		"sizing_interval", ctx.GlobalDuration(firehoseSizingIntervalFlag.Name),
+		"secondary_output_file", ctx.GlobalString(firehoseSecondaryOutputFileFlag.Name),
+		"secondary_output_format", ctx.GlobalString(firehoseSecondaryOutputFormatFlag.Name),
+		"output_tls_enabled", tlsConfig.Enabled(),
+		"output_backoff_max", ctx.GlobalDuration(firehoseOutputBackoffMaxFlag.Name),
+		"output_breaker_threshold", ctx.GlobalInt(firehoseOutputBreakerThresholdFlag.Name),
+		"output_breaker_cooldown", ctx.GlobalDuration(firehoseOutputBreakerCooldownFlag.Name),
+		"reference_rpc", ctx.GlobalString(firehoseReferenceRPCFlag.Name),
+		"stall_max_lag", stallConfig.MaxLag,
+		"stall_duration", stallConfig.Duration,
+		"stall_webhook", stallConfig.Webhook,
 		"genesis_provenance", genesisProvenance,
+		"genesis_alloc_from_state_enabled", firehose.GenesisAllocFromStateEnabled,
+		"genesis_alloc_readers", firehose.GenesisAllocReaders,
 		"firehose_version", params.FirehoseVersion(),
 		"geth_version", params.VersionWithMeta,
 		"chain_variant", params.Variant,
 	)
 
+	recordFirehoseConfig(ctx, genesisProvenance)
+
 	return nil
 }
 
@@ -236,6 +1089,8 @@ func StartPProf(address string) {
 	// from the registry into expvar, and execute regular expvar handler.
 	exp.Exp(metrics.DefaultRegistry)
 	http.Handle("/memsize/", http.StripPrefix("/memsize", &Memsize))
+	http.Handle("/debug/firehose", firehoseHealthHandler())
+	http.Handle("/debug/firehose/config", firehoseConfigHandler())
 	log.Info("Starting pprof server", "addr", fmt.Sprintf("http://%s/debug/pprof", address))
 	go func() {
 		if err := http.ListenAndServe(address, nil); err != nil {
@@ -249,4 +1104,38 @@ func StartPProf(address string) {
 func Exit() {
 	Handler.StopCPUProfile()
 	Handler.StopGoTrace()
+
+	if firehoseObjectStoreWriter != nil {
+		if err := firehoseObjectStoreWriter.Flush(); err != nil {
+			log.Error("Failed to upload last Firehose bundle", "err", err)
+		}
+	}
+
+	if err := firehose.CloseOutputMiddlewares(); err != nil {
+		log.Error("Failed to close Firehose output middlewares", "err", err)
+	}
+
+	if firehoseFileWriter != nil {
+		if err := firehoseFileWriter.Close(); err != nil {
+			log.Error("Failed to close Firehose output file", "err", err)
+		}
+	}
+
+	if firehoseSocketWriter != nil {
+		if err := firehoseSocketWriter.Close(); err != nil {
+			log.Error("Failed to close Firehose output socket", "err", err)
+		}
+	}
+
+	if firehoseReaderWriter != nil {
+		if err := firehoseReaderWriter.Close(); err != nil {
+			log.Error("Failed to close Firehose output reader", "err", err)
+		}
+	}
+
+	if firehoseSecondaryFileWriter != nil {
+		if err := firehoseSecondaryFileWriter.Close(); err != nil {
+			log.Error("Failed to close Firehose secondary output file", "err", err)
+		}
+	}
 }
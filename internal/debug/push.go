@@ -0,0 +1,137 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package debug
+
+import (
+	"bytes"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"runtime/pprof"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// profilePusher periodically captures CPU and heap profiles and pushes them in pprof format
+// to a Pyroscope compatible ingestion endpoint (`POST <url>/ingest`).
+type profilePusher struct {
+	url      string
+	app      string
+	token    string
+	interval time.Duration
+	client   *http.Client
+}
+
+func newProfilePusher(rawURL, app, token string, interval time.Duration) (*profilePusher, error) {
+	if _, err := url.Parse(rawURL); err != nil {
+		return nil, fmt.Errorf("invalid profile push url: %w", err)
+	}
+	if interval < time.Second {
+		return nil, fmt.Errorf("profile push interval must be at least 1s, got %s", interval)
+	}
+
+	return &profilePusher{
+		url:      strings.TrimSuffix(rawURL, "/") + "/ingest",
+		app:      app,
+		token:    token,
+		interval: interval,
+		client:   &http.Client{Timeout: 30 * time.Second},
+	}, nil
+}
+
+// run captures a CPU profile over each interval and a heap profile at its end, forever.
+// The CPU profiler is shared with the debug API through `Handler`, a cycle whose CPU profile
+// can't be captured, because one was started through the debug API, only pushes the heap
+// profile.
+func (p *profilePusher) run() {
+	for {
+		from := time.Now()
+
+		cpu := new(bytes.Buffer)
+		captured := Handler.startPushedCPUProfile(cpu)
+		if !captured {
+			log.Debug("Skipping continuous CPU profile, profiler busy")
+		}
+		time.Sleep(p.interval)
+		if captured && !Handler.stopPushedCPUProfile() {
+			log.Debug("Dropping continuous CPU profile, stopped through the debug API")
+			captured = false
+		}
+		until := time.Now()
+
+		if captured {
+			if err := p.push("cpu", cpu, from, until); err != nil {
+				log.Warn("Failed to push CPU profile", "url", p.url, "err", err)
+			}
+		}
+
+		heap := new(bytes.Buffer)
+		if err := pprof.Lookup("heap").WriteTo(heap, 0); err != nil {
+			log.Warn("Failed to capture heap profile", "err", err)
+			continue
+		}
+		if err := p.push("heap", heap, from, until); err != nil {
+			log.Warn("Failed to push heap profile", "url", p.url, "err", err)
+		}
+	}
+}
+
+func (p *profilePusher) push(kind string, profile *bytes.Buffer, from, until time.Time) error {
+	body := new(bytes.Buffer)
+	form := multipart.NewWriter(body)
+	part, err := form.CreateFormFile("profile", kind+".pprof")
+	if err != nil {
+		return err
+	}
+	if _, err := part.Write(profile.Bytes()); err != nil {
+		return err
+	}
+	if err := form.Close(); err != nil {
+		return err
+	}
+
+	query := url.Values{}
+	query.Set("name", p.app+"."+kind)
+	query.Set("from", strconv.FormatInt(from.Unix(), 10))
+	query.Set("until", strconv.FormatInt(until.Unix(), 10))
+	query.Set("format", "pprof")
+	query.Set("spyName", "gospy")
+
+	req, err := http.NewRequest(http.MethodPost, p.url+"?"+query.Encode(), body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	if p.token != "" {
+		req.Header.Set("Authorization", "Bearer "+p.token)
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("endpoint responded with status %s", resp.Status)
+	}
+	return nil
+}
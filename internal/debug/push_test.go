@@ -0,0 +1,101 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package debug
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestNewProfilePusher(t *testing.T) {
+	if _, err := newProfilePusher("http://localhost:4040", "geth", "", 500*time.Millisecond); err == nil {
+		t.Error("expected interval below 1s to be rejected")
+	}
+	if _, err := newProfilePusher("http://[::1", "geth", "", time.Minute); err == nil {
+		t.Error("expected invalid url to be rejected")
+	}
+
+	p, err := newProfilePusher("http://localhost:4040/", "geth", "", time.Minute)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if p.url != "http://localhost:4040/ingest" {
+		t.Errorf("ingest url mismatch: have %s, want http://localhost:4040/ingest", p.url)
+	}
+}
+
+func TestProfilePusherPush(t *testing.T) {
+	var (
+		request *http.Request
+		profile []byte
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		request = r
+		file, _, err := r.FormFile("profile")
+		if err != nil {
+			t.Errorf("missing profile form file: %v", err)
+			return
+		}
+		defer file.Close()
+		profile, _ = ioutil.ReadAll(file)
+	}))
+	defer server.Close()
+
+	p, err := newProfilePusher(server.URL, "geth", "secret", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	from, until := time.Unix(100, 0), time.Unix(160, 0)
+	if err := p.push("heap", bytes.NewBufferString("pprof data"), from, until); err != nil {
+		t.Fatalf("push failed: %v", err)
+	}
+
+	if request.URL.Path != "/ingest" {
+		t.Errorf("path mismatch: have %s, want /ingest", request.URL.Path)
+	}
+	query := request.URL.Query()
+	for key, want := range map[string]string{"name": "geth.heap", "from": "100", "until": "160", "format": "pprof", "spyName": "gospy"} {
+		if have := query.Get(key); have != want {
+			t.Errorf("query %s mismatch: have %q, want %q", key, have, want)
+		}
+	}
+	if have := request.Header.Get("Authorization"); have != "Bearer secret" {
+		t.Errorf("authorization header mismatch: have %q, want %q", have, "Bearer secret")
+	}
+	if string(profile) != "pprof data" {
+		t.Errorf("profile mismatch: have %q, want %q", profile, "pprof data")
+	}
+}
+
+func TestProfilePusherPushStatus(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	p, err := newProfilePusher(server.URL, "geth", "", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.push("cpu", new(bytes.Buffer), time.Now(), time.Now()); err == nil {
+		t.Error("expected push to fail on non 2xx status")
+	}
+}
@@ -19,6 +19,7 @@ package ethapi
 import (
 	"bytes"
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"math/big"
@@ -856,6 +857,7 @@ func DoCall(ctx context.Context, b Backend, args CallArgs, blockNrOrHash rpc.Blo
 		<-ctx.Done()
 		evm.Cancel()
 	}()
+	firehoseContext.OnBufferLimitExceeded(evm.Cancel)
 
 	if firehoseContext.Enabled() {
 		firehoseContext.StartTransactionRaw(
@@ -872,8 +874,9 @@ func DoCall(ctx context.Context, b Backend, args CallArgs, blockNrOrHash rpc.Blo
 			nil,
 			0,
 			0,
+			firehose.GasPriceFeeKind(msg.GasPrice()),
 		)
-		firehoseContext.RecordTrxFrom(msg.From())
+		firehoseContext.RecordTrxFrom(msg.From(), nil)
 	}
 
 	// Setup the gas pool (also for unmetered requests)
@@ -883,9 +886,16 @@ func DoCall(ctx context.Context, b Backend, args CallArgs, blockNrOrHash rpc.Blo
 	if err := vmError(); err != nil {
 		return nil, 0, false, err
 	}
+	if firehoseContext.BufferLimitExceeded() {
+		return nil, 0, false, firehose.ErrBufferLimitExceeded
+	}
 	// If the timer caused an abort, return an appropriate error message
 	if evm.Cancelled() {
-		return nil, 0, false, fmt.Errorf("execution aborted (timeout = %v)", timeout)
+		err := fmt.Errorf("execution aborted (timeout = %v)", timeout)
+		if firehoseContext.Enabled() && firehose.CallAbortMode == firehose.CloseAbortMode {
+			firehoseContext.AbortTransaction(err.Error())
+		}
+		return nil, 0, false, err
 	}
 
 	if firehoseContext.Enabled() {
@@ -1790,6 +1800,208 @@ func (api *PublicDebugAPI) PrintBlock(ctx context.Context, number uint64) (strin
 	return spew.Sdump(block), nil
 }
 
+// FirehoseCallResult is the result of a `debug_callWithFirehoseTrace` execution, it holds
+// the call's return data alongside the Firehose log accumulated during the execution.
+type FirehoseCallResult struct {
+	ReturnData  hexutil.Bytes  `json:"returnData"`
+	GasUsed     hexutil.Uint64 `json:"gasUsed"`
+	Failed      bool           `json:"failed"`
+	FirehoseLog string         `json:"firehoseLog"`
+	AbortReason string         `json:"abortReason,omitempty"`
+}
+
+// CallWithFirehoseTrace executes the given call like `eth_call` does but instruments the
+// execution through a speculative Firehose context, returning the accumulated Firehose log
+// along the call result. It's available only when `--firehose.calls` is set.
+//
+// The accumulated log is bounded by `--firehose.calls.bufferlimit`, the optional `bufferLimit`
+// argument can lower the bound for this request, the call fails once it's exceeded. A call
+// aborted by its timeout fails too, unless `--firehose.calls.onabort` is `close` in which case
+// the log closed by `firehose.Context.AbortTransaction` is returned along the abort reason.
+func (api *PublicDebugAPI) CallWithFirehoseTrace(ctx context.Context, args CallArgs, blockNrOrHash rpc.BlockNumberOrHash, overrides *map[common.Address]account, bufferLimit *hexutil.Uint64) (*FirehoseCallResult, error) {
+	if !firehose.CallInstrumentationEnabled {
+		return nil, errors.New("firehose call instrumentation is disabled, enable it with --firehose.calls")
+	}
+
+	var accounts map[common.Address]account
+	if overrides != nil {
+		accounts = *overrides
+	}
+
+	limit := firehose.CallBufferLimitInBytes
+	if bufferLimit != nil && *bufferLimit > 0 && (limit <= 0 || uint64(*bufferLimit) < uint64(limit)) {
+		limit = int(*bufferLimit)
+	}
+
+	firehoseContext := firehose.NewBoundedSpeculativeExecutionContext(128*1024, limit)
+	result, gas, failed, err := DoCall(ctx, api.b, args, blockNrOrHash, accounts, vm.Config{}, 5*time.Second, api.b.RPCGasCap(), firehoseContext)
+	if reason := firehoseContext.AbortReason(); reason != "" {
+		return &FirehoseCallResult{
+			Failed:      true,
+			FirehoseLog: string(firehoseContext.FirehoseLog()),
+			AbortReason: reason,
+		}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &FirehoseCallResult{
+		ReturnData:  result,
+		GasUsed:     hexutil.Uint64(gas),
+		Failed:      failed,
+		FirehoseLog: string(firehoseContext.FirehoseLog()),
+	}, nil
+}
+
+// PublicFirehoseAPI provides Firehose specific APIs, they are only served when the `firehose`
+// namespace is enabled on the RPC endpoints.
+type PublicFirehoseAPI struct {
+	b Backend
+}
+
+// NewPublicFirehoseAPI creates a new API definition for the Firehose methods.
+func NewPublicFirehoseAPI(b Backend) *PublicFirehoseAPI {
+	return &PublicFirehoseAPI{b: b}
+}
+
+// FirehosePendingTraceResult is the result of a `firehose_tracePendingTransaction` execution,
+// the accumulated Firehose log is also given as structured events, each being the NDJSON
+// output format rendering of a line. The block number is the one of the pending block, the
+// transaction being traced as if it was included in it.
+type FirehosePendingTraceResult struct {
+	FirehoseCallResult
+	BlockNumber hexutil.Uint64    `json:"blockNumber"`
+	Events      []json.RawMessage `json:"events"`
+}
+
+// TracePendingTransaction executes the pending transaction `hash` of the transaction pool
+// against the state of the current head, instrumented through a speculative Firehose context,
+// and returns its Firehose trace. Nothing is committed, the trace tells what the transaction
+// would do if it was the first one included in the next block. It's available only when
+// `--firehose.calls` is set, the trace being bounded by `--firehose.calls.bufferlimit`.
+func (api *PublicFirehoseAPI) TracePendingTransaction(ctx context.Context, hash common.Hash) (*FirehosePendingTraceResult, error) {
+	if !firehose.CallInstrumentationEnabled {
+		return nil, errors.New("firehose call instrumentation is disabled, enable it with --firehose.calls")
+	}
+
+	tx := api.b.GetPoolTransaction(hash)
+	if tx == nil {
+		return nil, fmt.Errorf("transaction %s not found in the transaction pool", hash.Hex())
+	}
+
+	state, header, err := api.b.StateAndHeaderByNumber(ctx, rpc.LatestBlockNumber)
+	if state == nil || err != nil {
+		return nil, err
+	}
+
+	signer := types.MakeSigner(api.b.ChainConfig(), header.Number)
+	msg, err := tx.AsMessage(signer)
+	if err != nil {
+		return nil, err
+	}
+
+	pendingNumber := new(big.Int).Add(header.Number, common.Big1)
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	firehoseContext := firehose.NewBoundedSpeculativeExecutionContext(128*1024, firehose.CallBufferLimitInBytes)
+	evm, vmError, err := api.b.GetEVM(ctx, msg, state, header, firehoseContext)
+	if err != nil {
+		return nil, err
+	}
+	go func() {
+		<-ctx.Done()
+		evm.Cancel()
+	}()
+	firehoseContext.OnBufferLimitExceeded(evm.Cancel)
+
+	state.Prepare(tx.Hash(), common.Hash{}, 0)
+	firehoseContext.StartTransaction(tx, 0, nil)
+
+	var pubkey []byte
+	if firehose.TrxFromPubkeyEnabled {
+		if pubkey, err = types.SenderPubkey(signer, tx); err != nil {
+			return nil, err
+		}
+	}
+	firehoseContext.RecordTrxFrom(msg.From(), pubkey)
+
+	res, gas, failed, err := core.ApplyMessage(evm, msg, new(core.GasPool).AddGas(header.GasLimit))
+	if err := vmError(); err != nil {
+		return nil, err
+	}
+	if firehoseContext.BufferLimitExceeded() {
+		return nil, firehose.ErrBufferLimitExceeded
+	}
+	if evm.Cancelled() {
+		err := errors.New("execution aborted (timeout = 5s)")
+		if firehose.CallAbortMode != firehose.CloseAbortMode {
+			return nil, err
+		}
+
+		firehoseContext.AbortTransaction(err.Error())
+		return newFirehosePendingTraceResult(FirehoseCallResult{
+			Failed:      true,
+			FirehoseLog: string(firehoseContext.FirehoseLog()),
+			AbortReason: err.Error(),
+		}, pendingNumber)
+	}
+	if err != nil {
+		// The transaction can't be applied on the head state (nonce gap, insufficient funds, ...)
+		return nil, err
+	}
+
+	config := api.b.ChainConfig()
+	var root []byte
+	if config.IsByzantium(header.Number) {
+		state.Finalise(true)
+	} else {
+		root = state.IntermediateRoot(config.IsEIP158(header.Number)).Bytes()
+	}
+
+	receipt := types.NewReceipt(root, failed, gas)
+	receipt.TxHash = tx.Hash()
+	receipt.GasUsed = gas
+	if msg.To() == nil {
+		receipt.ContractAddress = crypto.CreateAddress(msg.From(), tx.Nonce())
+	}
+	receipt.Logs = state.GetLogs(tx.Hash())
+	receipt.Bloom = types.CreateBloom(types.Receipts{receipt})
+	receipt.BlockNumber = pendingNumber
+	firehoseContext.EndTransaction(receipt)
+
+	return newFirehosePendingTraceResult(FirehoseCallResult{
+		ReturnData:  res,
+		GasUsed:     hexutil.Uint64(gas),
+		Failed:      failed,
+		FirehoseLog: string(firehoseContext.FirehoseLog()),
+	}, pendingNumber)
+}
+
+// newFirehosePendingTraceResult completes `result` with the structured events of its Firehose
+// log, `pendingNumber` being the number of the block the transaction was traced for.
+func newFirehosePendingTraceResult(result FirehoseCallResult, pendingNumber *big.Int) (*FirehosePendingTraceResult, error) {
+	var structured bytes.Buffer
+	if _, err := firehose.NewNDJSONWriter(&structured).Write([]byte(result.FirehoseLog)); err != nil {
+		return nil, err
+	}
+
+	events := []json.RawMessage{}
+	for _, line := range bytes.Split(bytes.TrimSuffix(structured.Bytes(), []byte("\n")), []byte("\n")) {
+		if len(line) > 0 {
+			events = append(events, json.RawMessage(line))
+		}
+	}
+
+	return &FirehosePendingTraceResult{
+		FirehoseCallResult: result,
+		BlockNumber:        hexutil.Uint64(pendingNumber.Uint64()),
+		Events:             events,
+	}, nil
+}
+
 // SeedHash retrieves the seed hash of a block.
 func (api *PublicDebugAPI) SeedHash(ctx context.Context, number uint64) (string, error) {
 	block, _ := api.b.BlockByNumber(ctx, rpc.BlockNumber(number))
@@ -1834,6 +2046,17 @@ func (api *PrivateDebugAPI) ChaindbCompact() error {
 	return nil
 }
 
+// ChaindbCompactRange flattens the given key range of the key-value database, a
+// nil start or limit being treated as the beginning or end of the key space.
+func (api *PrivateDebugAPI) ChaindbCompactRange(start, limit hexutil.Bytes) error {
+	log.Info("Compacting chain database", "range", fmt.Sprintf("%#x-%#x", []byte(start), []byte(limit)))
+	if err := api.b.ChainDb().Compact(start, limit); err != nil {
+		log.Error("Database compaction failed", "err", err)
+		return err
+	}
+	return nil
+}
+
 // SetHead rewinds the head of the blockchain to a previous block.
 func (api *PrivateDebugAPI) SetHead(number hexutil.Uint64) {
 	api.b.SetHead(uint64(number))
@@ -0,0 +1,77 @@
+package ethapi
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/firehose"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// pendingTraceBackend serves a single pool transaction on top of a head state, the other
+// Backend methods are not implemented.
+type pendingTraceBackend struct {
+	Backend
+
+	tx     *types.Transaction
+	state  *state.StateDB
+	header *types.Header
+}
+
+func (b *pendingTraceBackend) ChainConfig() *params.ChainConfig { return params.TestChainConfig }
+
+func (b *pendingTraceBackend) GetPoolTransaction(hash common.Hash) *types.Transaction {
+	if b.tx.Hash() == hash {
+		return b.tx
+	}
+	return nil
+}
+
+func (b *pendingTraceBackend) StateAndHeaderByNumber(ctx context.Context, number rpc.BlockNumber) (*state.StateDB, *types.Header, error) {
+	return b.state, b.header, nil
+}
+
+func (b *pendingTraceBackend) GetEVM(ctx context.Context, msg core.Message, state *state.StateDB, header *types.Header, firehoseContext *firehose.Context) (*vm.EVM, func() error, error) {
+	context := core.NewEVMContext(msg, header, nil, &header.Coinbase)
+	return vm.NewEVM(context, state, b.ChainConfig(), vm.Config{}, firehoseContext), func() error { return nil }, nil
+}
+
+func TestTracePendingTransactionBlockNumber(t *testing.T) {
+	defer func(enabled bool) { firehose.CallInstrumentationEnabled = enabled }(firehose.CallInstrumentationEnabled)
+	firehose.CallInstrumentationEnabled = true
+
+	key, _ := crypto.GenerateKey()
+	sender := crypto.PubkeyToAddress(key.PublicKey)
+
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(rawdb.NewMemoryDatabase()))
+	statedb.SetBalance(sender, big.NewInt(params.Ether), firehose.NoOpContext, firehose.IgnoredBalanceChangeReason)
+
+	header := &types.Header{Number: big.NewInt(10), GasLimit: params.GenesisGasLimit, Difficulty: big.NewInt(1)}
+	signer := types.MakeSigner(params.TestChainConfig, header.Number)
+	tx, _ := types.SignTx(types.NewTransaction(0, common.Address{1}, big.NewInt(1), params.TxGas, big.NewInt(1), nil), signer, key)
+
+	api := NewPublicFirehoseAPI(&pendingTraceBackend{tx: tx, state: statedb, header: header})
+	result, err := api.TracePendingTransaction(context.Background(), tx.Hash())
+	if err != nil {
+		t.Fatalf("trace failed: %v", err)
+	}
+
+	if uint64(result.BlockNumber) != 11 {
+		t.Errorf("block number mismatch: have %d, want the pending block 11", result.BlockNumber)
+	}
+	if uint64(result.GasUsed) != params.TxGas || result.Failed {
+		t.Errorf("unexpected execution result, gas used %d, failed %t", result.GasUsed, result.Failed)
+	}
+	if len(result.Events) == 0 {
+		t.Error("expected the trace to have events")
+	}
+}
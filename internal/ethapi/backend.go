@@ -119,6 +119,11 @@ func GetAPIs(apiBackend Backend) []rpc.API {
 			Namespace: "debug",
 			Version:   "1.0",
 			Service:   NewPrivateDebugAPI(apiBackend),
+		}, {
+			Namespace: "firehose",
+			Version:   "1.0",
+			Service:   NewPublicFirehoseAPI(apiBackend),
+			Public:    true,
 		}, {
 			Namespace: "eth",
 			Version:   "1.0",
@@ -25,6 +25,7 @@ var Modules = map[string]string{
 	"ethash":     EthashJs,
 	"debug":      DebugJs,
 	"eth":        EthJs,
+	"firehose":   FirehoseJs,
 	"miner":      MinerJs,
 	"net":        NetJs,
 	"personal":   PersonalJs,
@@ -35,6 +36,19 @@ var Modules = map[string]string{
 	"les":        LESJs,
 }
 
+const FirehoseJs = `
+web3._extend({
+	property: 'firehose',
+	methods: [
+		new web3._extend.Method({
+			name: 'tracePendingTransaction',
+			call: 'firehose_tracePendingTransaction',
+			params: 1
+		}),
+	]
+});
+`
+
 const ChequebookJs = `
 web3._extend({
 	property: 'chequebook',
@@ -180,6 +194,16 @@ web3._extend({
 			call: 'admin_importChain',
 			params: 1
 		}),
+		new web3._extend.Method({
+			name: 'setFirehoseAnnotation',
+			call: 'admin_setFirehoseAnnotation',
+			params: 2
+		}),
+		new web3._extend.Method({
+			name: 'firehoseAnnotations',
+			call: 'admin_firehoseAnnotations',
+			params: 0
+		}),
 		new web3._extend.Method({
 			name: 'sleepBlocks',
 			call: 'admin_sleepBlocks',
@@ -237,6 +261,12 @@ web3._extend({
 			call: 'debug_printBlock',
 			params: 1
 		}),
+		new web3._extend.Method({
+			name: 'callWithFirehoseTrace',
+			call: 'debug_callWithFirehoseTrace',
+			params: 4,
+			inputFormatter: [null, web3._extend.formatters.inputDefaultBlockNumberFormatter, null, null]
+		}),
 		new web3._extend.Method({
 			name: 'getBlockRlp',
 			call: 'debug_getBlockRlp',
@@ -273,16 +303,32 @@ web3._extend({
 			name: 'chaindbCompact',
 			call: 'debug_chaindbCompact',
 		}),
+		new web3._extend.Method({
+			name: 'chaindbCompactRange',
+			call: 'debug_chaindbCompactRange',
+			params: 2,
+			inputFormatter: [null, null],
+		}),
 		new web3._extend.Method({
 			name: 'verbosity',
 			call: 'debug_verbosity',
 			params: 1
 		}),
+		new web3._extend.Method({
+			name: 'setVerbosityForDuration',
+			call: 'debug_setVerbosityForDuration',
+			params: 2
+		}),
 		new web3._extend.Method({
 			name: 'vmodule',
 			call: 'debug_vmodule',
 			params: 1
 		}),
+		new web3._extend.Method({
+			name: 'setVmoduleForDuration',
+			call: 'debug_setVmoduleForDuration',
+			params: 2
+		}),
 		new web3._extend.Method({
 			name: 'backtraceAt',
 			call: 'debug_backtraceAt',
@@ -304,6 +350,11 @@ web3._extend({
 			call: 'debug_setGCPercent',
 			params: 1,
 		}),
+		new web3._extend.Method({
+			name: 'firehoseHealth',
+			call: 'debug_firehoseHealth',
+			params: 0,
+		}),
 		new web3._extend.Method({
 			name: 'memStats',
 			call: 'debug_memStats',
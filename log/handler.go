@@ -7,6 +7,7 @@ import (
 	"os"
 	"reflect"
 	"sync"
+	"time"
 
 	"io/ioutil"
 	"path/filepath"
@@ -467,3 +468,133 @@ func (m muster) FileHandler(path string, fmtr Format) Handler {
 func (m muster) NetHandler(network, addr string, fmtr Format) Handler {
 	return must(NetHandler(network, addr, fmtr))
 }
+
+// SamplingHandler rate-limits identical records, records being identical when they have the
+// same message and the same values for the given context keys. Within each window, the
+// first burst identical records are passed to the wrapped handler and the following ones
+// are suppressed. The amount of suppressed records is reported by adding a "suppressed"
+// context entry to the next record passed for the same key, or, if there isn't any, to a
+// copy of the last suppressed record once its window has elapsed. For example, to let at
+// most 5 "Discarded transaction" records per peer through every 10 seconds:
+//
+//     log.SamplingHandler(10*time.Second, 5, []string{"peer"}, log.StderrHandler)
+//
+func SamplingHandler(window time.Duration, burst int, keys []string, h Handler) Handler {
+	type sample struct {
+		start      time.Time
+		count      int
+		suppressed int
+		last       *Record
+		timer      *time.Timer
+	}
+
+	var (
+		mu        sync.Mutex
+		samples   = make(map[string]*sample)
+		lastSweep = time.Now()
+	)
+
+	// drop forgets about s, its suppressed records being reported by the caller
+	drop := func(key string, s *sample) {
+		if s.timer != nil {
+			s.timer.Stop()
+		}
+		delete(samples, key)
+	}
+
+	// report is called once the window of s elapsed, reporting its suppressed records
+	// unless a newer record for the same key already did
+	report := func(key string, s *sample) {
+		mu.Lock()
+		if samples[key] != s {
+			mu.Unlock()
+			return
+		}
+		delete(samples, key)
+		mu.Unlock()
+
+		h.Log(withSuppressed(s.last, s.suppressed))
+	}
+
+	return FuncHandler(func(r *Record) error {
+		now := r.Time
+		if now.IsZero() {
+			now = time.Now()
+		}
+
+		mu.Lock()
+
+		// Drop the state of the keys that went quiet, reporting their suppressed records
+		var summaries []*Record
+		if now.Sub(lastSweep) >= window {
+			for key, s := range samples {
+				if now.Sub(s.start) >= window {
+					if s.suppressed > 0 {
+						summaries = append(summaries, withSuppressed(s.last, s.suppressed))
+					}
+					drop(key, s)
+				}
+			}
+			lastSweep = now
+		}
+
+		key := samplingKey(r, keys)
+		s, ok := samples[key]
+		if !ok || now.Sub(s.start) >= window {
+			if ok {
+				if s.suppressed > 0 {
+					r = withSuppressed(r, s.suppressed)
+				}
+				drop(key, s)
+			}
+			s = &sample{start: now}
+			samples[key] = s
+		}
+
+		pass := s.count < burst
+		if pass {
+			s.count++
+		} else {
+			s.suppressed++
+			last := *r
+			s.last = &last
+
+			if s.timer == nil {
+				suppressing := s
+				s.timer = time.AfterFunc(window-now.Sub(s.start), func() { report(key, suppressing) })
+			}
+		}
+		mu.Unlock()
+
+		for _, summary := range summaries {
+			h.Log(summary)
+		}
+		if !pass {
+			return nil
+		}
+		return h.Log(r)
+	})
+}
+
+// withSuppressed returns a copy of r reporting the amount of records suppressed by
+// SamplingHandler, r itself is left untouched.
+func withSuppressed(r *Record, suppressed int) *Record {
+	summary := *r
+	summary.Ctx = append(append(make([]interface{}, 0, len(r.Ctx)+2), r.Ctx...), "suppressed", suppressed)
+	return &summary
+}
+
+// samplingKey identifies the records considered identical by SamplingHandler.
+func samplingKey(r *Record, keys []string) string {
+	var key strings.Builder
+	key.WriteString(r.Msg)
+	for _, k := range keys {
+		for i := 0; i < len(r.Ctx)-1; i += 2 {
+			if r.Ctx[i] == k {
+				fmt.Fprintf(&key, "\x00%s=%v", k, r.Ctx[i+1])
+				break
+			}
+		}
+	}
+	return key.String()
+}
@@ -44,6 +44,7 @@ type GlogHandler struct {
 	backtrace uint32 // Flag whether backtrace location is set
 
 	patterns  []pattern       // Current list of patterns to override with
+	ruleset   string          // Vmodule ruleset the patterns were built from
 	siteCache map[uintptr]Lvl // Cache of callsite pattern evaluations
 	location  string          // file:line location where to do a stackdump at
 	lock      sync.RWMutex    // Lock protecting the override pattern list
@@ -75,6 +76,19 @@ func (h *GlogHandler) Verbosity(level Lvl) {
 	atomic.StoreUint32(&h.level, uint32(level))
 }
 
+// GetVerbosity returns the current glog verbosity ceiling.
+func (h *GlogHandler) GetVerbosity() Lvl {
+	return Lvl(atomic.LoadUint32(&h.level))
+}
+
+// GetVmodule returns the current glog verbosity pattern.
+func (h *GlogHandler) GetVmodule() string {
+	h.lock.RLock()
+	defer h.lock.RUnlock()
+
+	return h.ruleset
+}
+
 // Vmodule sets the glog verbosity pattern.
 //
 // The syntax of the argument is a comma-separated list of pattern=N, where the
@@ -137,6 +151,7 @@ func (h *GlogHandler) Vmodule(ruleset string) error {
 	defer h.lock.Unlock()
 
 	h.patterns = filter
+	h.ruleset = ruleset
 	h.siteCache = make(map[uintptr]Lvl)
 	atomic.StoreUint32(&h.override, uint32(len(filter)))
 
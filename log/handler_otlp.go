@@ -0,0 +1,208 @@
+package log
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// otlpQueueSize is the amount of records buffered while waiting to be exported, records
+	// are dropped once it's full so that logging never blocks on the collector.
+	otlpQueueSize = 8192
+
+	// otlpBatchSize is the maximum amount of records sent in a single export request.
+	otlpBatchSize = 512
+
+	// otlpFlushInterval is the maximum time a record waits before being exported.
+	otlpFlushInterval = 2 * time.Second
+)
+
+// OTLPConfig configures the OTLPHandler.
+type OTLPConfig struct {
+	// Endpoint is the URL of the collector logs endpoint, e.g. `http://localhost:4318/v1/logs`.
+	Endpoint string
+
+	// ServiceName is reported as the `service.name` resource attribute.
+	ServiceName string
+
+	// Headers are added to each export request (e.g. authentication).
+	Headers map[string]string
+}
+
+// OTLPHandler returns a handler exporting log records to an OpenTelemetry collector using
+// the OTLP/HTTP protocol with JSON encoding. Records are exported asynchronously in batches,
+// records that can't be queued because the collector is too slow or unreachable are dropped
+// and the amount dropped is reported on the standard error output.
+func OTLPHandler(config OTLPConfig) Handler {
+	e := &otlpExporter{
+		config:  config,
+		client:  &http.Client{Timeout: 10 * time.Second},
+		records: make(chan otlpLogRecord, otlpQueueSize),
+	}
+	go e.loop()
+
+	return LazyHandler(FuncHandler(func(r *Record) error {
+		select {
+		case e.records <- newOTLPLogRecord(r):
+		default:
+			atomic.AddUint64(&e.dropped, 1)
+		}
+		return nil
+	}))
+}
+
+type otlpExporter struct {
+	config  OTLPConfig
+	client  *http.Client
+	records chan otlpLogRecord
+	dropped uint64
+}
+
+func (e *otlpExporter) loop() {
+	ticker := time.NewTicker(otlpFlushInterval)
+	defer ticker.Stop()
+
+	batch := make([]otlpLogRecord, 0, otlpBatchSize)
+	for {
+		select {
+		case record := <-e.records:
+			batch = append(batch, record)
+			if len(batch) < otlpBatchSize {
+				continue
+			}
+		case <-ticker.C:
+			if dropped := atomic.SwapUint64(&e.dropped, 0); dropped > 0 {
+				fmt.Fprintf(os.Stderr, "log: dropped %d records not exported to OTLP collector\n", dropped)
+			}
+			if len(batch) == 0 {
+				continue
+			}
+		}
+		if err := e.export(batch); err != nil {
+			fmt.Fprintf(os.Stderr, "log: failed to export %d records to OTLP collector: %v\n", len(batch), err)
+		}
+		batch = batch[:0]
+	}
+}
+
+func (e *otlpExporter) export(batch []otlpLogRecord) error {
+	request := otlpExportRequest{ResourceLogs: []otlpResourceLogs{{
+		Resource: otlpResource{Attributes: []otlpAttribute{
+			{Key: "service.name", Value: otlpValue{StringValue: e.config.ServiceName}},
+		}},
+		ScopeLogs: []otlpScopeLogs{{
+			Scope:      otlpScope{Name: "github.com/ethereum/go-ethereum/log"},
+			LogRecords: batch,
+		}},
+	}}}
+
+	body, err := json.Marshal(request)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, e.config.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range e.config.Headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("collector responded with status %s", resp.Status)
+	}
+	return nil
+}
+
+// newOTLPLogRecord converts a record, its context values being formatted right away since
+// they may be mutated once the record is logged.
+func newOTLPLogRecord(r *Record) otlpLogRecord {
+	record := otlpLogRecord{
+		TimeUnixNano:   strconv.FormatInt(r.Time.UnixNano(), 10),
+		SeverityNumber: otlpSeverity(r.Lvl),
+		SeverityText:   strings.TrimSpace(r.Lvl.AlignedString()),
+		Body:           otlpValue{StringValue: r.Msg},
+	}
+	for i := 0; i < len(r.Ctx)-1; i += 2 {
+		key, ok := r.Ctx[i].(string)
+		if !ok {
+			key = fmt.Sprintf("%+v", r.Ctx[i])
+		}
+		record.Attributes = append(record.Attributes, otlpAttribute{
+			Key:   key,
+			Value: otlpValue{StringValue: fmt.Sprint(formatJSONValue(r.Ctx[i+1]))},
+		})
+	}
+	return record
+}
+
+// otlpSeverity maps a level to its OpenTelemetry severity number.
+func otlpSeverity(lvl Lvl) int {
+	switch lvl {
+	case LvlTrace:
+		return 1
+	case LvlDebug:
+		return 5
+	case LvlInfo:
+		return 9
+	case LvlWarn:
+		return 13
+	case LvlError:
+		return 17
+	default:
+		return 21
+	}
+}
+
+type otlpExportRequest struct {
+	ResourceLogs []otlpResourceLogs `json:"resourceLogs"`
+}
+
+type otlpResourceLogs struct {
+	Resource  otlpResource    `json:"resource"`
+	ScopeLogs []otlpScopeLogs `json:"scopeLogs"`
+}
+
+type otlpResource struct {
+	Attributes []otlpAttribute `json:"attributes"`
+}
+
+type otlpScopeLogs struct {
+	Scope      otlpScope       `json:"scope"`
+	LogRecords []otlpLogRecord `json:"logRecords"`
+}
+
+type otlpScope struct {
+	Name string `json:"name"`
+}
+
+type otlpLogRecord struct {
+	TimeUnixNano   string          `json:"timeUnixNano"`
+	SeverityNumber int             `json:"severityNumber"`
+	SeverityText   string          `json:"severityText"`
+	Body           otlpValue       `json:"body"`
+	Attributes     []otlpAttribute `json:"attributes,omitempty"`
+}
+
+type otlpAttribute struct {
+	Key   string    `json:"key"`
+	Value otlpValue `json:"value"`
+}
+
+type otlpValue struct {
+	StringValue string `json:"stringValue"`
+}
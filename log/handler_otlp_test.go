@@ -0,0 +1,90 @@
+package log
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestOTLPLogRecord(t *testing.T) {
+	r := &Record{
+		Time: time.Unix(1, 500),
+		Lvl:  LvlWarn,
+		Msg:  "Discarded transaction",
+		Ctx:  []interface{}{"peer", "a", 7, "non string key"},
+	}
+	record := newOTLPLogRecord(r)
+
+	if record.TimeUnixNano != "1000000500" {
+		t.Errorf("time mismatch: have %s, want 1000000500", record.TimeUnixNano)
+	}
+	if record.SeverityNumber != 13 || record.SeverityText != "WARN" {
+		t.Errorf("severity mismatch: have %d %q, want 13 \"WARN\"", record.SeverityNumber, record.SeverityText)
+	}
+	if record.Body.StringValue != r.Msg {
+		t.Errorf("body mismatch: have %q, want %q", record.Body.StringValue, r.Msg)
+	}
+
+	want := []otlpAttribute{
+		{Key: "peer", Value: otlpValue{StringValue: "a"}},
+		{Key: "7", Value: otlpValue{StringValue: "non string key"}},
+	}
+	if len(record.Attributes) != len(want) {
+		t.Fatalf("attribute count mismatch: have %d, want %d", len(record.Attributes), len(want))
+	}
+	for i := range want {
+		if record.Attributes[i] != want[i] {
+			t.Errorf("attribute %d mismatch: have %+v, want %+v", i, record.Attributes[i], want[i])
+		}
+	}
+}
+
+func TestOTLPExport(t *testing.T) {
+	var (
+		request otlpExportRequest
+		header  http.Header
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header = r.Header
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			t.Errorf("invalid export request: %v", err)
+		}
+	}))
+	defer server.Close()
+
+	e := &otlpExporter{
+		config: OTLPConfig{Endpoint: server.URL, ServiceName: "geth", Headers: map[string]string{"Authorization": "Bearer secret"}},
+		client: server.Client(),
+	}
+	batch := []otlpLogRecord{newOTLPLogRecord(&Record{Time: time.Now(), Lvl: LvlInfo, Msg: "Imported new chain segment"})}
+	if err := e.export(batch); err != nil {
+		t.Fatalf("export failed: %v", err)
+	}
+
+	if have := header.Get("Authorization"); have != "Bearer secret" {
+		t.Errorf("authorization header mismatch: have %q, want %q", have, "Bearer secret")
+	}
+	if len(request.ResourceLogs) != 1 || len(request.ResourceLogs[0].ScopeLogs) != 1 {
+		t.Fatalf("unexpected export request layout: %+v", request)
+	}
+	if have := request.ResourceLogs[0].Resource.Attributes[0]; have.Key != "service.name" || have.Value.StringValue != "geth" {
+		t.Errorf("service name mismatch: have %+v", have)
+	}
+	if have := request.ResourceLogs[0].ScopeLogs[0].LogRecords; len(have) != 1 || have[0].Body.StringValue != "Imported new chain segment" {
+		t.Errorf("exported records mismatch: have %+v", have)
+	}
+}
+
+func TestOTLPExportStatus(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	e := &otlpExporter{config: OTLPConfig{Endpoint: server.URL}, client: server.Client()}
+	if err := e.export(nil); err == nil {
+		t.Fatal("expected export to fail on non 2xx status")
+	}
+}
@@ -0,0 +1,243 @@
+package log
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// backupTimeFormat is the timestamp suffix of rotated files, it sorts chronologically.
+const backupTimeFormat = "2006-01-02T15-04-05.000000000"
+
+// RotationConfig controls when a RotatingFileWriter rotates its file and what happens to
+// the rotated files.
+type RotationConfig struct {
+	// MaxSize is the size in bytes after which the file is rotated, 0 disables size
+	// based rotation.
+	MaxSize int64
+
+	// Interval is the age after which the file is rotated, 0 disables time based rotation.
+	Interval time.Duration
+
+	// MaxBackups is the amount of rotated files kept, the oldest ones being deleted, 0
+	// keeps them all.
+	MaxBackups int
+
+	// Compress gzips the rotated files.
+	Compress bool
+}
+
+// RotatingFileWriter is an io.WriteCloser appending to the file at a fixed path, rotating it
+// according to its RotationConfig. A rotated file is renamed to `<path>.<timestamp>` (with
+// a `.gz` suffix once compressed) and a new file is created at the original path.
+type RotatingFileWriter struct {
+	path   string
+	config RotationConfig
+
+	mu       sync.Mutex
+	file     *os.File // nil if the file couldn't be reopened on rotation
+	size     int64
+	openedAt time.Time
+
+	// compressing is used to wait for pending compressions on Close.
+	compressing sync.WaitGroup
+	compressMu  sync.Mutex
+}
+
+// NewRotatingFileWriter opens, or creates, the file at the given path. Writes to an existing
+// file are appended and count toward its size limit.
+func NewRotatingFileWriter(path string, config RotationConfig) (*RotatingFileWriter, error) {
+	w := &RotatingFileWriter{path: path, config: config}
+	if err := w.open(); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// Write implements io.Writer, rotating the file beforehand if needed. A single write is
+// never split across files.
+func (w *RotatingFileWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.file == nil {
+		if err := w.open(); err != nil {
+			return 0, err
+		}
+	}
+	if w.shouldRotate(int64(len(p))) {
+		if err := w.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := w.file.Write(p)
+	w.size += int64(n)
+	return n, err
+}
+
+// Close closes the current file and waits for the rotated files being compressed.
+func (w *RotatingFileWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	var err error
+	if w.file != nil {
+		err = w.file.Close()
+		w.file = nil
+	}
+	w.compressing.Wait()
+	return err
+}
+
+func (w *RotatingFileWriter) shouldRotate(incoming int64) bool {
+	if w.size == 0 {
+		return false
+	}
+	if w.config.MaxSize > 0 && w.size+incoming > w.config.MaxSize {
+		return true
+	}
+	return w.config.Interval > 0 && time.Since(w.openedAt) >= w.config.Interval
+}
+
+func (w *RotatingFileWriter) open() error {
+	if err := os.MkdirAll(filepath.Dir(w.path), 0700); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(w.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	w.file, w.size, w.openedAt = f, info.Size(), time.Now()
+	return nil
+}
+
+// rotate renames the current file and opens a new one at the original path. If the file
+// can't be renamed, the current one is reopened and rotation is retried on the next write.
+// If it can't be reopened, the file is left nil and reopening is retried on the next write.
+func (w *RotatingFileWriter) rotate() error {
+	err := w.file.Close()
+	w.file = nil
+	if err != nil {
+		return err
+	}
+	backup := w.backupPath(time.Now())
+	if err := os.Rename(w.path, backup); err != nil {
+		if openErr := w.open(); openErr != nil {
+			return openErr
+		}
+		return err
+	}
+	if err := w.open(); err != nil {
+		return err
+	}
+
+	if w.config.Compress {
+		w.compressing.Add(1)
+		go func() {
+			defer w.compressing.Done()
+
+			// Compressions are serialized so that pruning never races with one of them
+			w.compressMu.Lock()
+			defer w.compressMu.Unlock()
+
+			if err := compressFile(backup); err != nil {
+				fmt.Fprintf(os.Stderr, "log: failed to compress rotated file %s: %v\n", backup, err)
+			}
+			w.prune()
+		}()
+	} else {
+		w.prune()
+	}
+	return nil
+}
+
+// backupPath returns the path a file rotated at the given time is renamed to, suffixed with
+// a counter if a file rotated at the same instant already exists.
+func (w *RotatingFileWriter) backupPath(now time.Time) string {
+	base := w.path + "." + now.Format(backupTimeFormat)
+	backup := base
+	for i := 1; ; i++ {
+		if !exists(backup) && !exists(backup+".gz") {
+			return backup
+		}
+		backup = fmt.Sprintf("%s-%d", base, i)
+	}
+}
+
+// prune deletes the oldest rotated files in excess of MaxBackups. When compression is
+// enabled, only the compressed files are considered, the others being pending compression.
+func (w *RotatingFileWriter) prune() {
+	if w.config.MaxBackups <= 0 {
+		return
+	}
+	matches, err := filepath.Glob(w.path + ".*")
+	if err != nil {
+		return
+	}
+	var backups []string
+	for _, match := range matches {
+		if !w.config.Compress || strings.HasSuffix(match, ".gz") {
+			backups = append(backups, match)
+		}
+	}
+	sort.Strings(backups)
+	for len(backups) > w.config.MaxBackups {
+		os.Remove(backups[0])
+		backups = backups[1:]
+	}
+}
+
+func exists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+// compressFile gzips the file at path to `path.gz` and removes the original.
+func compressFile(path string) error {
+	in, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(path+".gz", os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	gz := gzip.NewWriter(out)
+	if _, err := io.Copy(gz, in); err != nil {
+		out.Close()
+		os.Remove(path + ".gz")
+		return err
+	}
+	if err := gz.Close(); err != nil {
+		out.Close()
+		os.Remove(path + ".gz")
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	return os.Remove(path)
+}
+
+// FileRotationHandler returns a handler which writes log records to the file at the given
+// path using the given format, rotating it according to config.
+func FileRotationHandler(path string, config RotationConfig, fmtr Format) (Handler, error) {
+	w, err := NewRotatingFileWriter(path, config)
+	if err != nil {
+		return nil, err
+	}
+	return closingHandler{w, StreamHandler(w, fmtr)}, nil
+}
@@ -0,0 +1,113 @@
+package log
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRotatingFileWriterRotatesOnSize(t *testing.T) {
+	dir, err := ioutil.TempDir("", "log-rotate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "geth.log")
+	w, err := NewRotatingFileWriter(path, RotationConfig{MaxSize: 10, MaxBackups: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{"first\n", "second\n", "third\n", "fourth\n"} {
+		if _, err := w.Write([]byte(line)); err != nil {
+			t.Fatalf("write %q failed: %v", line, err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	current, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(current) != "fourth\n" {
+		t.Errorf("current file mismatch: have %q, want %q", current, "fourth\n")
+	}
+
+	// The oldest rotated file is pruned, only MaxBackups are kept
+	backups, _ := filepath.Glob(path + ".*")
+	if len(backups) != 2 {
+		t.Fatalf("backup count mismatch: have %d, want 2", len(backups))
+	}
+	for i, want := range []string{"second\n", "third\n"} {
+		have, err := ioutil.ReadFile(backups[i])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(have) != want {
+			t.Errorf("backup %d mismatch: have %q, want %q", i, have, want)
+		}
+	}
+}
+
+func TestRotatingFileWriterCompresses(t *testing.T) {
+	dir, err := ioutil.TempDir("", "log-rotate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "geth.log")
+	w, err := NewRotatingFileWriter(path, RotationConfig{MaxSize: 10, Compress: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Write([]byte("first\n"))
+	w.Write([]byte("second\n"))
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	backups, _ := filepath.Glob(path + ".*")
+	if len(backups) != 1 || !strings.HasSuffix(backups[0], ".gz") {
+		t.Fatalf("expected a single compressed backup, have %v", backups)
+	}
+}
+
+func TestRotatingFileWriterRenameFailure(t *testing.T) {
+	dir, err := ioutil.TempDir("", "log-rotate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "geth.log")
+	w, err := NewRotatingFileWriter(path, RotationConfig{MaxSize: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	w.Write([]byte("first\n"))
+
+	// The file can't be renamed anymore, rotation fails
+	os.Remove(path)
+	if _, err := w.Write([]byte("second\n")); err == nil {
+		t.Fatal("expected rotation to fail")
+	}
+
+	// The writer must keep working once the rotation failed
+	if _, err := w.Write([]byte("third\n")); err != nil {
+		t.Fatalf("write after failed rotation failed: %v", err)
+	}
+	current, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(current) != "third\n" {
+		t.Errorf("current file mismatch: have %q, want %q", current, "third\n")
+	}
+}
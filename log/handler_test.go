@@ -0,0 +1,116 @@
+package log
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+// recordingHandler collects the records it's given.
+type recordingHandler struct {
+	mu      sync.Mutex
+	records []*Record
+}
+
+func (h *recordingHandler) Log(r *Record) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.records = append(h.records, r)
+	return nil
+}
+
+func (h *recordingHandler) logged() []*Record {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	return append([]*Record{}, h.records...)
+}
+
+func suppressedCount(r *Record) (int, bool) {
+	for i := 0; i < len(r.Ctx)-1; i += 2 {
+		if r.Ctx[i] == "suppressed" {
+			return r.Ctx[i+1].(int), true
+		}
+	}
+	return 0, false
+}
+
+func TestSamplingHandlerBurst(t *testing.T) {
+	recorder := new(recordingHandler)
+	h := SamplingHandler(time.Hour, 2, []string{"peer"}, recorder)
+
+	for i := 0; i < 5; i++ {
+		h.Log(&Record{Time: time.Now(), Msg: "Discarded transaction", Ctx: []interface{}{"peer", "a"}})
+	}
+	h.Log(&Record{Time: time.Now(), Msg: "Discarded transaction", Ctx: []interface{}{"peer", "b"}})
+
+	if have := len(recorder.logged()); have != 3 {
+		t.Fatalf("passed record count mismatch: have %d, want 3", have)
+	}
+}
+
+func TestSamplingHandlerReportsSuppressed(t *testing.T) {
+	recorder := new(recordingHandler)
+	window := 50 * time.Millisecond
+	h := SamplingHandler(window, 1, nil, recorder)
+
+	for i := 0; i < 4; i++ {
+		h.Log(&Record{Time: time.Now(), Msg: "Discarded transaction"})
+	}
+
+	// No other record is logged, the suppressed ones must still be reported once the
+	// window has elapsed
+	deadline := time.Now().Add(time.Second)
+	for len(recorder.logged()) < 2 && time.Now().Before(deadline) {
+		time.Sleep(window / 5)
+	}
+	records := recorder.logged()
+	if len(records) != 2 {
+		t.Fatalf("record count mismatch: have %d, want 2", len(records))
+	}
+	if suppressed, _ := suppressedCount(records[1]); suppressed != 3 {
+		t.Errorf("suppressed count mismatch: have %d, want 3", suppressed)
+	}
+
+	// The suppressed records are reported once
+	h.Log(&Record{Time: time.Now(), Msg: "Discarded transaction"})
+	records = recorder.logged()
+	if len(records) != 3 {
+		t.Fatalf("record count mismatch: have %d, want 3", len(records))
+	}
+	if _, found := suppressedCount(records[2]); found {
+		t.Errorf("unexpected suppressed count on record following the report: %v", records[2].Ctx)
+	}
+}
+
+func TestSamplingHandlerKeepsCallerRecord(t *testing.T) {
+	recorder := new(recordingHandler)
+	start := time.Now()
+	h := SamplingHandler(time.Hour, 1, nil, recorder)
+
+	h.Log(&Record{Time: start.Add(30 * time.Minute), Msg: "Discarded transaction"})
+	h.Log(&Record{Time: start.Add(30 * time.Minute), Msg: "Discarded transaction"})
+
+	// Sweeps the quiet keys, the suppressed record's window isn't elapsed yet
+	h.Log(&Record{Time: start.Add(65 * time.Minute), Msg: "Peer connected"})
+
+	// The next record reports the suppressed one, room for the entry in the caller's
+	// context must be left untouched
+	ctx := make([]interface{}, 2, 4)
+	ctx[0], ctx[1] = "peer", "a"
+	r := &Record{Time: start.Add(100 * time.Minute), Msg: "Discarded transaction", Ctx: ctx}
+	h.Log(r)
+
+	if len(r.Ctx) != 2 || ctx[:cap(ctx)][2] != nil {
+		t.Errorf("caller's record context mutated: %v", ctx[:cap(ctx)])
+	}
+
+	records := recorder.logged()
+	if len(records) != 3 {
+		t.Fatalf("record count mismatch: have %d, want 3", len(records))
+	}
+	if suppressed, _ := suppressedCount(records[2]); suppressed != 1 {
+		t.Errorf("suppressed count mismatch: have %d, want 1", suppressed)
+	}
+}
@@ -723,11 +723,26 @@ func (w *worker) updateSnapshot() {
 func (w *worker) commitTransaction(tx *types.Transaction, coinbase common.Address) ([]*types.Log, error) {
 	snap := w.current.state.Snapshot()
 
-	receipt, err := core.ApplyTransaction(w.chainConfig, w.chain, &coinbase, w.current.gasPool, w.current.state, w.current.header, tx, &w.current.header.GasUsed, *w.chain.GetVMConfig(), firehose.NoOpContext)
+	// Speculative traces are routed to the mining context which never writes to the canonical sync stream
+	firehoseContext := firehose.MaybeMiningContext()
+	txFirehoseContext := firehoseContext
+	if txFirehoseContext.Enabled() {
+		txFirehoseContext = firehoseContext.NewTransactionContext(512 * 1024)
+
+		// London fork not active in this branch yet, replace by `header.BaseFee` instead of `nil` when it's the case (and remove this comment)
+		txFirehoseContext.StartTransaction(tx, uint(len(w.current.txs)), nil)
+	}
+
+	receipt, err := core.ApplyTransaction(w.chainConfig, w.chain, &coinbase, w.current.gasPool, w.current.state, w.current.header, tx, &w.current.header.GasUsed, *w.chain.GetVMConfig(), txFirehoseContext)
 	if err != nil {
 		w.current.state.RevertToSnapshot(snap)
 		return nil, err
 	}
+
+	if txFirehoseContext.Enabled() {
+		txFirehoseContext.EndTransaction(receipt)
+		firehoseContext.FlushTransaction(txFirehoseContext)
+	}
 	w.current.txs = append(w.current.txs, tx)
 	w.current.receipts = append(w.current.receipts, receipt)
 
@@ -746,6 +761,9 @@ func (w *worker) commitTransactions(txs *types.TransactionsByPriceAndNonce, coin
 
 	var coalescedLogs []*types.Log
 
+	// Transactions skipped by the pre-checks are recorded on the mining context, see `firehose.Context.RecordSkippedTransaction`
+	firehoseContext := firehose.MaybeMiningContext()
+
 	for {
 		// In the following three cases, we will interrupt the execution of the transaction.
 		// (1) new head block event arrival, the interrupt signal is 1
@@ -786,6 +804,7 @@ func (w *worker) commitTransactions(txs *types.TransactionsByPriceAndNonce, coin
 		// phase, start ignoring the sender until we do.
 		if tx.Protected() && !w.chainConfig.IsEIP155(w.current.header.Number) {
 			log.Trace("Ignoring reply protected transaction", "hash", tx.Hash(), "eip155", w.chainConfig.EIP155Block)
+			firehoseContext.RecordSkippedTransaction(tx, core.ErrorToSkippedTransactionReason(core.ErrTxReplayProtected), core.ErrTxReplayProtected)
 
 			txs.Pop()
 			continue
@@ -798,16 +817,19 @@ func (w *worker) commitTransactions(txs *types.TransactionsByPriceAndNonce, coin
 		case core.ErrGasLimitReached:
 			// Pop the current out-of-gas transaction without shifting in the next from the account
 			log.Trace("Gas limit exceeded for current block", "sender", from)
+			firehoseContext.RecordSkippedTransaction(tx, core.ErrorToSkippedTransactionReason(err), err)
 			txs.Pop()
 
 		case core.ErrNonceTooLow:
 			// New head notification data race between the transaction pool and miner, shift
 			log.Trace("Skipping transaction with low nonce", "sender", from, "nonce", tx.Nonce())
+			firehoseContext.RecordSkippedTransaction(tx, core.ErrorToSkippedTransactionReason(err), err)
 			txs.Shift()
 
 		case core.ErrNonceTooHigh:
 			// Reorg notification data race between the transaction pool and miner, skip account =
 			log.Trace("Skipping account with hight nonce", "sender", from, "nonce", tx.Nonce())
+			firehoseContext.RecordSkippedTransaction(tx, core.ErrorToSkippedTransactionReason(err), err)
 			txs.Pop()
 
 		case nil:
@@ -820,6 +842,7 @@ func (w *worker) commitTransactions(txs *types.TransactionsByPriceAndNonce, coin
 			// Strange error, discard the transaction and get the next in line (note, the
 			// nonce-too-high clause will prevent us from executing in vain).
 			log.Debug("Transaction failed, account skipped", "hash", tx.Hash(), "err", err)
+			firehoseContext.RecordSkippedTransaction(tx, core.ErrorToSkippedTransactionReason(err), err)
 			txs.Shift()
 		}
 	}
@@ -17,6 +17,7 @@
 package miner
 
 import (
+	"bytes"
 	"fmt"
 	"math/big"
 	"math/rand"
@@ -36,7 +37,9 @@ import (
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/ethdb"
 	"github.com/ethereum/go-ethereum/event"
+	"github.com/ethereum/go-ethereum/firehose"
 	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rlp"
 )
 
 const (
@@ -306,6 +309,49 @@ func testEmptyWork(t *testing.T, chainConfig *params.ChainConfig, engine consens
 	}
 }
 
+func TestCommitTransactionsRecordsSkippedTransactions(t *testing.T) {
+	engine := ethash.NewFaker()
+	defer engine.Close()
+
+	w, b := newTestWorker(t, ethashChainConfig, engine, rawdb.NewMemoryDatabase(), 0)
+	defer w.close()
+
+	defer func(enabled, miningEnabled bool) {
+		firehose.Enabled, firehose.MiningEnabled = enabled, miningEnabled
+	}(firehose.Enabled, firehose.MiningEnabled)
+	firehose.Enabled, firehose.MiningEnabled = true, true
+
+	output := &bytes.Buffer{}
+	firehose.SetMiningContextWriter(output)
+
+	parent := b.chain.Genesis()
+	header := &types.Header{
+		ParentHash: parent.Hash(),
+		Number:     big.NewInt(1),
+		GasLimit:   parent.GasLimit(),
+		Time:       parent.Time() + 1,
+		Difficulty: big.NewInt(1),
+	}
+	if err := w.makeCurrent(parent, header); err != nil {
+		t.Fatalf("failed to prepare mining environment: %v", err)
+	}
+
+	// Bank account nonce is 0, the transaction is skipped with a nonce too high pre-check failure
+	tx, _ := types.SignTx(types.NewTransaction(5, testUserAddress, big.NewInt(1000), params.TxGas, nil, nil), types.HomesteadSigner{}, testBankKey)
+	txs := types.NewTransactionsByPriceAndNonce(w.current.signer, map[common.Address]types.Transactions{testBankAddress: {tx}})
+	w.commitTransactions(txs, testBankAddress, nil)
+
+	if w.current.tcount != 0 {
+		t.Fatalf("transaction count mismatch: have %d, want 0", w.current.tcount)
+	}
+
+	encoded, _ := rlp.EncodeToBytes(tx)
+	expected := fmt.Sprintf("FIRE SKIPPED_TRX %s %s nonce_too_high %s\n", firehose.Hash(tx.Hash()), firehose.Hex(encoded), core.ErrNonceTooHigh)
+	if output.String() != expected {
+		t.Errorf("mining output mismatch, have %q, want %q", output.String(), expected)
+	}
+}
+
 func TestStreamUncleBlock(t *testing.T) {
 	ethash := ethash.NewFaker()
 	defer ethash.Close()
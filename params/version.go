@@ -24,13 +24,19 @@ const (
 	VersionMajor = 1       // Major version component of the current release
 	VersionMinor = 9       // Minor version component of the current release
 	VersionPatch = 10      // Patch version component of the current release
-	VersionMeta  = "fh2.3" // Version metadata to append to the version string
+	VersionMeta  = "fh3.0" // Version metadata to append to the version string
 
-	FirehoseVersionMajor = 2
-	FirehoseVersionMinor = 3
-	Variant              = "geth"
+	// Firehose protocol version, the major component is bumped whenever the required fields
+	// of existing events change, readers parsing a fixed field count would break otherwise
+	FirehoseVersionMajor = 3
+	FirehoseVersionMinor = 0
 )
 
+// Variant is the chain variant the node is built for, it must be one of the variants known
+// to the Firehose variant registry. It's selected at build time by the engine integrations
+// through `-ldflags "-X github.com/ethereum/go-ethereum/params.Variant=<variant>"`.
+var Variant = "geth"
+
 // Version holds the textual version string.
 var Version = func() string {
 	return fmt.Sprintf("%d.%d.%d", VersionMajor, VersionMinor, VersionPatch)
@@ -0,0 +1,109 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package tests
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/vm"
+)
+
+// TestFirehoseCoverage runs the GeneralStateTests through a Firehose instrumented EVM and
+// ensures every state mutation of the post-state has a corresponding emitted event, this
+// guards against instrumentation missed while merging upstream changes.
+func TestFirehoseCoverage(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping Firehose coverage in short mode")
+	}
+	t.Parallel()
+
+	st := new(testMatcher)
+	st.skipLoad(`^stTimeConsuming/`)
+	st.skipLoad(`^stQuadraticComplexityTest/`)
+
+	st.walk(t, stateTestDir, func(t *testing.T, name string, test *StateTest) {
+		for _, subtest := range test.Subtests() {
+			subtest := subtest
+			key := fmt.Sprintf("%s/%d", subtest.Fork, subtest.Index)
+			t.Run(key, func(t *testing.T) {
+				uncovered, err := test.RunWithFirehoseCoverage(subtest, vm.Config{})
+				if err != nil {
+					if _, unsupported := err.(UnsupportedForkError); unsupported {
+						t.Skip(err)
+					}
+					t.Fatal(err)
+				}
+
+				if len(uncovered) > 0 {
+					t.Errorf("state mutations without emitted Firehose events:\n%s", strings.Join(uncovered, "\n"))
+				}
+			})
+		}
+	})
+}
+
+func TestFirehoseCoverageUncoveredMutations(t *testing.T) {
+	covered := common.HexToAddress("0x01")
+	reverted := common.HexToAddress("0x02")
+	silent := common.HexToAddress("0x03")
+	slot := common.HexToHash("0x01")
+
+	firehoseLog := strings.Join([]string{
+		"FIRE EVM_RUN_CALL CALL 1 2 100 0",
+		"FIRE BALANCE_CHANGE 1 " + covered.Hex()[2:] + " . 0a transfer 3",
+		"FIRE NONCE_CHANGE 1 " + covered.Hex()[2:] + " 0 1 4",
+		"FIRE EVM_RUN_CALL CALL 2 5 100 0",
+		// The sub-call fails, its storage change is reverted and doesn't cover the post-state
+		"FIRE STORAGE_CHANGE 2 " + reverted.Hex()[2:] + " " + slot.Hex()[2:] + " " + common.Hash{}.Hex()[2:] + " " + common.HexToHash("0x02").Hex()[2:] + " 6",
+		"FIRE EVM_CALL_FAILED 2 0 execution_reverted . . 1 execution reverted",
+		"FIRE EVM_REVERTED 2 . .",
+		"FIRE EVM_END_CALL 2 0 . 8 100 0",
+		"FIRE EVM_END_CALL 1 10 . 9 100 0",
+	}, "\n") + "\n"
+
+	events, err := parseFirehoseStateEvents([]byte(firehoseLog))
+	if err != nil {
+		t.Fatalf("parse firehose log: %s", err)
+	}
+
+	pre := state.Dump{Accounts: map[common.Address]state.DumpAccount{
+		covered:  {Balance: "0", Nonce: 0},
+		reverted: {Balance: "0", Storage: map[common.Hash]string{}},
+		silent:   {Balance: "5"},
+	}}
+	post := state.Dump{Accounts: map[common.Address]state.DumpAccount{
+		covered:  {Balance: "10", Nonce: 1},
+		reverted: {Balance: "0", Storage: map[common.Hash]string{slot: "0x02"}},
+		silent:   {Balance: "7"},
+	}}
+
+	uncovered := events.uncoveredMutations(pre, post)
+	sort.Strings(uncovered)
+
+	expected := []string{
+		reverted.Hex() + " storage " + slot.Hex() + " changed from " + common.Hash{}.Hex() + " to " + common.HexToHash("0x02").Hex() + ", last emitted " + common.Hash{}.Hex(),
+		silent.Hex() + " balance changed from 5 to 7, last emitted <nil>",
+	}
+	if strings.Join(uncovered, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("unexpected uncovered mutations, have:\n%s\nwant:\n%s", strings.Join(uncovered, "\n"), strings.Join(expected, "\n"))
+	}
+}
@@ -0,0 +1,217 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package tests
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/firehose"
+)
+
+// RunWithFirehoseCoverage runs a specific subtest through a Firehose instrumented EVM and
+// returns the state mutations found in the post-state that have no corresponding emitted
+// event, an empty list meaning the instrumentation fully covers the subtest.
+func (t *StateTest) RunWithFirehoseCoverage(subtest StateSubtest, vmconfig vm.Config) ([]string, error) {
+	pre := MakePreState(rawdb.NewMemoryDatabase(), t.json.Pre).RawDump(false, false, true)
+
+	firehoseContext := firehose.NewSpeculativeExecutionContext(64 * 1024)
+	statedb, _, err := t.runNoVerify(subtest, vmconfig, firehoseContext)
+	if err != nil {
+		return nil, err
+	}
+	post := statedb.RawDump(false, false, true)
+
+	events, err := parseFirehoseStateEvents(firehoseContext.FirehoseLog())
+	if err != nil {
+		return nil, err
+	}
+
+	return events.uncoveredMutations(pre, post), nil
+}
+
+// firehoseStateEvents holds the last value emitted for each piece of state, events
+// emitted within a failed call (or within one of its failed ancestors) are discarded
+// since their effects are reverted.
+type firehoseStateEvents struct {
+	balances map[common.Address]*big.Int
+	nonces   map[common.Address]uint64
+	codes    map[common.Address]string
+	storage  map[common.Address]map[common.Hash]common.Hash
+	suicided map[common.Address]bool
+}
+
+func parseFirehoseStateEvents(firehoseLog []byte) (*firehoseStateEvents, error) {
+	var lines [][]string
+	parents := map[string]string{}
+	failed := map[string]bool{}
+	callStack := []string{"0"}
+
+	scanner := bufio.NewScanner(bytes.NewReader(firehoseLog))
+	scanner.Buffer(make([]byte, 0, 1024*1024), 64*1024*1024)
+	for scanner.Scan() {
+		fields := strings.Fields(strings.TrimPrefix(scanner.Text(), "FIRE "))
+		if len(fields) < 2 {
+			continue
+		}
+
+		switch fields[0] {
+		case "EVM_RUN_CALL":
+			parents[fields[2]] = callStack[len(callStack)-1]
+			callStack = append(callStack, fields[2])
+		case "EVM_END_CALL":
+			callStack = callStack[:len(callStack)-1]
+		case "EVM_CALL_FAILED":
+			failed[fields[1]] = true
+		case "BALANCE_CHANGE", "NONCE_CHANGE", "STORAGE_CHANGE", "CODE_CHANGE", "SUICIDE_CHANGE":
+			lines = append(lines, fields)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("read firehose log: %w", err)
+	}
+
+	var reverted func(index string) bool
+	reverted = func(index string) bool {
+		if failed[index] {
+			return true
+		}
+		if parent, found := parents[index]; found {
+			return reverted(parent)
+		}
+		return false
+	}
+
+	events := &firehoseStateEvents{
+		balances: map[common.Address]*big.Int{},
+		nonces:   map[common.Address]uint64{},
+		codes:    map[common.Address]string{},
+		storage:  map[common.Address]map[common.Hash]common.Hash{},
+		suicided: map[common.Address]bool{},
+	}
+
+	for _, fields := range lines {
+		if reverted(fields[1]) {
+			continue
+		}
+
+		addr := common.HexToAddress(fields[2])
+		switch fields[0] {
+		case "BALANCE_CHANGE":
+			events.balances[addr] = hexToBig(fields[4])
+		case "NONCE_CHANGE":
+			nonce, err := strconv.ParseUint(fields[4], 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid nonce %q: %w", fields[4], err)
+			}
+			events.nonces[addr] = nonce
+		case "STORAGE_CHANGE":
+			if events.storage[addr] == nil {
+				events.storage[addr] = map[common.Hash]common.Hash{}
+			}
+			events.storage[addr][common.HexToHash(fields[3])] = common.HexToHash(fields[5])
+		case "CODE_CHANGE":
+			events.codes[addr] = strings.TrimPrefix(fields[6], ".")
+		case "SUICIDE_CHANGE":
+			events.suicided[addr] = fields[3] == "true"
+		}
+	}
+
+	return events, nil
+}
+
+func (e *firehoseStateEvents) uncoveredMutations(pre, post state.Dump) (uncovered []string) {
+	addresses := map[common.Address]bool{}
+	for addr := range pre.Accounts {
+		addresses[addr] = true
+	}
+	for addr := range post.Accounts {
+		addresses[addr] = true
+	}
+
+	for addr := range addresses {
+		preAccount, postAccount := pre.Accounts[addr], post.Accounts[addr]
+		if _, exists := post.Accounts[addr]; !exists && e.suicided[addr] {
+			// All the account's state is wiped at once by the self-destruct
+			continue
+		}
+
+		preBalance, postBalance := decimalToBig(preAccount.Balance), decimalToBig(postAccount.Balance)
+		if preBalance.Cmp(postBalance) != 0 {
+			if balance, found := e.balances[addr]; !found || balance.Cmp(postBalance) != 0 {
+				uncovered = append(uncovered, fmt.Sprintf("%s balance changed from %s to %s, last emitted %v", addr.Hex(), preBalance, postBalance, balance))
+			}
+		}
+
+		if preAccount.Nonce != postAccount.Nonce {
+			if nonce, found := e.nonces[addr]; !found || nonce != postAccount.Nonce {
+				uncovered = append(uncovered, fmt.Sprintf("%s nonce changed from %d to %d, last emitted %d", addr.Hex(), preAccount.Nonce, postAccount.Nonce, nonce))
+			}
+		}
+
+		if preAccount.Code != postAccount.Code {
+			if code, found := e.codes[addr]; !found || code != postAccount.Code {
+				uncovered = append(uncovered, fmt.Sprintf("%s code changed without a matching CODE_CHANGE", addr.Hex()))
+			}
+		}
+
+		keys := map[common.Hash]bool{}
+		for key := range preAccount.Storage {
+			keys[key] = true
+		}
+		for key := range postAccount.Storage {
+			keys[key] = true
+		}
+
+		for key := range keys {
+			preValue, postValue := common.HexToHash(preAccount.Storage[key]), common.HexToHash(postAccount.Storage[key])
+			if preValue == postValue {
+				continue
+			}
+
+			if value, found := e.storage[addr][key]; !found || value != postValue {
+				uncovered = append(uncovered, fmt.Sprintf("%s storage %s changed from %s to %s, last emitted %s", addr.Hex(), key.Hex(), preValue.Hex(), postValue.Hex(), value.Hex()))
+			}
+		}
+	}
+
+	return uncovered
+}
+
+func hexToBig(in string) *big.Int {
+	value, _ := new(big.Int).SetString(strings.TrimPrefix(in, "."), 16)
+	if value == nil {
+		return new(big.Int)
+	}
+	return value
+}
+
+func decimalToBig(in string) *big.Int {
+	value, _ := new(big.Int).SetString(in, 10)
+	if value == nil {
+		return new(big.Int)
+	}
+	return value
+}
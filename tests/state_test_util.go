@@ -151,20 +151,49 @@ func (t *StateTest) Run(subtest StateSubtest, vmconfig vm.Config) (*state.StateD
 	if err != nil {
 		return statedb, err
 	}
+	return statedb, t.verify(subtest, statedb, root)
+}
+
+// verify checks the post-state root and logs of a subtest against the expected ones.
+func (t *StateTest) verify(subtest StateSubtest, statedb *state.StateDB, root common.Hash) error {
 	post := t.json.Post[subtest.Fork][subtest.Index]
 	// N.B: We need to do this in a two-step process, because the first Commit takes care
 	// of suicides, and we need to touch the coinbase _after_ it has potentially suicided.
 	if root != common.Hash(post.Root) {
-		return statedb, fmt.Errorf("post state root mismatch: got %x, want %x", root, post.Root)
+		return fmt.Errorf("post state root mismatch: got %x, want %x", root, post.Root)
 	}
 	if logs := rlpHash(statedb.Logs()); logs != common.Hash(post.Logs) {
-		return statedb, fmt.Errorf("post state logs hash mismatch: got %x, want %x", logs, post.Logs)
+		return fmt.Errorf("post state logs hash mismatch: got %x, want %x", logs, post.Logs)
+	}
+	return nil
+}
+
+// RunWithFirehose is `Run` recording the subtest's transaction through `firehoseContext`, as
+// the single transaction of a block built from the test's environment.
+func (t *StateTest) RunWithFirehose(subtest StateSubtest, vmconfig vm.Config, firehoseContext *firehose.Context) (*state.StateDB, error) {
+	config, _, err := getVMConfig(subtest.Fork)
+	if err != nil {
+		return nil, UnsupportedForkError{subtest.Fork}
+	}
+	block := t.genesis(config).ToBlock(nil)
+
+	firehoseContext.StartBlock(block)
+	statedb, root, err := t.runNoVerify(subtest, vmconfig, firehoseContext)
+	firehoseContext.FinalizeBlock(block)
+	firehoseContext.EndBlock(block, block.Difficulty())
+	if err != nil {
+		return statedb, err
 	}
-	return statedb, nil
+
+	return statedb, t.verify(subtest, statedb, root)
 }
 
 // RunNoVerify runs a specific subtest and returns the statedb and post-state root
 func (t *StateTest) RunNoVerify(subtest StateSubtest, vmconfig vm.Config) (*state.StateDB, common.Hash, error) {
+	return t.runNoVerify(subtest, vmconfig, firehose.NoOpContext)
+}
+
+func (t *StateTest) runNoVerify(subtest StateSubtest, vmconfig vm.Config, firehoseContext *firehose.Context) (*state.StateDB, common.Hash, error) {
 	config, eips, err := getVMConfig(subtest.Fork)
 	if err != nil {
 		return nil, common.Hash{}, UnsupportedForkError{subtest.Fork}
@@ -180,14 +209,28 @@ func (t *StateTest) RunNoVerify(subtest StateSubtest, vmconfig vm.Config) (*stat
 	}
 	context := core.NewEVMContext(msg, block.Header(), nil, &t.json.Env.Coinbase)
 	context.GetHash = vmTestBlockHash
-	evm := vm.NewEVM(context, statedb, config, vmconfig, firehose.NoOpContext)
+	evm := vm.NewEVM(context, statedb, config, vmconfig, firehoseContext)
+
+	if firehoseContext.Enabled() {
+		firehoseContext.StartTransactionRaw(common.Hash{}, msg.To(), msg.Value(), nil, nil, nil, msg.Gas(), msg.GasPrice(), msg.Nonce(), msg.Data(), nil, nil, nil, 0, 0, firehose.GasPriceFeeKind(msg.GasPrice()))
+		firehoseContext.RecordTrxFrom(msg.From(), nil)
+	}
 
 	gaspool := new(core.GasPool)
 	gaspool.AddGas(block.GasLimit())
 	snapshot := statedb.Snapshot()
-	if _, _, _, err := core.ApplyMessage(evm, msg, gaspool); err != nil {
+	_, gasUsed, failed, err := core.ApplyMessage(evm, msg, gaspool)
+	if err != nil {
 		statedb.RevertToSnapshot(snapshot)
 	}
+
+	if firehoseContext.Enabled() {
+		receipt := &types.Receipt{Status: types.ReceiptStatusSuccessful, GasUsed: gasUsed, CumulativeGasUsed: gasUsed, Logs: statedb.GetLogs(common.Hash{})}
+		if failed || err != nil {
+			receipt.Status = types.ReceiptStatusFailed
+		}
+		firehoseContext.EndTransaction(receipt)
+	}
 	// Commit block
 	statedb.Commit(config.IsEIP158(block.Number()))
 	// Add 0-value mining reward. This only makes a difference in the cases
@@ -89,6 +89,9 @@ type Database struct {
 	flushnodes uint64             // Nodes flushed since last commit
 	flushsize  common.StorageSize // Data storage flushed since last commit
 
+	persistednodes uint64             // Nodes persisted since the database creation (never reset)
+	persistedsize  common.StorageSize // Data storage persisted since the database creation (never reset)
+
 	dirtiesSize   common.StorageSize // Storage size of the dirty node cache (exc. metadata)
 	childrenSize  common.StorageSize // Storage size of the external children tracking
 	preimagesSize common.StorageSize // Storage size of the preimages cache
@@ -668,6 +671,9 @@ func (db *Database) Cap(limit common.StorageSize) error {
 	db.flushsize += storage - db.dirtiesSize
 	db.flushtime += time.Since(start)
 
+	db.persistednodes += uint64(nodes - len(db.dirties))
+	db.persistedsize += storage - db.dirtiesSize
+
 	memcacheFlushTimeTimer.Update(time.Since(start))
 	memcacheFlushSizeMeter.Mark(int64(storage - db.dirtiesSize))
 	memcacheFlushNodesMeter.Mark(int64(nodes - len(db.dirties)))
@@ -737,6 +743,9 @@ func (db *Database) Commit(node common.Hash, report bool) error {
 	db.preimages = make(map[common.Hash][]byte)
 	db.preimagesSize = 0
 
+	db.persistednodes += uint64(nodes - len(db.dirties))
+	db.persistedsize += storage - db.dirtiesSize
+
 	memcacheCommitTimeTimer.Update(time.Since(start))
 	memcacheCommitSizeMeter.Mark(int64(storage - db.dirtiesSize))
 	memcacheCommitNodesMeter.Mark(int64(nodes - len(db.dirties)))
@@ -836,6 +845,15 @@ func (c *cleaner) Delete(key []byte) error {
 	panic("not implemented")
 }
 
+// Persisted returns the total amount of nodes and their storage size persisted
+// from the memory cache to the persistent database layer since its creation.
+func (db *Database) Persisted() (uint64, common.StorageSize) {
+	db.lock.RLock()
+	defer db.lock.RUnlock()
+
+	return db.persistednodes, db.persistedsize
+}
+
 // Size returns the current storage size of the memory cache in front of the
 // persistent database layer.
 func (db *Database) Size() (common.StorageSize, common.StorageSize) {